@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package alloydbapi
+package alloydbadmin
 
 import (
 	"bytes"
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 
 	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
@@ -31,7 +32,12 @@ import (
 type ConnectionInfoResponse struct {
 	ServerResponse googleapi.ServerResponse
 	IPAddress      string `json:"ipAddress"`
-	InstanceUID    string `json:"instanceUid"`
+	// PublicIPAddress is set when the instance has public IP enabled.
+	PublicIPAddress string `json:"publicIpAddress"`
+	// PSCDNSName is set when the instance has Private Service Connect
+	// enabled.
+	PSCDNSName  string `json:"pscDnsName"`
+	InstanceUID string `json:"instanceUid"`
 }
 
 // GenerateClientCertificateRequest is the request to generate a client
@@ -49,8 +55,46 @@ type GenerateClientCertificateResponse struct {
 	PemCertificateChain []string `json:"pemCertificateChain"`
 }
 
+// ClusterResponse is the response from the cluster endpoint.
+type ClusterResponse struct {
+	ServerResponse googleapi.ServerResponse
+	Name           string `json:"name"`
+	// ClusterType is either PRIMARY or SECONDARY.
+	ClusterType string `json:"clusterType"`
+	// DatabaseVersion is the major version of the cluster's database
+	// engine, e.g., POSTGRES_15.
+	DatabaseVersion string `json:"databaseVersion"`
+}
+
+// Instance describes an instance in the response from the instance list
+// endpoint.
+type Instance struct {
+	// Name is the instance URI.
+	Name string `json:"name"`
+	// InstanceType is PRIMARY, READ_POOL, or SECONDARY.
+	InstanceType string `json:"instanceType"`
+	// State is the current serving state of the instance, e.g., READY.
+	State string `json:"state"`
+}
+
+// ListInstancesResponse is the response from the instance list endpoint.
+type ListInstancesResponse struct {
+	ServerResponse googleapi.ServerResponse
+	Instances      []Instance `json:"instances"`
+	NextPageToken  string     `json:"nextPageToken"`
+}
+
+// DefaultUniverseDomain is the universe domain of the public Google Cloud.
+const DefaultUniverseDomain = "googleapis.com"
+
 // baseURL is the production API endpoint of the AlloyDB Admin API
-const baseURL = "https://alloydb.googleapis.com/v1beta"
+var baseURL = UniverseEndpoint(DefaultUniverseDomain)
+
+// UniverseEndpoint returns the AlloyDB Admin API endpoint within the provided
+// universe domain (e.g., googleapis.com).
+func UniverseEndpoint(domain string) string {
+	return "https://alloydb." + domain + "/v1beta"
+}
 
 // Client is an API client to the AlloyDB Rest API
 type Client struct {
@@ -86,35 +130,12 @@ func (c *Client) ConnectionInfo(ctx context.Context, project, region, cluster, i
 	if err != nil {
 		return ConnectionInfoResponse{}, err
 	}
-	res, err := c.client.Do(req)
+	var ret ConnectionInfoResponse
+	sr, err := c.do(req, &ret)
 	if err != nil {
 		return ConnectionInfoResponse{}, err
 	}
-	defer res.Body.Close()
-
-	// If the status code is 300 or greater, capture any information in the
-	// response and return it as part of the error.
-	if res.StatusCode >= http.StatusMultipleChoices {
-		body, err := io.ReadAll(res.Body)
-		if err != nil {
-			return ConnectionInfoResponse{}, err
-		}
-
-		return ConnectionInfoResponse{}, &googleapi.Error{
-			Code:   res.StatusCode,
-			Header: res.Header,
-			Body:   string(body),
-		}
-	}
-	ret := ConnectionInfoResponse{
-		ServerResponse: googleapi.ServerResponse{
-			Header:         res.Header,
-			HTTPStatusCode: res.StatusCode,
-		},
-	}
-	if err := json.NewDecoder(res.Body).Decode(&ret); err != nil {
-		return ConnectionInfoResponse{}, err
-	}
+	ret.ServerResponse = sr
 	return ret, nil
 }
 
@@ -135,33 +156,94 @@ func (c *Client) GenerateClientCert(ctx context.Context, project, region, cluste
 	if err != nil {
 		return GenerateClientCertificateResponse{}, err
 	}
-	res, err := c.client.Do(req.WithContext(ctx))
+	var ret GenerateClientCertificateResponse
+	sr, err := c.do(req, &ret)
 	if err != nil {
 		return GenerateClientCertificateResponse{}, err
 	}
+	ret.ServerResponse = sr
+	return ret, nil
+}
+
+// Cluster retrieves the provided cluster.
+func (c *Client) Cluster(ctx context.Context, project, region, cluster string) (ClusterResponse, error) {
+	u := fmt.Sprintf(
+		"%s/projects/%s/locations/%s/clusters/%s",
+		c.endpoint, project, region, cluster,
+	)
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return ClusterResponse{}, err
+	}
+	var ret ClusterResponse
+	sr, err := c.do(req, &ret)
+	if err != nil {
+		return ClusterResponse{}, err
+	}
+	ret.ServerResponse = sr
+	return ret, nil
+}
+
+// ListInstances retrieves all instances of the provided cluster.
+func (c *Client) ListInstances(ctx context.Context, project, region, cluster string) ([]Instance, error) {
+	var insts []Instance
+	pageToken := ""
+	for {
+		u := fmt.Sprintf(
+			"%s/projects/%s/locations/%s/clusters/%s/instances",
+			c.endpoint, project, region, cluster,
+		)
+		if pageToken != "" {
+			u += "?pageToken=" + url.QueryEscape(pageToken)
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		var ret ListInstancesResponse
+		if _, err := c.do(req, &ret); err != nil {
+			return nil, err
+		}
+		insts = append(insts, ret.Instances...)
+		if ret.NextPageToken == "" {
+			return insts, nil
+		}
+		pageToken = ret.NextPageToken
+	}
+}
+
+// do sends the request and decodes the JSON response body into v.
+func (c *Client) do(req *http.Request, v interface{}) (googleapi.ServerResponse, error) {
+	for k, vs := range RequestHeaders(req.Context()) {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return googleapi.ServerResponse{}, err
+	}
 	defer res.Body.Close()
+
 	// If the status code is 300 or greater, capture any information in the
 	// response and return it as part of the error.
 	if res.StatusCode >= http.StatusMultipleChoices {
 		body, err := io.ReadAll(res.Body)
 		if err != nil {
-			return GenerateClientCertificateResponse{}, err
+			return googleapi.ServerResponse{}, err
 		}
 
-		return GenerateClientCertificateResponse{}, &googleapi.Error{
+		return googleapi.ServerResponse{}, &googleapi.Error{
 			Code:   res.StatusCode,
 			Header: res.Header,
 			Body:   string(body),
 		}
 	}
-	ret := GenerateClientCertificateResponse{
-		ServerResponse: googleapi.ServerResponse{
-			Header:         res.Header,
-			HTTPStatusCode: res.StatusCode,
-		},
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return googleapi.ServerResponse{}, err
 	}
-	if err := json.NewDecoder(res.Body).Decode(&ret); err != nil {
-		return GenerateClientCertificateResponse{}, err
-	}
-	return ret, nil
+	return googleapi.ServerResponse{
+		Header:         res.Header,
+		HTTPStatusCode: res.StatusCode,
+	}, nil
 }
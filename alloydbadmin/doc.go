@@ -0,0 +1,30 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package alloydbadmin provides a client for the subset of the AlloyDB Admin
+// API used by the connector: retrieving an instance's connection info,
+// generating client certificates, and describing clusters and their
+// instances. It is intended for tooling that needs to mint client
+// certificates or inspect connection info directly.
+//
+// Client methods make a single request. Wrap calls with Retry to retry
+// transient failures the way the connector does:
+//
+//	var info alloydbadmin.ConnectionInfoResponse
+//	err := alloydbadmin.Retry(ctx, func(ctx context.Context) error {
+//		var err error
+//		info, err = client.ConnectionInfo(ctx, project, region, cluster, instance)
+//		return err
+//	})
+package alloydbadmin
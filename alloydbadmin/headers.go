@@ -0,0 +1,46 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbadmin
+
+import (
+	"context"
+	"net/http"
+)
+
+type headersKey struct{}
+
+// WithRequestHeaders returns a copy of ctx that adds h to the headers of each
+// request a Client sends with it, e.g., X-Goog-Request-Reason for audit logs.
+// Headers already attached to ctx are kept. Headers set by the transport,
+// such as Authorization, cannot be replaced.
+func WithRequestHeaders(ctx context.Context, h http.Header) context.Context {
+	merged := RequestHeaders(ctx).Clone()
+	if merged == nil {
+		merged = make(http.Header, len(h))
+	}
+	for k, vs := range h {
+		for _, v := range vs {
+			merged.Add(k, v)
+		}
+	}
+	return context.WithValue(ctx, headersKey{}, merged)
+}
+
+// RequestHeaders returns the headers attached to ctx with WithRequestHeaders,
+// or nil if there are none. The result must not be modified.
+func RequestHeaders(ctx context.Context) http.Header {
+	h, _ := ctx.Value(headersKey{}).(http.Header)
+	return h
+}
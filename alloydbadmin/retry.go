@@ -0,0 +1,102 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbadmin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// retryAttempts is the maximum number of times Retry calls its
+	// function.
+	retryAttempts = 5
+	// retryBaseDelay is the upper bound of the delay before the first retry.
+	// The bound doubles with each subsequent retry.
+	retryBaseDelay = 200 * time.Millisecond
+	// retryMaxDelay caps the delay between attempts.
+	retryMaxDelay = 5 * time.Second
+)
+
+// Retry calls f, typically wrapping a single Client method, until it
+// succeeds, fails with an error that IsRetryable reports as permanent, five
+// attempts have been made, or ctx is done. Between attempts, Retry waits a
+// random duration up to an exponentially increasing bound, so that many
+// clients retrying at once are spread out. Retry returns the error of the
+// last attempt.
+func Retry(ctx context.Context, f func(context.Context) error) error {
+	for n := 0; ; n++ {
+		err := f(ctx)
+		if err == nil || n == retryAttempts-1 || !IsRetryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retryDelay(n)):
+		}
+	}
+}
+
+// retryDelay returns the delay before retry number n, counting from zero.
+func retryDelay(n int) time.Duration {
+	bound := retryMaxDelay
+	if n < 16 && retryBaseDelay<<n < retryMaxDelay {
+		bound = retryBaseDelay << n
+	}
+	return time.Duration(jitter.int63n(int64(bound)))
+}
+
+// jitter is seeded per process so that retries differ across clients.
+var jitter = &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// lockedRand is a *rand.Rand that is safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) int63n(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63n(n)
+}
+
+// IsRetryable reports whether err is likely transient: a rate limit or server
+// error response from the Admin API, or a dropped connection.
+func IsRetryable(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+}
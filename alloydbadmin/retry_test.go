@@ -0,0 +1,78 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tcs := []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{desc: "rate limited", err: &googleapi.Error{Code: http.StatusTooManyRequests}, want: true},
+		{desc: "unavailable", err: &googleapi.Error{Code: http.StatusServiceUnavailable}, want: true},
+		{desc: "permission denied", err: &googleapi.Error{Code: http.StatusForbidden}, want: false},
+		{desc: "not implemented", err: &googleapi.Error{Code: http.StatusNotImplemented}, want: false},
+		{desc: "connection reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{desc: "other error", err: errors.New("boom"), want: false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := IsRetryable(tc.err); got != tc.want {
+				t.Fatalf("IsRetryable(%v): want = %v, got = %v", tc.err, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for n := 0; n < 20; n++ {
+		if d := retryDelay(n); d < 0 || d > retryMaxDelay {
+			t.Fatalf("retryDelay(%d) = %v, want between 0 and %v", n, d, retryMaxDelay)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	unavailable := &googleapi.Error{Code: http.StatusServiceUnavailable}
+	var calls int
+	err := Retry(context.Background(), func(context.Context) error {
+		calls++
+		return unavailable
+	})
+	if !errors.Is(err, unavailable) || calls != retryAttempts {
+		t.Fatalf("want %d attempts ending in %v, got %d attempts, err = %v", retryAttempts, unavailable, calls, err)
+	}
+
+	calls = 0
+	forbidden := &googleapi.Error{Code: http.StatusForbidden}
+	err = Retry(context.Background(), func(context.Context) error {
+		calls++
+		return forbidden
+	})
+	if !errors.Is(err, forbidden) || calls != 1 {
+		t.Fatalf("want 1 attempt ending in %v, got %d attempts, err = %v", forbidden, calls, err)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbadmin
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StaticInstanceInfo is the static connection info of a single instance.
+type StaticInstanceInfo struct {
+	IPAddress       string `json:"ipAddress"`
+	PublicIPAddress string `json:"publicIpAddress,omitempty"`
+	PSCDNSName      string `json:"pscDnsName,omitempty"`
+	InstanceUID     string `json:"instanceUid"`
+	// PemCertificateChain holds the client certificate, the intermediate
+	// certificate that signed it, and the root CA certificate, in that order.
+	PemCertificateChain []string `json:"pemCertificateChain"`
+}
+
+// StaticClient serves connection info from a static document in place of the
+// AlloyDB Admin API. The document is a JSON object with a "privateKey" field
+// holding the PEM encoded RSA key of the client certificates, and one field
+// per instance URI holding a StaticInstanceInfo:
+//
+//	{
+//	  "privateKey": "<PEM encoded RSA private key>",
+//	  "projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>": {
+//	    "ipAddress": "<private IP address>",
+//	    "publicIpAddress": "<public IP address>",
+//	    "pscDnsName": "<PSC DNS name>",
+//	    "instanceUid": "<instance UID>",
+//	    "pemCertificateChain": ["<client cert>", "<intermediate cert>", "<root CA cert>"]
+//	  }
+//	}
+type StaticClient struct {
+	key       *rsa.PrivateKey
+	instances map[string]StaticInstanceInfo
+}
+
+// NewStaticClient reads a static connection info document from r.
+func NewStaticClient(r io.Reader) (*StaticClient, error) {
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode static connection info: %v", err)
+	}
+	rawKey, ok := doc["privateKey"]
+	if !ok {
+		return nil, errors.New("static connection info is missing privateKey")
+	}
+	var keyPEM string
+	if err := json.Unmarshal(rawKey, &keyPEM); err != nil {
+		return nil, fmt.Errorf("failed to decode privateKey: %v", err)
+	}
+	key, err := parseRSAKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	s := &StaticClient{key: key, instances: make(map[string]StaticInstanceInfo)}
+	for k, v := range doc {
+		if k == "privateKey" {
+			continue
+		}
+		var info StaticInstanceInfo
+		if err := json.Unmarshal(v, &info); err != nil {
+			return nil, fmt.Errorf("failed to decode static connection info for %v: %v", k, err)
+		}
+		if len(info.PemCertificateChain) < 2 {
+			return nil, fmt.Errorf(
+				"static connection info for %v: want at least 2 certificates in pemCertificateChain, got %d",
+				k, len(info.PemCertificateChain),
+			)
+		}
+		s.instances[strings.TrimPrefix(k, "/")] = info
+	}
+	return s, nil
+}
+
+func parseRSAKey(keyPEM string) (*rsa.PrivateKey, error) {
+	b, _ := pem.Decode([]byte(keyPEM))
+	if b == nil {
+		return nil, errors.New("privateKey is not a valid PEM")
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(b.Bytes); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(b.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse privateKey: %v", err)
+	}
+	rk, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("privateKey is not an RSA key")
+	}
+	return rk, nil
+}
+
+// PrivateKey returns the key of the client certificates.
+func (s *StaticClient) PrivateKey() *rsa.PrivateKey {
+	return s.key
+}
+
+func (s *StaticClient) instance(project, region, cluster, instance string) (StaticInstanceInfo, error) {
+	uri := fmt.Sprintf(
+		"projects/%s/locations/%s/clusters/%s/instances/%s",
+		project, region, cluster, instance,
+	)
+	info, ok := s.instances[uri]
+	if !ok {
+		return StaticInstanceInfo{}, fmt.Errorf("static connection info has no entry for %v", uri)
+	}
+	return info, nil
+}
+
+// ConnectionInfo returns the static connection info of the instance.
+func (s *StaticClient) ConnectionInfo(_ context.Context, project, region, cluster, instance string) (ConnectionInfoResponse, error) {
+	info, err := s.instance(project, region, cluster, instance)
+	if err != nil {
+		return ConnectionInfoResponse{}, err
+	}
+	return ConnectionInfoResponse{
+		IPAddress:       info.IPAddress,
+		PublicIPAddress: info.PublicIPAddress,
+		PSCDNSName:      info.PSCDNSName,
+		InstanceUID:     info.InstanceUID,
+	}, nil
+}
+
+// GenerateClientCert returns the static certificate chain of any instance in
+// the cluster. The CSR is ignored, as the certificates are issued for the
+// document's private key.
+func (s *StaticClient) GenerateClientCert(_ context.Context, project, region, cluster string, _ []byte) (GenerateClientCertificateResponse, error) {
+	prefix := fmt.Sprintf("projects/%s/locations/%s/clusters/%s/", project, region, cluster)
+	for uri, info := range s.instances {
+		if strings.HasPrefix(uri, prefix) {
+			return GenerateClientCertificateResponse{
+				PemCertificate:      info.PemCertificateChain[0],
+				PemCertificateChain: info.PemCertificateChain[1:],
+			}, nil
+		}
+	}
+	return GenerateClientCertificateResponse{}, fmt.Errorf(
+		"static connection info has no entry for cluster %v", strings.TrimSuffix(prefix, "/"),
+	)
+}
+
+// Cluster reports an error, as static connection info does not include the
+// cluster's role.
+func (s *StaticClient) Cluster(_ context.Context, project, region, cluster string) (ClusterResponse, error) {
+	return ClusterResponse{}, fmt.Errorf(
+		"static connection info does not include cluster details for projects/%s/locations/%s/clusters/%s",
+		project, region, cluster,
+	)
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package alloydbconntest provides utilities for testing code that uses the
+// alloydbconn package without Google Cloud resources. Dialer is a fake
+// alloydbconn.Connector that needs no network connection or TLS, while Server
+// emulates an AlloyDB instance so that a real alloydbconn.Dialer can be
+// exercised end to end.
+package alloydbconntest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"cloud.google.com/go/alloydbconn"
+)
+
+// ErrClosed is returned by Dial after the Dialer has been closed.
+var ErrClosed = errors.New("alloydbconntest: dialer is closed")
+
+// Response is a scripted result of a single call to Dial.
+type Response struct {
+	// Conn is returned when Err is nil. If Conn is nil, Dial returns one end
+	// of a net.Pipe and passes the other end to the Dialer's handler.
+	Conn net.Conn
+	// Err is returned by Dial when set.
+	Err error
+}
+
+// Call records a single call to Dial.
+type Call struct {
+	// Instance is the instance URI passed to Dial.
+	Instance string
+	// Err is the error returned by Dial, if any.
+	Err error
+}
+
+// Option configures a Dialer.
+type Option func(d *Dialer)
+
+// WithResponses returns an Option that scripts the results of calls to Dial
+// for the provided instance. Responses are used in order, one per call. Once
+// all responses have been used, Dial falls back to the Dialer's handler.
+func WithResponses(instance string, rs ...Response) Option {
+	return func(d *Dialer) {
+		d.responses[instance] = append(d.responses[instance], rs...)
+	}
+}
+
+// WithDialError returns an Option that makes every unscripted call to Dial for
+// the provided instance fail with err.
+func WithDialError(instance string, err error) Option {
+	return func(d *Dialer) {
+		d.errs[instance] = err
+	}
+}
+
+// WithHandler returns an Option that sets the function that serves
+// connections returned by Dial. Each call to Dial creates a net.Pipe, returns
+// one end to the caller, and invokes h with the other end in a new goroutine.
+// The handler is responsible for closing its end of the pipe.
+func WithHandler(h func(instance string, conn net.Conn)) Option {
+	return func(d *Dialer) {
+		d.handler = h
+	}
+}
+
+// Dialer is a fake alloydbconn.Connector. By default, Dial succeeds for any
+// instance and returns a connection whose other end is immediately closed.
+type Dialer struct {
+	mu        sync.Mutex
+	responses map[string][]Response
+	errs      map[string]error
+	handler   func(instance string, conn net.Conn)
+	calls     []Call
+	closed    bool
+}
+
+var _ alloydbconn.Connector = (*Dialer)(nil)
+
+// NewDialer returns a fake Dialer configured with the provided options.
+func NewDialer(opts ...Option) *Dialer {
+	d := &Dialer{
+		responses: make(map[string][]Response),
+		errs:      make(map[string]error),
+		handler: func(_ string, conn net.Conn) {
+			conn.Close()
+		},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Dial returns the next scripted response for instance or, if none remain, a
+// connection served by the Dialer's handler. DialOptions are ignored.
+func (d *Dialer) Dial(ctx context.Context, instance string, _ ...alloydbconn.DialOption) (net.Conn, error) {
+	conn, err := d.dial(ctx, instance)
+	d.mu.Lock()
+	d.calls = append(d.calls, Call{Instance: instance, Err: err})
+	d.mu.Unlock()
+	return conn, err
+}
+
+func (d *Dialer) dial(ctx context.Context, instance string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil, ErrClosed
+	}
+	var r Response
+	if rs := d.responses[instance]; len(rs) > 0 {
+		r, d.responses[instance] = rs[0], rs[1:]
+	} else if err, ok := d.errs[instance]; ok {
+		r = Response{Err: err}
+	}
+	h := d.handler
+	d.mu.Unlock()
+
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if r.Conn != nil {
+		return r.Conn, nil
+	}
+	if h == nil {
+		return nil, fmt.Errorf("alloydbconntest: no response configured for %q", instance)
+	}
+	client, server := net.Pipe()
+	go h(instance, server)
+	return client, nil
+}
+
+// Calls returns every call to Dial in the order they were made.
+func (d *Dialer) Calls() []Call {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	calls := make([]Call, len(d.calls))
+	copy(calls, d.calls)
+	return calls
+}
+
+// Close marks the Dialer as closed. Subsequent calls to Dial return
+// ErrClosed.
+func (d *Dialer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	return nil
+}
+
+// Closed reports whether Close has been called.
+func (d *Dialer) Closed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconntest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+const testInstance = "projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+
+func TestDialerScriptedResponses(t *testing.T) {
+	ctx := context.Background()
+	wantErr := errors.New("dial failed")
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	d := NewDialer(WithResponses(testInstance,
+		Response{Err: wantErr},
+		Response{Conn: c1},
+	))
+
+	if _, err := d.Dial(ctx, testInstance); !errors.Is(err, wantErr) {
+		t.Fatalf("first dial: want = %v, got = %v", wantErr, err)
+	}
+	conn, err := d.Dial(ctx, testInstance)
+	if err != nil {
+		t.Fatalf("second dial: want no error, got = %v", err)
+	}
+	if conn != c1 {
+		t.Fatalf("second dial: want scripted conn, got = %v", conn)
+	}
+	conn.Close()
+
+	calls := d.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("want 2 calls, got = %v", len(calls))
+	}
+	if calls[0].Instance != testInstance || !errors.Is(calls[0].Err, wantErr) {
+		t.Errorf("first call: want (%v, %v), got = %+v", testInstance, wantErr, calls[0])
+	}
+	if calls[1].Err != nil {
+		t.Errorf("second call: want no error, got = %v", calls[1].Err)
+	}
+}
+
+func TestDialerHandler(t *testing.T) {
+	d := NewDialer(WithHandler(func(instance string, conn net.Conn) {
+		defer conn.Close()
+		conn.Write([]byte(instance))
+	}))
+
+	conn, err := d.Dial(context.Background(), testInstance)
+	if err != nil {
+		t.Fatalf("want no error, got = %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != testInstance {
+		t.Fatalf("want = %v, got = %v", testInstance, string(data))
+	}
+}
+
+func TestDialerErrors(t *testing.T) {
+	wantErr := errors.New("instance not found")
+	d := NewDialer(WithDialError(testInstance, wantErr))
+
+	ctx := context.Background()
+	if _, err := d.Dial(ctx, testInstance); !errors.Is(err, wantErr) {
+		t.Fatalf("want = %v, got = %v", wantErr, err)
+	}
+	conn, err := d.Dial(ctx, "projects/p/locations/r/clusters/c/instances/other")
+	if err != nil {
+		t.Fatalf("other instance: want no error, got = %v", err)
+	}
+	conn.Close()
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := d.Dial(cctx, testInstance); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled context: want = %v, got = %v", context.Canceled, err)
+	}
+
+	d.Close()
+	if !d.Closed() {
+		t.Fatal("want Closed to report true after Close")
+	}
+	if _, err := d.Dial(ctx, testInstance); !errors.Is(err, ErrClosed) {
+		t.Fatalf("after Close: want = %v, got = %v", ErrClosed, err)
+	}
+}
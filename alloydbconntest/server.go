@@ -0,0 +1,158 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconntest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/alloydbconn"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+)
+
+// ServerOption configures a Server.
+type ServerOption func(s *Server)
+
+// WithConnHandler returns a ServerOption that sets the function that serves
+// each connection accepted by the emulated instance. The connection has
+// completed its TLS handshake with the client. The Server closes the
+// connection once h returns. By default, the Server writes the instance name
+// to each connection and closes it.
+func WithConnHandler(h func(conn net.Conn)) ServerOption {
+	return func(s *Server) {
+		s.handler = h
+	}
+}
+
+// WithCertExpiry returns a ServerOption that makes every client certificate
+// the Server issues expire at t rather than a day after the Server started,
+// e.g., to test how code reacts to certificate expiration with
+// alloydbconn.WithConnExpiryHandler.
+func WithCertExpiry(t time.Time) ServerOption {
+	return func(s *Server) {
+		s.instOpts = append(s.instOpts, mock.WithCertExpiry(t))
+	}
+}
+
+// Server emulates the AlloyDB Admin API and the server-side proxy of a single
+// AlloyDB instance in-process. A Dialer configured with the Server's
+// DialerOptions performs the full Dial path, including certificate
+// retrieval and mutual TLS, without any Google Cloud resources.
+//
+// Use NewServer to initialize a Server.
+type Server struct {
+	project, region, cluster, instance string
+
+	client  *http.Client
+	url     string
+	cleanup func() error
+
+	ln       net.Listener
+	handler  func(conn net.Conn)
+	instOpts []mock.Option
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a Server emulating the named instance. Callers should
+// invoke Close once the Server is no longer needed.
+func NewServer(project, region, cluster, instance string, opts ...ServerOption) (*Server, error) {
+	s := &Server{
+		project:  project,
+		region:   region,
+		cluster:  cluster,
+		instance: instance,
+		handler: func(conn net.Conn) {
+			conn.Write([]byte(instance))
+		},
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	inst := mock.NewFakeInstance(project, region, cluster, instance, s.instOpts...)
+	ln, err := mock.ListenServerProxy(inst)
+	if err != nil {
+		return nil, fmt.Errorf("alloydbconntest: failed to start server proxy: %v", err)
+	}
+	s.ln = ln
+	s.client, s.url, s.cleanup = mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, mock.Unlimited),
+		mock.CreateEphemeralSuccess(inst, mock.Unlimited),
+		mock.ClusterGetSuccess(inst, mock.Unlimited),
+	)
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handler(conn)
+		}()
+	}
+}
+
+// InstanceURI returns the URI of the emulated instance for use with Dial.
+func (s *Server) InstanceURI() string {
+	return fmt.Sprintf(
+		"projects/%s/locations/%s/clusters/%s/instances/%s",
+		s.project, s.region, s.cluster, s.instance,
+	)
+}
+
+// DialerOptions returns the options that configure an alloydbconn.Dialer to
+// use the Server for both the Admin API and connections to the instance.
+func (s *Server) DialerOptions() []alloydbconn.Option {
+	addr := s.ln.Addr().String()
+	var d net.Dialer
+	return []alloydbconn.Option{
+		alloydbconn.WithHTTPClient(s.client),
+		alloydbconn.WithAdminAPIEndpoint(s.url),
+		alloydbconn.WithDialFunc(func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, network, addr)
+		}),
+	}
+}
+
+// NewDialer returns an alloydbconn.Dialer configured with the Server's
+// DialerOptions followed by opts. Callers should close the Dialer before the
+// Server.
+func (s *Server) NewDialer(ctx context.Context, opts ...alloydbconn.Option) (*alloydbconn.Dialer, error) {
+	return alloydbconn.NewDialer(ctx, append(s.DialerOptions(), opts...)...)
+}
+
+// Close stops the Server and waits for all connection handlers to return.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	// Unlimited requests never report missing calls, so cleanup only stops
+	// the Admin API server.
+	if cerr := s.cleanup(); err == nil {
+		err = cerr
+	}
+	return err
+}
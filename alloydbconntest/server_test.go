@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconntest
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn"
+)
+
+func TestServer(t *testing.T) {
+	s, err := NewServer("my-project", "my-region", "my-cluster", "my-instance",
+		WithConnHandler(func(conn net.Conn) {
+			io.Copy(conn, conn)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewServer to succeed, got error: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	d, err := alloydbconn.NewDialer(ctx, s.DialerOptions()...)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, got error: %v", err)
+	}
+	defer d.Close()
+
+	for n := 0; n < 2; n++ {
+		conn, err := d.Dial(ctx, s.InstanceURI())
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, got error: %v", err)
+		}
+		want := "hello"
+		if _, err := conn.Write([]byte(want)); err != nil {
+			t.Fatalf("expected Write to succeed, got error: %v", err)
+		}
+		got := make([]byte, len(want))
+		if _, err := io.ReadFull(conn, got); err != nil {
+			t.Fatalf("expected Read to succeed, got error: %v", err)
+		}
+		if string(got) != want {
+			t.Fatalf("want = %q, got = %q", want, got)
+		}
+		conn.Close()
+	}
+}
+
+func TestServerNewDialer(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	s, err := NewServer("my-project", "my-region", "my-cluster", "my-instance",
+		WithCertExpiry(expiry),
+	)
+	if err != nil {
+		t.Fatalf("expected NewServer to succeed, got error: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	d, err := s.NewDialer(ctx, alloydbconn.WithRefreshTimeout(10*time.Second))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, got error: %v", err)
+	}
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, s.InstanceURI())
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, got error: %v", err)
+	}
+	conn.Close()
+	info, err := d.ConnectionInfo(s.InstanceURI())
+	if err != nil {
+		t.Fatalf("expected ConnectionInfo to succeed, got error: %v", err)
+	}
+	if !info.CertExpiry.Equal(expiry) {
+		t.Fatalf("want expiry = %v, got = %v", expiry, info.CertExpiry)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+)
+
+// ConnectionInfoCache supplies the information used to connect to instances
+// in place of the Dialer's own calls to the AlloyDB Admin API. Implementations
+// may share connection info among many Dialers, e.g., through an external
+// store, to reduce calls to the Admin API. Because client certificates are
+// issued for a particular key, every Dialer sharing connection info must be
+// configured with the same key using WithRSAKey.
+//
+// An implementation will typically populate itself using
+// Dialer.FetchConnectionInfo. Implementations must be safe for concurrent
+// use.
+type ConnectionInfoCache interface {
+	// ConnectionInfo returns the information used to connect to the
+	// instance. The Dialer calls ConnectionInfo when it first connects to an
+	// instance and again as the returned information nears expiration.
+	ConnectionInfo(ctx context.Context, instance string) (ConnectionInfo, error)
+	// ForceRefresh reports that the most recent information for the instance
+	// is unusable, e.g., because a connection attempt with it failed.
+	ForceRefresh(instance string)
+	// Close is called when the Dialer is closed.
+	Close() error
+}
+
+// WithConnectionInfoCache returns an Option that configures the Dialer to
+// retrieve connection info from c. The Dialer still schedules refresh
+// operations as usual, but each one calls c rather than the AlloyDB Admin API.
+func WithConnectionInfoCache(c ConnectionInfoCache) Option {
+	return func(d *dialerConfig) {
+		d.cache = c
+	}
+}
+
+// cacheSource adapts a ConnectionInfoCache to a single instance's source of
+// connection info.
+type cacheSource struct {
+	c        ConnectionInfoCache
+	instance string
+}
+
+func (s cacheSource) ConnectInfo(ctx context.Context) (alloydb.ConnectInfoResult, error) {
+	info, err := s.c.ConnectionInfo(ctx, s.instance)
+	if err != nil {
+		return alloydb.ConnectInfoResult{}, err
+	}
+	var eps []alloydb.Endpoint
+	for _, a := range info.Addresses {
+		eps = append(eps, alloydb.Endpoint{IPType: a.Type, Addr: a.Addr})
+	}
+	// A cache that leaves Addresses empty supplies only the preferred
+	// address, which is used as the private IP address.
+	if len(eps) == 0 && info.IPAddress != "" {
+		eps = append(eps, alloydb.Endpoint{IPType: alloydb.PrivateIP, Addr: info.IPAddress})
+	}
+	return alloydb.ConnectInfoResult{
+		Endpoints: eps,
+		TLSConfig: info.TLSConfig,
+		Expiry:    info.Expiry,
+	}, nil
+}
+
+func (s cacheSource) ForceRefresh() {
+	s.c.ForceRefresh(s.instance)
+}
+
+// cacheExportVersion is the version of the format written by ExportCache.
+const cacheExportVersion = 1
+
+// exportedCache is the format written by ExportCache.
+type exportedCache struct {
+	Version   int
+	Instances []alloydb.Snapshot
+}
+
+// ExportCache returns the Dialer's current connection info for each instance,
+// including client certificates and their expiry, as an opaque blob. Pass the
+// blob to WithImportedCache to start another Dialer, e.g., in a new release
+// or a forked worker process, without calling the AlloyDB Admin API for those
+// instances.
+//
+// The blob includes the private key the client certificates were issued for
+// and must be protected like any other credential. Connection info retrieved
+// through a ConnectionInfoCache or issued for a key configured with
+// WithClientSigner is not exported.
+func (d *Dialer) ExportCache() ([]byte, error) {
+	insts := d.cachedInstances()
+	ex := exportedCache{Version: cacheExportVersion}
+	for _, i := range insts {
+		if s, ok := i.Snapshot(); ok {
+			ex.Instances = append(ex.Instances, s)
+		}
+	}
+	return json.Marshal(ex)
+}
+
+// WithImportedCache returns an Option that starts the Dialer with the
+// connection info in data, a blob returned by ExportCache. Imported
+// connection info is used only until its client certificate expires; the
+// Dialer then refreshes it as usual, using its own key. Expired or invalid
+// entries are ignored, so the Dialer retrieves connection info for those
+// instances from the AlloyDB Admin API on first use.
+func WithImportedCache(data []byte) Option {
+	return func(d *dialerConfig) {
+		var ex exportedCache
+		if err := json.Unmarshal(data, &ex); err != nil {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("failed to parse imported cache: %v", err), "n/a",
+			)
+			return
+		}
+		if ex.Version != cacheExportVersion {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("unsupported imported cache version %v", ex.Version), "n/a",
+			)
+			return
+		}
+		now := time.Now()
+		d.imported = make(map[string]alloydb.Snapshot)
+		for _, s := range ex.Instances {
+			if now.Before(s.Expiry) {
+				d.imported[s.Instance] = s
+			}
+		}
+	}
+}
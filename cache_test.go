@@ -0,0 +1,378 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+// spyCache serves connection info fetched once by another Dialer.
+type spyCache struct {
+	mu     sync.Mutex
+	fetch  func(ctx context.Context, instance string) (ConnectionInfo, error)
+	info   map[string]ConnectionInfo
+	calls  int
+	forced int
+	closed bool
+}
+
+func (s *spyCache) ConnectionInfo(ctx context.Context, instance string) (ConnectionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if info, ok := s.info[instance]; ok {
+		return info, nil
+	}
+	info, err := s.fetch(ctx, instance)
+	if err != nil {
+		return ConnectionInfo{}, err
+	}
+	s.info[instance] = info
+	return info, nil
+}
+
+func (s *spyCache) ForceRefresh(instance string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forced++
+	delete(s.info, instance)
+}
+
+func (s *spyCache) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestDialerWithConnectionInfoCache(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// The Admin API is called only once, by the Dialer that populates the
+	// cache.
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	fetcher, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(key))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	fetcher.client = c
+	defer fetcher.Close()
+	cache := &spyCache{fetch: fetcher.FetchConnectionInfo, info: make(map[string]ConnectionInfo)}
+
+	uri := "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+	for n := 0; n < 2; n++ {
+		d, err := NewDialer(ctx,
+			WithTokenSource(stubTokenSource{}),
+			WithRSAKey(key),
+			WithConnectionInfoCache(cache),
+		)
+		if err != nil {
+			t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+		}
+		conn, err := d.Dial(ctx, uri)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		conn.Close()
+		d.Close()
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.calls != 2 {
+		t.Fatalf("want 2 calls to the cache, got = %v", cache.calls)
+	}
+	if !cache.closed {
+		t.Fatal("want cache to be closed with the Dialer")
+	}
+}
+
+func TestCacheSourceAddresses(t *testing.T) {
+	tcs := []struct {
+		desc string
+		info ConnectionInfo
+		want []alloydb.Endpoint
+	}{
+		{
+			desc: "addresses with types",
+			info: ConnectionInfo{
+				IPAddress: "10.0.0.1",
+				Addresses: []InstanceAddress{
+					{Type: alloydb.PrivateIP, Addr: "10.0.0.1"},
+					{Type: alloydb.PublicIP, Addr: "34.0.0.1"},
+				},
+			},
+			want: []alloydb.Endpoint{
+				{IPType: alloydb.PrivateIP, Addr: "10.0.0.1"},
+				{IPType: alloydb.PublicIP, Addr: "34.0.0.1"},
+			},
+		},
+		{
+			desc: "preferred address only",
+			info: ConnectionInfo{IPAddress: "10.0.0.1"},
+			want: []alloydb.Endpoint{
+				{IPType: alloydb.PrivateIP, Addr: "10.0.0.1"},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := &spyCache{
+				info: map[string]ConnectionInfo{testInstanceURI: tc.info},
+			}
+			res, err := cacheSource{c: c, instance: testInstanceURI}.ConnectInfo(context.Background())
+			if err != nil {
+				t.Fatalf("want no error, got = %v", err)
+			}
+			if !reflect.DeepEqual(res.Endpoints, tc.want) {
+				t.Fatalf("want = %v, got = %v", tc.want, res.Endpoints)
+			}
+		})
+	}
+}
+
+func TestDialerExportImportCache(t *testing.T) {
+	ctx := context.Background()
+	// The client certificate chain may have any number of intermediate CAs.
+	for _, n := range []int{0, 1, 3} {
+		t.Run(fmt.Sprintf("%d intermediate CAs", n), func(t *testing.T) {
+			inst := mock.NewFakeInstance(
+				"my-project", "my-region", "my-cluster", "my-instance",
+				mock.WithIntermediateCAs(n),
+			)
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				mock.CreateEphemeralSuccess(inst, 1),
+			)
+			stop := mock.StartServerProxy(t, inst)
+			defer func() {
+				stop()
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
+
+			conn, err := d.Dial(ctx, testInstanceURI)
+			if err != nil {
+				t.Fatalf("expected Dial to succeed, but got error: %v", err)
+			}
+			conn.Close()
+			data, err := d.ExportCache()
+			if err != nil {
+				t.Fatalf("expected ExportCache to succeed, but got error: %v", err)
+			}
+
+			// The importing Dialer has a different key and an Admin API that
+			// rejects every request, so it can only connect with the imported info.
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mc2, url2, cleanup2 := mock.HTTPClient()
+			defer func() {
+				if err := cleanup2(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			c2, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc2), option.WithEndpoint(url2))
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			d2, err := NewDialer(ctx,
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(key),
+				WithImportedCache(data),
+			)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d2.client = c2
+			defer d2.Close()
+
+			conn, err = d2.Dial(ctx, testInstanceURI)
+			if err != nil {
+				t.Fatalf("expected Dial with imported cache to succeed, but got error: %v", err)
+			}
+			conn.Close()
+		})
+	}
+}
+
+func TestWithImportedCacheErrors(t *testing.T) {
+	tcs := []struct {
+		desc string
+		data string
+	}{
+		{desc: "malformed data", data: "not-json"},
+		{desc: "unsupported version", data: `{"Version": 99}`},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := NewDialer(context.Background(),
+				WithTokenSource(stubTokenSource{}),
+				WithImportedCache([]byte(tc.data)),
+			)
+			var cErr *errtype.ConfigError
+			if !errors.As(err, &cErr) {
+				t.Fatalf("want = %T, got = %v", cErr, err)
+			}
+		})
+	}
+}
+
+func TestDialerWithStaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	var fetched bool
+	c := &spyCache{
+		info: make(map[string]ConnectionInfo),
+		// The first refresh succeeds and later ones hang.
+		fetch: func(ctx context.Context, _ string) (ConnectionInfo, error) {
+			if fetched {
+				<-ctx.Done()
+				return ConnectionInfo{}, ctx.Err()
+			}
+			fetched = true
+			return ConnectionInfo{
+				IPAddress: "127.0.0.1",
+				Expiry:    time.Now().Add(time.Hour),
+				TLSConfig: &tls.Config{},
+			}, nil
+		},
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithConnectionInfoCache(c),
+		WithStaleWhileRevalidate(0),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	if err := d.Warmup(ctx, testInstanceURI); err != nil {
+		t.Fatalf("expected Warmup to succeed, but got error: %v", err)
+	}
+	if err := d.ForceRefresh(testInstanceURI); err != nil {
+		t.Fatalf("expected ForceRefresh to succeed, but got error: %v", err)
+	}
+	// The previous connection info is used rather than waiting on the
+	// forced refresh.
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := d.Warmup(ctx, testInstanceURI); err != nil {
+		t.Fatalf("expected Warmup to succeed with stale connection info, but got error: %v", err)
+	}
+
+	_, err = NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithStaleWhileRevalidate(-time.Second),
+	)
+	var cErr *errtype.ConfigError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+}
+
+func TestDialerWithRefreshCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	c := &spyCache{
+		info: make(map[string]ConnectionInfo),
+		fetch: func(context.Context, string) (ConnectionInfo, error) {
+			return ConnectionInfo{}, errtype.NewConfigError("instance deleted", testInstanceURI)
+		},
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithConnectionInfoCache(c),
+		WithRefreshCircuitBreaker(2, time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	var cErr *errtype.CircuitOpenError
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		_, err := d.Dial(ctx, testInstanceURI)
+		if errors.As(err, &cErr) {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("want = %T, got = %v", cErr, err)
+		}
+	}
+	s, err := d.InstanceStatus(testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected InstanceStatus to succeed, but got error: %v", err)
+	}
+	if !s.CircuitOpen {
+		t.Fatal("want CircuitOpen = true, got false")
+	}
+
+	_, err = NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRefreshCircuitBreaker(0, time.Hour),
+	)
+	var cfgErr *errtype.ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("want = %T, got = %v", cfgErr, err)
+	}
+}
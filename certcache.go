@@ -0,0 +1,124 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+	"cloud.google.com/go/alloydbconn/internal/logging"
+)
+
+// WithCertCacheDir returns an Option that persists each instance's client
+// certificate, private key, and CA certificates to a file in dir after every
+// refresh, and starts the Dialer with the still-valid contents of dir. A
+// process that restarts frequently, e.g., a batch job, then connects to an
+// instance without calling the AlloyDB Admin API until the cached certificate
+// nears expiration. dir is created if necessary.
+//
+// The files hold private keys and are readable only by their owner (mode
+// 0600); dir must be protected like any other credential. Connection info
+// retrieved through a ConnectionInfoCache or issued for a key configured with
+// WithClientSigner is not persisted. Connection info imported with
+// WithImportedCache takes precedence over dir.
+func WithCertCacheDir(dir string) Option {
+	return func(d *dialerConfig) {
+		d.certCacheDir = dir
+	}
+}
+
+// certCachePath returns the path of the instance's file in the cache dir.
+func (d *Dialer) certCachePath(k instanceKey) string {
+	name := strings.Join([]string{
+		url.QueryEscape(k.project),
+		url.QueryEscape(k.region),
+		url.QueryEscape(k.cluster),
+		url.QueryEscape(k.name),
+	}, ".")
+	return filepath.Join(d.certCacheDir, name+".json")
+}
+
+// loadCachedCert reads the instance's connection info from the cache dir. It
+// reports false if the cache is disabled or has no entry for the instance.
+// Expired or invalid connection info is rejected when the instance is
+// created.
+func (d *Dialer) loadCachedCert(k instanceKey) (alloydb.Snapshot, bool) {
+	if d.certCacheDir == "" {
+		return alloydb.Snapshot{}, false
+	}
+	b, err := os.ReadFile(d.certCachePath(k))
+	if err != nil {
+		return alloydb.Snapshot{}, false
+	}
+	var s alloydb.Snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return alloydb.Snapshot{}, false
+	}
+	return s, true
+}
+
+// storeCachedCert writes the instance's connection info to the cache dir,
+// replacing the previous file atomically.
+func (d *Dialer) storeCachedCert(k instanceKey, s alloydb.Snapshot) {
+	err := func() error {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		// CreateTemp creates the file with mode 0600.
+		f, err := os.CreateTemp(d.certCacheDir, ".tmp-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.Write(b); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		return os.Rename(f.Name(), d.certCachePath(k))
+	}()
+	if err != nil {
+		d.logger.Log(logging.Event{
+			Instance: s.Instance,
+			Name:     logging.EventSnapshot,
+			Message:  "failed to write certificate cache",
+			Err:      fmt.Errorf("failed to write certificate cache: %v", err),
+		})
+	}
+}
+
+// removeCachedCert deletes the instance's file from the cache dir, e.g.,
+// because the server rejected the certificates it holds.
+func (d *Dialer) removeCachedCert(k instanceKey, instance string) {
+	if d.certCacheDir == "" {
+		return
+	}
+	if err := os.Remove(d.certCachePath(k)); err != nil && !os.IsNotExist(err) {
+		d.logger.Log(logging.Event{
+			Instance: instance,
+			Name:     logging.EventSnapshot,
+			Message:  "failed to remove certificate cache entry",
+			Err:      err,
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestDialerWithCertCacheDir(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "certs")
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithCertCacheDir(dir))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	// The cache is written after the refresh operation completes, which may
+	// be after Dial returns.
+	k, _ := parseInstanceKey(testInstanceURI)
+	path := d.certCachePath(k)
+	var fi os.FileInfo
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if fi, err = os.Stat(path); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("expected certificate cache file, but got error: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		if got := fi.Mode().Perm(); got != 0600 {
+			t.Fatalf("want mode 0600, got = %v", got)
+		}
+	}
+
+	// The second Dialer has an Admin API that rejects every request, so it
+	// can only connect with the cached certificate.
+	mc2, url2, cleanup2 := mock.HTTPClient()
+	defer func() {
+		if err := cleanup2(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c2, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc2), option.WithEndpoint(url2))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d2, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithCertCacheDir(dir))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d2.client = c2
+	defer d2.Close()
+
+	conn, err = d2.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial with cached certificate to succeed, but got error: %v", err)
+	}
+	conn.Close()
+}
+
+func TestDialerCertCacheDirIgnoresCorruptFile(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	dir := t.TempDir()
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithCertCacheDir(dir))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+	k, _ := parseInstanceKey(testInstanceURI)
+	if err := os.WriteFile(d.certCachePath(k), []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	// The refreshed certificate replaces the corrupt file.
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if _, ok := d.loadCachedCert(k); ok {
+			return
+		}
+	}
+	t.Fatal("want corrupt certificate cache file to be replaced")
+}
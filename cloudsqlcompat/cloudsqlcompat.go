@@ -0,0 +1,194 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudsqlcompat mirrors the API of the Cloud SQL Go Connector
+// (cloud.google.com/go/cloudsqlconn) for workloads migrating from Cloud SQL to
+// AlloyDB. Code written against cloudsqlconn usually needs only its imports
+// and instance names changed:
+//
+//	import cloudsqlconn "cloud.google.com/go/alloydbconn/cloudsqlcompat"
+//
+//	d, err := cloudsqlconn.NewDialer(ctx, cloudsqlconn.WithIAMAuthN())
+//	// ...
+//	conn, err := d.Dial(ctx, "projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>")
+//
+// Instances are named by their AlloyDB instance URI rather than a Cloud SQL
+// instance connection name (<PROJECT>:<REGION>:<INSTANCE>), as AlloyDB
+// instances belong to a cluster.
+//
+// The types of this package are aliases of the alloydbconn types, so the
+// Options it returns may be passed to, e.g., the RegisterDriver function of
+// cloud.google.com/go/alloydbconn/driver/pgxv4, which replaces the one of
+// cloud.google.com/go/cloudsqlconn/postgres/pgxv4 with the same semantics, and
+// may be mixed with alloydbconn Options.
+//
+// cloudsqlconn features without an AlloyDB counterpart, such as lazy refresh
+// and per-dial IAM authentication, are not provided.
+package cloudsqlcompat
+
+import (
+	"context"
+	"crypto/rsa"
+	"net"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/alloydbconn"
+	"cloud.google.com/go/alloydbconn/debug"
+	"golang.org/x/oauth2"
+)
+
+// Dialer is the alloydbconn Dialer. Like the cloudsqlconn Dialer, it offers
+// Dial, Warmup, and Close.
+type Dialer = alloydbconn.Dialer
+
+// An Option is an option for configuring a Dialer.
+type Option = alloydbconn.Option
+
+// A DialOption is an option for configuring how a Dialer's Dial call is
+// executed.
+type DialOption = alloydbconn.DialOption
+
+// NewDialer creates a new Dialer; see alloydbconn.NewDialer.
+func NewDialer(ctx context.Context, opts ...Option) (*Dialer, error) {
+	return alloydbconn.NewDialer(ctx, opts...)
+}
+
+// WithOptions turns a list of Option's into a single Option.
+func WithOptions(opts ...Option) Option {
+	return alloydbconn.WithOptions(opts...)
+}
+
+// WithCredentialsFile returns an Option that specifies a service account or
+// refresh token JSON credentials file to be used as the basis for
+// authentication.
+func WithCredentialsFile(filename string) Option {
+	return alloydbconn.WithCredentialsFile(filename)
+}
+
+// WithCredentialsJSON returns an Option that specifies a service account or
+// refresh token JSON credentials to be used as the basis for authentication.
+func WithCredentialsJSON(b []byte) Option {
+	return alloydbconn.WithCredentialsJSON(b)
+}
+
+// WithTokenSource returns an Option that specifies an OAuth2 token source to
+// be used as the basis for authentication.
+func WithTokenSource(s oauth2.TokenSource) Option {
+	return alloydbconn.WithTokenSource(s)
+}
+
+// WithRSAKey returns an Option that specifies a rsa.PrivateKey used to
+// represent the client.
+func WithRSAKey(k *rsa.PrivateKey) Option {
+	return alloydbconn.WithRSAKey(k)
+}
+
+// WithRefreshTimeout returns an Option that sets a timeout on refresh
+// operations. Defaults to 30s.
+func WithRefreshTimeout(t time.Duration) Option {
+	return alloydbconn.WithRefreshTimeout(t)
+}
+
+// WithHTTPClient configures the underlying AlloyDB Admin API client with the
+// provided HTTP client.
+func WithHTTPClient(client *http.Client) Option {
+	return alloydbconn.WithHTTPClient(client)
+}
+
+// WithAdminAPIEndpoint configures the underlying AlloyDB Admin API client to
+// use the provided URL.
+func WithAdminAPIEndpoint(url string) Option {
+	return alloydbconn.WithAdminAPIEndpoint(url)
+}
+
+// WithUniverseDomain configures the Dialer to use the AlloyDB Admin API
+// within the provided universe domain.
+func WithUniverseDomain(domain string) Option {
+	return alloydbconn.WithUniverseDomain(domain)
+}
+
+// WithQuotaProject returns an Option that attributes the Dialer's AlloyDB
+// Admin API calls to the provided project.
+func WithQuotaProject(project string) Option {
+	return alloydbconn.WithQuotaProject(project)
+}
+
+// WithUserAgent returns an Option that sets the User-Agent.
+func WithUserAgent(ua string) Option {
+	return alloydbconn.WithUserAgent(ua)
+}
+
+// WithDefaultDialOptions returns an Option that specifies the default
+// DialOptions used.
+func WithDefaultDialOptions(opts ...DialOption) Option {
+	return alloydbconn.WithDefaultDialOptions(opts...)
+}
+
+// WithDialFunc configures the function used to connect to the address on the
+// named network.
+func WithDialFunc(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return alloydbconn.WithDialFunc(dial)
+}
+
+// WithIAMAuthN returns an Option that enables automatic IAM database
+// authentication.
+func WithIAMAuthN() Option {
+	return alloydbconn.WithIAMAuthN()
+}
+
+// WithIAMAuthNTokenSources returns an Option that enables automatic IAM
+// database authentication, using apiTS to call the AlloyDB Admin API and
+// iamLoginTS to log in to the database.
+func WithIAMAuthNTokenSources(apiTS, iamLoginTS oauth2.TokenSource) Option {
+	return alloydbconn.WithOptions(
+		alloydbconn.WithTokenSource(apiTS),
+		alloydbconn.WithIAMAuthNTokenSource(iamLoginTS),
+	)
+}
+
+// WithDebugLogger configures a debug logger for reporting on internal
+// operations.
+func WithDebugLogger(l debug.Logger) Option {
+	return alloydbconn.WithDebugLogger(l)
+}
+
+// DialOptions turns a list of DialOption instances into a DialOption.
+func DialOptions(opts ...DialOption) DialOption {
+	return alloydbconn.DialOptions(opts...)
+}
+
+// WithTCPKeepAlive returns a DialOption that specifies the tcp keep alive
+// period for the connection returned by Dial.
+func WithTCPKeepAlive(d time.Duration) DialOption {
+	return alloydbconn.WithTCPKeepAlive(d)
+}
+
+// WithPublicIP returns a DialOption that specifies a public IP will be used
+// to connect.
+func WithPublicIP() DialOption {
+	return alloydbconn.WithPublicIP()
+}
+
+// WithPrivateIP returns a DialOption that specifies a private IP will be used
+// to connect.
+func WithPrivateIP() DialOption {
+	return alloydbconn.WithPrivateIP()
+}
+
+// WithPSC returns a DialOption that specifies a PSC endpoint will be used to
+// connect.
+func WithPSC() DialOption {
+	return alloydbconn.WithPSC()
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsqlcompat
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"golang.org/x/oauth2"
+)
+
+type fixedTokenSource struct {
+	token string
+}
+
+func (s fixedTokenSource) Token() (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: s.token}, nil
+}
+
+const testInstanceURI = "projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+
+func TestDialer(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+
+	d, err := NewDialer(ctx,
+		WithIAMAuthNTokenSources(fixedTokenSource{"api"}, fixedTokenSource{"login"}),
+		WithHTTPClient(mc),
+		WithAdminAPIEndpoint(url),
+		WithDefaultDialOptions(WithPrivateIP()),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	if err := d.Warmup(ctx, testInstanceURI); err != nil {
+		t.Fatalf("expected Warmup to succeed, but got error: %v", err)
+	}
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	if !d.IAMAuthN() {
+		t.Fatal("want IAM authentication to be enabled")
+	}
+	tok, err := d.IAMAuthNToken()
+	if err != nil {
+		t.Fatalf("expected IAMAuthNToken to succeed, but got error: %v", err)
+	}
+	if tok != "login" {
+		t.Fatalf("want login token, got = %q", tok)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"strings"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+)
+
+// An InstanceOption configures a single instance; see Dialer.Configure.
+type InstanceOption func(*instanceConfig)
+
+type instanceConfig struct {
+	instance string
+	settings instanceSettings
+	err      error
+}
+
+// instanceSettings holds the settings of an instance configured with
+// Dialer.Configure.
+type instanceSettings struct {
+	// dialOpts are applied to each dial of the instance after the Dialer's
+	// default DialOptions and before the caller's.
+	dialOpts []DialOption
+	// iamAuthN overrides the Dialer's IAM database authentication setting if
+	// non-nil.
+	iamAuthN *bool
+	// refreshTimeout overrides the Dialer's refresh timeout if positive.
+	refreshTimeout time.Duration
+	// rateLimit overrides the Dialer's rate limit if non-nil.
+	rateLimit *rateLimit
+}
+
+// InstanceDialOptions returns an InstanceOption that applies opts to each
+// dial of the instance, on top of the Dialer's default DialOptions, e.g., to
+// connect to one instance over its public IP. DialOptions passed to Dial take
+// precedence.
+func InstanceDialOptions(opts ...DialOption) InstanceOption {
+	return func(c *instanceConfig) {
+		c.settings.dialOpts = append(c.settings.dialOpts, opts...)
+	}
+}
+
+// InstanceIAMAuthN returns an InstanceOption that enables or disables IAM
+// database authentication for the instance, overriding WithIAMAuthN. See
+// Dialer.IAMAuthNFor.
+func InstanceIAMAuthN(enabled bool) InstanceOption {
+	return func(c *instanceConfig) {
+		c.settings.iamAuthN = &enabled
+	}
+}
+
+// InstanceRefreshTimeout returns an InstanceOption that sets a timeout on the
+// refresh operations of the instance, overriding WithRefreshTimeout and
+// WithInstanceRefreshTimeout.
+func InstanceRefreshTimeout(t time.Duration) InstanceOption {
+	return func(c *instanceConfig) {
+		if t <= 0 {
+			c.err = errtype.NewConfigError("invalid refresh timeout, must be positive", c.instance)
+			return
+		}
+		c.settings.refreshTimeout = t
+	}
+}
+
+// InstanceRateLimiter returns an InstanceOption that throttles the refresh
+// operations of the instance, overriding WithRateLimiter and
+// WithInstanceRateLimiter. See WithRateLimiter.
+func InstanceRateLimiter(interval time.Duration, burst int) InstanceOption {
+	return func(c *instanceConfig) {
+		if !validRateLimit(interval, burst) {
+			c.err = errtype.NewConfigError(
+				"invalid rate limit, interval must not be negative and burst must be positive", c.instance,
+			)
+			return
+		}
+		c.settings.rateLimit = &rateLimit{interval: interval, burst: burst}
+	}
+}
+
+// Configure sets the options of a single instance, overriding the Dialer's
+// options for it, so that instances dialed through the same Dialer can, e.g.,
+// use different IP types or authentication. The instance must be an instance
+// URI. Each call replaces the instance's previous configuration; calling
+// Configure without options restores the Dialer's settings for the instance.
+//
+// DialOptions and the IAM database authentication setting apply to subsequent
+// calls to Dial. Refresh settings apply when the instance is first dialed
+// afterwards; the cached connection info of an instance dialed before is
+// refreshed as configured before. If any InstanceOption fails, the
+// instance's configuration is unchanged.
+func (d *Dialer) Configure(instance string, opts ...InstanceOption) error {
+	if _, ok := parseInstanceKey(instance); !ok {
+		return invalidInstanceURIError(instance)
+	}
+	uri := strings.TrimPrefix(instance, "/")
+	cfg := &instanceConfig{instance: uri}
+	for _, opt := range opts {
+		opt(cfg)
+		if cfg.err != nil {
+			return cfg.err
+		}
+	}
+
+	d.applyMu.Lock()
+	defer d.applyMu.Unlock()
+	cur := d.currentSettings()
+	next := *cur
+	next.instances = make(map[string]instanceSettings, len(cur.instances)+1)
+	for k, s := range cur.instances {
+		next.instances[k] = s
+	}
+	if len(opts) == 0 {
+		delete(next.instances, uri)
+	} else {
+		next.instances[uri] = cfg.settings
+	}
+	d.settings.Store(&next)
+	return nil
+}
+
+// instanceSettings returns the settings of the instance set with Configure,
+// if any.
+func (d *Dialer) instanceSettings(instanceURI string) (instanceSettings, bool) {
+	s, ok := d.currentSettings().instances[strings.TrimPrefix(instanceURI, "/")]
+	return s, ok
+}
+
+// IAMAuthNFor reports whether connections to the instance use IAM database
+// authentication, i.e., whether the Dialer was configured with WithIAMAuthN
+// or WithIAMAuthNTokenSource, unless overridden for the instance with
+// InstanceIAMAuthN. Names that are not instance URIs are not resolved and so
+// always use the Dialer's setting.
+func (d *Dialer) IAMAuthNFor(instance string) bool {
+	if s, ok := d.instanceSettings(instance); ok && s.iamAuthN != nil {
+		return *s.iamAuthN
+	}
+	return d.iamAuthN
+}
+
+// iamAuthNEnabled reports whether any instance uses IAM database
+// authentication.
+func (d *Dialer) iamAuthNEnabled() bool {
+	if d.iamAuthN {
+		return true
+	}
+	for _, s := range d.currentSettings().instances {
+		if s.iamAuthN != nil && *s.iamAuthN {
+			return true
+		}
+	}
+	return false
+}
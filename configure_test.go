@@ -0,0 +1,169 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+func TestDialerConfigure(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithIPAddr("10.0.0.1"),
+		mock.WithPublicIPAddr("127.0.0.1"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		addrs []string
+	)
+	d, err := NewDialer(ctx,
+		WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "api-token"})),
+		WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			addrs = append(addrs, addr)
+			mu.Unlock()
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	err = d.Configure(testInstanceURI,
+		InstanceDialOptions(WithPublicIP()),
+		InstanceIAMAuthN(true),
+		InstanceRefreshTimeout(5*time.Second),
+		InstanceRateLimiter(0, 1),
+	)
+	if err != nil {
+		t.Fatalf("expected Configure to succeed, but got error: %v", err)
+	}
+	other := "projects/my-project/locations/my-region/clusters/my-cluster/instances/other"
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+	mu.Lock()
+	if len(addrs) != 1 || addrs[0] != "127.0.0.1:5433" {
+		t.Fatalf("want dial to public IP 127.0.0.1:5433, got = %v", addrs)
+	}
+	mu.Unlock()
+
+	if !d.IAMAuthNFor(testInstanceURI) {
+		t.Error("want IAMAuthNFor to be true for the configured instance")
+	}
+	if d.IAMAuthNFor(other) {
+		t.Error("want IAMAuthNFor to be false for other instances")
+	}
+	if tok, err := d.IAMAuthNToken(); err != nil || tok != "api-token" {
+		t.Errorf("want IAMAuthNToken = api-token, got = %v, %v", tok, err)
+	}
+	if got := d.refreshTimeout(testInstanceURI); got != 5*time.Second {
+		t.Errorf("want refresh timeout = 5s, got = %v", got)
+	}
+	if got := d.refreshTimeout(other); got != 30*time.Second {
+		t.Errorf("want refresh timeout = 30s for other instances, got = %v", got)
+	}
+
+	// Configuring the instance without options restores the Dialer's
+	// settings.
+	if err := d.Configure(testInstanceURI); err != nil {
+		t.Fatalf("expected Configure to succeed, but got error: %v", err)
+	}
+	if d.IAMAuthNFor(testInstanceURI) {
+		t.Error("want IAMAuthNFor to be false after reset")
+	}
+	if got := d.refreshTimeout(testInstanceURI); got != 30*time.Second {
+		t.Errorf("want refresh timeout = 30s after reset, got = %v", got)
+	}
+}
+
+func TestDialerConfigureErrors(t *testing.T) {
+	d, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	if err := d.Configure(testInstanceURI, InstanceIAMAuthN(true)); err != nil {
+		t.Fatalf("expected Configure to succeed, but got error: %v", err)
+	}
+
+	tcs := []struct {
+		desc     string
+		instance string
+		opts     []InstanceOption
+	}{
+		{
+			desc:     "invalid instance URI",
+			instance: "my-instance",
+			opts:     []InstanceOption{InstanceIAMAuthN(false)},
+		},
+		{
+			desc:     "invalid refresh timeout",
+			instance: testInstanceURI,
+			opts:     []InstanceOption{InstanceIAMAuthN(false), InstanceRefreshTimeout(0)},
+		},
+		{
+			desc:     "invalid rate limit",
+			instance: testInstanceURI,
+			opts:     []InstanceOption{InstanceIAMAuthN(false), InstanceRateLimiter(time.Second, 0)},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := d.Configure(tc.instance, tc.opts...)
+			var cErr *errtype.ConfigError
+			if !errors.As(err, &cErr) {
+				t.Fatalf("want = %T, got = %v", cErr, err)
+			}
+			// A failed Configure leaves the configuration unchanged.
+			if !d.IAMAuthNFor(testInstanceURI) {
+				t.Fatal("want IAMAuthNFor to still be true")
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"net"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+	"cloud.google.com/go/alloydbconn/internal/logging"
+)
+
+// WithConnExpiryHandler returns an Option that calls h with each connection
+// returned by Dial that is still open when the client certificate it was
+// established with expires. Established connections keep working after the
+// certificate expires unless the instance requires a new TLS handshake, so
+// long-lived connections, e.g., of replication or LISTEN clients, may use h
+// to reconnect at a convenient time. To recycle such connections as soon as
+// possible, close them in h:
+//
+//	alloydbconn.WithConnExpiryHandler(func(_ string, conn net.Conn) {
+//		conn.Close()
+//	})
+//
+// h is called on its own goroutine with the instance URI and at most once
+// per connection. It is not called for connections closed before the
+// certificate expires.
+func WithConnExpiryHandler(h func(instance string, conn net.Conn)) Option {
+	return func(d *dialerConfig) {
+		d.connExpiryHandler = h
+	}
+}
+
+// watchConnExpiry arranges for the handler set with WithConnExpiryHandler to
+// be called with ic once the instance's current client certificate expires.
+func (d *Dialer) watchConnExpiry(instance string, i *alloydb.Instance, ic *instrumentedConn) {
+	if d.connExpiryHandler == nil {
+		return
+	}
+	c, ok := i.CachedInfo()
+	if !ok || c.Expiry.IsZero() {
+		return
+	}
+	ic.expiryTimer = time.AfterFunc(time.Until(c.Expiry), func() {
+		d.logger.Log(logging.Event{
+			Instance: instance,
+			Name:     logging.EventConnExpired,
+			Addr:     ic.RemoteAddr().String(),
+			Message:  "client certificate of open connection expired",
+		})
+		d.connExpiryHandler(instance, ic)
+	})
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestDialerWithConnExpiryHandler(t *testing.T) {
+	ctx := context.Background()
+	expiry := time.Now().Add(time.Second)
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithCertExpiry(expiry),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, mock.Unlimited),
+		mock.CreateEphemeralSuccess(inst, mock.Unlimited),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		cleanup()
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	type expired struct {
+		instance string
+		conn     net.Conn
+	}
+	expiredCh := make(chan expired, 2)
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRateLimiter(0, 0),
+		WithConnExpiryHandler(func(instance string, conn net.Conn) {
+			conn.Close()
+			expiredCh <- expired{instance: instance, conn: conn}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	open, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer open.Close()
+	closed, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	closed.Close()
+
+	select {
+	case e := <-expiredCh:
+		if e.instance != testInstanceURI {
+			t.Errorf("want instance = %v, got = %v", testInstanceURI, e.instance)
+		}
+		if e.conn != open {
+			t.Errorf("want handler to be called with the open connection")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not called")
+	}
+	select {
+	case <-expiredCh:
+		t.Fatal("want handler not to be called for a closed connection")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if got := d.Stats().OpenConnections; got != 0 {
+		t.Errorf("want no open connections, got = %v", got)
+	}
+}
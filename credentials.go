@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/logging"
+	"golang.org/x/oauth2"
+)
+
+// CheckCredentials requests a token from the Dialer's credentials and
+// returns an error that wraps errtype.ErrCredentials if none is produced,
+// e.g., because a Workload Identity Federation token expired or a service
+// account key was revoked. It does not call the AlloyDB Admin API, so it
+// tells credential failures apart from API outages and is suitable as a
+// health check. Dialers using static connection info, or credentials
+// supplied by WithHTTPClient, have no credentials to check and return nil.
+//
+// Refresh operations that fail for the same reason return errors that wrap
+// errtype.ErrCredentials, are logged with the "credentials" event, and are
+// counted with the CREDENTIALS error code.
+func (d *Dialer) CheckCredentials(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// Token sources do not take a context, so a hung token request is
+	// abandoned rather than canceled.
+	ch := make(chan error, 1)
+	go func() {
+		_, err := d.checkCredentials(ctx)
+		ch <- err
+	}()
+	var err error
+	select {
+	case err = <-ch:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err == nil || errors.Is(err, errSkipCheck) {
+		return nil
+	}
+	if !errors.Is(err, errtype.ErrCredentials) {
+		err = &credentialsError{err: err}
+	}
+	d.logger.Log(logging.Event{
+		Name:    logging.EventCredentials,
+		Message: "credentials failed to produce a token",
+		Err:     err,
+	})
+	return err
+}
+
+// credentialsTokenSource marks the errors of a token source with
+// errtype.ErrCredentials, so that refresh errors caused by the Dialer's
+// credentials are told apart from AlloyDB Admin API errors.
+type credentialsTokenSource struct {
+	ts oauth2.TokenSource
+}
+
+func (c credentialsTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := c.ts.Token()
+	if err != nil && !errors.Is(err, errtype.ErrCredentials) {
+		return nil, &credentialsError{err: err}
+	}
+	return tok, err
+}
+
+// credentialsError is an error of the Dialer's credentials. It matches
+// errtype.ErrCredentials and unwraps to the underlying error.
+type credentialsError struct {
+	err error
+}
+
+func (e *credentialsError) Error() string {
+	return errtype.ErrCredentials.Error() + ": " + e.err.Error()
+}
+
+func (e *credentialsError) Is(target error) bool {
+	return target == errtype.ErrCredentials
+}
+
+func (e *credentialsError) Unwrap() error {
+	return e.err
+}
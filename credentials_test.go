@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+var errTokenExpired = errors.New("token expired")
+
+type failingTokenSource struct{}
+
+func (failingTokenSource) Token() (*oauth2.Token, error) {
+	return nil, errTokenExpired
+}
+
+type validTokenSource struct{}
+
+func (validTokenSource) Token() (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: "my-token"}, nil
+}
+
+func TestDialerCheckCredentials(t *testing.T) {
+	ctx := context.Background()
+	tcs := []struct {
+		desc    string
+		opts    []Option
+		wantErr bool
+	}{
+		{
+			desc: "valid token source",
+			opts: []Option{WithTokenSource(validTokenSource{})},
+		},
+		{
+			desc:    "failing token source",
+			opts:    []Option{WithTokenSource(failingTokenSource{})},
+			wantErr: true,
+		},
+		{
+			desc: "credentials supplied by the HTTP client",
+			opts: []Option{WithHTTPClient(&http.Client{})},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			d, err := NewDialer(ctx, tc.opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+			err = d.CheckCredentials(ctx)
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatalf("want no error, got = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, errtype.ErrCredentials) {
+				t.Fatalf("want = %v, got = %v", errtype.ErrCredentials, err)
+			}
+			if !errors.Is(err, errTokenExpired) {
+				t.Fatalf("want error to wrap %v, got = %v", errTokenExpired, err)
+			}
+		})
+	}
+}
+
+func TestDialerCheckCredentialsCanceled(t *testing.T) {
+	d, err := NewDialer(context.Background(), WithTokenSource(validTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.CheckCredentials(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want = %v, got = %v", context.Canceled, err)
+	}
+}
+
+func TestRefreshCredentialsError(t *testing.T) {
+	ctx := context.Background()
+	_, url, cleanup := mock.HTTPClient()
+	defer cleanup()
+
+	d, err := NewDialer(ctx, WithTokenSource(failingTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	// The client authenticates with the Dialer's credentials.
+	c, err := alloydbadmin.NewClient(ctx,
+		option.WithTokenSource(d.credsTokenSource), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	_, err = d.FetchConnectionInfo(ctx, testInstanceURI)
+	var rErr *errtype.RefreshError
+	if !errors.As(err, &rErr) {
+		t.Fatalf("want = %T, got = %v", rErr, err)
+	}
+	if !errors.Is(err, errtype.ErrCredentials) {
+		t.Fatalf("want = %v, got = %v", errtype.ErrCredentials, err)
+	}
+}
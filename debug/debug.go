@@ -0,0 +1,28 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package debug provides interfaces for receiving debug output from the
+// alloydbconn package.
+package debug
+
+// Logger is the interface used for debug logging. By default, it is unused.
+//
+// Debug logs describe the internal operations of the connector, e.g., when a
+// refresh is scheduled, when the client certificate expires, how long a
+// refresh waited on the rate limiter, which IP address was dialed, and why a
+// TLS handshake failed.
+type Logger interface {
+	// Debugf is for reporting information about internal operations.
+	Debugf(format string, args ...interface{})
+}
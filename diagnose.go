@@ -0,0 +1,251 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/google"
+)
+
+// The checks run by Diagnose, in order.
+const (
+	// CheckCredentials verifies that the Dialer's credentials produce a
+	// token.
+	CheckCredentials = "credentials"
+	// CheckInstance verifies that the name resolves to a valid instance URI.
+	CheckInstance = "instance"
+	// CheckAdminAPI verifies that the AlloyDB Admin API is reachable and
+	// returns the instance's connection info to the Dialer's credentials.
+	CheckAdminAPI = "admin_api"
+	// CheckClientCert verifies that the Dialer holds a valid client
+	// certificate for the instance.
+	CheckClientCert = "client_cert"
+	// CheckEndpoint verifies that a TCP connection can be made to the
+	// instance.
+	CheckEndpoint = "endpoint"
+	// CheckTLS verifies the TLS handshake with the instance, including the
+	// server certificate.
+	CheckTLS = "tls"
+)
+
+// DiagnosticStatus is the outcome of a diagnostic check.
+type DiagnosticStatus string
+
+const (
+	// DiagnosticPass indicates the check succeeded.
+	DiagnosticPass DiagnosticStatus = "PASS"
+	// DiagnosticFail indicates the check failed.
+	DiagnosticFail DiagnosticStatus = "FAIL"
+	// DiagnosticSkip indicates the check did not apply to the Dialer's
+	// configuration or was not run because an earlier check failed.
+	DiagnosticSkip DiagnosticStatus = "SKIP"
+)
+
+// DiagnosticResult is the result of a single diagnostic check.
+type DiagnosticResult struct {
+	// Check is the name of the check, e.g., CheckCredentials.
+	Check  string
+	Status DiagnosticStatus
+	// Detail describes what was checked or why the check was skipped.
+	Detail string
+	// Err is the error of a failed check.
+	Err      error
+	Duration time.Duration
+}
+
+// DiagnosticReport is the result of Diagnose.
+type DiagnosticReport struct {
+	// Instance is the name passed to Diagnose.
+	Instance string
+	// Results hold one result for each check, in the order they ran.
+	Results []DiagnosticResult
+}
+
+// Err returns the error of the first failed check, or nil if no check
+// failed.
+func (r *DiagnosticReport) Err() error {
+	for _, res := range r.Results {
+		if res.Status == DiagnosticFail {
+			return fmt.Errorf("%v check failed: %w", res.Check, res.Err)
+		}
+	}
+	return nil
+}
+
+// String formats the report with one line per check.
+func (r *DiagnosticReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diagnostics for %v\n", r.Instance)
+	for _, res := range r.Results {
+		fmt.Fprintf(&b, "  %-4v %-12v %v", res.Status, res.Check, res.Detail)
+		if res.Err != nil {
+			fmt.Fprintf(&b, ": %v", res.Err)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Diagnose runs a sequence of checks that together cover what Dial needs to
+// connect to the instance: the credentials, the instance name, the AlloyDB
+// Admin API, the client certificate, the instance's endpoint, and the TLS
+// handshake. Once a check fails, the remaining checks are skipped, so the
+// first failure in the report is the most specific cause. DialOptions select
+// the IP type to check as they would for Dial.
+//
+// Diagnose is intended for troubleshooting, e.g., behind a --diagnose flag,
+// rather than for readiness checks; see WaitForReady. The connection made to
+// check the instance is closed immediately and does not count toward the
+// Dialer's connection limit.
+func (d *Dialer) Diagnose(ctx context.Context, instance string, opts ...DialOption) *DiagnosticReport {
+	r := &DiagnosticReport{Instance: instance}
+	failed := false
+	run := func(check string, f func() (string, error)) {
+		if failed {
+			r.Results = append(r.Results, DiagnosticResult{
+				Check:  check,
+				Status: DiagnosticSkip,
+				Detail: "skipped after an earlier failure",
+			})
+			return
+		}
+		start := time.Now()
+		detail, err := f()
+		res := DiagnosticResult{
+			Check:    check,
+			Status:   DiagnosticPass,
+			Detail:   detail,
+			Err:      err,
+			Duration: time.Since(start),
+		}
+		switch {
+		case errors.Is(err, errSkipCheck):
+			res.Status, res.Err = DiagnosticSkip, nil
+		case err != nil:
+			res.Status = DiagnosticFail
+			failed = true
+		}
+		r.Results = append(r.Results, res)
+	}
+
+	run(CheckCredentials, func() (string, error) {
+		return d.checkCredentials(ctx)
+	})
+
+	cfg := d.currentSettings().defaultDialCfg
+	var uri string
+	run(CheckInstance, func() (string, error) {
+		var err error
+		if uri, err = d.resolve(ctx, instance, &cfg); err != nil {
+			return "failed to resolve instance", err
+		}
+		if _, ok := parseInstanceKey(uri); !ok {
+			return "invalid instance URI", invalidInstanceURIError(uri)
+		}
+		return fmt.Sprintf("instance URI is %v", uri), nil
+	})
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	run(CheckAdminAPI, func() (string, error) {
+		k, _ := parseInstanceKey(uri)
+		res, err := d.adminAPI().ConnectionInfo(d.adminContext(ctx), k.project, k.region, k.cluster, k.name)
+		if err != nil {
+			return "failed to retrieve connection info", err
+		}
+		return fmt.Sprintf("instance UID is %v", res.InstanceUID), nil
+	})
+
+	var (
+		addr   string
+		tlsCfg *tls.Config
+	)
+	run(CheckClientCert, func() (string, error) {
+		i, err := d.instance(uri)
+		if err != nil {
+			return "failed to create instance", err
+		}
+		if addr, tlsCfg, err = d.connectInfo(ctx, i, cfg.ipType); err != nil {
+			return "failed to retrieve client certificate", err
+		}
+		if c, ok := i.CachedInfo(); ok {
+			return fmt.Sprintf("client certificate expires at %v", c.Expiry.Format(time.RFC3339)), nil
+		}
+		return "client certificate issued", nil
+	})
+
+	var conn net.Conn
+	run(CheckEndpoint, func() (string, error) {
+		hostPort := net.JoinHostPort(addr, serverProxyPort)
+		var err error
+		if conn, err = d.dialFunc(ctx, "tcp", hostPort); err != nil {
+			return fmt.Sprintf("failed to dial %v", hostPort), err
+		}
+		return fmt.Sprintf("connected to %v", hostPort), nil
+	})
+
+	run(CheckTLS, func() (string, error) {
+		if dl, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(dl)
+		}
+		tlsConn := tls.Client(conn, tlsCfg)
+		defer tlsConn.Close()
+		if err := tlsConn.Handshake(); err != nil {
+			return "TLS handshake failed", err
+		}
+		return "server certificate verified", nil
+	})
+	if conn != nil {
+		_ = conn.Close()
+	}
+	return r
+}
+
+// errSkipCheck is returned by a diagnostic check that does not apply.
+var errSkipCheck = errors.New("check skipped")
+
+// checkCredentials requests a token from the Dialer's credentials.
+func (d *Dialer) checkCredentials(ctx context.Context) (string, error) {
+	if d.staticInfo != nil {
+		return "static connection info does not use credentials", errSkipCheck
+	}
+	ts := d.credsTokenSource
+	if ts == nil {
+		if d.httpClient != nil {
+			return "credentials are supplied by the HTTP client", errSkipCheck
+		}
+		creds, err := google.FindDefaultCredentials(ctx, CloudPlatformScope)
+		if err != nil {
+			return "failed to find Application Default Credentials", err
+		}
+		ts = creds.TokenSource
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		return "failed to get token", err
+	}
+	if tok == nil || tok.AccessToken == "" {
+		return "failed to get token", errors.New("token is empty")
+	}
+	return "token retrieved", nil
+}
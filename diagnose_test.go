@@ -0,0 +1,114 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+func TestDialerDiagnose(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}),
+	))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	r := d.Diagnose(ctx, testInstanceURI)
+	if err := r.Err(); err != nil {
+		t.Fatalf("want no failed checks, got = %v\n%v", err, r)
+	}
+	want := []string{
+		CheckCredentials, CheckInstance, CheckAdminAPI,
+		CheckClientCert, CheckEndpoint, CheckTLS,
+	}
+	if len(r.Results) != len(want) {
+		t.Fatalf("want %v results, got = %v", len(want), len(r.Results))
+	}
+	for n, res := range r.Results {
+		if res.Check != want[n] || res.Status != DiagnosticPass {
+			t.Errorf("result %v: want %v %v, got = %v %v", n, want[n], DiagnosticPass, res.Check, res.Status)
+		}
+	}
+	if got := atomic.LoadUint64(&d.openConns); got != 0 {
+		t.Fatalf("want no open connections, got = %v", got)
+	}
+}
+
+func TestDialerDiagnoseFailure(t *testing.T) {
+	ctx := context.Background()
+	// The Admin API rejects every request.
+	mc, url, cleanup := mock.HTTPClient()
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}),
+	))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	r := d.Diagnose(ctx, testInstanceURI)
+	err = r.Err()
+	if err == nil || !strings.Contains(err.Error(), CheckAdminAPI) {
+		t.Fatalf("want %v check to fail, got = %v", CheckAdminAPI, err)
+	}
+	for _, res := range r.Results[3:] {
+		if res.Status != DiagnosticSkip {
+			t.Errorf("want %v to be skipped, got = %v", res.Check, res.Status)
+		}
+	}
+	if !strings.Contains(r.String(), "FAIL admin_api") {
+		t.Errorf("want report to show the failure, got = %v", r)
+	}
+}
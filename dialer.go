@@ -16,23 +16,35 @@ package alloydbconn
 
 import (
 	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
 	_ "embed"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
 	"cloud.google.com/go/alloydbconn/errtype"
 	"cloud.google.com/go/alloydbconn/internal/alloydb"
-	"cloud.google.com/go/alloydbconn/internal/alloydbapi"
+	"cloud.google.com/go/alloydbconn/internal/logging"
 	"cloud.google.com/go/alloydbconn/internal/trace"
 	"github.com/google/uuid"
-	"golang.org/x/net/proxy"
+	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
+	"google.golang.org/api/impersonate"
 	"google.golang.org/api/option"
 )
 
@@ -42,8 +54,24 @@ const (
 	defaultTCPKeepAlive = 30 * time.Second
 	// serverProxyPort is the port the server-side proxy receives connections on.
 	serverProxyPort = "5433"
+	// shutdownPollInterval is how often Shutdown checks for open connections.
+	shutdownPollInterval = 50 * time.Millisecond
+	// certLatencySamples is the number of recent certificate issuance
+	// latencies Stats computes percentiles over.
+	certLatencySamples = 1000
+	// defaultTelemetryQueueSize is the default number of metric measurements
+	// that may wait to be reported.
+	defaultTelemetryQueueSize = 1024
 )
 
+// ErrDialerClosed is returned when dialing with a Dialer that has been shut
+// down.
+var ErrDialerClosed = errors.New("alloydbconn: dialer is closed")
+
+// ErrUnknownInstance is returned by InstanceStatus for an instance the Dialer
+// has not connected to.
+var ErrUnknownInstance = errors.New("alloydbconn: instance has not been dialed")
+
 var (
 	// versionString indicates the version of this library.
 	//go:embed version.txt
@@ -63,29 +91,208 @@ func getDefaultKeys() (*rsa.PrivateKey, error) {
 	return defaultKey, defaultKeyErr
 }
 
+// Connector is the interface implemented by Dialer. Applications that depend
+// on a Connector rather than a *Dialer may substitute a fake in tests, e.g.,
+// the one provided by the alloydbconntest package.
+type Connector interface {
+	// Dial returns a net.Conn connected to the specified AlloyDB instance.
+	Dial(ctx context.Context, instance string, opts ...DialOption) (net.Conn, error)
+	// Close releases any resources held by the Connector.
+	Close() error
+}
+
+var _ Connector = (*Dialer)(nil)
+
 // A Dialer is used to create connections to AlloyDB instance.
 //
 // Use NewDialer to initialize a Dialer.
 type Dialer struct {
+	// openConns is the number of open connections across all instances,
+	// including dials in progress. It is accessed atomically and kept first
+	// in the struct for 64-bit alignment.
+	openConns uint64
+	// maxConns is the maximum number of open connections. Zero means there is
+	// no limit. It is accessed atomically so it may be changed with
+	// ApplyOptions.
+	maxConns uint64
+
+	// labelMu guards labelConns and instanceConns.
+	labelMu sync.Mutex
+	// labelConns maps the labels set with WithConnectionLabel to their
+	// number of open connections. Labels without open connections are
+	// removed.
+	labelConns map[string]uint64
+	// instanceConns maps instances to the number of connections the Dialer
+	// has open to them. Unlike an instance's OpenConns, it excludes the
+	// connections of the Dialer's parent and children, which share the
+	// instance. Instances without open connections are removed.
+	instanceConns map[string]uint64
+
 	lock sync.RWMutex
-	// instances map instance URIs to *alloydb.Instance types
-	instances      map[string]*alloydb.Instance
-	key            *rsa.PrivateKey
-	refreshTimeout time.Duration
+	// instances map instance keys to *alloydb.Instance types
+	instances map[instanceKey]*alloydb.Instance
+	// closed reports whether Shutdown has been called. Guarded by lock.
+	closed bool
+	// primers map instance URIs to their primed connections. Guarded by
+	// lock.
+	primers map[string]*primer
+	// primedConns is the number of connections kept ready per instance.
+	primedConns int
 
-	client *alloydbapi.Client
+	// key signs the requests for client certificates and the TLS handshakes
+	// of connections.
+	key crypto.Signer
+	// rand is the entropy source set with WithRandReader. Nil means
+	// crypto/rand.
+	rand io.Reader
 
-	// defaultDialCfg holds the constructor level DialOptions, so that it can
-	// be copied and mutated by the Dial function.
-	defaultDialCfg dialCfg
+	client *alloydbadmin.Client
+	// staticInfo replaces client when the Dialer uses static connection info.
+	staticInfo *alloydbadmin.StaticClient
+	// cache, when set, supplies connection info in place of client.
+	cache ConnectionInfoCache
+	// imported map instance URIs to connection info imported with
+	// WithImportedCache that has not yet been used. Guarded by lock.
+	imported map[string]alloydb.Snapshot
+	// certCacheDir, when set, is the directory that persists each
+	// instance's connection info; see WithCertCacheDir.
+	certCacheDir string
+	// caPins map instance URIs, without a leading slash, to the SHA-256
+	// fingerprints of their allowed root CAs.
+	caPins map[string][][sha256.Size]byte
+	// requireSAN rejects server certificates without subject alternative
+	// names.
+	requireSAN bool
+	// skipNameCheck accepts server certificates that do not name the
+	// instance; see WithOptOutOfAdvancedConnectionCheck.
+	skipNameCheck bool
+	// rateLimit throttles the refresh operations of each instance. Nil means
+	// the default limit applies.
+	rateLimit *rateLimit
+	// rateLimits map instance URIs, without a leading slash, to limits that
+	// override rateLimit.
+	rateLimits map[string]rateLimit
+	// refreshTimeouts map instance URIs, without a leading slash, to
+	// timeouts that override the refresh timeout of the Dialer's settings.
+	refreshTimeouts map[string]time.Duration
+	// resolver maps names passed to Dial that are not instance URIs to
+	// instances. Nil means names are not resolved.
+	resolver Resolver
+	// tenantOpts configure the Admin API clients for credentials set with
+	// WithCredentialsTokenSource, with httpClient, if set, as their base.
+	tenantOpts []option.ClientOption
+	httpClient *http.Client
+	// tenants map token sources set with WithCredentialsTokenSource to their
+	// clients and instances. Guarded by lock.
+	tenants map[oauth2.TokenSource]*tenant
+	// parent is the Dialer a Dialer created with Scope belongs to, which
+	// owns the shared cache. Nil for other Dialers.
+	parent *Dialer
+	// children are the Dialers created with Scope. Guarded by lock.
+	children []*Dialer
+	// limiterMu guards refreshLimiters.
+	limiterMu sync.Mutex
+	// refreshLimiters map instance URIs, without a leading slash, to the
+	// limiters of the refreshes a Dialer created with Scope forces in the
+	// shared cache.
+	refreshLimiters map[string]*rate.Limiter
+	// groups map failover group names to their instance URIs, in order of
+	// preference. Guarded by lock.
+	groups map[string][]string
+	// pools map cluster URIs, with the token sources the instances are listed
+	// with, to their read pool instances. Guarded by lock.
+	pools map[poolKey]*readPool
+
+	// settings holds the *settings that may be changed with ApplyOptions.
+	settings atomic.Value
+	// applyMu serializes calls to ApplyOptions.
+	applyMu sync.Mutex
 
 	// dialerID uniquely identifies a Dialer. Used for monitoring purposes,
 	// *only* when a client has configured OpenCensus exporters.
 	dialerID string
 
 	// dialFunc is the function used to connect to the address on the named
-	// network. By default it is golang.org/x/net/proxy#Dial.
+	// network. By default it connects through the proxy configured in the
+	// environment, if any.
 	dialFunc func(cxt context.Context, network, addr string) (net.Conn, error)
+
+	// logger receives debug events and forwards them to debugLogger and
+	// the dial event handler, if any.
+	logger logging.Logger
+	// debugLogger receives debug events for logging. By default all events
+	// are discarded.
+	debugLogger *logging.Swappable
+
+	// recorder reports metrics and traces.
+	recorder trace.Recorder
+	// certLatency holds the most recent certificate issuance latencies.
+	certLatency *trace.LatencyWindow
+	// refreshGroup deduplicates concurrent refresh operations of the same
+	// instance that use the Dialer's credentials.
+	refreshGroup *alloydb.RefreshGroup
+
+	// exec runs background work: the Admin API calls of refresh operations.
+	exec Executor
+
+	// detectClusterRole enables retrieving the role of each instance's
+	// cluster during refresh.
+	detectClusterRole bool
+	// preemptForced configures forced refreshes to restart a refresh in
+	// progress; see WithForcedRefreshPreemption.
+	preemptForced bool
+	// serveStale configures dials to use recent connection info rather than
+	// wait on a refresh in progress; see WithStaleWhileRevalidate.
+	serveStale   bool
+	maxStaleness time.Duration
+	// certHook, when set, is called with the expiration of each client
+	// certificate retrieved; see WithCertRefreshHook.
+	certHook func(instance string, notAfter time.Time)
+	// tlsPatch, when set, modifies the TLS configuration of each connection;
+	// see WithTLSConfigPatch.
+	tlsPatch func(*tls.Config)
+	// adminHeaders are added to every Admin API request; see
+	// WithAdminAPIHeaders.
+	adminHeaders http.Header
+	// connExpiryHandler, when set, is called with each open connection whose
+	// client certificate expired; see WithConnExpiryHandler.
+	connExpiryHandler func(instance string, conn net.Conn)
+	// breakerThreshold, when positive, configures the circuit breaker of
+	// each instance's refreshes; see WithRefreshCircuitBreaker.
+	breakerThreshold int
+	probeInterval    time.Duration
+
+	// refreshStrategy determines when refresh operations start.
+	refreshStrategy alloydb.RefreshStrategy
+
+	// iamAuthN indicates instances require IAM database authentication.
+	iamAuthN bool
+	// credsTokenSource is the token source of the Dialer's credentials, if
+	// known. It is nil for Application Default Credentials and credentials
+	// supplied by WithHTTPClient.
+	credsTokenSource oauth2.TokenSource
+	// iamTokens supplies the tokens used to log in to databases when
+	// iamAuthN is set.
+	iamTokens *iamTokenSource
+	// softFail reports the last known connection info of instances whose
+	// refresh fails; see WithSoftFail.
+	softFail bool
+}
+
+// settings are the Dialer's tunable settings. A settings value is never
+// modified once stored; ApplyOptions stores a new one instead.
+type settings struct {
+	refreshTimeout time.Duration
+	// defaultDialCfg holds the constructor level DialOptions, so that it can
+	// be copied and mutated by the Dial function.
+	defaultDialCfg dialCfg
+	// coldStartBudget is the maximum time a Dial waits for an instance's
+	// first refresh to complete. Zero means Dial waits until its context
+	// expires.
+	coldStartBudget time.Duration
+	// instances holds the settings of instances configured with Configure,
+	// keyed by instance URI.
+	instances map[string]instanceSettings
 }
 
 // NewDialer creates a new Dialer.
@@ -95,9 +302,10 @@ type Dialer struct {
 // RSA keypair is generated will be faster.
 func NewDialer(ctx context.Context, opts ...Option) (*Dialer, error) {
 	cfg := &dialerConfig{
-		refreshTimeout: 30 * time.Second,
-		dialFunc:       proxy.Dial,
-		useragents:     []string{userAgent},
+		refreshTimeout:     30 * time.Second,
+		useragents:         []string{userAgent},
+		logger:             logging.Nop(),
+		telemetryQueueSize: defaultTelemetryQueueSize,
 	}
 	for _, opt := range opts {
 		opt(cfg)
@@ -105,20 +313,90 @@ func NewDialer(ctx context.Context, opts ...Option) (*Dialer, error) {
 			return nil, cfg.err
 		}
 	}
+	if cfg.impersonateTarget != "" {
+		var baseOpts []option.ClientOption
+		if cfg.tokenSource != nil {
+			baseOpts = append(baseOpts, option.WithTokenSource(cfg.tokenSource))
+		}
+		ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: cfg.impersonateTarget,
+			Delegates:       cfg.impersonateDelegates,
+			Scopes:          []string{CloudPlatformScope},
+		}, baseOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to impersonate service account: %v", err)
+		}
+		cfg.tokenSource = credentialsTokenSource{ts: ts}
+		cfg.credsOpt = option.WithTokenSource(cfg.tokenSource)
+	}
+	if cfg.universeDomain != "" {
+		// Prepend the endpoint so that WithAdminAPIEndpoint takes precedence.
+		cfg.adminOpts = append([]option.ClientOption{
+			option.WithEndpoint(alloydbadmin.UniverseEndpoint(cfg.universeDomain)),
+		}, cfg.adminOpts...)
+	}
+	if cfg.quotaProject != "" {
+		// The Admin API client rejects a quota project alongside an HTTP
+		// client, so the HTTP client sets the header instead.
+		if cfg.httpClient != nil {
+			hc := *cfg.httpClient
+			hc.Transport = &quotaProjectTransport{
+				project: cfg.quotaProject,
+				base:    cfg.httpClient.Transport,
+			}
+			cfg.httpClient = &hc
+		} else {
+			cfg.adminOpts = append(cfg.adminOpts, option.WithQuotaProject(cfg.quotaProject))
+		}
+	}
+	// The clients for credentials set with WithCredentialsTokenSource share
+	// every option but the credentials and HTTP client.
+	ua := option.WithUserAgent(strings.Join(cfg.useragents, " "))
+	tenantOpts := append(append([]option.ClientOption{}, cfg.adminOpts...), ua)
+	if cfg.httpClient != nil {
+		cfg.adminOpts = append(cfg.adminOpts, option.WithHTTPClient(cfg.httpClient))
+	}
+	if cfg.credsOpt != nil {
+		cfg.adminOpts = append(cfg.adminOpts, cfg.credsOpt)
+	}
 	// Add this to the end to make sure it's not overridden
-	cfg.adminOpts = append(cfg.adminOpts, option.WithUserAgent(strings.Join(cfg.useragents, " ")))
+	cfg.adminOpts = append(cfg.adminOpts, ua)
 
-	if cfg.rsaKey == nil {
+	if cfg.staticInfo != nil {
+		// The static client certificates are issued for the static key.
+		cfg.key = cfg.staticInfo.PrivateKey()
+	}
+	if cfg.key == nil && cfg.rand != nil {
+		key, err := rsa.GenerateKey(cfg.rand, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA keys: %v", err)
+		}
+		cfg.key = key
+	}
+	if cfg.key == nil {
 		key, err := getDefaultKeys()
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate RSA keys: %v", err)
 		}
-		cfg.rsaKey = key
+		cfg.key = key
+	}
+	if cfg.certCacheDir != "" {
+		if err := os.MkdirAll(cfg.certCacheDir, 0700); err != nil {
+			return nil, errtype.NewConfigError(
+				fmt.Sprintf("failed to create certificate cache dir: %v", err), "n/a",
+			)
+		}
 	}
 
-	client, err := alloydbapi.NewClient(ctx, cfg.adminOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create AlloyDB Admin API client: %v", err)
+	// Static connection info replaces the Admin API, so no client (or
+	// credentials) are needed.
+	var client *alloydbadmin.Client
+	if cfg.staticInfo == nil {
+		var err error
+		client, err = alloydbadmin.NewClient(ctx, cfg.adminOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AlloyDB Admin API client: %v", err)
+		}
 	}
 
 	dialCfg := dialCfg{
@@ -131,87 +409,915 @@ func NewDialer(ctx context.Context, opts ...Option) (*Dialer, error) {
 	if err := trace.InitMetrics(); err != nil {
 		return nil, err
 	}
+	recorders := []trace.Recorder{trace.OpenCensus()}
+	if cfg.tracer != nil {
+		recorders[0] = trace.TracerRecorder(cfg.tracer)
+	}
+	if cfg.otelEnabled {
+		r, err := trace.NewOTelRecorder(cfg.meterProvider, cfg.tracerProvider)
+		if err != nil {
+			return nil, err
+		}
+		recorders = append(recorders, r)
+	}
+	for _, m := range cfg.metricsRecorders {
+		recorders = append(recorders, trace.MetricsRecorder(m))
+	}
+	var refreshStrategy alloydb.RefreshStrategy = cfg.refreshStrategy
+	if refreshStrategy == nil {
+		refreshStrategy = alloydb.NewRefreshStrategy(cfg.refreshBuffer, cfg.refreshJitter)
+	}
+	// Measurements are reported from a single worker so that dials and
+	// refresh operations never wait on a telemetry backend.
+	recorder := trace.NewBatchRecorder(
+		trace.MultiRecorder(recorders...), cfg.telemetryQueueSize, cfg.telemetryTTL,
+	)
+	// Certificate issuance latencies are also kept in-process so that Stats
+	// can report their percentiles without a telemetry backend.
+	certLatency := trace.NewLatencyWindow(certLatencySamples)
+	recorder = trace.MultiRecorder(recorder, trace.CertIssuanceRecorder(certLatency))
+	var exec Executor = cfg.exec
+	if exec == nil {
+		exec = goExecutor{}
+	}
 	d := &Dialer{
-		instances:      make(map[string]*alloydb.Instance),
-		key:            cfg.rsaKey,
-		refreshTimeout: cfg.refreshTimeout,
-		client:         client,
-		defaultDialCfg: dialCfg,
-		dialerID:       uuid.New().String(),
-		dialFunc:       cfg.dialFunc,
+		instances:         make(map[instanceKey]*alloydb.Instance),
+		key:               cfg.key,
+		rand:              cfg.rand,
+		client:            client,
+		dialerID:          uuid.New().String(),
+		dialFunc:          newDialFunc(cfg),
+		debugLogger:       logging.NewSwappable(cfg.logger),
+		recorder:          recorder,
+		refreshGroup:      alloydb.NewRefreshGroup(),
+		certLatency:       certLatency,
+		exec:              exec,
+		detectClusterRole: cfg.detectClusterRole,
+		preemptForced:     cfg.preemptForced,
+		serveStale:        cfg.serveStale,
+		maxStaleness:      cfg.maxStaleness,
+		certHook:          cfg.certHook,
+		tlsPatch:          cfg.tlsPatch,
+		connExpiryHandler: cfg.connExpiryHandler,
+		adminHeaders:      cfg.adminHeaders,
+		breakerThreshold:  cfg.breakerThreshold,
+		probeInterval:     cfg.probeInterval,
+		refreshStrategy:   refreshStrategy,
+		iamAuthN:          cfg.iamAuthN,
+		iamTokens:         newIAMTokenSource(cfg),
+		credsTokenSource:  cfg.tokenSource,
+		softFail:          cfg.softFail,
+		maxConns:          cfg.maxConns,
+		primers:           make(map[string]*primer),
+		groups:            make(map[string][]string),
+		pools:             make(map[poolKey]*readPool),
+		primedConns:       cfg.primedConns,
+		staticInfo:        cfg.staticInfo,
+		cache:             cfg.cache,
+		imported:          cfg.imported,
+		certCacheDir:      cfg.certCacheDir,
+		caPins:            cfg.caPins,
+		requireSAN:        cfg.requireSAN,
+		skipNameCheck:     cfg.skipNameCheck,
+		rateLimit:         cfg.rateLimit,
+		rateLimits:        cfg.rateLimits,
+		refreshTimeouts:   cfg.refreshTimeouts,
+		resolver:          cfg.resolver,
+		tenantOpts:        tenantOpts,
+		httpClient:        cfg.httpClient,
+		tenants:           make(map[oauth2.TokenSource]*tenant),
+	}
+	d.logger = d.debugLogger
+	if cfg.eventHandler != nil {
+		d.logger = logging.Multi(d.debugLogger, eventLogger(cfg.eventHandler))
 	}
+	d.logger = logging.WithDialerID(d.logger, d.dialerID)
+	d.settings.Store(&settings{
+		refreshTimeout:  cfg.refreshTimeout,
+		defaultDialCfg:  dialCfg,
+		coldStartBudget: cfg.coldStartBudget,
+	})
 	return d, nil
 }
 
+// currentSettings returns the Dialer's current tunable settings.
+func (d *Dialer) currentSettings() *settings {
+	return d.settings.Load().(*settings)
+}
+
+// ApplyOptions updates the settings of a live Dialer. The following Options
+// take effect, each applied atomically with respect to concurrent calls to
+// Dial:
+//
+//   - WithDefaultDialOptions applies to subsequent calls to Dial, on top of
+//     the current defaults.
+//   - WithRefreshTimeout applies to instances first dialed afterwards and to
+//     FetchConnectionInfo.
+//   - WithColdStartBudget and WithMaxConnections apply to subsequent calls to
+//     Dial. Lowering the connection limit does not close open connections.
+//   - WithDebugLogger, WithJSONDebugLogger, and WithSlogLogger replace the
+//     debug logger for all instances.
+//
+// All other Options are ignored. Existing connections and cached connection
+// info are unaffected. If any Option fails, no settings are changed.
+func (d *Dialer) ApplyOptions(opts ...Option) error {
+	d.applyMu.Lock()
+	defer d.applyMu.Unlock()
+	cur := d.currentSettings()
+	cfg := &dialerConfig{
+		refreshTimeout:  cur.refreshTimeout,
+		coldStartBudget: cur.coldStartBudget,
+		maxConns:        atomic.LoadUint64(&d.maxConns),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+		if cfg.err != nil {
+			return cfg.err
+		}
+	}
+	next := &settings{
+		refreshTimeout:  cfg.refreshTimeout,
+		defaultDialCfg:  cur.defaultDialCfg,
+		coldStartBudget: cfg.coldStartBudget,
+		instances:       cur.instances,
+	}
+	for _, opt := range cfg.dialOpts {
+		opt(&next.defaultDialCfg)
+	}
+	d.settings.Store(next)
+	atomic.StoreUint64(&d.maxConns, cfg.maxConns)
+	if cfg.logger != nil {
+		d.debugLogger.Swap(cfg.logger)
+	}
+	return nil
+}
+
 // Dial returns a net.Conn connected to the specified AlloyDB instance. The
 // instance argument must be the instance's URI, which is in the format
 // projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>
+// or, if the Dialer was configured with a Resolver, a name to be resolved to
+// an instance. To dial a failover group, use "group/<NAME>"; see
+// RegisterFailoverGroup.
+//
+// If the connection is refused or times out, Dial refreshes the instance's
+// connection info and, if the instance's address has changed, e.g., after a
+// failover or maintenance, retries once at the new address.
+//
+// Concurrent dials of an instance share its refresh operations: any number of
+// dials of an instance with no cached connection info, and calls to
+// FetchConnectionInfo made at the same time, wait on a single refresh, which
+// makes exactly one call to the AlloyDB Admin API for the instance's metadata
+// and one for a client certificate.
 func (d *Dialer) Dial(ctx context.Context, instance string, opts ...DialOption) (conn net.Conn, err error) {
+	if name, ok := failoverGroupName(instance); ok {
+		return d.dialGroup(ctx, name, opts...)
+	}
 	startTime := time.Now()
+	s := d.currentSettings()
+	cfg := s.defaultDialCfg
+	// The instance is not resolved yet, so the start event carries the label
+	// set by the caller or the Dialer's defaults.
+	startCfg := cfg
+	for _, opt := range opts {
+		opt(&startCfg)
+	}
+	d.logger.Log(logging.Event{
+		Instance: instance,
+		Name:     logging.EventDialStart,
+		Message:  "dial started",
+		Label:    startCfg.label,
+	})
 	var endDial trace.EndSpanFunc
-	ctx, endDial = trace.StartSpan(ctx, "cloud.google.com/go/alloydbconn.Dial",
+	ctx, endDial = d.recorder.StartSpan(ctx, "cloud.google.com/go/alloydbconn.Dial",
 		trace.AddInstanceName(instance),
 		trace.AddDialerID(d.dialerID),
 	)
 	defer func() {
-		go trace.RecordDialError(context.Background(), instance, d.dialerID, err)
+		d.recorder.RecordDialError(context.Background(), instance, d.dialerID, err)
+		msg := "dial succeeded"
+		if err != nil {
+			msg = "dial failed"
+		}
+		d.logger.Log(logging.Event{
+			Instance: instance,
+			Name:     logging.EventDial,
+			Duration: time.Since(startTime),
+			Message:  msg,
+			Err:      err,
+			Label:    cfg.label,
+		})
 		endDial(err)
 	}()
-	cfg := d.defaultDialCfg
+	uri, err := d.resolve(ctx, instance, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	instance = uri
 	for _, opt := range opts {
 		opt(&cfg)
 	}
 
-	var endInfo trace.EndSpanFunc
-	ctx, endInfo = trace.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.InstanceInfo")
-	i, err := d.instance(instance)
+	// Reserve a connection before dialing so that concurrent dials cannot
+	// exceed the limit. The reservation is released if the dial fails.
+	if !d.acquireConn() {
+		return nil, errtype.NewConnectionLimitError(
+			fmt.Sprintf("maximum number of open connections (%d) reached", atomic.LoadUint64(&d.maxConns)),
+			instance,
+		)
+	}
+	defer func() {
+		if err != nil {
+			d.releaseConn()
+		}
+	}()
+
+	var i *alloydb.Instance
+	if cfg.tokenSource != nil && d.staticInfo == nil {
+		i, err = d.tenantInstance(instance, cfg.tokenSource)
+	} else {
+		i, err = d.instance(instance)
+	}
 	if err != nil {
-		endInfo(err)
 		return nil, err
 	}
-	addr, tlsCfg, err := i.ConnectInfo(ctx)
+	var tlsConn net.Conn
+	if d.usePrimedConn(instance, cfg, s.defaultDialCfg) {
+		if p := d.primer(instance, i); p != nil {
+			tlsConn = p.take()
+		}
+	}
+	if tlsConn == nil {
+		tlsConn, err = d.connect(ctx, i, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	latency := time.Since(startTime).Milliseconds()
+	if d.isClosed() {
+		_ = tlsConn.Close()
+		return nil, ErrDialerClosed
+	}
+	atomic.AddUint64(&i.OpenConns, 1)
+	n := d.addInstanceConn(i.String(), 1)
+	d.recorder.RecordOpenConnections(ctx, int64(n), d.dialerID, i.String())
+	d.recorder.RecordDialLatency(ctx, instance, d.dialerID, latency)
+	label := cfg.label
+	d.addLabelConn(label, 1)
+
+	openedAt := time.Now()
+	ic := newInstrumentedConn(tlsConn, func() {
+		atomic.AddUint64(&i.OpenConns, ^uint64(0))
+		n := d.addInstanceConn(i.String(), -1)
+		d.releaseConn()
+		d.addLabelConn(label, -1)
+		d.logger.Log(logging.Event{
+			Instance: i.String(),
+			Name:     logging.EventConnClosed,
+			Addr:     tlsConn.RemoteAddr().String(),
+			Duration: time.Since(openedAt),
+			Message:  "connection closed",
+			Label:    label,
+		})
+		d.recorder.RecordOpenConnections(context.Background(), int64(n), d.dialerID, i.String())
+	})
+	ic.valid = connValidator(i)
+	d.watchConnExpiry(instance, i, ic)
+	return ic, nil
+}
+
+// keepAliveConn is a connection that supports TCP keep-alive, such as a
+// *net.TCPConn. Connections returned by a custom dial function may implement
+// it to have the keep-alive DialOption applied.
+type keepAliveConn interface {
+	SetKeepAlive(keepalive bool) error
+	SetKeepAlivePeriod(d time.Duration) error
+}
+
+// connectIPType establishes a TLS connection to the instance's address of
+// the IP type of cfg.
+func (d *Dialer) connectIPType(ctx context.Context, i *alloydb.Instance, cfg dialCfg) (_ net.Conn, err error) {
+	var endInfo trace.EndSpanFunc
+	ctx, endInfo = d.recorder.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.InstanceInfo")
+	addr, tlsCfg, err := d.connectInfo(ctx, i, cfg.ipType)
 	if err != nil {
 		endInfo(err)
-		return nil, err
+		return nil, d.softFailError(i, err)
 	}
 	endInfo(err)
 
 	var connectEnd trace.EndSpanFunc
-	ctx, connectEnd = trace.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.Connect")
+	ctx, connectEnd = d.recorder.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.Connect")
 	defer func() { connectEnd(err) }()
+	if cfg.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.dialTimeout)
+		defer cancel()
+	}
+	ipAddr := addr
 	addr = net.JoinHostPort(addr, serverProxyPort)
-	conn, err = d.dialFunc(ctx, "tcp", addr)
+	d.logger.Log(logging.Event{
+		Instance: i.String(),
+		Label:    cfg.label,
+		Name:     logging.EventDialAttempt,
+		Addr:     addr,
+		Message:  fmt.Sprintf("dialing %v", addr),
+	})
+	conn, err := d.dialFunc(ctx, "tcp", addr)
 	if err != nil {
+		i.RecordDial(ipAddr, err)
+		if cfg.fallbackAttempt {
+			// The address is likely unreachable from this client, e.g., a
+			// private IP outside the instance's VPC network, which a refresh
+			// would not change.
+			return nil, errtype.NewDialError("failed to dial", i.String(), &connectError{err: err})
+		}
 		// refresh the instance info in case it caused the connection failure
-		i.ForceRefresh()
-		return nil, errtype.NewDialError("failed to dial", i.String(), err)
+		d.forceRefresh(i)
+		if !isUnreachable(err) || ctx.Err() != nil {
+			return nil, errtype.NewDialError("failed to dial", i.String(), &connectError{err: err})
+		}
+		// The instance's address may have changed, e.g., after a failover
+		// or maintenance. Retry once if the refresh reports a new address.
+		newAddr, newCfg, rErr := d.connectInfo(ctx, i, cfg.ipType)
+		if rErr != nil || newAddr == ipAddr {
+			return nil, errtype.NewDialError("failed to dial", i.String(), &connectError{err: err})
+		}
+		d.logger.Log(logging.Event{
+			Instance: i.String(),
+			Label:    cfg.label,
+			Name:     logging.EventAddrChange,
+			Addr:     net.JoinHostPort(newAddr, serverProxyPort),
+			Message:  fmt.Sprintf("address changed from %v to %v, retrying dial", ipAddr, newAddr),
+			Err:      err,
+		})
+		ipAddr, tlsCfg = newAddr, newCfg
+		addr = net.JoinHostPort(ipAddr, serverProxyPort)
+		d.logger.Log(logging.Event{
+			Instance: i.String(),
+			Label:    cfg.label,
+			Name:     logging.EventDialAttempt,
+			Addr:     addr,
+			Message:  fmt.Sprintf("dialing %v", addr),
+		})
+		conn, err = d.dialFunc(ctx, "tcp", addr)
+		if err != nil {
+			i.RecordDial(ipAddr, err)
+			d.forceRefresh(i)
+			return nil, errtype.NewDialError("failed to dial", i.String(), &connectError{err: err})
+		}
 	}
-	if c, ok := conn.(*net.TCPConn); ok {
-		if err := c.SetKeepAlive(true); err != nil {
+	if c, ok := conn.(keepAliveConn); ok {
+		if err := c.SetKeepAlive(cfg.tcpKeepAlive >= 0); err != nil {
+			_ = conn.Close()
 			return nil, errtype.NewDialError("failed to set keep-alive", i.String(), err)
 		}
-		if err := c.SetKeepAlivePeriod(cfg.tcpKeepAlive); err != nil {
-			return nil, errtype.NewDialError("failed to set keep-alive period", i.String(), err)
+		if cfg.tcpKeepAlive > 0 {
+			if err := c.SetKeepAlivePeriod(cfg.tcpKeepAlive); err != nil {
+				_ = conn.Close()
+				return nil, errtype.NewDialError("failed to set keep-alive period", i.String(), err)
+			}
 		}
 	}
-	tlsConn := tls.Client(conn, tlsCfg)
+	if dl, ok := ctx.Deadline(); ok && cfg.dialTimeout > 0 {
+		_ = conn.SetDeadline(dl)
+	}
+	tlsConn := tls.Client(conn, d.patchTLSConfig(tlsCfg))
+	handshakeStart := time.Now()
 	if err := tlsConn.Handshake(); err != nil {
+		d.logger.Log(logging.Event{
+			Instance: i.String(),
+			Label:    cfg.label,
+			Name:     logging.EventTLSHandshake,
+			Addr:     addr,
+			Duration: time.Since(handshakeStart),
+			Message:  "TLS handshake failed",
+			Err:      err,
+		})
+		i.RecordDial(ipAddr, err)
 		// refresh the instance info in case it caused the handshake failure
-		i.ForceRefresh()
+		d.forceRefresh(i)
 		_ = tlsConn.Close() // best effort close attempt
-		return nil, errtype.NewDialError("handshake failed", i.String(), err)
+		if clientCertExpired(tlsCfg) {
+			err = fmt.Errorf("%w: %v", errtype.ErrCertExpired, err)
+		}
+		dErr := errtype.NewDialError("handshake failed", i.String(), &handshakeError{err: err})
+		if errors.Is(dErr, errtype.ErrTLSVerificationFailed) {
+			// The server certificate no longer matches the cached connection
+			// info, e.g., because the instance was recreated with the same
+			// name, so the persisted copy is stale too.
+			if k, ok := parseInstanceKey(i.URI()); ok {
+				d.removeCachedCert(k, i.String())
+			}
+		}
+		return nil, dErr
 	}
-	latency := time.Since(startTime).Milliseconds()
-	go func() {
-		n := atomic.AddUint64(&i.OpenConns, 1)
-		trace.RecordOpenConnections(ctx, int64(n), d.dialerID, i.String())
-		trace.RecordDialLatency(ctx, instance, d.dialerID, latency)
-	}()
+	d.logger.Log(logging.Event{
+		Instance: i.String(),
+		Label:    cfg.label,
+		Name:     logging.EventTLSHandshake,
+		Addr:     addr,
+		Duration: time.Since(handshakeStart),
+		Message:  "TLS handshake complete",
+	})
+	if cfg.dialTimeout > 0 {
+		_ = conn.SetDeadline(time.Time{})
+	}
+	i.RecordDial(ipAddr, nil)
+	return tlsConn, nil
+}
+
+// clientCertExpired reports whether the client certificate of cfg has
+// expired.
+func clientCertExpired(cfg *tls.Config) bool {
+	if len(cfg.Certificates) == 0 || cfg.Certificates[0].Leaf == nil {
+		return false
+	}
+	return time.Now().After(cfg.Certificates[0].Leaf.NotAfter)
+}
+
+// isUnreachable reports whether err indicates that nothing is listening at
+// the dialed address, as when an instance has moved to a new address.
+func isUnreachable(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH) {
+		return true
+	}
+	var nErr net.Error
+	return errors.As(err, &nErr) && nErr.Timeout()
+}
+
+// acquireConn reserves one of the Dialer's connections, reporting false if
+// the maximum number of connections are already open.
+func (d *Dialer) acquireConn() bool {
+	max := atomic.LoadUint64(&d.maxConns)
+	if max == 0 {
+		atomic.AddUint64(&d.openConns, 1)
+		return true
+	}
+	for {
+		n := atomic.LoadUint64(&d.openConns)
+		if n >= max {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(&d.openConns, n, n+1) {
+			return true
+		}
+	}
+}
+
+// releaseConn releases a connection reserved with acquireConn.
+func (d *Dialer) releaseConn() {
+	atomic.AddUint64(&d.openConns, ^uint64(0))
+}
+
+// Stats is a snapshot of a Dialer's connections.
+type Stats struct {
+	// OpenConnections is the number of open connections across all
+	// instances, including dials in progress.
+	OpenConnections uint64
+	// Instances maps each instance URI passed to Dial to statistics about
+	// that instance. An instance dialed both with and without a leading
+	// slash is reported under the URI it was first dialed with.
+	Instances map[string]InstanceStats
+	// CertIssuanceLatency summarizes how long the AlloyDB Admin API took to
+	// issue the Dialer's most recent client certificates, across all
+	// instances. Unlike the latency of a refresh operation, it excludes
+	// time spent on the Dialer's side, e.g., waiting on a rate limiter.
+	CertIssuanceLatency LatencyPercentiles
+	// Labels maps each label set with WithConnectionLabel to statistics
+	// about the open connections dialed with that label. Labels without
+	// open connections are omitted.
+	Labels map[string]LabelStats
+}
+
+// LatencyPercentiles summarize a latency distribution.
+type LatencyPercentiles struct {
+	// Count is the number of samples the percentiles were computed over.
+	// The percentiles are zero when Count is zero.
+	Count         int
+	P50, P95, P99 time.Duration
+}
+
+// LabelStats is a snapshot of the connections dialed with a single label.
+type LabelStats struct {
+	// OpenConnections is the number of open connections with the label.
+	OpenConnections uint64
+}
+
+// InstanceStats is a snapshot of the connections to a single instance.
+type InstanceStats struct {
+	// OpenConnections is the number of open connections to the instance.
+	OpenConnections uint64
+}
+
+// addLabelConn adds delta to the open connections of label, if set.
+func (d *Dialer) addLabelConn(label string, delta int) {
+	if label == "" {
+		return
+	}
+	d.labelMu.Lock()
+	defer d.labelMu.Unlock()
+	if d.labelConns == nil {
+		d.labelConns = make(map[string]uint64)
+	}
+	n := d.labelConns[label] + uint64(delta)
+	if n == 0 {
+		delete(d.labelConns, label)
+		return
+	}
+	d.labelConns[label] = n
+}
+
+// addInstanceConn adds delta to the Dialer's open connections to the
+// instance and returns the new count.
+func (d *Dialer) addInstanceConn(instance string, delta int) uint64 {
+	d.labelMu.Lock()
+	defer d.labelMu.Unlock()
+	if d.instanceConns == nil {
+		d.instanceConns = make(map[string]uint64)
+	}
+	n := d.instanceConns[instance] + uint64(delta)
+	if n == 0 {
+		delete(d.instanceConns, instance)
+		return 0
+	}
+	d.instanceConns[instance] = n
+	return n
+}
+
+// Stats returns a snapshot of the Dialer's open connections.
+func (d *Dialer) Stats() Stats {
+	insts := d.cachedInstances()
+	ps, n := d.certLatency.Percentiles(50, 95, 99)
+	s := Stats{
+		OpenConnections: atomic.LoadUint64(&d.openConns),
+		Instances:       make(map[string]InstanceStats, len(insts)),
+		CertIssuanceLatency: LatencyPercentiles{
+			Count: n,
+			P50:   time.Duration(ps[0]) * time.Millisecond,
+			P95:   time.Duration(ps[1]) * time.Millisecond,
+			P99:   time.Duration(ps[2]) * time.Millisecond,
+		},
+	}
+	d.labelMu.Lock()
+	for _, i := range insts {
+		s.Instances[i.URI()] = InstanceStats{
+			OpenConnections: d.instanceConns[i.String()],
+		}
+	}
+	s.Labels = make(map[string]LabelStats, len(d.labelConns))
+	for l, n := range d.labelConns {
+		s.Labels[l] = LabelStats{OpenConnections: n}
+	}
+	d.labelMu.Unlock()
+	return s
+}
+
+// RefreshFailure is a failed refresh of the information used to connect to an
+// instance.
+type RefreshFailure struct {
+	// Time is when the refresh failed.
+	Time time.Time
+	// Err is the cause of the failure. Errors from the AlloyDB Admin API
+	// may be inspected with errors.As and *googleapi.Error, e.g., to tell an
+	// exhausted quota (HTTP 429) from a deleted instance (HTTP 404).
+	Err error
+}
 
-	return newInstrumentedConn(tlsConn, func() {
-		n := atomic.AddUint64(&i.OpenConns, ^uint64(0))
-		trace.RecordOpenConnections(context.Background(), int64(n), d.dialerID, i.String())
-	}), nil
+// InstanceStatus describes the health of the background refresh of the
+// information used to connect to an instance.
+type InstanceStatus struct {
+	// LastRefresh is when the information was last refreshed successfully,
+	// or zero if it never has been.
+	LastRefresh time.Time
+	// CertExpiry is when the current client certificate expires, or zero if
+	// there is no valid certificate. Dials fail once it has passed.
+	CertExpiry time.Time
+	// ConsecutiveFailures is the number of refreshes that have failed since
+	// the last success.
+	ConsecutiveFailures int
+	// RecentFailures are up to the five most recent failed refreshes, oldest
+	// first, including failures before the last success.
+	RecentFailures []RefreshFailure
+	// CircuitOpen is true while refreshes are suspended after repeated
+	// permanent failures; see WithRefreshCircuitBreaker.
+	CircuitOpen bool
+}
+
+// InstanceStatus reports the health of the background refresh for the
+// instance, so that applications can alert on the cause of refresh failures
+// rather than on the resulting dial errors. The instance argument must be
+// an instance URI, with or without a leading slash. If the Dialer has not
+// connected to the instance, InstanceStatus returns ErrUnknownInstance.
+func (d *Dialer) InstanceStatus(instance string) (InstanceStatus, error) {
+	i, ok := d.cachedInstance(instance)
+	if !ok {
+		return InstanceStatus{}, ErrUnknownInstance
+	}
+	s := i.Status()
+	st := InstanceStatus{
+		LastRefresh:         s.LastSuccess,
+		CertExpiry:          s.Expiry,
+		ConsecutiveFailures: s.ConsecutiveFailures,
+		CircuitOpen:         s.CircuitOpen,
+	}
+	for _, f := range s.Failures {
+		st.RecentFailures = append(st.RecentFailures, RefreshFailure{Time: f.Time, Err: f.Err})
+	}
+	return st, nil
+}
+
+// ErrNoConnectionInfo is returned by ConnectionInfo for an instance whose
+// connection info has never been retrieved successfully.
+var ErrNoConnectionInfo = errors.New("alloydbconn: no connection info has been retrieved for instance")
+
+// InstanceAddress is an address of an instance.
+type InstanceAddress struct {
+	// Type is the type of the address: "PRIVATE", "PUBLIC", or "PSC".
+	Type string
+	// Addr is an IP address or, for PSC, a DNS name. IPv6 addresses are
+	// not enclosed in brackets; use net.JoinHostPort to add a port.
+	Addr string
+}
+
+// CachedConnectionInfo describes the connection info the Dialer has cached
+// for an instance.
+type CachedConnectionInfo struct {
+	// Addresses are the instance's addresses in order of preference.
+	Addresses []InstanceAddress
+	// UID is the instance UID, which identifies the server certificate. It
+	// is empty if the connection info was supplied by a ConnectionInfoCache.
+	UID string
+	// CertExpiry is when the cached client certificate expires. A process
+	// whose certificate is about to expire, e.g., within the hour, likely
+	// has a wedged refresh; see InstanceStatus for the cause.
+	CertExpiry time.Time
+	// RefreshedAt is when the connection info was retrieved, or zero if it
+	// was imported with WithImportedCache.
+	RefreshedAt time.Time
+}
+
+// ConnectionInfo returns the connection info the Dialer has cached for the
+// instance, without waiting on a refresh in progress or contacting the
+// AlloyDB Admin API. The cached info is returned even if its certificate has
+// expired. The instance argument must be an instance URI, with or without a
+// leading slash. If the Dialer has not connected to the instance,
+// ConnectionInfo returns ErrUnknownInstance; if no refresh has succeeded yet,
+// it returns ErrNoConnectionInfo.
+func (d *Dialer) ConnectionInfo(instance string) (CachedConnectionInfo, error) {
+	i, ok := d.cachedInstance(instance)
+	if !ok {
+		return CachedConnectionInfo{}, ErrUnknownInstance
+	}
+	c, ok := i.CachedInfo()
+	if !ok {
+		return CachedConnectionInfo{}, ErrNoConnectionInfo
+	}
+	return cachedConnectionInfo(c), nil
+}
+
+// cachedConnectionInfo converts an instance's cached info into its exported
+// form.
+func cachedConnectionInfo(c alloydb.CachedInfo) CachedConnectionInfo {
+	info := CachedConnectionInfo{
+		UID:         c.UID,
+		CertExpiry:  c.Expiry,
+		RefreshedAt: c.RefreshedAt,
+	}
+	for _, e := range c.Endpoints {
+		info.Addresses = append(info.Addresses, InstanceAddress{Type: e.IPType, Addr: e.Addr})
+	}
+	return info
+}
+
+// ForceRefresh invalidates the connection info the Dialer has cached for the
+// instance, including any copy persisted with WithCertCacheDir, and starts a
+// refresh immediately. Subsequent dials wait for the refresh to complete
+// rather than use the invalidated info. The Dialer invalidates an instance's
+// connection info itself when a dial to it fails, so ForceRefresh is only
+// needed when the caller learns of a change first, e.g., after recreating an
+// instance with the same name. The instance argument must be an instance URI,
+// with or without a leading slash. If the Dialer has not connected to the
+// instance, ForceRefresh returns ErrUnknownInstance.
+func (d *Dialer) ForceRefresh(instance string) error {
+	k, ok := parseInstanceKey(instance)
+	if !ok {
+		return invalidInstanceURIError(instance)
+	}
+	d.lock.RLock()
+	if d.closed {
+		d.lock.RUnlock()
+		return ErrDialerClosed
+	}
+	var insts []*alloydb.Instance
+	for _, t := range d.tenants {
+		if i, ok := t.instances[k]; ok {
+			insts = append(insts, i)
+		}
+	}
+	d.lock.RUnlock()
+	if i, ok := d.cachedInstance(instance); ok {
+		insts = append(insts, i)
+	}
+	d.removeCachedCert(k, strings.TrimPrefix(instance, "/"))
+	if len(insts) == 0 {
+		return ErrUnknownInstance
+	}
+	for _, i := range insts {
+		i.ForceRefresh()
+	}
+	return nil
+}
+
+// connectInfo retrieves the connection info for the instance. While the
+// instance has yet to complete its first refresh, the wait is bounded by the
+// cold start budget, if configured.
+func (d *Dialer) connectInfo(ctx context.Context, i *alloydb.Instance, ipType string) (string, *tls.Config, error) {
+	budget := d.currentSettings().coldStartBudget
+	if budget <= 0 || i.Warm() {
+		return i.ConnectInfo(ctx, ipType)
+	}
+	budgetCtx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+	addr, tlsCfg, err := i.ConnectInfo(budgetCtx, ipType)
+	if err != nil && ctx.Err() == nil && errors.Is(budgetCtx.Err(), context.DeadlineExceeded) {
+		// The refresh continues in the background and will be used by
+		// subsequent dials once complete.
+		return "", nil, errtype.NewWarmupError(
+			fmt.Sprintf("instance is warming up, connection info not available within %v", budget),
+			i.String(),
+			err,
+		)
+	}
+	return addr, tlsCfg, err
+}
+
+// ClusterRole describes the role of the cluster an AlloyDB instance belongs
+// to.
+type ClusterRole string
+
+const (
+	// ClusterRoleUnknown indicates the role of the cluster could not be
+	// determined or cluster role detection is disabled.
+	ClusterRoleUnknown ClusterRole = ""
+	// ClusterRolePrimary indicates the instance belongs to a primary cluster
+	// and accepts writes.
+	ClusterRolePrimary ClusterRole = alloydb.ClusterRolePrimary
+	// ClusterRoleSecondary indicates the instance belongs to a secondary
+	// cluster and is read-only until the cluster is promoted.
+	ClusterRoleSecondary ClusterRole = alloydb.ClusterRoleSecondary
+)
+
+// ClusterRole returns the role of the specified instance's cluster as of the
+// most recent refresh. Applications connecting to secondary clusters may use
+// the role to enforce read-only usage until the cluster is promoted. When a
+// change of role is observed, the connection info for the instance is
+// re-resolved immediately.
+//
+// Cluster role detection must be enabled with WithClusterRoleDetection,
+// otherwise ClusterRole always reports ClusterRoleUnknown.
+func (d *Dialer) ClusterRole(ctx context.Context, instance string) (ClusterRole, error) {
+	i, err := d.instance(instance)
+	if err != nil {
+		return ClusterRoleUnknown, err
+	}
+	r, err := i.ClusterRole(ctx)
+	if err != nil {
+		return ClusterRoleUnknown, err
+	}
+	return ClusterRole(r), nil
+}
+
+// EngineVersion is the version of an instance's database engine.
+type EngineVersion struct {
+	// DatabaseVersion is the version reported by the AlloyDB Admin API,
+	// e.g., "POSTGRES_15".
+	DatabaseVersion string
+	// Major is the PostgreSQL major version, e.g., 15, or zero if
+	// DatabaseVersion is not of the form POSTGRES_<MAJOR>.
+	Major int
+}
+
+// EngineVersion retrieves the version of the specified instance's database
+// engine from the AlloyDB Admin API, e.g., so that an ORM or migration tool
+// can adapt to the PostgreSQL major version before connecting. The version is
+// not cached, as a cluster's major version may be upgraded. EngineVersion
+// requires the alloydb.clusters.get permission and is not supported by
+// Dialers configured with WithStaticConnectionInfo.
+func (d *Dialer) EngineVersion(ctx context.Context, instance string) (EngineVersion, error) {
+	k, ok := parseInstanceKey(instance)
+	if !ok {
+		return EngineVersion{}, invalidInstanceURIError(instance)
+	}
+	var resp alloydbadmin.ClusterResponse
+	err := alloydbadmin.Retry(d.adminContext(ctx), func(ctx context.Context) error {
+		var err error
+		resp, err = d.adminAPI().Cluster(ctx, k.project, k.region, k.cluster)
+		return err
+	})
+	if err != nil {
+		return EngineVersion{}, errtype.NewRefreshError(
+			"failed to get cluster",
+			strings.Join([]string{k.project, k.region, k.cluster, k.name}, "/"), err,
+		)
+	}
+	v := EngineVersion{DatabaseVersion: resp.DatabaseVersion}
+	if s := strings.TrimPrefix(resp.DatabaseVersion, "POSTGRES_"); s != resp.DatabaseVersion {
+		v.Major, _ = strconv.Atoi(s)
+	}
+	return v, nil
+}
+
+// ConnectionInfo is the information used to connect to an instance.
+type ConnectionInfo struct {
+	// IPAddress is the instance's preferred address. IPv6 addresses are not
+	// enclosed in brackets; use net.JoinHostPort to add a port.
+	IPAddress string
+	// Addresses are the instance's addresses with their types, in order of
+	// preference. A ConnectionInfoCache that leaves Addresses empty supplies
+	// only IPAddress, which is then used as the private IP address.
+	Addresses []InstanceAddress
+	// Expiry is when the client certificate in TLSConfig expires. The
+	// information should be refreshed before then.
+	Expiry time.Time
+	// TLSConfig configures TLS connections to the instance on port 5433,
+	// including the client certificate.
+	TLSConfig *tls.Config
+}
+
+// FetchConnectionInfo retrieves the information needed to connect to the
+// instance with a single refresh operation, bypassing the Dialer's cache and
+// refresh schedule. It allows applications to run refresh operations on their
+// own schedule while the Dialer handles the AlloyDB Admin API and TLS
+// details. FetchConnectionInfo is not rate limited. A call made while a
+// refresh operation of the instance is in progress, whether started by Dial
+// or by another call to FetchConnectionInfo, shares its result rather than
+// calling the AlloyDB Admin API again, unless ctx carries request headers
+// attached with alloydbadmin.WithRequestHeaders.
+func (d *Dialer) FetchConnectionInfo(ctx context.Context, instance string) (ConnectionInfo, error) {
+	opts := []alloydb.Option{
+		alloydb.WithLogger(d.logger),
+		alloydb.WithRecorder(d.recorder),
+		alloydb.WithExecutor(d.exec),
+	}
+	// A call that carries its own request headers must send them with its
+	// own API calls, so it is not shared with other refresh operations.
+	if alloydbadmin.RequestHeaders(ctx) == nil {
+		opts = append(opts, alloydb.WithRefreshGroup(d.refreshGroup))
+	}
+	if pins, ok := d.caPins[strings.TrimPrefix(instance, "/")]; ok {
+		opts = append(opts, alloydb.WithPinnedCAs(pins...))
+	}
+	if h := d.certRefreshHook(instance); h != nil {
+		opts = append(opts, alloydb.WithCertRefreshHook(h))
+	}
+	if d.requireSAN {
+		opts = append(opts, alloydb.WithStrictSANVerification())
+	}
+	if d.skipNameCheck {
+		opts = append(opts, alloydb.WithoutServerNameVerification())
+	}
+	if d.rand != nil {
+		opts = append(opts, alloydb.WithRand(d.rand))
+	}
+	res, err := alloydb.FetchConnectInfo(
+		d.adminContext(ctx), instance, d.adminAPI(), d.key, d.refreshTimeout(instance), d.dialerID, opts...,
+	)
+	if err != nil {
+		return ConnectionInfo{}, err
+	}
+	info := ConnectionInfo{
+		Expiry:    res.Expiry,
+		TLSConfig: res.TLSConfig,
+	}
+	if len(res.Endpoints) > 0 {
+		info.IPAddress = res.Endpoints[0].Addr
+	}
+	for _, e := range res.Endpoints {
+		info.Addresses = append(info.Addresses, InstanceAddress{Type: e.IPType, Addr: e.Addr})
+	}
+	return info, nil
+}
+
+// IAMAuthN reports whether the Dialer was configured with WithIAMAuthN or
+// WithIAMAuthNTokenSource.
+func (d *Dialer) IAMAuthN() bool {
+	return d.iamAuthN
+}
+
+// IAMAuthNToken returns an OAuth2 access token to use as the password when
+// logging in to a database with IAM database authentication. The token comes
+// from the credentials set with WithIAMAuthNTokenSource, or otherwise from
+// the Dialer's credentials or Application Default Credentials. Tokens expire,
+// so callers should request a token for each new connection. IAMAuthNToken
+// returns an *errtype.ConfigError if IAM database authentication is not
+// enabled for the Dialer or any instance configured with Configure.
+func (d *Dialer) IAMAuthNToken() (string, error) {
+	if !d.iamAuthNEnabled() {
+		return "", errtype.NewConfigError("IAM database authentication is not enabled", "n/a")
+	}
+	return d.iamTokens.token()
 }
 
 // newInstrumentedConn initializes an instrumentedConn that on closing will
@@ -228,6 +1334,51 @@ func newInstrumentedConn(conn net.Conn, closeFunc func()) *instrumentedConn {
 type instrumentedConn struct {
 	net.Conn
 	closeFunc func()
+	// valid reports whether the connection is still usable. Nil means it
+	// always is.
+	valid func() bool
+	// pending holds data read by ValidateConn that has not yet been returned
+	// by Read.
+	pending []byte
+	// expiryTimer, when set, calls the handler set with
+	// WithConnExpiryHandler; it is stopped when the connection is closed.
+	expiryTimer *time.Timer
+}
+
+// connValidator returns a function that reports whether a connection
+// established to the instance now is still usable: its client certificate
+// has not expired and the role of the instance's cluster has not changed.
+func connValidator(i *alloydb.Instance) func() bool {
+	role := i.Role()
+	var expiry time.Time
+	if c, ok := i.CachedInfo(); ok {
+		expiry = c.Expiry
+	}
+	return func() bool {
+		if !expiry.IsZero() && !time.Now().Before(expiry) {
+			return false
+		}
+		if r := i.Role(); role != "" && r != "" && r != role {
+			return false
+		}
+		return true
+	}
+}
+
+// ConnValid reports whether conn, as returned by Dial, is still usable given
+// the state of its instance. A connection is no longer usable once the client
+// certificate it was established with has expired or, with
+// WithClusterRoleDetection, once the instance's cluster has changed role,
+// e.g., after a failover or switchover. Pools may use ConnValid to discard
+// such connections rather than wait for them to fail; the drivers in this
+// module do so for database/sql. ConnValid reports true for any other
+// connection.
+func ConnValid(conn net.Conn) bool {
+	c, ok := conn.(*instrumentedConn)
+	if !ok || c.valid == nil {
+		return true
+	}
+	return c.valid()
 }
 
 // Close delegates to the underylying net.Conn interface and reports the close
@@ -237,42 +1388,287 @@ func (i *instrumentedConn) Close() error {
 	if err != nil {
 		return err
 	}
-	go i.closeFunc()
+	if i.expiryTimer != nil {
+		i.expiryTimer.Stop()
+	}
+	i.closeFunc()
 	return nil
 }
 
 // Close closes the Dialer; it prevents the Dialer from refreshing the information
 // needed to connect. Additional dial operations may succeed until the information
-// expires.
+// expires. To refuse new dials and wait for open connections to close, use
+// Shutdown instead.
 func (d *Dialer) Close() error {
 	d.lock.Lock()
-	defer d.lock.Unlock()
 	for _, i := range d.instances {
 		i.Close()
 	}
+	for _, t := range d.tenants {
+		t.close()
+	}
+	children := d.children
+	d.children = nil
+	d.closePrimers()
+	d.lock.Unlock()
+	// Children remove themselves from their parent, so they are closed
+	// without holding lock.
+	for _, c := range children {
+		c.Close()
+	}
+	if d.parent != nil {
+		d.parent.removeChild(d)
+		return nil
+	}
+	if d.cache != nil {
+		return d.cache.Close()
+	}
 	return nil
 }
 
+// usePrimedConn reports whether a dial of the instance with cfg may use a
+// primed connection. Primed connections are established with the Dialer's
+// default DialOptions and settings, so a dial whose effective DialOptions or
+// IAM authentication setting differ in any way connects on its own.
+func (d *Dialer) usePrimedConn(instance string, cfg, defaults dialCfg) bool {
+	return reflect.DeepEqual(cfg, defaults) && d.IAMAuthNFor(instance) == d.iamAuthN
+}
+
+// closePrimers stops all primers and closes their connections. Callers must
+// hold lock.
+func (d *Dialer) closePrimers() {
+	for uri, p := range d.primers {
+		p.close()
+		delete(d.primers, uri)
+	}
+}
+
+// Shutdown gracefully shuts down the Dialer. Shutdown stops refreshing the
+// information needed to connect, refuses new dials with ErrDialerClosed, and
+// then waits for all connections returned by Dial to be closed. If ctx
+// expires first, Shutdown returns the context's error and leaves any
+// remaining connections open.
+func (d *Dialer) Shutdown(ctx context.Context) error {
+	d.lock.Lock()
+	d.closed = true
+	for _, i := range d.instances {
+		i.Close()
+	}
+	for _, t := range d.tenants {
+		t.close()
+	}
+	children := d.children
+	d.children = nil
+	d.closePrimers()
+	d.lock.Unlock()
+	for _, c := range children {
+		c.Close()
+	}
+	var cacheErr error
+	if d.parent != nil {
+		d.parent.removeChild(d)
+	} else if d.cache != nil {
+		cacheErr = d.cache.Close()
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if atomic.LoadUint64(&d.openConns) == 0 {
+			return cacheErr
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// isClosed reports whether Shutdown has been called.
+func (d *Dialer) isClosed() bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.closed
+}
+
+// adminContext returns ctx with the headers set with WithAdminAPIHeaders
+// attached, for Admin API calls made on behalf of the caller.
+func (d *Dialer) adminContext(ctx context.Context) context.Context {
+	if len(d.adminHeaders) == 0 {
+		return ctx
+	}
+	return alloydbadmin.WithRequestHeaders(ctx, d.adminHeaders)
+}
+
+// adminAPI returns the source of connection info used by the Dialer.
+func (d *Dialer) adminAPI() alloydb.AdminAPI {
+	if d.staticInfo != nil {
+		return d.staticInfo
+	}
+	return d.client
+}
+
 func (d *Dialer) instance(instanceURI string) (*alloydb.Instance, error) {
+	if d.parent != nil {
+		// Dialers created with Scope use their parent's cache.
+		if d.isClosed() {
+			return nil, ErrDialerClosed
+		}
+		return d.parent.instance(instanceURI)
+	}
+	k, ok := parseInstanceKey(instanceURI)
+	if !ok {
+		return nil, invalidInstanceURIError(instanceURI)
+	}
 	// Check instance cache
 	d.lock.RLock()
-	i, ok := d.instances[instanceURI]
+	i, ok := d.instances[k]
+	closed := d.closed
 	d.lock.RUnlock()
+	if closed {
+		return nil, ErrDialerClosed
+	}
 	if !ok {
 		d.lock.Lock()
+		if d.closed {
+			d.lock.Unlock()
+			return nil, ErrDialerClosed
+		}
 		// Recheck to ensure instance wasn't created between locks
-		i, ok = d.instances[instanceURI]
+		i, ok = d.instances[k]
 		if !ok {
 			// Create a new instance
 			var err error
-			i, err = alloydb.NewInstance(instanceURI, d.client, d.key, d.refreshTimeout, d.dialerID)
+			opts := d.instanceOpts(instanceURI)
+			if d.cache != nil {
+				opts = append(opts, alloydb.WithConnectInfoSource(
+					cacheSource{c: d.cache, instance: instanceURI},
+				))
+			}
+			// Imported connection info is keyed by the canonical URI,
+			// without a leading slash.
+			key := strings.TrimPrefix(instanceURI, "/")
+			if snap, ok := d.imported[key]; ok {
+				opts = append(opts, alloydb.WithSnapshot(snap))
+				delete(d.imported, key)
+			} else if snap, ok := d.loadCachedCert(k); ok {
+				opts = append(opts, alloydb.WithSnapshot(snap))
+			}
+			if d.certCacheDir != "" {
+				opts = append(opts, alloydb.WithSnapshotHandler(func(s alloydb.Snapshot) {
+					d.storeCachedCert(k, s)
+				}))
+			}
+			opts = append(opts, alloydb.WithRefreshGroup(d.refreshGroup))
+			i, err = alloydb.NewInstance(
+				instanceURI, d.adminAPI(), d.key, d.refreshTimeout(instanceURI), d.dialerID, opts...,
+			)
 			if err != nil {
 				d.lock.Unlock()
 				return nil, err
 			}
-			d.instances[instanceURI] = i
+			d.instances[k] = i
 		}
 		d.lock.Unlock()
 	}
 	return i, nil
 }
+
+// lookupInstance returns the cached instance for instanceURI. Callers must
+// hold lock.
+func (d *Dialer) lookupInstance(instanceURI string) (*alloydb.Instance, bool) {
+	k, ok := parseInstanceKey(instanceURI)
+	if !ok {
+		return nil, false
+	}
+	i, ok := d.instances[k]
+	return i, ok
+}
+
+// refreshTimeout returns the timeout of the instance's refresh operations.
+func (d *Dialer) refreshTimeout(instanceURI string) time.Duration {
+	if s, ok := d.instanceSettings(instanceURI); ok && s.refreshTimeout > 0 {
+		return s.refreshTimeout
+	}
+	if t, ok := d.refreshTimeouts[strings.TrimPrefix(instanceURI, "/")]; ok {
+		return t
+	}
+	return d.currentSettings().refreshTimeout
+}
+
+// certRefreshHook returns the instance's callback for the hook set with
+// WithCertRefreshHook, or nil if there is none.
+func (d *Dialer) certRefreshHook(instanceURI string) func(time.Time) {
+	if d.certHook == nil {
+		return nil
+	}
+	uri := strings.TrimPrefix(instanceURI, "/")
+	return func(notAfter time.Time) {
+		d.certHook(uri, notAfter)
+	}
+}
+
+// instanceOpts returns the options for a new instance that apply regardless of
+// the credentials used.
+func (d *Dialer) instanceOpts(instanceURI string) []alloydb.Option {
+	opts := []alloydb.Option{
+		alloydb.WithLogger(d.logger),
+		alloydb.WithRecorder(d.recorder),
+		alloydb.WithExecutor(d.exec),
+		alloydb.WithRefreshStrategy(d.refreshStrategy),
+	}
+	if d.detectClusterRole {
+		opts = append(opts, alloydb.WithClusterRoleDetection())
+	}
+	if d.preemptForced {
+		opts = append(opts, alloydb.WithForcedRefreshPreemption())
+	}
+	if d.serveStale {
+		opts = append(opts, alloydb.WithStaleWhileRevalidate(d.maxStaleness))
+	}
+	if h := d.certRefreshHook(instanceURI); h != nil {
+		opts = append(opts, alloydb.WithCertRefreshHook(h))
+	}
+	if d.breakerThreshold > 0 {
+		opts = append(opts, alloydb.WithCircuitBreaker(d.breakerThreshold, d.probeInterval))
+	}
+	if d.requireSAN {
+		opts = append(opts, alloydb.WithStrictSANVerification())
+	}
+	if d.skipNameCheck {
+		opts = append(opts, alloydb.WithoutServerNameVerification())
+	}
+	if d.rand != nil {
+		opts = append(opts, alloydb.WithRand(d.rand))
+	}
+	if len(d.adminHeaders) > 0 {
+		opts = append(opts, alloydb.WithRequestHeaders(d.adminHeaders))
+	}
+	// Pins and rate limits are keyed by the canonical URI, without a leading
+	// slash.
+	key := strings.TrimPrefix(instanceURI, "/")
+	if pins, ok := d.caPins[key]; ok {
+		opts = append(opts, alloydb.WithPinnedCAs(pins...))
+	}
+	if l, ok := d.instanceRateLimit(key); ok {
+		opts = append(opts, alloydb.WithRateLimit(l.interval, l.burst))
+	}
+	return opts
+}
+
+// instanceRateLimit returns the limit of the instance's refresh operations,
+// or false if the default limit applies. key is the instance URI without a
+// leading slash.
+func (d *Dialer) instanceRateLimit(key string) (rateLimit, bool) {
+	if s, ok := d.instanceSettings(key); ok && s.rateLimit != nil {
+		return *s.rateLimit, true
+	}
+	if l, ok := d.rateLimits[key]; ok {
+		return l, true
+	}
+	if d.rateLimit != nil {
+		return *d.rateLimit, true
+	}
+	return rateLimit{}, false
+}
@@ -15,18 +15,37 @@
 package alloydbconn
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
 	"cloud.google.com/go/alloydbconn/errtype"
-	"cloud.google.com/go/alloydbconn/internal/alloydbapi"
 	"cloud.google.com/go/alloydbconn/internal/mock"
 	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
@@ -52,7 +71,7 @@ func TestDialerCanConnectToInstance(t *testing.T) {
 			t.Fatalf("%v", err)
 		}
 	}()
-	c, err := alloydbapi.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
 	if err != nil {
 		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
 	}
@@ -86,7 +105,7 @@ func TestDialWithAdminAPIErrors(t *testing.T) {
 			t.Fatalf("%v", err)
 		}
 	}()
-	c, err := alloydbapi.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
 	if err != nil {
 		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
 	}
@@ -131,7 +150,7 @@ func TestDialWithConfigurationErrors(t *testing.T) {
 		mock.InstanceGetSuccess(inst, 2),
 		mock.CreateEphemeralSuccess(inst, 2),
 	)
-	c, err := alloydbapi.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
 	if err != nil {
 		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
 	}
@@ -173,7 +192,7 @@ func TestDialerWithCustomDialFunc(t *testing.T) {
 			t.Fatalf("%v", err)
 		}
 	}()
-	c, err := alloydbapi.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
 	if err != nil {
 		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
 	}
@@ -195,6 +214,150 @@ func TestDialerWithCustomDialFunc(t *testing.T) {
 	}
 }
 
+func TestDialerRetriesAtNewAddress(t *testing.T) {
+	ctx := context.Background()
+	// The instance moves from 10.0.0.1 to 127.0.0.1 between refreshes.
+	oldInst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithIPAddr("10.0.0.1"),
+	)
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(oldInst, 1),
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		addrs []string
+	)
+	d, err := NewDialer(ctx,
+		WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			addrs = append(addrs, addr)
+			mu.Unlock()
+			if addr == "10.0.0.1:5433" {
+				return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		}),
+		WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"10.0.0.1:5433", "127.0.0.1:5433"}; !reflect.DeepEqual(want, addrs) {
+		t.Fatalf("want dials to %v, got = %v", want, addrs)
+	}
+}
+
+// keepAliveSpy wraps a connection and records keep-alive settings.
+type keepAliveSpy struct {
+	net.Conn
+	mu        sync.Mutex
+	keepAlive bool
+	period    time.Duration
+}
+
+func (k *keepAliveSpy) SetKeepAlive(keepalive bool) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keepAlive = keepalive
+	return nil
+}
+
+func (k *keepAliveSpy) SetKeepAlivePeriod(d time.Duration) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.period = d
+	return nil
+}
+
+func TestDialerTCPKeepAlive(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	var spy *keepAliveSpy
+	d, err := NewDialer(ctx,
+		WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			spy = &keepAliveSpy{Conn: conn}
+			return spy, nil
+		}),
+		WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	uri := "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+	conn, err := d.Dial(ctx, uri, WithTCPKeepAlive(time.Minute))
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+	if !spy.keepAlive || spy.period != time.Minute {
+		t.Fatalf("want keep-alive enabled with period %v, got = %v, %v", time.Minute, spy.keepAlive, spy.period)
+	}
+
+	conn, err = d.Dial(ctx, uri, WithTCPKeepAlive(-1))
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+	if spy.keepAlive || spy.period != 0 {
+		t.Fatalf("want keep-alive disabled, got = %v, %v", spy.keepAlive, spy.period)
+	}
+}
+
 func TestDialerUserAgent(t *testing.T) {
 	data, err := os.ReadFile("version.txt")
 	if err != nil {
@@ -206,3 +369,2159 @@ func TestDialerUserAgent(t *testing.T) {
 		t.Errorf("embed version mismatched: want %q, got %q", want, userAgent)
 	}
 }
+
+func TestDialerWithJSONDebugLogger(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	buf := &syncBuffer{}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithJSONDebugLogger(buf),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	conn, err := d.Dial(ctx, "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+
+	events := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry struct {
+			Event    string `json:"event"`
+			Instance string `json:"instance"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("want valid JSON, got error = %v (%q)", err, line)
+		}
+		if entry.Instance == "" {
+			t.Errorf("want instance field, got line = %q", line)
+		}
+		events[entry.Event] = true
+	}
+	for _, want := range []string{"refresh", "dial"} {
+		if !events[want] {
+			t.Errorf("want %q event, got = %v", want, buf.String())
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDialerClusterRole(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithClusterType("SECONDARY"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+		mock.ClusterGetSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithClusterRoleDetection(),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	got, err := d.ClusterRole(ctx, "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
+	if err != nil {
+		t.Fatalf("expected ClusterRole to succeed, but got error: %v", err)
+	}
+	if got != ClusterRoleSecondary {
+		t.Fatalf("want = %v, got = %v", ClusterRoleSecondary, got)
+	}
+}
+
+func TestDialerEngineVersion(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithDatabaseVersion("POSTGRES_16"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.ClusterGetSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	got, err := d.EngineVersion(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected EngineVersion to succeed, but got error: %v", err)
+	}
+	want := EngineVersion{DatabaseVersion: "POSTGRES_16", Major: 16}
+	if got != want {
+		t.Fatalf("want = %+v, got = %+v", want, got)
+	}
+	var cErr *errtype.ConfigError
+	if _, err := d.EngineVersion(ctx, "bad-uri"); !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+}
+
+// headerTransport records the headers of each request it sends.
+type headerTransport struct {
+	mu      sync.Mutex
+	headers map[string][]string
+	rt      http.RoundTripper
+}
+
+func (h *headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	h.mu.Lock()
+	h.headers[r.URL.Path] = r.Header.Values("X-Goog-Request-Reason")
+	h.mu.Unlock()
+	return h.rt.RoundTrip(r)
+}
+
+func TestDialerWithAdminAPIHeaders(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	ht := &headerTransport{headers: make(map[string][]string), rt: mc.Transport}
+	mc.Transport = ht
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithAdminAPIHeaders(http.Header{"X-Goog-Request-Reason": {"payments"}}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	// Background refresh operations carry the Dialer's headers.
+	if err := d.Warmup(ctx, testInstanceURI); err != nil {
+		t.Fatalf("expected Warmup to succeed, but got error: %v", err)
+	}
+	certPath := "/projects/my-project/locations/my-region/clusters/my-cluster:generateClientCertificate"
+	ht.mu.Lock()
+	got := ht.headers[certPath]
+	ht.mu.Unlock()
+	if want := []string{"payments"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want refresh headers = %v, got = %v", want, got)
+	}
+
+	// Calls on behalf of a caller also carry the headers of its context.
+	callCtx := alloydbadmin.WithRequestHeaders(ctx, http.Header{"X-Goog-Request-Reason": {"audit-123"}})
+	if _, err := d.FetchConnectionInfo(callCtx, testInstanceURI); err != nil {
+		t.Fatalf("expected FetchConnectionInfo to succeed, but got error: %v", err)
+	}
+	ht.mu.Lock()
+	got = ht.headers[certPath]
+	ht.mu.Unlock()
+	if want := []string{"audit-123", "payments"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want call headers = %v, got = %v", want, got)
+	}
+}
+
+// delayedTransport blocks all requests until release is closed.
+type delayedTransport struct {
+	release chan struct{}
+	rt      http.RoundTripper
+}
+
+func (d delayedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	<-d.release
+	return d.rt.RoundTrip(r)
+}
+
+// countingTransport counts the requests sent for each path.
+type countingTransport struct {
+	mu     sync.Mutex
+	counts map[string]int
+	rt     http.RoundTripper
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.counts[r.URL.Path]++
+	c.mu.Unlock()
+	return c.rt.RoundTrip(r)
+}
+
+func TestDialerConcurrentFirstDialsShareRefresh(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	// Hold the API calls until every caller is waiting on them.
+	release := make(chan struct{})
+	ct := &countingTransport{
+		counts: make(map[string]int),
+		rt:     delayedTransport{release: release, rt: mc.Transport},
+	}
+	mc.Transport = ct
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*n)
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			conn, err := d.Dial(ctx, testInstanceURI)
+			if err != nil {
+				errs <- err
+				return
+			}
+			conn.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := d.FetchConnectionInfo(ctx, testInstanceURI); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("want every caller to succeed, got error: %v", err)
+	}
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	for path, n := range ct.counts {
+		if n != 1 {
+			t.Errorf("want 1 call to %v, got = %v", path, n)
+		}
+	}
+	if len(ct.counts) != 2 {
+		t.Errorf("want a metadata call and a certificate call, got = %v", ct.counts)
+	}
+}
+
+func TestDialerFetchConnectionInfoDoesNotWaitOnRateLimit(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRateLimiter(time.Hour, 1),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if err := d.Warmup(ctx, testInstanceURI); err != nil {
+		t.Fatalf("expected Warmup to succeed, but got error: %v", err)
+	}
+	// The forced refresh waits on the instance's rate limiter for an hour.
+	if err := d.ForceRefresh(testInstanceURI); err != nil {
+		t.Fatalf("expected ForceRefresh to succeed, but got error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := d.FetchConnectionInfo(ctx, testInstanceURI); err != nil {
+		t.Fatalf("want FetchConnectionInfo not to wait on the rate limiter, got error: %v", err)
+	}
+}
+
+func TestDialerFetchConnectionInfoWithHeadersDoesNotShareRefresh(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	release := make(chan struct{})
+	ct := &countingTransport{
+		counts: make(map[string]int),
+		rt:     delayedTransport{release: release, rt: mc.Transport},
+	}
+	mc.Transport = ct
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	// Start a refresh and hold its API calls.
+	warm := make(chan error, 1)
+	go func() { warm <- d.Warmup(ctx, testInstanceURI) }()
+	for {
+		ct.mu.Lock()
+		n := len(ct.counts)
+		ct.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// A call with its own headers makes its own API calls.
+	fetched := make(chan error, 1)
+	go func() {
+		callCtx := alloydbadmin.WithRequestHeaders(ctx, http.Header{"X-Goog-Request-Reason": {"audit-123"}})
+		_, err := d.FetchConnectionInfo(callCtx, testInstanceURI)
+		fetched <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	if err := <-warm; err != nil {
+		t.Fatalf("expected Warmup to succeed, but got error: %v", err)
+	}
+	if err := <-fetched; err != nil {
+		t.Fatalf("expected FetchConnectionInfo to succeed, but got error: %v", err)
+	}
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	for path, n := range ct.counts {
+		if n != 2 {
+			t.Errorf("want 2 calls to %v, got = %v", path, n)
+		}
+	}
+}
+
+func TestDialerColdStartBudget(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	release := make(chan struct{})
+	mc.Transport = delayedTransport{release: release, rt: mc.Transport}
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithColdStartBudget(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	uri := "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+	_, err = d.Dial(ctx, uri)
+	var wantErr *errtype.WarmupError
+	if !errors.As(err, &wantErr) {
+		t.Fatalf("when first refresh exceeds budget, want = %T, got = %v", wantErr, err)
+	}
+
+	// let the background refresh complete
+	close(release)
+	i, err := d.instance(uri)
+	if err != nil {
+		t.Fatalf("expected instance to succeed, but got error: %v", err)
+	}
+	for start := time.Now(); !i.Warm(); {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("instance did not complete refresh in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn, err := d.Dial(ctx, uri)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+}
+
+func TestDialerShutdown(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	uri := "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+	conn, err := d.Dial(ctx, uri)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+
+	// Shutdown waits on the open connection until the context expires
+	sctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(sctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("with an open connection, want = %v, got = %v", context.DeadlineExceeded, err)
+	}
+
+	if _, err := d.Dial(ctx, uri); !errors.Is(err, ErrDialerClosed) {
+		t.Fatalf("after Shutdown, want = %v, got = %v", ErrDialerClosed, err)
+	}
+
+	conn.Close()
+	sctx2, cancel2 := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel2()
+	if err := d.Shutdown(sctx2); err != nil {
+		t.Fatalf("after closing all connections, want no error, got = %v", err)
+	}
+}
+
+func TestDialerMaxConnections(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithMaxConnections(1),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	uri := "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+	conn, err := d.Dial(ctx, uri)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	got := d.Stats()
+	if got.OpenConnections != 1 || got.Instances[uri].OpenConnections != 1 {
+		t.Fatalf("with one open connection, got stats = %+v", got)
+	}
+	if l := got.CertIssuanceLatency; l.Count != 1 || l.P50 != l.P99 {
+		t.Fatalf("after one refresh, want one certificate issuance latency, got = %+v", l)
+	}
+
+	_, err = d.Dial(ctx, uri)
+	var wantErr *errtype.ConnectionLimitError
+	if !errors.As(err, &wantErr) {
+		t.Fatalf("when at the limit, want = %T, got = %v", wantErr, err)
+	}
+
+	conn.Close()
+	got = d.Stats()
+	if got.OpenConnections != 0 || got.Instances[uri].OpenConnections != 0 {
+		t.Fatalf("after closing the connection, got stats = %+v", got)
+	}
+	conn, err = d.Dial(ctx, uri)
+	if err != nil {
+		t.Fatalf("after closing a connection, want Dial to succeed, got error: %v", err)
+	}
+	conn.Close()
+}
+
+func TestDialerApplyOptions(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	uri := "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+	conn, err := d.Dial(ctx, uri)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+
+	if err := d.ApplyOptions(
+		WithMaxConnections(1),
+		WithCredentialsFile("missing.json"),
+	); err == nil {
+		t.Fatal("want ApplyOptions to fail with an invalid option, got nil")
+	}
+	// Nothing was applied, so there is still no connection limit.
+	conn2, err := d.Dial(ctx, uri)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn2.Close()
+
+	var buf bytes.Buffer
+	if err := d.ApplyOptions(
+		WithMaxConnections(1),
+		WithJSONDebugLogger(&buf),
+		WithDefaultDialOptions(WithTCPKeepAlive(time.Minute)),
+	); err != nil {
+		t.Fatalf("expected ApplyOptions to succeed, got error: %v", err)
+	}
+	if got := d.currentSettings().defaultDialCfg.tcpKeepAlive; got != time.Minute {
+		t.Fatalf("default TCP keep-alive: want = %v, got = %v", time.Minute, got)
+	}
+	_, err = d.Dial(ctx, uri)
+	var wantErr *errtype.ConnectionLimitError
+	if !errors.As(err, &wantErr) {
+		t.Fatalf("when at the new limit, want = %T, got = %v", wantErr, err)
+	}
+	if !strings.Contains(buf.String(), `"event":"dial"`) {
+		t.Fatalf("want dial event logged to the new logger, got = %q", buf.String())
+	}
+}
+
+// countingExecutor runs functions on new goroutines and counts them.
+type countingExecutor struct {
+	n int64
+}
+
+func (e *countingExecutor) Go(f func()) {
+	atomic.AddInt64(&e.n, 1)
+	go f()
+}
+
+func TestDialerWithExecutor(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	exec := &countingExecutor{}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithExecutor(exec))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	// The refresh fetches metadata and a certificate.
+	if got := atomic.LoadInt64(&exec.n); got < 2 {
+		t.Fatalf("want at least 2 functions run on the executor, got = %v", got)
+	}
+}
+
+func TestWithTelemetryQueueErrors(t *testing.T) {
+	for _, opt := range []Option{
+		WithTelemetryQueue(0, 0),
+		WithTelemetryQueue(10, -time.Second),
+	} {
+		_, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}), opt)
+		var cErr *errtype.ConfigError
+		if !errors.As(err, &cErr) {
+			t.Errorf("want = %T, got = %v", cErr, err)
+		}
+	}
+}
+
+func TestDialerWithImpersonatedCredentials(t *testing.T) {
+	d, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithImpersonatedCredentials(
+			"target@my-project.iam.gserviceaccount.com",
+			"delegate@my-project.iam.gserviceaccount.com",
+		),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.Close()
+}
+
+// hostRecorder records the host of each request and fails it.
+type hostRecorder struct {
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (h *hostRecorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hosts = append(h.hosts, r.URL.Host)
+	return nil, errors.New("request not sent")
+}
+
+func (h *hostRecorder) recorded() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.hosts...)
+}
+
+func TestDialerWithUniverseDomain(t *testing.T) {
+	tcs := []struct {
+		desc string
+		opts []Option
+		want string
+	}{
+		{
+			desc: "with a universe domain",
+			opts: []Option{WithUniverseDomain("example-universe.com")},
+			want: "alloydb.example-universe.com",
+		},
+		{
+			desc: "with a universe domain and an Admin API endpoint",
+			opts: []Option{
+				WithAdminAPIEndpoint("https://alloydb.private.example.com/v1beta"),
+				WithUniverseDomain("example-universe.com"),
+			},
+			want: "alloydb.private.example.com",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			rec := &hostRecorder{}
+			opts := append([]Option{
+				WithHTTPClient(&http.Client{Transport: rec}),
+			}, tc.opts...)
+			d, err := NewDialer(ctx, opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+
+			_, err = d.Dial(ctx, "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
+			if err == nil {
+				t.Fatal("want Dial to fail, got no error")
+			}
+			hosts := rec.recorded()
+			if len(hosts) == 0 {
+				t.Fatal("want Admin API requests, got none")
+			}
+			for _, h := range hosts {
+				if h != tc.want {
+					t.Fatalf("want host = %v, got = %v", tc.want, h)
+				}
+			}
+		})
+	}
+}
+
+func TestDialerFetchConnectionInfo(t *testing.T) {
+	ctx := context.Background()
+	wantExpiry := time.Now().Add(time.Hour).UTC().Round(time.Second)
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithIPAddr("127.0.0.1"),
+		mock.WithCertExpiry(wantExpiry),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	uri := "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+	// Each call performs its own refresh operation.
+	for n := 0; n < 2; n++ {
+		info, err := d.FetchConnectionInfo(ctx, uri)
+		if err != nil {
+			t.Fatalf("expected FetchConnectionInfo to succeed, but got error: %v", err)
+		}
+		if info.IPAddress != "127.0.0.1" {
+			t.Fatalf("want IP address = 127.0.0.1, got = %v", info.IPAddress)
+		}
+		if len(info.Addresses) == 0 || info.Addresses[0] != (InstanceAddress{Type: "PRIVATE", Addr: "127.0.0.1"}) {
+			t.Fatalf("want the private IP address first, got = %v", info.Addresses)
+		}
+		if !info.Expiry.Equal(wantExpiry) {
+			t.Fatalf("want expiry = %v, got = %v", wantExpiry, info.Expiry)
+		}
+
+		conn, err := tls.Dial("tcp", net.JoinHostPort(info.IPAddress, "5433"), info.TLSConfig)
+		if err != nil {
+			t.Fatalf("expected TLS dial with fetched info to succeed, but got error: %v", err)
+		}
+		conn.Close()
+	}
+}
+
+func TestDialerWithStaticConnectionInfo(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer stop()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	doc, err := mock.StaticConnectionInfo(inst, key)
+	if err != nil {
+		t.Fatalf("failed to create static connection info: %v", err)
+	}
+
+	// No credentials are configured, as the Admin API is not used.
+	d, err := NewDialer(ctx, WithStaticConnectionInfo(bytes.NewReader(doc)))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, "projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+
+	_, err = d.Dial(ctx, "projects/my-project/locations/my-region/clusters/my-cluster/instances/other")
+	if err == nil {
+		t.Fatal("want Dial to an unknown instance to fail, got no error")
+	}
+}
+
+func TestDialerWithInvalidStaticConnectionInfo(t *testing.T) {
+	_, err := NewDialer(context.Background(),
+		WithStaticConnectionInfo(strings.NewReader(`{"privateKey": "not a key"}`)),
+	)
+	var wantErr *errtype.ConfigError
+	if !errors.As(err, &wantErr) {
+		t.Fatalf("want = %T, got = %v", wantErr, err)
+	}
+}
+
+type spyRefreshStrategy struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *spyRefreshStrategy) NextRefresh(now, certExpiry time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	return time.Hour
+}
+
+func (s *spyRefreshStrategy) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func TestDialerWithRefreshStrategy(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	spy := &spyRefreshStrategy{}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRefreshStrategy(spy),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	// the next refresh is scheduled after the first refresh completes
+	for start := time.Now(); spy.count() == 0; {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("want refresh strategy to be called, but it was not")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type spyDebugLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (s *spyDebugLogger) Debugf(format string, args ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, fmt.Sprintf(format, args...))
+}
+
+func (s *spyDebugLogger) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.lines, "\n")
+}
+
+func TestDialerWithDebugLogger(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	spy := &spyDebugLogger{}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithDebugLogger(spy),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	conn, err := d.Dial(ctx, "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+
+	got := spy.String()
+	for _, want := range []string{
+		"refresh scheduled",
+		"refresh succeeded",
+		"cert expiry",
+		"dialing 127.0.0.1:5433",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want debug logs to contain %q, got = %v", want, got)
+		}
+	}
+}
+
+func TestDialerWithPinnedCA(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	pemCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: inst.RootCA().Raw})
+	// The fingerprint of some other certificate.
+	fp := sha256.Sum256([]byte("not the root CA"))
+	tcs := []struct {
+		desc    string
+		opt     Option
+		wantErr bool
+	}{
+		{
+			desc: "matching PEM",
+			opt:  WithPinnedCAPEM(testInstanceURI, pemCA),
+		},
+		{
+			desc:    "mismatched fingerprint",
+			opt:     WithPinnedCAFingerprint(testInstanceURI, hex.EncodeToString(fp[:])),
+			wantErr: true,
+		},
+	}
+	stop := mock.StartServerProxy(t, inst)
+	defer stop()
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				mock.CreateEphemeralSuccess(inst, 1),
+			)
+			defer cleanup()
+			c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), tc.opt)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
+
+			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			conn, err := d.Dial(ctx, testInstanceURI)
+			if tc.wantErr {
+				var rErr *errtype.RefreshError
+				if !errors.As(err, &rErr) {
+					t.Fatalf("want = %T, got = %v", rErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected Dial to succeed, but got error: %v", err)
+			}
+			conn.Close()
+		})
+	}
+}
+
+func TestDialerServerSANVerification(t *testing.T) {
+	ctx := context.Background()
+	tcs := []struct {
+		desc    string
+		sans    []string
+		cn      string
+		strict  bool
+		optOut  bool
+		wantErr bool
+	}{
+		{
+			desc: "CN without SANs",
+		},
+		{
+			desc:    "CN without SANs in strict mode",
+			strict:  true,
+			wantErr: true,
+		},
+		{
+			desc:   "SAN matching server name",
+			sans:   []string{"00000000-0000-0000-0000-000000000000.server.alloydb"},
+			strict: true,
+		},
+		{
+			desc: "SAN matching IP address",
+			sans: []string{"127.0.0.1"},
+		},
+		{
+			desc:    "mismatched SAN with matching CN",
+			sans:    []string{"other.example.com"},
+			wantErr: true,
+		},
+		{
+			desc:    "mismatched CN",
+			cn:      "other.server.alloydb",
+			wantErr: true,
+		},
+		{
+			desc:   "mismatched SAN with opt-out",
+			sans:   []string{"other.example.com"},
+			optOut: true,
+		},
+		{
+			desc:   "mismatched CN with opt-out",
+			cn:     "other.server.alloydb",
+			optOut: true,
+		},
+		{
+			desc:   "CN without SANs in strict mode with opt-out",
+			strict: true,
+			optOut: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			instOpts := []mock.Option{mock.WithServerSANs(tc.sans...)}
+			if tc.cn != "" {
+				instOpts = append(instOpts, mock.WithServerName(tc.cn))
+			}
+			inst := mock.NewFakeInstance(
+				"my-project", "my-region", "my-cluster", "my-instance",
+				instOpts...,
+			)
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				mock.CreateEphemeralSuccess(inst, 1),
+			)
+			defer cleanup()
+			stop := mock.StartServerProxy(t, inst)
+			defer stop()
+			c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			opts := []Option{WithTokenSource(stubTokenSource{})}
+			if tc.strict {
+				opts = append(opts, WithStrictSANVerification())
+			}
+			if tc.optOut {
+				opts = append(opts, WithOptOutOfAdvancedConnectionCheck())
+			}
+			d, err := NewDialer(ctx, opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
+
+			conn, err := d.Dial(ctx, testInstanceURI)
+			if tc.wantErr {
+				var dErr *errtype.DialError
+				if !errors.As(err, &dErr) {
+					t.Fatalf("want = %T, got = %v", dErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected Dial to succeed, but got error: %v", err)
+			}
+			conn.Close()
+		})
+	}
+}
+
+// spySigner is a crypto.Signer that counts its signatures and, unlike an
+// *rsa.PrivateKey, does not expose its key.
+type spySigner struct {
+	k *rsa.PrivateKey
+	n int64
+}
+
+func (s *spySigner) Public() crypto.PublicKey { return s.k.Public() }
+
+func (s *spySigner) Sign(r io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	atomic.AddInt64(&s.n, 1)
+	return s.k.Sign(r, digest, opts)
+}
+
+func TestDialerWithClientSigner(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	s := &spySigner{k: key}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithClientSigner(s))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	// The signer signs the certificate request and the TLS handshake.
+	if got := atomic.LoadInt64(&s.n); got < 2 {
+		t.Fatalf("want at least 2 signatures, got = %v", got)
+	}
+	b, err := d.ExportCache()
+	if err != nil {
+		t.Fatalf("expected ExportCache to succeed, but got error: %v", err)
+	}
+	var exp exportedCache
+	if err := json.Unmarshal(b, &exp); err != nil {
+		t.Fatalf("failed to decode exported cache: %v", err)
+	}
+	if len(exp.Instances) != 0 {
+		t.Fatalf("want no exported instances, got = %v", len(exp.Instances))
+	}
+}
+
+func TestWithClientSignerErrors(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	_, err = NewDialer(context.Background(), WithTokenSource(stubTokenSource{}), WithClientSigner(key))
+	var cErr *errtype.ConfigError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+}
+
+func TestPinnedCAErrors(t *testing.T) {
+	tcs := []struct {
+		desc string
+		opt  Option
+	}{
+		{
+			desc: "malformed fingerprint",
+			opt:  WithPinnedCAFingerprint(testInstanceURI, "not-hex"),
+		},
+		{
+			desc: "short fingerprint",
+			opt:  WithPinnedCAFingerprint(testInstanceURI, "AB:CD"),
+		},
+		{
+			desc: "no PEM certificates",
+			opt:  WithPinnedCAPEM(testInstanceURI, []byte("garbage")),
+		},
+		{
+			desc: "invalid instance URI",
+			opt:  WithPinnedCAFingerprint("my-instance", strings.Repeat("00", sha256.Size)),
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}), tc.opt)
+			var cErr *errtype.ConfigError
+			if !errors.As(err, &cErr) {
+				t.Fatalf("want = %T, got = %v", cErr, err)
+			}
+		})
+	}
+}
+
+func TestDialerWithRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 4),
+		mock.CreateEphemeralSuccess(inst, 4),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		// The dialer-wide limit would block the second refresh for an
+		// hour, were it not overridden for the instance.
+		WithRateLimiter(time.Hour, 1),
+		WithInstanceRateLimiter(testInstanceURI, 0, 0),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	for n := 0; n < 4; n++ {
+		if n > 0 {
+			i, err := d.instance(testInstanceURI)
+			if err != nil {
+				t.Fatalf("expected instance to succeed, but got error: %v", err)
+			}
+			i.ForceRefresh()
+		}
+		conn, err := d.Dial(ctx, testInstanceURI)
+		if err != nil {
+			t.Fatalf("dial %v: expected Dial to succeed, but got error: %v", n, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestRateLimiterErrors(t *testing.T) {
+	tcs := []struct {
+		desc string
+		opt  Option
+	}{
+		{
+			desc: "negative interval",
+			opt:  WithRateLimiter(-time.Second, 1),
+		},
+		{
+			desc: "zero burst",
+			opt:  WithRateLimiter(time.Second, 0),
+		},
+		{
+			desc: "invalid instance URI",
+			opt:  WithInstanceRateLimiter("my-instance", time.Second, 1),
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}), tc.opt)
+			var cErr *errtype.ConfigError
+			if !errors.As(err, &cErr) {
+				t.Fatalf("want = %T, got = %v", cErr, err)
+			}
+		})
+	}
+}
+
+func TestDialerInstanceStatus(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if _, err := d.InstanceStatus(testInstanceURI); !errors.Is(err, ErrUnknownInstance) {
+		t.Fatalf("before Dial, want = %v, got = %v", ErrUnknownInstance, err)
+	}
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	s, err := d.InstanceStatus(testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected InstanceStatus to succeed, but got error: %v", err)
+	}
+	if s.LastRefresh.IsZero() || s.CertExpiry.IsZero() {
+		t.Fatalf("want refresh and cert expiry times, got = %+v", s)
+	}
+	if s.ConsecutiveFailures != 0 || len(s.RecentFailures) != 0 {
+		t.Fatalf("want no failures, got = %+v", s)
+	}
+}
+
+func TestDialerConnectionInfo(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithPublicIPAddr("34.0.0.1"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if _, err := d.ConnectionInfo(testInstanceURI); !errors.Is(err, ErrUnknownInstance) {
+		t.Fatalf("before Dial, want = %v, got = %v", ErrUnknownInstance, err)
+	}
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	info, err := d.ConnectionInfo(testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected ConnectionInfo to succeed, but got error: %v", err)
+	}
+	wantAddrs := []InstanceAddress{
+		{Type: "PRIVATE", Addr: "127.0.0.1"},
+		{Type: "PUBLIC", Addr: "34.0.0.1"},
+	}
+	if !reflect.DeepEqual(wantAddrs, info.Addresses) {
+		t.Fatalf("want addresses = %v, got = %v", wantAddrs, info.Addresses)
+	}
+	if info.UID != "00000000-0000-0000-0000-000000000000" {
+		t.Fatalf("want instance UID, got = %q", info.UID)
+	}
+	if !info.CertExpiry.After(time.Now()) || info.RefreshedAt.IsZero() {
+		t.Fatalf("want cert expiry and refresh time, got = %+v", info)
+	}
+}
+
+func TestDialerConnectionInfoBeforeRefresh(t *testing.T) {
+	ctx := context.Background()
+	// The mock rejects every request as unimplemented.
+	mc, url, cleanup := mock.HTTPClient()
+	defer cleanup()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if _, err := d.instance(testInstanceURI); err != nil {
+		t.Fatalf("expected instance to succeed, but got error: %v", err)
+	}
+	if _, err := d.ConnectionInfo(testInstanceURI); !errors.Is(err, ErrNoConnectionInfo) {
+		t.Fatalf("want = %v, got = %v", ErrNoConnectionInfo, err)
+	}
+}
+
+func TestConnValid(t *testing.T) {
+	ctx := context.Background()
+	// The client certificate expires shortly, and refreshes keep issuing
+	// certificates that do.
+	expiry := time.Now().Add(2 * time.Second)
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithCertExpiry(expiry),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, mock.Unlimited),
+		mock.CreateEphemeralSuccess(inst, mock.Unlimited),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		cleanup()
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRateLimiter(0, 0))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	if !ConnValid(conn) {
+		t.Fatal("want new connection to be valid")
+	}
+	time.Sleep(time.Until(expiry))
+	if ConnValid(conn) {
+		t.Fatal("want connection with expired certificate to be invalid")
+	}
+
+	other, _ := net.Pipe()
+	defer other.Close()
+	if !ConnValid(other) {
+		t.Fatal("want connection not returned by Dial to be valid")
+	}
+}
+
+func TestDialerInstanceStatusFailures(t *testing.T) {
+	ctx := context.Background()
+	// The mock rejects every request as unimplemented.
+	mc, url, cleanup := mock.HTTPClient()
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if _, err := d.Dial(ctx, testInstanceURI); err == nil {
+		t.Fatal("want Dial to fail, got nil")
+	}
+	s, err := d.InstanceStatus(testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected InstanceStatus to succeed, but got error: %v", err)
+	}
+	if !s.LastRefresh.IsZero() || !s.CertExpiry.IsZero() {
+		t.Fatalf("want no successful refresh, got = %+v", s)
+	}
+	if s.ConsecutiveFailures < 1 || len(s.RecentFailures) < 1 {
+		t.Fatalf("want at least one failure, got = %+v", s)
+	}
+	var gErr *googleapi.Error
+	if !errors.As(s.RecentFailures[0].Err, &gErr) || gErr.Code != http.StatusNotImplemented {
+		t.Fatalf("want Admin API error with code %v, got = %v", http.StatusNotImplemented, s.RecentFailures[0].Err)
+	}
+}
+
+func TestDialerIPv6(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	ln.Close()
+
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithIPAddr("::1"),
+		mock.WithServerSANs("::1"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	var (
+		mu    sync.Mutex
+		addrs []string
+	)
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithStrictSANVerification(),
+		WithDialEventHandler(func(e DialEvent) {
+			if e.Type == DialAttempt {
+				mu.Lock()
+				defer mu.Unlock()
+				addrs = append(addrs, e.Addr)
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"[::1]:5433"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("want dial attempts = %v, got = %v", want, addrs)
+	}
+	info, err := d.ConnectionInfo(testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected ConnectionInfo to succeed, but got error: %v", err)
+	}
+	if got := info.Addresses[0].Addr; got != "::1" {
+		t.Errorf("want address ::1, got = %v", got)
+	}
+}
+
+func TestDialerWithDialTimeout(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// A failed handshake forces a refresh.
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, mock.Unlimited),
+		mock.CreateEphemeralSuccess(inst, mock.Unlimited),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	// The server accepts the connection but never completes the handshake.
+	d, err := NewDialer(ctx,
+		WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			client, server := net.Pipe()
+			t.Cleanup(func() { server.Close() })
+			return client, nil
+		}),
+		WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	start := time.Now()
+	_, err = d.Dial(ctx, testInstanceURI, WithDialTimeout(100*time.Millisecond))
+	var wantErr *errtype.DialError
+	if !errors.As(err, &wantErr) {
+		t.Fatalf("want = %T, got = %v", wantErr, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("want Dial to time out, took %v", elapsed)
+	}
+}
+
+func TestDialerInstanceRefreshTimeout(t *testing.T) {
+	other := "projects/my-project/locations/my-region/clusters/my-cluster/instances/other"
+	d, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithRefreshTimeout(10*time.Second),
+		WithInstanceRefreshTimeout(testInstanceURI, time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	for uri, want := range map[string]time.Duration{
+		testInstanceURI:       time.Minute,
+		"/" + testInstanceURI: time.Minute,
+		other:                 10 * time.Second,
+	} {
+		if got := d.refreshTimeout(uri); got != want {
+			t.Errorf("%v: want = %v, got = %v", uri, want, got)
+		}
+	}
+
+	for _, opt := range []Option{
+		WithInstanceRefreshTimeout("bad-uri", time.Minute),
+		WithInstanceRefreshTimeout(testInstanceURI, 0),
+	} {
+		_, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}), opt)
+		var cErr *errtype.ConfigError
+		if !errors.As(err, &cErr) {
+			t.Errorf("want = %T, got = %v", cErr, err)
+		}
+	}
+}
+
+// countingReader counts the bytes read from an entropy source.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func TestDialerWithRandReader(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	r := &countingReader{r: rand.Reader}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRandReader(r),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+	if k, _ := getDefaultKeys(); d.key == crypto.Signer(k) {
+		t.Fatal("want Dialer to generate its own key, got the shared default key")
+	}
+
+	before := atomic.LoadInt64(&r.n)
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+	if got := atomic.LoadInt64(&r.n); got <= before {
+		t.Fatal("want the TLS handshake to read from the entropy source")
+	}
+}
+
+func TestDialerForceRefresh(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if err := d.ForceRefresh(testInstanceURI); !errors.Is(err, ErrUnknownInstance) {
+		t.Fatalf("before Dial, want = %v, got = %v", ErrUnknownInstance, err)
+	}
+	var cErr *errtype.ConfigError
+	if err := d.ForceRefresh("bad-uri"); !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+	before, err := d.ConnectionInfo(testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected ConnectionInfo to succeed, but got error: %v", err)
+	}
+
+	if err := d.ForceRefresh("/" + testInstanceURI); err != nil {
+		t.Fatalf("expected ForceRefresh to succeed, but got error: %v", err)
+	}
+	// The next dial waits on the forced refresh.
+	conn, err = d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+	after, err := d.ConnectionInfo(testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected ConnectionInfo to succeed, but got error: %v", err)
+	}
+	if !after.RefreshedAt.After(before.RefreshedAt) {
+		t.Fatalf("want connection info refreshed after %v, got = %v", before.RefreshedAt, after.RefreshedAt)
+	}
+}
+
+func TestDialerWithConnectionLabel(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	buf := &syncBuffer{}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithJSONDebugLogger(buf),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	const label = "pool=payments-readonly"
+	var conns []net.Conn
+	for n := 0; n < 2; n++ {
+		conn, err := d.Dial(ctx, testInstanceURI, WithConnectionLabel(label))
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	want := map[string]LabelStats{label: {OpenConnections: 2}}
+	if got := d.Stats().Labels; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want labels = %v, got = %v", want, got)
+	}
+	for _, c := range conns {
+		c.Close()
+	}
+	if got := d.Stats().Labels; len(got) != 0 {
+		t.Fatalf("want no labels after close, got = %v", got)
+	}
+
+	labeled := make(map[string]int)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry struct {
+			Event string `json:"event"`
+			Label string `json:"label"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("want valid JSON, got error = %v (%q)", err, line)
+		}
+		if entry.Label == label {
+			labeled[entry.Event]++
+		}
+	}
+	for _, e := range []string{"dial", "conn_closed"} {
+		if labeled[e] != 2 {
+			t.Errorf("want 2 labeled %q events, got = %v", e, labeled[e])
+		}
+	}
+}
+
+// headerRecorder records a header of each request.
+type headerRecorder struct {
+	base   http.RoundTripper
+	header string
+
+	mu     sync.Mutex
+	values []string
+}
+
+func (h *headerRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.mu.Lock()
+	h.values = append(h.values, req.Header.Get(h.header))
+	h.mu.Unlock()
+	return h.base.RoundTrip(req)
+}
+
+func TestDialerWithQuotaProject(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	// The recorder sees requests after the quota project is set.
+	rec := &headerRecorder{base: mc.Transport, header: "X-Goog-User-Project"}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithHTTPClient(&http.Client{Transport: rec}),
+		WithAdminAPIEndpoint(url),
+		WithQuotaProject("shared-quota-project"),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.values) == 0 {
+		t.Fatal("want Admin API requests, got none")
+	}
+	for _, v := range rec.values {
+		if v != "shared-quota-project" {
+			t.Fatalf("want quota project on every request, got = %v", rec.values)
+		}
+	}
+}
+
+func TestWithQuotaProjectErrors(t *testing.T) {
+	_, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithQuotaProject(""),
+	)
+	var cErr *errtype.ConfigError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+}
+
+func TestDialerWithCertRefreshHook(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithCertRefreshHook(func(instance string, notAfter time.Time) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !notAfter.After(time.Now()) {
+				t.Errorf("want certificate expiry in the future, got = %v", notAfter)
+			}
+			calls = append(calls, instance)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if err := d.Warmup(ctx, "/"+testInstanceURI); err != nil {
+		t.Fatalf("expected Warmup to succeed, but got error: %v", err)
+	}
+	if _, err := d.FetchConnectionInfo(ctx, testInstanceURI); err != nil {
+		t.Fatalf("expected FetchConnectionInfo to succeed, but got error: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{testInstanceURI, testInstanceURI}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("want = %v, got = %v", want, calls)
+	}
+}
+
+func TestDialerCertRefreshHookSkipsFailedRefresh(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	// The fingerprint of some other certificate fails the refresh.
+	fp := sha256.Sum256([]byte("not the root CA"))
+	var calls int32
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithPinnedCAFingerprint(testInstanceURI, hex.EncodeToString(fp[:])),
+		WithCertRefreshHook(func(string, time.Time) {
+			atomic.AddInt32(&calls, 1)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if _, err := d.FetchConnectionInfo(ctx, testInstanceURI); err == nil {
+		t.Fatal("want FetchConnectionInfo to fail, got no error")
+	}
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Fatalf("want no hook calls for a failed refresh, got = %v", got)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgxv4
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// instanceURIPrefix matches the start of an AlloyDB instance URI up to its
+// instance name.
+var instanceURIPrefix = regexp.MustCompile(
+	`^projects/[^/?#@]+/locations/[^/?#@]+/clusters/[^/?#@]+/instances/[^/?#@:,]+`,
+)
+
+// parseConfig parses a keyword/value or URL connection string whose host is
+// an AlloyDB instance URI, e.g.,
+//
+//	host=projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE> user=myuser
+//	postgres://myuser@projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>/mydb
+//	postgres://myuser@/mydb?host=projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>
+//
+// and returns the instance URI and the config with the host replaced by
+// "localhost". The host is never used to dial, so pgx does not mistake it
+// for a Unix socket directory or a Windows path, and the connection string
+// needs no changes between platforms.
+func parseConfig(dsn string) (string, *pgx.ConnConfig, error) {
+	dsn, inst := rewriteURLHost(dsn)
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return "", nil, err
+	}
+	if inst == "" {
+		inst = config.Config.Host
+	}
+	config.Config.Host = "localhost"
+	// The fallbacks hold the same host, as the instance URI contains no
+	// commas.
+	for _, f := range config.Config.Fallbacks {
+		f.Host = "localhost"
+	}
+	return inst, config, nil
+}
+
+// rewriteURLHost replaces an instance URI in the host of a URL connection
+// string with "localhost" and returns the new connection string and the
+// instance URI. The slashes of the instance URI would otherwise end the host
+// when the URL is parsed. Other connection strings are returned unchanged
+// with an empty instance URI.
+func rewriteURLHost(dsn string) (string, string) {
+	var scheme string
+	for _, s := range []string{"postgres://", "postgresql://"} {
+		if strings.HasPrefix(dsn, s) {
+			scheme = s
+		}
+	}
+	if scheme == "" {
+		return dsn, ""
+	}
+	rest := dsn[len(scheme):]
+	// The host follows the user info, which cannot contain an unescaped
+	// slash.
+	host := 0
+	if at := strings.Index(rest, "@"); at >= 0 && !strings.Contains(rest[:at], "/") {
+		host = at + 1
+	}
+	m := instanceURIPrefix.FindString(rest[host:])
+	if m == "" {
+		return dsn, ""
+	}
+	return scheme + rest[:host] + "localhost" + rest[host+len(m):], m
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgxv4
+
+import "testing"
+
+const testInstanceURI = "projects/p/locations/r/clusters/c/instances/i"
+
+func TestParseConfig(t *testing.T) {
+	tcs := []struct {
+		desc string
+		dsn  string
+	}{
+		{
+			desc: "keyword/value",
+			dsn:  "host=" + testInstanceURI + " user=u password=secret dbname=d",
+		},
+		{
+			desc: "quoted keyword/value",
+			dsn:  "host='" + testInstanceURI + "' user=u password=secret dbname=d",
+		},
+		{
+			desc: "URL",
+			dsn:  "postgres://u:secret@" + testInstanceURI + "/d",
+		},
+		{
+			desc: "URL with port and parameters",
+			dsn:  "postgresql://u:secret@" + testInstanceURI + ":5432/d?sslmode=disable",
+		},
+		{
+			desc: "URL with host parameter",
+			dsn:  "postgres://u:secret@/d?host=" + testInstanceURI,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			inst, config, err := parseConfig(tc.dsn)
+			if err != nil {
+				t.Fatalf("expected parseConfig to succeed, got error: %v", err)
+			}
+			if inst != testInstanceURI {
+				t.Errorf("want instance = %v, got = %v", testInstanceURI, inst)
+			}
+			if config.Host != "localhost" {
+				t.Errorf("want host = localhost, got = %v", config.Host)
+			}
+			for _, f := range config.Fallbacks {
+				if f.Host != "localhost" {
+					t.Errorf("want fallback host = localhost, got = %v", f.Host)
+				}
+			}
+			if config.User != "u" || config.Password != "secret" || config.Database != "d" {
+				t.Errorf("want user, password, and database to be kept, got = %v, %v, %v",
+					config.User, config.Password, config.Database)
+			}
+		})
+	}
+}
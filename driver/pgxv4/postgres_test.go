@@ -16,10 +16,18 @@ package pgxv4_test
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
 	"time"
 
+	"cloud.google.com/go/alloydbconn"
 	"cloud.google.com/go/alloydbconn/driver/pgxv4"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"golang.org/x/oauth2"
 )
 
 // Example shows how to use the AlloyDB driver
@@ -41,3 +49,49 @@ func ExampleRegisterDriver() {
 	}
 	log.Println(now)
 }
+
+type stubTokenSource struct{}
+
+func (stubTokenSource) Token() (*oauth2.Token, error) {
+	return nil, nil
+}
+
+// driverCount makes the names of the drivers registered by tests unique, as
+// database/sql does not allow a name to be registered twice, e.g., when tests
+// run with -count.
+var driverCount int32
+
+// driverName returns a driver name unique to this run of the test.
+func driverName(t *testing.T) string {
+	return fmt.Sprintf("%v-%d", t.Name(), atomic.AddInt32(&driverCount, 1))
+}
+
+func TestIAMAuthNRejectsPassword(t *testing.T) {
+	name := driverName(t)
+	cleanup, err := pgxv4.RegisterDriver(name,
+		alloydbconn.WithTokenSource(stubTokenSource{}),
+		alloydbconn.WithIAMAuthN(),
+	)
+	if err != nil {
+		t.Fatalf("expected RegisterDriver to succeed, got error: %v", err)
+	}
+	defer cleanup()
+
+	db, err := sql.Open(
+		name,
+		"host=projects/p/locations/r/clusters/c/instances/i user=u dbname=d password=secret sslmode=disable",
+	)
+	if err != nil {
+		t.Fatalf("expected sql.Open to succeed, got error: %v", err)
+	}
+	defer db.Close()
+
+	err = db.Ping()
+	var wantErr *errtype.ConfigError
+	if !errors.As(err, &wantErr) {
+		t.Fatalf("want = %T, got = %v", wantErr, err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Fatalf("want error to omit the password, got = %v", err)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"cloud.google.com/go/alloydbconn"
 	"cloud.google.com/go/alloydbconn/driver/pgxv4"
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
 )
 
 var (
@@ -117,4 +118,20 @@ func TestAlloyDBHook(t *testing.T) {
 	}
 	defer db.Close()
 	testConn(db)
+
+	// Raw exposes the pgx connection, e.g., to use pgx-specific APIs.
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("db.Conn want err = nil, got = %v", err)
+	}
+	defer conn.Close()
+	err = conn.Raw(func(driverConn interface{}) error {
+		if _, ok := driverConn.(*stdlib.Conn); !ok {
+			return fmt.Errorf("want *stdlib.Conn, got = %T", driverConn)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Raw failed: %v", err)
+	}
 }
@@ -16,7 +16,101 @@
 // alloydbconn package.
 package errtype
 
-import "fmt"
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// Sentinel errors classify the cause of a RefreshError or DialError. Test for
+// them with errors.Is:
+//
+//	if errors.Is(err, errtype.ErrPermissionDenied) {
+//		// ...
+//	}
+var (
+	// ErrQuotaExceeded indicates the AlloyDB Admin API rejected a request
+	// because a quota or rate limit was exceeded.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrPermissionDenied indicates the AlloyDB Admin API rejected the
+	// credentials or the credentials lack a required permission.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrInstanceNotFound indicates the instance, or its cluster, does not
+	// exist.
+	ErrInstanceNotFound = errors.New("instance not found")
+	// ErrCertExpired indicates a connection failed because the client
+	// certificate had expired, e.g., because refresh operations have been
+	// failing.
+	ErrCertExpired = errors.New("client certificate expired")
+	// ErrTLSVerificationFailed indicates the instance's server certificate
+	// or CA could not be verified.
+	ErrTLSVerificationFailed = errors.New("TLS verification failed")
+	// ErrCredentials indicates the Dialer's credentials could not produce a
+	// token, e.g., because a Workload Identity Federation token expired or a
+	// service account key was revoked. Unlike ErrPermissionDenied, the
+	// request never reached the AlloyDB Admin API.
+	ErrCredentials = errors.New("credentials failed")
+)
+
+// classify returns the sentinel error that describes err, or nil if there is
+// none.
+func classify(err error) error {
+	var rErr *oauth2.RetrieveError
+	if errors.Is(err, ErrCredentials) || errors.As(err, &rErr) {
+		return ErrCredentials
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusTooManyRequests:
+			return ErrQuotaExceeded
+		case http.StatusUnauthorized:
+			return ErrPermissionDenied
+		case http.StatusForbidden:
+			for _, e := range apiErr.Errors {
+				if e.Reason == "rateLimitExceeded" || e.Reason == "quotaExceeded" {
+					return ErrQuotaExceeded
+				}
+			}
+			return ErrPermissionDenied
+		case http.StatusNotFound:
+			return ErrInstanceNotFound
+		}
+		return nil
+	}
+	var (
+		uaErr   x509.UnknownAuthorityError
+		ciErr   x509.CertificateInvalidError
+		hostErr x509.HostnameError
+	)
+	if errors.As(err, &uaErr) || errors.As(err, &ciErr) || errors.As(err, &hostErr) {
+		return ErrTLSVerificationFailed
+	}
+	return nil
+}
+
+// temporary reports whether retrying the operation that failed with err, of
+// kind kind, may succeed without changes to the configuration or
+// permissions.
+func temporary(kind, err error) bool {
+	switch kind {
+	case ErrPermissionDenied, ErrInstanceNotFound, ErrTLSVerificationFailed:
+		return false
+	}
+	var cErr *ConfigError
+	if errors.As(err, &cErr) {
+		return false
+	}
+	var t interface{ Temporary() bool }
+	if errors.As(err, &t) {
+		return t.Temporary()
+	}
+	return true
+}
 
 type genericError struct {
 	Message  string
@@ -39,11 +133,16 @@ func NewConfigError(msg, cn string) *ConfigError {
 // malformated, etc).
 type ConfigError struct{ *genericError }
 
+// Temporary always reports false, as the request will fail until the
+// configuration is corrected.
+func (e *ConfigError) Temporary() bool { return false }
+
 // NewRefreshError initializes a RefreshError.
 func NewRefreshError(msg, cn string, err error) *RefreshError {
 	return &RefreshError{
 		genericError: &genericError{Message: msg, ConnName: cn},
 		Err:          err,
+		kind:         classify(err),
 	}
 }
 
@@ -56,6 +155,8 @@ type RefreshError struct {
 	*genericError
 	// Err is the underlying error and may be nil.
 	Err error
+	// kind is the sentinel error that describes Err, if any.
+	kind error
 }
 
 func (e *RefreshError) Error() string {
@@ -67,11 +168,20 @@ func (e *RefreshError) Error() string {
 
 func (e *RefreshError) Unwrap() error { return e.Err }
 
+// Is reports whether target is the sentinel error that describes the cause
+// of e, e.g., ErrPermissionDenied.
+func (e *RefreshError) Is(target error) bool { return e.kind != nil && target == e.kind }
+
+// Temporary reports whether retrying may succeed without changes to the
+// configuration or permissions.
+func (e *RefreshError) Temporary() bool { return temporary(e.kind, e.Err) }
+
 // NewDialError initializes a DialError.
 func NewDialError(msg, cn string, err error) *DialError {
 	return &DialError{
 		genericError: &genericError{Message: msg, ConnName: cn},
 		Err:          err,
+		kind:         classify(err),
 	}
 }
 
@@ -82,6 +192,8 @@ type DialError struct {
 	*genericError
 	// Err is the underlying error and may be nil.
 	Err error
+	// kind is the sentinel error that describes Err, if any.
+	kind error
 }
 
 func (e *DialError) Error() string {
@@ -92,3 +204,93 @@ func (e *DialError) Error() string {
 }
 
 func (e *DialError) Unwrap() error { return e.Err }
+
+// Is reports whether target is the sentinel error that describes the cause
+// of e, e.g., ErrTLSVerificationFailed.
+func (e *DialError) Is(target error) bool { return e.kind != nil && target == e.kind }
+
+// Temporary reports whether retrying may succeed without changes to the
+// configuration or permissions.
+func (e *DialError) Temporary() bool { return temporary(e.kind, e.Err) }
+
+// NewWarmupError initializes a WarmupError.
+func NewWarmupError(msg, cn string, err error) *WarmupError {
+	return &WarmupError{
+		genericError: &genericError{Message: msg, ConnName: cn},
+		Err:          err,
+	}
+}
+
+// WarmupError indicates the information needed to connect to an AlloyDB
+// instance for the first time was not retrieved within the configured cold
+// start budget. The retrieval continues in the background, so callers may
+// retry shortly or degrade gracefully in the meantime.
+type WarmupError struct {
+	*genericError
+	// Err is the underlying error and may be nil.
+	Err error
+}
+
+func (e *WarmupError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("Warmup error: %v", e.genericError)
+	}
+	return fmt.Sprintf("Warmup error: %v: %v", e.genericError, e.Err)
+}
+
+func (e *WarmupError) Unwrap() error { return e.Err }
+
+// Temporary always reports true, as the retrieval continues in the
+// background.
+func (e *WarmupError) Temporary() bool { return true }
+
+// NewConnectionLimitError initializes a ConnectionLimitError.
+func NewConnectionLimitError(msg, cn string) *ConnectionLimitError {
+	return &ConnectionLimitError{
+		genericError: &genericError{Message: msg, ConnName: cn},
+	}
+}
+
+// ConnectionLimitError indicates a dial was rejected because the dialer
+// already holds the maximum number of open connections it was configured to
+// allow. Closing an existing connection makes room for another.
+type ConnectionLimitError struct{ *genericError }
+
+func (e *ConnectionLimitError) Error() string {
+	return fmt.Sprintf("Connection limit error: %v", e.genericError)
+}
+
+// Temporary always reports true, as a connection may be closed at any time.
+func (e *ConnectionLimitError) Temporary() bool { return true }
+
+// NewCircuitOpenError initializes a CircuitOpenError.
+func NewCircuitOpenError(msg, cn string, err error) *CircuitOpenError {
+	return &CircuitOpenError{
+		genericError: &genericError{Message: msg, ConnName: cn},
+		Err:          err,
+	}
+}
+
+// CircuitOpenError indicates the connection info of an instance is no longer
+// refreshed as usual because its recent refresh operations failed in a way
+// that retrying cannot fix, e.g., because the instance was deleted or
+// permission to connect to it was revoked. The instance is only probed
+// occasionally until a refresh succeeds.
+type CircuitOpenError struct {
+	*genericError
+	// Err is the error of the most recent refresh operation.
+	Err error
+}
+
+func (e *CircuitOpenError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("Circuit open error: %v", e.genericError)
+	}
+	return fmt.Sprintf("Circuit open error: %v: %v", e.genericError, e.Err)
+}
+
+func (e *CircuitOpenError) Unwrap() error { return e.Err }
+
+// Temporary always reports false, as dials fail until the cause of the
+// refresh failures is corrected.
+func (e *CircuitOpenError) Temporary() bool { return false }
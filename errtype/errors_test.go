@@ -15,10 +15,15 @@
 package errtype_test
 
 import (
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"net/http"
 	"testing"
 
 	"cloud.google.com/go/alloydbconn/errtype"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
 )
 
 func TestErrorFormatting(t *testing.T) {
@@ -60,6 +65,34 @@ func TestErrorFormatting(t *testing.T) {
 			),
 			want: "Dial error: message (instance URI = \"proj/reg/inst\"): inner-error",
 		},
+		{
+			desc: "Warmup error without inner error",
+			err:  errtype.NewWarmupError("message", "proj/reg/inst", nil),
+			want: "Warmup error: message (instance URI = \"proj/reg/inst\")",
+		},
+		{
+			desc: "Warmup error with inner error",
+			err: errtype.NewWarmupError(
+				"message",
+				"proj/reg/inst",
+				errors.New("inner-error"),
+			),
+			want: "Warmup error: message (instance URI = \"proj/reg/inst\"): inner-error",
+		},
+		{
+			desc: "Connection limit error",
+			err:  errtype.NewConnectionLimitError("message", "proj/reg/inst"),
+			want: "Connection limit error: message (instance URI = \"proj/reg/inst\")",
+		},
+		{
+			desc: "Circuit open error with inner error",
+			err: errtype.NewCircuitOpenError(
+				"message",
+				"proj/reg/inst",
+				errors.New("inner-error"),
+			),
+			want: "Circuit open error: message (instance URI = \"proj/reg/inst\"): inner-error",
+		},
 	}
 
 	for _, c := range tc {
@@ -68,3 +101,134 @@ func TestErrorFormatting(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorKinds(t *testing.T) {
+	apiErr := func(code int, reason string) error {
+		e := &googleapi.Error{Code: code}
+		if reason != "" {
+			e.Errors = []googleapi.ErrorItem{{Reason: reason}}
+		}
+		return fmt.Errorf("wrapped: %w", e)
+	}
+	tcs := []struct {
+		desc string
+		err  error
+		want error
+		temp bool
+	}{
+		{
+			desc: "too many requests",
+			err:  errtype.NewRefreshError("msg", "inst", apiErr(http.StatusTooManyRequests, "")),
+			want: errtype.ErrQuotaExceeded,
+			temp: true,
+		},
+		{
+			desc: "quota exceeded",
+			err:  errtype.NewRefreshError("msg", "inst", apiErr(http.StatusForbidden, "quotaExceeded")),
+			want: errtype.ErrQuotaExceeded,
+			temp: true,
+		},
+		{
+			desc: "forbidden",
+			err:  errtype.NewRefreshError("msg", "inst", apiErr(http.StatusForbidden, "")),
+			want: errtype.ErrPermissionDenied,
+		},
+		{
+			desc: "unauthorized",
+			err:  errtype.NewRefreshError("msg", "inst", apiErr(http.StatusUnauthorized, "")),
+			want: errtype.ErrPermissionDenied,
+		},
+		{
+			desc: "not found",
+			err:  errtype.NewRefreshError("msg", "inst", apiErr(http.StatusNotFound, "")),
+			want: errtype.ErrInstanceNotFound,
+		},
+		{
+			desc: "unknown authority",
+			err:  errtype.NewDialError("msg", "inst", x509.UnknownAuthorityError{}),
+			want: errtype.ErrTLSVerificationFailed,
+		},
+		{
+			desc: "wrapped sentinel",
+			err:  errtype.NewDialError("msg", "inst", fmt.Errorf("%w: bad certificate", errtype.ErrCertExpired)),
+			want: errtype.ErrCertExpired,
+			temp: true,
+		},
+		{
+			desc: "token endpoint rejected credentials",
+			err: errtype.NewRefreshError("msg", "inst",
+				fmt.Errorf("wrapped: %w", &oauth2.RetrieveError{Body: []byte("invalid_grant")}),
+			),
+			want: errtype.ErrCredentials,
+			temp: true,
+		},
+		{
+			desc: "wrapped credentials sentinel",
+			err:  errtype.NewRefreshError("msg", "inst", fmt.Errorf("%w: token expired", errtype.ErrCredentials)),
+			want: errtype.ErrCredentials,
+			temp: true,
+		},
+		{
+			desc: "dial error wrapping refresh error",
+			err: errtype.NewDialError("msg", "inst",
+				errtype.NewRefreshError("msg", "inst", apiErr(http.StatusNotFound, "")),
+			),
+			want: errtype.ErrInstanceNotFound,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			if !errors.Is(tc.err, tc.want) {
+				t.Fatalf("want errors.Is(%v, %v) to be true", tc.err, tc.want)
+			}
+			var tErr interface{ Temporary() bool }
+			if !errors.As(tc.err, &tErr) {
+				t.Fatalf("want %T to have a Temporary method", tc.err)
+			}
+			if got := tErr.Temporary(); got != tc.temp {
+				t.Fatalf("want Temporary() = %v, got = %v", tc.temp, got)
+			}
+		})
+	}
+}
+
+func TestErrorTemporary(t *testing.T) {
+	tcs := []struct {
+		desc string
+		err  interface{ Temporary() bool }
+		want bool
+	}{
+		{
+			desc: "config error",
+			err:  errtype.NewConfigError("msg", "inst"),
+		},
+		{
+			desc: "refresh error wrapping a config error",
+			err:  errtype.NewRefreshError("msg", "inst", errtype.NewConfigError("msg", "inst")),
+		},
+		{
+			desc: "unclassified refresh error",
+			err:  errtype.NewRefreshError("msg", "inst", errors.New("inner-error")),
+			want: true,
+		},
+		{
+			desc: "warmup error",
+			err:  errtype.NewWarmupError("msg", "inst", nil),
+			want: true,
+		},
+		{
+			desc: "connection limit error",
+			err:  errtype.NewConnectionLimitError("msg", "inst"),
+			want: true,
+		},
+		{
+			desc: "circuit open error",
+			err:  errtype.NewCircuitOpenError("msg", "inst", errors.New("inner-error")),
+		},
+	}
+	for _, tc := range tcs {
+		if got := tc.err.Temporary(); got != tc.want {
+			t.Errorf("%v: want Temporary() = %v, got = %v", tc.desc, tc.want, got)
+		}
+	}
+}
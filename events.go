@@ -0,0 +1,178 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"strings"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/internal/logging"
+)
+
+// DialEventType identifies the kind of a DialEvent.
+type DialEventType int
+
+const (
+	// RefreshStarted is emitted when a refresh operation starts.
+	RefreshStarted DialEventType = iota + 1
+	// RefreshSucceeded is emitted when a refresh operation succeeds.
+	RefreshSucceeded
+	// RefreshFailed is emitted when a refresh operation fails. Err holds
+	// the cause.
+	RefreshFailed
+	// DialAttempt is emitted before connecting to an instance's address.
+	DialAttempt
+	// TLSHandshakeDone is emitted when a TLS handshake with an instance
+	// completes. Err is set if the handshake failed.
+	TLSHandshakeDone
+	// ConnectionClosed is emitted when a connection returned by Dial is
+	// closed. Duration is how long the connection was open.
+	ConnectionClosed
+	// DialStarted is emitted when a call to Dial starts.
+	DialStarted
+	// DialFinished is emitted when a call to Dial returns. Err is set if
+	// the dial failed.
+	DialFinished
+	// InstanceEvicted is emitted when an instance is removed from the
+	// Dialer's cache, e.g., after it is deleted from a read pool.
+	InstanceEvicted
+)
+
+func (t DialEventType) String() string {
+	switch t {
+	case RefreshStarted:
+		return "RefreshStarted"
+	case RefreshSucceeded:
+		return "RefreshSucceeded"
+	case RefreshFailed:
+		return "RefreshFailed"
+	case DialAttempt:
+		return "DialAttempt"
+	case TLSHandshakeDone:
+		return "TLSHandshakeDone"
+	case ConnectionClosed:
+		return "ConnectionClosed"
+	case DialStarted:
+		return "DialStarted"
+	case DialFinished:
+		return "DialFinished"
+	case InstanceEvicted:
+		return "InstanceEvicted"
+	}
+	return "Unknown"
+}
+
+// DialEvent describes an operation of the Dialer.
+type DialEvent struct {
+	// Type is the kind of event.
+	Type DialEventType
+	// Time is when the event occurred.
+	Time time.Time
+	// Instance identifies the instance, in the format
+	// <PROJECT>/<REGION>/<CLUSTER>/<INSTANCE>.
+	Instance string
+	// Addr is the address of the instance, including the port, for
+	// DialAttempt, TLSHandshakeDone, and ConnectionClosed events, e.g.,
+	// "10.0.0.1:5433" or "[2001:db8::1]:5433".
+	Addr string
+	// Duration is how long the operation took for RefreshSucceeded,
+	// RefreshFailed, TLSHandshakeDone, DialFinished, and ConnectionClosed
+	// events.
+	Duration time.Duration
+	// Err is the error the operation failed with, if any.
+	Err error
+	// Label is the label attached to the dial with WithConnectionLabel, if
+	// any. Refresh and eviction events, which are shared by all dials of an
+	// instance, have no label.
+	Label string
+}
+
+// WithDialEventHandler returns an Option that calls h with an event for each
+// call to Dial, refresh operation, dial attempt, TLS handshake, connection
+// close, and cache eviction, e.g., to feed the events into an application's
+// own telemetry. h is called synchronously on the goroutine performing the
+// operation, so it must return quickly and be safe for concurrent use.
+func WithDialEventHandler(h func(DialEvent)) Option {
+	return func(d *dialerConfig) {
+		d.eventHandler = h
+	}
+}
+
+// WithDialEventChannel returns an Option that sends the events described in
+// WithDialEventHandler to ch, a lightweight alternative to a metrics SDK for,
+// e.g., command line tools. Events are sent without blocking and are dropped
+// if ch is full, so ch should be buffered. ch is never closed.
+// WithDialEventChannel replaces any handler set with WithDialEventHandler.
+func WithDialEventChannel(ch chan<- DialEvent) Option {
+	return WithDialEventHandler(func(e DialEvent) {
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+}
+
+// eventLogger converts debug events into DialEvents for a handler.
+type eventLogger func(DialEvent)
+
+func (h eventLogger) Log(e logging.Event) {
+	ev := DialEvent{
+		Time:     e.Time,
+		Instance: shortInstanceName(e.Instance),
+		Addr:     e.Addr,
+		Duration: e.Duration,
+		Err:      e.Err,
+		Label:    e.Label,
+	}
+	switch e.Name {
+	case logging.EventRefreshStart:
+		ev.Type = RefreshStarted
+	case logging.EventRefresh:
+		ev.Type = RefreshSucceeded
+		if e.Err != nil {
+			ev.Type = RefreshFailed
+		}
+	case logging.EventDialAttempt:
+		ev.Type = DialAttempt
+	case logging.EventTLSHandshake:
+		ev.Type = TLSHandshakeDone
+	case logging.EventConnClosed:
+		ev.Type = ConnectionClosed
+	case logging.EventDialStart:
+		ev.Type = DialStarted
+	case logging.EventDial:
+		ev.Type = DialFinished
+	case logging.EventEvict:
+		ev.Type = InstanceEvicted
+	default:
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	h(ev)
+}
+
+// shortInstanceName returns the <PROJECT>/<REGION>/<CLUSTER>/<INSTANCE> form
+// of an instance URI. Other names, e.g., those to be resolved, are returned
+// unchanged.
+func shortInstanceName(name string) string {
+	parts := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	if len(parts) != 8 || parts[0] != "projects" || parts[2] != "locations" ||
+		parts[4] != "clusters" || parts[6] != "instances" {
+		return name
+	}
+	return strings.Join([]string{parts[1], parts[3], parts[5], parts[7]}, "/")
+}
@@ -0,0 +1,143 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestDialerWithDialEventHandler(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	var (
+		mu     sync.Mutex
+		events []DialEvent
+	)
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithDialEventHandler(func(e DialEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI, WithConnectionLabel("pool=payments"))
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []DialEventType{
+		DialStarted, RefreshStarted, RefreshSucceeded, DialAttempt,
+		TLSHandshakeDone, DialFinished, ConnectionClosed,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("want events %v, got = %+v", want, events)
+	}
+	for n, e := range events {
+		if e.Type != want[n] {
+			t.Fatalf("event %d: want = %v, got = %v", n, want[n], e.Type)
+		}
+		if e.Instance != "my-project/my-region/my-cluster/my-instance" {
+			t.Errorf("event %d: want instance my-project/my-region/my-cluster/my-instance, got = %v", n, e.Instance)
+		}
+		if e.Err != nil {
+			t.Errorf("event %d: want no error, got = %v", n, e.Err)
+		}
+		wantLabel := "pool=payments"
+		if e.Type == RefreshStarted || e.Type == RefreshSucceeded {
+			wantLabel = ""
+		}
+		if e.Label != wantLabel {
+			t.Errorf("event %d (%v): want label %q, got = %q", n, e.Type, wantLabel, e.Label)
+		}
+	}
+	if got := events[3].Addr; got != "127.0.0.1:5433" {
+		t.Errorf("want DialAttempt address 127.0.0.1:5433, got = %v", got)
+	}
+	if events[4].Duration <= 0 || events[5].Duration <= 0 || events[6].Duration <= 0 {
+		t.Errorf("want TLSHandshakeDone, DialFinished, and ConnectionClosed durations, got = %+v", events)
+	}
+}
+
+func TestDialerWithDialEventChannel(t *testing.T) {
+	ctx := context.Background()
+	// Events that do not fit in the channel are dropped.
+	for _, tc := range []struct {
+		size int
+		want []DialEventType
+	}{
+		{size: 1, want: []DialEventType{DialStarted}},
+		{size: 8, want: []DialEventType{DialStarted, DialFinished}},
+	} {
+		ch := make(chan DialEvent, tc.size)
+		d, err := NewDialer(ctx,
+			WithTokenSource(stubTokenSource{}),
+			WithDialEventChannel(ch),
+		)
+		if err != nil {
+			t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+		}
+		if _, err := d.Dial(ctx, "projects/bad-instance-uri"); err == nil {
+			t.Fatal("want Dial to fail, got nil")
+		}
+		d.Close()
+		close(ch)
+
+		var got []DialEventType
+		for e := range ch {
+			got = append(got, e.Type)
+			if e.Type == DialFinished && e.Err == nil {
+				t.Errorf("want DialFinished error, got nil")
+			}
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("channel size %v: want = %v, got = %v", tc.size, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/logging"
+)
+
+// failoverGroupPrefix marks the argument to Dial as a failover group name.
+const failoverGroupPrefix = "group/"
+
+// RegisterFailoverGroup registers a named list of instance URIs, in order of
+// preference, that may be dialed as "group/<NAME>". A dial to the group
+// tries each instance in turn, moving on to the next only when the dial fails
+// because the connection was refused or the TLS handshake failed. Any other
+// error is returned immediately. Registering an existing name replaces the
+// group.
+func (d *Dialer) RegisterFailoverGroup(name string, instances []string) error {
+	if name == "" || strings.Contains(name, "/") {
+		return errtype.NewConfigError(
+			fmt.Sprintf("invalid failover group name %q", name), failoverGroupPrefix+name,
+		)
+	}
+	if len(instances) == 0 {
+		return errtype.NewConfigError("failover group has no instances", failoverGroupPrefix+name)
+	}
+	for _, inst := range instances {
+		if !isInstanceURI(inst) {
+			return errtype.NewConfigError(
+				fmt.Sprintf("failover group member %q is not an instance URI", inst),
+				failoverGroupPrefix+name,
+			)
+		}
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.groups[name] = append([]string(nil), instances...)
+	return nil
+}
+
+// failoverGroupName returns the group name from a "group/<NAME>" argument to
+// Dial.
+func failoverGroupName(instance string) (string, bool) {
+	if !strings.HasPrefix(instance, failoverGroupPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(instance, failoverGroupPrefix), true
+}
+
+// dialGroup dials the instances of the named failover group in order until
+// one succeeds or fails with an error that does not warrant failover.
+func (d *Dialer) dialGroup(ctx context.Context, name string, opts ...DialOption) (net.Conn, error) {
+	d.lock.RLock()
+	instances, ok := d.groups[name]
+	d.lock.RUnlock()
+	if !ok {
+		return nil, errtype.NewConfigError(
+			fmt.Sprintf("unknown failover group %q", name), failoverGroupPrefix+name,
+		)
+	}
+	var err error
+	for n, inst := range instances {
+		var conn net.Conn
+		conn, err = d.Dial(ctx, inst, opts...)
+		if err == nil || !isFailoverError(err) {
+			return conn, err
+		}
+		if n < len(instances)-1 {
+			d.logger.Log(logging.Event{
+				Instance: inst,
+				Name:     logging.EventFailover,
+				Message:  fmt.Sprintf("failing over to %v", instances[n+1]),
+				Err:      err,
+			})
+		}
+	}
+	return nil, err
+}
+
+// handshakeError marks a failed TLS handshake so that failover can tell it
+// apart from other dial errors.
+type handshakeError struct {
+	err error
+}
+
+func (e *handshakeError) Error() string { return e.err.Error() }
+
+func (e *handshakeError) Unwrap() error { return e.err }
+
+// isFailoverError reports whether a failed dial should be retried against the
+// next instance of a failover group.
+func isFailoverError(err error) bool {
+	var he *handshakeError
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.As(err, &he)
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestDialerFailoverGroup(t *testing.T) {
+	ctx := context.Background()
+	// refused has no server listening, mismatched is served by a server with
+	// another instance's certificate, and healthy accepts connections.
+	refused := mock.NewFakeInstance(
+		"my-project", "my-region", "refused-cluster", "my-instance",
+		mock.WithIPAddr("10.0.0.1"),
+	)
+	mismatched := mock.NewFakeInstance(
+		"my-project", "my-region", "mismatched-cluster", "my-instance",
+		mock.WithUID("11111111-1111-1111-1111-111111111111"),
+	)
+	healthy := mock.NewFakeInstance(
+		"my-project", "my-region", "healthy-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(refused, 1),
+		mock.CreateEphemeralSuccess(refused, 1),
+		mock.InstanceGetSuccess(mismatched, 1),
+		mock.CreateEphemeralSuccess(mismatched, 1),
+		mock.InstanceGetSuccess(healthy, 1),
+		mock.CreateEphemeralSuccess(healthy, 1),
+	)
+	stop := mock.StartServerProxy(t, healthy)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == "10.0.0.1:5433" {
+				return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+			}
+			return (&net.Dialer{}).DialContext(ctx, network, "127.0.0.1:5433")
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if err := d.RegisterFailoverGroup("orders", []string{
+		"projects/my-project/locations/my-region/clusters/refused-cluster/instances/my-instance",
+		"projects/my-project/locations/my-region/clusters/mismatched-cluster/instances/my-instance",
+		"projects/my-project/locations/my-region/clusters/healthy-cluster/instances/my-instance",
+	}); err != nil {
+		t.Fatalf("expected RegisterFailoverGroup to succeed, got error: %v", err)
+	}
+
+	conn, err := d.Dial(ctx, "group/orders")
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+}
+
+func TestDialerFailoverGroupErrors(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	var wantErr *errtype.ConfigError
+	for _, tc := range []struct {
+		name      string
+		instances []string
+	}{
+		{name: "", instances: []string{testInstanceURI}},
+		{name: "a/b", instances: []string{testInstanceURI}},
+		{name: "empty"},
+		{name: "invalid", instances: []string{"my-project:my-instance"}},
+	} {
+		if err := d.RegisterFailoverGroup(tc.name, tc.instances); !errors.As(err, &wantErr) {
+			t.Errorf("RegisterFailoverGroup(%q, %v): want = %T, got = %v", tc.name, tc.instances, wantErr, err)
+		}
+	}
+	if _, err := d.Dial(ctx, "group/unknown"); !errors.As(err, &wantErr) {
+		t.Fatalf("Dial to unknown group: want = %T, got = %v", wantErr, err)
+	}
+}
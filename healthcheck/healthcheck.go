@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package healthcheck reports whether an alloydbconn.Dialer is ready to
+// connect to a set of instances, e.g., to back a Kubernetes readiness probe:
+//
+//	d, err := alloydbconn.NewDialer(ctx)
+//	// ...
+//	http.Handle("/readyz", healthcheck.NewReadinessCheck(d, []string{
+//		"projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>",
+//	}))
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/alloydbconn"
+)
+
+// An Option configures a ReadinessCheck.
+type Option func(c *ReadinessCheck)
+
+// WithDial returns an Option that makes the check connect to each instance,
+// including the TLS handshake, with the Dialer's Dial method and the provided
+// DialOptions. The connection is closed immediately. By default, the check
+// only inspects the Dialer's cache.
+func WithDial(opts ...alloydbconn.DialOption) Option {
+	return func(c *ReadinessCheck) {
+		c.dial = true
+		c.dialOpts = opts
+	}
+}
+
+// WithTimeout returns an Option that bounds how long each call to Check may
+// take. The default is 5 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(c *ReadinessCheck) {
+		c.timeout = d
+	}
+}
+
+// ReadinessCheck reports whether a Dialer is ready to connect to a set of
+// instances. An instance is ready if the Dialer has connection info for it
+// and the client certificate has not expired.
+//
+// Use NewReadinessCheck to initialize a ReadinessCheck.
+type ReadinessCheck struct {
+	d         *alloydbconn.Dialer
+	instances []string
+	dial      bool
+	dialOpts  []alloydbconn.DialOption
+	timeout   time.Duration
+}
+
+// NewReadinessCheck returns a ReadinessCheck of d for the instances, which
+// must be the instance URIs as passed to Dial.
+func NewReadinessCheck(d *alloydbconn.Dialer, instances []string, opts ...Option) *ReadinessCheck {
+	c := &ReadinessCheck{
+		d:         d,
+		instances: instances,
+		timeout:   5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Check returns nil if the Dialer is ready to connect to every instance.
+// Otherwise it returns an error describing each instance that is not.
+func (c *ReadinessCheck) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	var errs []string
+	for _, inst := range c.instances {
+		if err := c.checkInstance(ctx, inst); err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", inst, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (c *ReadinessCheck) checkInstance(ctx context.Context, inst string) error {
+	// A successful dial also warms the cache.
+	if c.dial {
+		conn, err := c.d.Dial(ctx, inst, c.dialOpts...)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+	}
+	info, err := c.d.ConnectionInfo(inst)
+	if err != nil {
+		return err
+	}
+	if !time.Now().Before(info.CertExpiry) {
+		return fmt.Errorf("client certificate expired at %v", info.CertExpiry)
+	}
+	return nil
+}
+
+// ServeHTTP responds with 200 OK if Check succeeds and 503 Service
+// Unavailable with the error otherwise.
+func (c *ReadinessCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := c.Check(r.Context()); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
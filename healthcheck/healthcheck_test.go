@@ -0,0 +1,74 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package healthcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn"
+	"cloud.google.com/go/alloydbconn/alloydbconntest"
+)
+
+func TestReadinessCheck(t *testing.T) {
+	s, err := alloydbconntest.NewServer("my-project", "my-region", "my-cluster", "my-instance")
+	if err != nil {
+		t.Fatalf("expected NewServer to succeed, got error: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	d, err := alloydbconn.NewDialer(ctx, s.DialerOptions()...)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, got error: %v", err)
+	}
+	defer d.Close()
+
+	serve := func(h http.Handler) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec.Code
+	}
+	insts := []string{s.InstanceURI()}
+
+	// The cache is cold until the instance is dialed.
+	cached := NewReadinessCheck(d, insts)
+	if err := cached.Check(ctx); err == nil {
+		t.Fatalf("want error before dial, got nil")
+	}
+	if got := serve(cached); got != http.StatusServiceUnavailable {
+		t.Fatalf("want status %v before dial, got = %v", http.StatusServiceUnavailable, got)
+	}
+
+	dialed := NewReadinessCheck(d, insts, WithDial())
+	if err := dialed.Check(ctx); err != nil {
+		t.Fatalf("expected Check to succeed, got error: %v", err)
+	}
+	if got := serve(dialed); got != http.StatusOK {
+		t.Fatalf("want status %v, got = %v", http.StatusOK, got)
+	}
+	if got := serve(cached); got != http.StatusOK {
+		t.Fatalf("want status %v after dial, got = %v", http.StatusOK, got)
+	}
+
+	missing := NewReadinessCheck(d, append(insts,
+		"projects/my-project/locations/my-region/clusters/my-cluster/instances/other",
+	))
+	if got := serve(missing); got != http.StatusServiceUnavailable {
+		t.Fatalf("want status %v for unknown instance, got = %v", http.StatusServiceUnavailable, got)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// iamTokenSource supplies the tokens used to log in to databases with IAM
+// database authentication.
+type iamTokenSource struct {
+	once sync.Once
+	ts   oauth2.TokenSource
+	err  error
+}
+
+// newIAMTokenSource returns the IAM token source for cfg. The credentials set
+// with WithIAMAuthNTokenSource take precedence over the Dialer's credentials.
+// If neither is set, Application Default Credentials are looked up on first
+// use.
+func newIAMTokenSource(cfg *dialerConfig) *iamTokenSource {
+	s := &iamTokenSource{ts: cfg.iamTokenSource}
+	if s.ts == nil {
+		s.ts = cfg.tokenSource
+	}
+	if s.ts != nil {
+		s.ts = oauth2.ReuseTokenSource(nil, s.ts)
+	}
+	return s
+}
+
+// token returns a valid access token.
+func (s *iamTokenSource) token() (string, error) {
+	s.once.Do(func() {
+		if s.ts != nil {
+			return
+		}
+		s.ts, s.err = google.DefaultTokenSource(
+			context.Background(), CloudPlatformScope, AlloyDBLoginScope,
+		)
+	})
+	if s.err != nil {
+		return "", fmt.Errorf("failed to find credentials for IAM database authentication: %v", s.err)
+	}
+	tok, err := s.ts.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to get token for IAM database authentication: %v", err)
+	}
+	if tok == nil || tok.AccessToken == "" {
+		return "", errors.New("failed to get token for IAM database authentication: empty token")
+	}
+	return tok.AccessToken, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+	"golang.org/x/oauth2"
+)
+
+func TestDialerIAMAuthNToken(t *testing.T) {
+	api := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "api-token"})
+	user := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "user-token"})
+	tcs := []struct {
+		desc string
+		opts []Option
+		want string
+	}{
+		{
+			desc: "Dialer credentials",
+			opts: []Option{WithTokenSource(api), WithIAMAuthN()},
+			want: "api-token",
+		},
+		{
+			desc: "separate credentials",
+			opts: []Option{WithTokenSource(api), WithIAMAuthNTokenSource(user)},
+			want: "user-token",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			d, err := NewDialer(context.Background(), tc.opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+			if !d.IAMAuthN() {
+				t.Fatal("want IAMAuthN to be true")
+			}
+			got, err := d.IAMAuthNToken()
+			if err != nil {
+				t.Fatalf("expected IAMAuthNToken to succeed, but got error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDialerIAMAuthNTokenErrors(t *testing.T) {
+	d, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	_, err = d.IAMAuthNToken()
+	var cErr *errtype.ConfigError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+
+	// stubTokenSource returns no token.
+	d2, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithIAMAuthN(),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d2.Close()
+	if _, err := d2.IAMAuthNToken(); err == nil {
+		t.Fatal("want error for empty token, got nil")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydb
+
+import "sync"
+
+const (
+	// PrivateIP is the type of an instance's private IP address.
+	PrivateIP = "PRIVATE"
+	// PublicIP is the type of an instance's public IP address.
+	PublicIP = "PUBLIC"
+	// PSC is the type of an instance's Private Service Connect DNS name.
+	PSC = "PSC"
+)
+
+// healthDecay is the weight given to the most recent dial outcome when
+// updating a health score.
+const healthDecay = 0.5
+
+// Endpoint is an address an instance may be reached at.
+type Endpoint struct {
+	// IPType is one of PrivateIP, PublicIP, or PSC.
+	IPType string
+	// Addr is an IP address or, for PSC, a DNS name. IPv6 addresses are
+	// not enclosed in brackets.
+	Addr string
+	// Health is a score between 0 and 1 derived from recent dial outcomes,
+	// where 1 means recent dials succeeded. Endpoints that have never been
+	// dialed have a score of 1.
+	Health float64
+}
+
+// healthTracker records a health score per address. Scores are kept
+// separately from refresh results so they survive refresh operations.
+type healthTracker struct {
+	mu     sync.Mutex
+	scores map[string]float64
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{scores: make(map[string]float64)}
+}
+
+// score returns the health score of addr.
+func (h *healthTracker) score(addr string) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.scores[addr]
+	if !ok {
+		return 1
+	}
+	return s
+}
+
+// record updates the health score of addr as an exponentially weighted
+// moving average of dial outcomes.
+func (h *healthTracker) record(addr string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.scores[addr]
+	if !ok {
+		s = 1
+	}
+	var outcome float64
+	if err == nil {
+		outcome = 1
+	}
+	h.scores[addr] = (1-healthDecay)*s + healthDecay*outcome
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHealthTracker(t *testing.T) {
+	h := newHealthTracker()
+	addr := "10.0.0.1"
+	if got := h.score(addr); got != 1 {
+		t.Fatalf("initial score: want = 1, got = %v", got)
+	}
+
+	dialErr := errors.New("connection refused")
+	h.record(addr, dialErr)
+	h.record(addr, dialErr)
+	if got := h.score(addr); got != 0.25 {
+		t.Fatalf("after two failures: want = 0.25, got = %v", got)
+	}
+
+	h.record(addr, nil)
+	if got := h.score(addr); got != 0.625 {
+		t.Fatalf("after a success: want = 0.625, got = %v", got)
+	}
+
+	if got := h.score("10.0.0.2"); got != 1 {
+		t.Fatalf("other address: want = 1, got = %v", got)
+	}
+}
@@ -16,15 +16,23 @@ package alloydb
 
 import (
 	"context"
-	"crypto/rsa"
+	"crypto"
+	"crypto/sha256"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
 	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
 	"cloud.google.com/go/alloydbconn/errtype"
-	"cloud.google.com/go/alloydbconn/internal/alloydbapi"
+	"cloud.google.com/go/alloydbconn/internal/logging"
+	"cloud.google.com/go/alloydbconn/internal/trace"
 )
 
 var (
@@ -46,6 +54,14 @@ func (i *instanceURI) String() string {
 	return fmt.Sprintf("%s/%s/%s/%s", i.project, i.region, i.cluster, i.name)
 }
 
+// uri returns the instance URI in the format accepted by parseInstURI.
+func (i *instanceURI) uri() string {
+	return fmt.Sprintf(
+		"projects/%s/locations/%s/clusters/%s/instances/%s",
+		i.project, i.region, i.cluster, i.name,
+	)
+}
+
 // parseInstURI initializes a new instanceURI struct.
 func parseInstURI(cn string) (instanceURI, error) {
 	b := []byte(cn)
@@ -75,8 +91,17 @@ type refreshOperation struct {
 
 	// timer that triggers refresh, can be used to cancel.
 	timer *time.Timer
+	// due is when the timer fires.
+	due time.Time
 	// indicates the struct is ready to read from
 	ready chan struct{}
+
+	mu sync.Mutex
+	// cancel cancels the attempt in progress, if any.
+	cancel context.CancelFunc
+	// preempted reports that the attempt in progress was canceled to be
+	// started over.
+	preempted bool
 }
 
 // Cancel prevents the instanceInfo from starting, if it hasn't already started. Returns true if timer
@@ -85,7 +110,44 @@ func (r *refreshOperation) Cancel() bool {
 	return r.timer.Stop()
 }
 
-// Wait blocks until the refreshOperation attempt is completed.
+// preempt cancels the attempt in progress so that the operation starts over.
+// It reports false if no attempt is in progress.
+func (r *refreshOperation) preempt() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel == nil {
+		return false
+	}
+	r.preempted = true
+	r.cancel()
+	r.cancel = nil
+	return true
+}
+
+// run performs the operation's refresh, starting over with a forced trigger
+// each time it is preempted, so that at most one attempt is in progress.
+func (r *refreshOperation) run(i *Instance, trigger string) {
+	for {
+		ctx, cancel := context.WithCancel(i.ctx)
+		r.mu.Lock()
+		r.cancel, r.preempted = cancel, false
+		r.mu.Unlock()
+		r.result, r.err = i.refresh(ctx, trigger)
+		r.mu.Lock()
+		r.cancel = nil
+		preempted := r.preempted
+		r.mu.Unlock()
+		cancel()
+		if !preempted || i.ctx.Err() != nil {
+			return
+		}
+		trigger = trace.RefreshTriggerForced
+	}
+}
+
+// Wait blocks until the refreshOperation attempt is completed. Any number of
+// callers may wait on the same operation; all of them receive its result when
+// it completes.
 func (r *refreshOperation) Wait(ctx context.Context) error {
 	select {
 	case <-r.ready:
@@ -116,10 +178,18 @@ func (r *refreshOperation) IsValid() bool {
 type Instance struct {
 	// OpenConns is the number of open connections to the instance.
 	OpenConns uint64
+	// queued is the number of dials waiting on an in-flight refresh
+	// operation. It must stay 64-bit aligned for atomic access.
+	queued int64
 
 	instanceURI
-	key *rsa.PrivateKey
-	r   refresher
+	// name is the result of String, computed once as it is logged on
+	// every dial.
+	name string
+	// given is the instance URI as passed to NewInstance.
+	given string
+	key   crypto.Signer
+	r     refresher
 
 	resultGuard sync.RWMutex
 	// cur represents the current refreshOperation that will be used to create connections. If a valid complete
@@ -128,6 +198,54 @@ type Instance struct {
 	// next represents a future or ongoing refreshOperation. Once complete, it will replace cur and schedule a
 	// replacement to occur.
 	next *refreshOperation
+	// role is the most recently observed role of the instance's cluster, or
+	// the empty string if unknown.
+	role string
+	// warm is true once a refresh operation has succeeded.
+	warm bool
+	// lastSuccess is when a refresh operation last succeeded.
+	lastSuccess time.Time
+	// latest is the result of the most recent successful refresh operation,
+	// or nil if none has succeeded.
+	latest *refreshResult
+	// failures are the most recent failed refresh operations, oldest first.
+	failures []RefreshFailure
+	// consecutiveFailures is the number of refresh operations that have
+	// failed since the last success.
+	consecutiveFailures int
+	// permanentFailures is the number of consecutive refresh operations
+	// that failed in a way retrying cannot fix.
+	permanentFailures int
+	// breakerOpen is true while refresh operations are suspended after
+	// breakerThreshold permanent failures; see WithCircuitBreaker.
+	breakerOpen      bool
+	breakerThreshold int
+	probeInterval    time.Duration
+
+	// strategy determines when refresh operations start.
+	strategy RefreshStrategy
+	// preemptForced configures forced refreshes to restart a refresh
+	// operation in progress rather than share its result.
+	preemptForced bool
+	// serveStale configures connection attempts to use the most recent
+	// successful result, if it is no older than maxStaleness, rather than
+	// wait on a refresh operation in progress.
+	serveStale   bool
+	maxStaleness time.Duration
+
+	// health tracks the health of the instance's endpoints across refresh
+	// operations.
+	health *healthTracker
+
+	// source, when set, supplies the results of refresh operations in place
+	// of the AlloyDB Admin API.
+	source ConnectInfoSource
+
+	// seed, when set, is used as the result of the first refresh operation.
+	seed *Snapshot
+	// onSnapshot, when set, is called with the connection info of each
+	// successful refresh operation.
+	onSnapshot func(Snapshot)
 
 	// ctx is the default ctx for refresh operations. Canceling it prevents new refresh
 	// operations from being triggered.
@@ -135,13 +253,194 @@ type Instance struct {
 	cancel context.CancelFunc
 }
 
+// Option configures optional behavior of an Instance.
+type Option func(i *Instance)
+
+// WithLogger configures the Logger that receives debug events about the
+// instance's refresh operations.
+func WithLogger(l logging.Logger) Option {
+	return func(i *Instance) {
+		i.r.logger = l
+	}
+}
+
+// WithRecorder configures the Recorder that receives metrics and traces about
+// the instance's refresh operations.
+func WithRecorder(r trace.Recorder) Option {
+	return func(i *Instance) {
+		i.r.recorder = r
+	}
+}
+
+// Executor runs background work.
+type Executor interface {
+	// Go runs f asynchronously.
+	Go(f func())
+}
+
+// goExecutor runs each function on a new goroutine.
+type goExecutor struct{}
+
+func (goExecutor) Go(f func()) { go f() }
+
+// WithExecutor configures the Executor that runs the Admin API calls of the
+// instance's refresh operations and records their telemetry.
+func WithExecutor(e Executor) Option {
+	return func(i *Instance) {
+		i.r.exec = e
+	}
+}
+
+// WithClusterRoleDetection enables retrieving the role of the instance's
+// cluster (primary or secondary) as part of each refresh.
+func WithClusterRoleDetection() Option {
+	return func(i *Instance) {
+		i.r.detectRole = true
+	}
+}
+
+// WithPinnedCAs configures the SHA-256 fingerprints of the root CA
+// certificates the instance's certificate chain may use. A refresh operation
+// that returns any other root CA fails.
+func WithPinnedCAs(fingerprints ...[sha256.Size]byte) Option {
+	return func(i *Instance) {
+		i.r.pinnedCAs = fingerprints
+	}
+}
+
+// WithStrictSANVerification configures the Instance to reject server
+// certificates that do not include subject alternative names, rather than
+// verifying their CN.
+func WithStrictSANVerification() Option {
+	return func(i *Instance) {
+		if i.r.nameCheck != nameCheckSkip {
+			i.r.nameCheck = nameCheckRequireSAN
+		}
+	}
+}
+
+// WithoutServerNameVerification configures the Instance to accept any server
+// certificate issued by the instance's CA, without checking that it names
+// the instance. It takes precedence over WithStrictSANVerification.
+func WithoutServerNameVerification() Option {
+	return func(i *Instance) {
+		i.r.nameCheck = nameCheckSkip
+	}
+}
+
+// WithRand configures the Instance to use r as the entropy source for
+// certificate signing requests and TLS handshakes rather than crypto/rand.
+func WithRand(r io.Reader) Option {
+	return func(i *Instance) {
+		i.r.rand = r
+	}
+}
+
+// WithRequestHeaders configures the Instance to add h to the headers of the
+// AlloyDB Admin API requests of its refresh operations. See
+// alloydbadmin.WithRequestHeaders.
+func WithRequestHeaders(h http.Header) Option {
+	return func(i *Instance) {
+		i.ctx = alloydbadmin.WithRequestHeaders(i.ctx, h)
+	}
+}
+
+// WithCertRefreshHook configures the Instance to call h with the expiration
+// of the client certificate of each successful refresh operation. h is called
+// on the goroutine that runs the refresh operation.
+func WithCertRefreshHook(h func(notAfter time.Time)) Option {
+	return func(i *Instance) {
+		i.r.onCert = h
+	}
+}
+
+// WithRefreshGroup configures the Instance to share refresh operations with
+// the other instances and calls to FetchConnectInfo that use g. See
+// RefreshGroup.
+func WithRefreshGroup(g *RefreshGroup) Option {
+	return func(i *Instance) {
+		i.r.group = g
+	}
+}
+
+// WithRateLimit configures the Instance to start at most burst refresh
+// operations at once and then one per interval. An interval of zero disables
+// rate limiting.
+func WithRateLimit(interval time.Duration, burst int) Option {
+	return func(i *Instance) {
+		i.r.clientLimiter = newLimiter(interval, burst)
+	}
+}
+
+// WithRefreshStrategy configures the RefreshStrategy used to schedule
+// refresh operations.
+func WithRefreshStrategy(s RefreshStrategy) Option {
+	return func(i *Instance) {
+		i.strategy = s
+	}
+}
+
+// WithForcedRefreshPreemption configures the Instance to restart a refresh
+// operation in progress when a refresh is forced, e.g., because a connection
+// attempt failed, rather than use the result of the operation in progress,
+// which may have started before the failure.
+func WithForcedRefreshPreemption() Option {
+	return func(i *Instance) {
+		i.preemptForced = true
+	}
+}
+
+// WithStaleWhileRevalidate configures the Instance to return the connection
+// info of the most recent successful refresh operation, as long as its client
+// certificate has not expired, rather than wait for a refresh operation in
+// progress to complete. If maxStaleness is positive, connection info retrieved
+// longer ago than maxStaleness is not used.
+func WithStaleWhileRevalidate(maxStaleness time.Duration) Option {
+	return func(i *Instance) {
+		i.serveStale = true
+		i.maxStaleness = maxStaleness
+	}
+}
+
+// WithCircuitBreaker configures the Instance to suspend refresh operations
+// after threshold consecutive refresh operations fail in a way that retrying
+// cannot fix, e.g., because the instance does not exist. While suspended,
+// connection attempts fail with an *errtype.CircuitOpenError and a refresh
+// operation is attempted once every probeInterval, or when a refresh is
+// forced. A successful refresh operation resumes the refresh cycle.
+func WithCircuitBreaker(threshold int, probeInterval time.Duration) Option {
+	return func(i *Instance) {
+		i.breakerThreshold = threshold
+		i.probeInterval = probeInterval
+	}
+}
+
+// ConnectInfoSource supplies the results of refresh operations in place of the
+// AlloyDB Admin API, e.g., from a cache shared by many clients.
+type ConnectInfoSource interface {
+	// ConnectInfo returns the information used to connect to the instance.
+	ConnectInfo(ctx context.Context) (ConnectInfoResult, error)
+	// ForceRefresh indicates the most recent result is unusable, e.g.,
+	// because a connection attempt with it failed.
+	ForceRefresh()
+}
+
+// WithConnectInfoSource configures the Instance to retrieve connection info
+// from s rather than the AlloyDB Admin API.
+func WithConnectInfoSource(s ConnectInfoSource) Option {
+	return func(i *Instance) {
+		i.source = s
+	}
+}
+
 // NewInstance initializes a new Instance given an instance URI
 func NewInstance(
 	instance string,
-	client *alloydbapi.Client,
-	key *rsa.PrivateKey,
+	client AdminAPI,
+	key crypto.Signer,
 	refreshTimeout time.Duration,
 	dialerID string,
+	opts ...Option,
 ) (*Instance, error) {
 	cn, err := parseInstURI(instance)
 	if err != nil {
@@ -150,6 +449,8 @@ func NewInstance(
 	ctx, cancel := context.WithCancel(context.Background())
 	i := &Instance{
 		instanceURI: cn,
+		name:        cn.String(),
+		given:       instance,
 		key:         key,
 		r: newRefresher(
 			client,
@@ -158,57 +459,485 @@ func NewInstance(
 			2,
 			dialerID,
 		),
-		ctx:    ctx,
-		cancel: cancel,
+		strategy: NewRefreshStrategy(0, 0),
+		health:   newHealthTracker(),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for _, o := range opts {
+		o(i)
 	}
 	// For the initial refresh operation, set cur = next so that connection requests block
 	// until the first refresh is complete.
 	i.resultGuard.Lock()
-	i.cur = i.scheduleRefresh(0)
-	i.next = i.cur
+	if !i.restoreSeed() {
+		i.cur = i.scheduleRefresh(0, trace.RefreshTriggerDialDemand)
+		i.next = i.cur
+	}
 	i.resultGuard.Unlock()
 	return i, nil
 }
 
+// ConnectInfoResult is the outcome of a single refresh operation.
+type ConnectInfoResult struct {
+	// Endpoints are the instance's addresses in order of preference.
+	Endpoints []Endpoint
+	// TLSConfig configures TLS connections to the instance.
+	TLSConfig *tls.Config
+	// Expiry is when the client certificate in TLSConfig expires.
+	Expiry time.Time
+}
+
+// FetchConnectInfo performs a single refresh operation for the instance,
+// independent of any refresh cycle. It is not rate limited, so callers are
+// responsible for scheduling refresh operations sensibly.
+func FetchConnectInfo(
+	ctx context.Context,
+	instance string,
+	client AdminAPI,
+	key crypto.Signer,
+	refreshTimeout time.Duration,
+	dialerID string,
+	opts ...Option,
+) (ConnectInfoResult, error) {
+	cn, err := parseInstURI(instance)
+	if err != nil {
+		return ConnectInfoResult{}, err
+	}
+	i := &Instance{
+		instanceURI: cn,
+		name:        cn.String(),
+		given:       instance,
+		key:         key,
+		// A dedicated limiter with a burst of one never delays the single
+		// refresh operation.
+		r: newRefresher(client, refreshTimeout, 30*time.Second, 1, dialerID),
+	}
+	for _, o := range opts {
+		o(i)
+	}
+	res, err := i.r.performRefresh(ctx, cn, key, trace.RefreshTriggerDialDemand)
+	if err != nil {
+		return ConnectInfoResult{}, err
+	}
+	return ConnectInfoResult{
+		Endpoints: res.endpoints,
+		TLSConfig: res.conf,
+		Expiry:    res.expiry,
+	}, nil
+}
+
 // Close closes the instance; it stops the refresh cycle and prevents it from
 // making additional calls to the AlloyDB Admin API.
 func (i *Instance) Close() {
 	i.cancel()
 }
 
-// ConnectInfo returns an IP address of the AlloyDB instance.
-func (i *Instance) ConnectInfo(ctx context.Context) (string, *tls.Config, error) {
+// ConnectInfo returns the address of the AlloyDB instance with the provided
+// IP type, one of PrivateIP, PublicIP, or PSC. If ipType is empty, the
+// preferred address is returned.
+func (i *Instance) ConnectInfo(ctx context.Context, ipType string) (string, *tls.Config, error) {
 	res, err := i.result(ctx)
 	if err != nil {
 		return "", nil, err
 	}
-	return res.result.instanceIPAddr, res.result.conf, nil
+	eps := res.result.endpoints
+	if ipType == "" {
+		var addr string
+		if len(eps) > 0 {
+			addr = eps[0].Addr
+		}
+		return addr, res.result.conf, nil
+	}
+	for _, e := range eps {
+		if e.IPType == ipType {
+			return e.Addr, res.result.conf, nil
+		}
+	}
+	return "", nil, errtype.NewConfigError(
+		fmt.Sprintf("instance does not have an IP address of type %q", ipType),
+		i.String(),
+	)
+}
+
+// Endpoints returns all addresses of the AlloyDB instance as of the most
+// recent refresh, in order of preference, along with their current health
+// scores.
+func (i *Instance) Endpoints(ctx context.Context) ([]Endpoint, error) {
+	res, err := i.result(ctx)
+	if err != nil {
+		return nil, err
+	}
+	eps := make([]Endpoint, len(res.result.endpoints))
+	for n, e := range res.result.endpoints {
+		e.Health = i.health.score(e.Addr)
+		eps[n] = e
+	}
+	return eps, nil
+}
+
+// RecordDial updates the health score of the endpoint at addr with the
+// outcome of a dial attempt. A nil err indicates success.
+func (i *Instance) RecordDial(addr string, err error) {
+	i.health.record(addr, err)
+}
+
+// Warm reports whether the instance has completed at least one successful
+// refresh operation.
+func (i *Instance) Warm() bool {
+	i.resultGuard.RLock()
+	defer i.resultGuard.RUnlock()
+	return i.warm
+}
+
+// maxRefreshFailures is the number of failed refresh operations kept for
+// Status.
+const maxRefreshFailures = 5
+
+// RefreshFailure is a failed refresh operation.
+type RefreshFailure struct {
+	// Time is when the refresh operation failed.
+	Time time.Time
+	// Err is the cause of the failure.
+	Err error
+}
+
+// Status describes the health of the instance's refresh cycle.
+type Status struct {
+	// LastSuccess is when a refresh operation last succeeded, or zero if
+	// none has.
+	LastSuccess time.Time
+	// Expiry is when the current client certificate expires, or zero if
+	// there is no valid certificate.
+	Expiry time.Time
+	// ConsecutiveFailures is the number of refresh operations that have
+	// failed since the last success.
+	ConsecutiveFailures int
+	// Failures are the most recent failed refresh operations, oldest first.
+	Failures []RefreshFailure
+	// CircuitOpen is true while refresh operations are suspended by the
+	// circuit breaker.
+	CircuitOpen bool
+}
+
+// recordResult records the outcome of a refresh operation for Status and
+// CachedInfo.
+func (i *Instance) recordResult(res refreshResult, err error) {
+	i.resultGuard.Lock()
+	defer i.resultGuard.Unlock()
+	if err == nil {
+		i.lastSuccess = time.Now()
+		i.latest = &res
+		i.consecutiveFailures = 0
+		return
+	}
+	i.consecutiveFailures++
+	i.failures = append(i.failures, RefreshFailure{Time: time.Now(), Err: err})
+	if len(i.failures) > maxRefreshFailures {
+		i.failures = i.failures[len(i.failures)-maxRefreshFailures:]
+	}
+}
+
+// Status reports the health of the instance's refresh cycle.
+func (i *Instance) Status() Status {
+	i.resultGuard.RLock()
+	defer i.resultGuard.RUnlock()
+	s := Status{
+		LastSuccess:         i.lastSuccess,
+		ConsecutiveFailures: i.consecutiveFailures,
+		Failures:            append([]RefreshFailure(nil), i.failures...),
+		CircuitOpen:         i.breakerOpen,
+	}
+	if i.cur.IsValid() {
+		s.Expiry = i.cur.result.expiry
+	}
+	return s
+}
+
+// CachedInfo is the connection info of the most recent successful refresh
+// operation.
+type CachedInfo struct {
+	// Endpoints are the instance's addresses in order of preference.
+	Endpoints []Endpoint
+	// UID is the instance UID, or the empty string if the connection info
+	// was supplied by a ConnectInfoSource.
+	UID string
+	// Expiry is when the client certificate expires.
+	Expiry time.Time
+	// RefreshedAt is when the refresh operation completed, or zero if the
+	// connection info was restored from a Snapshot.
+	RefreshedAt time.Time
+}
+
+// CachedInfo returns the connection info of the most recent successful
+// refresh operation without waiting on a refresh in progress. The connection
+// info is returned even if its certificate has expired. CachedInfo reports
+// false if no refresh operation has succeeded.
+func (i *Instance) CachedInfo() (CachedInfo, bool) {
+	i.resultGuard.RLock()
+	defer i.resultGuard.RUnlock()
+	if i.latest == nil {
+		return CachedInfo{}, false
+	}
+	eps := make([]Endpoint, len(i.latest.endpoints))
+	copy(eps, i.latest.endpoints)
+	return CachedInfo{
+		Endpoints:   eps,
+		UID:         i.latest.uid,
+		Expiry:      i.latest.expiry,
+		RefreshedAt: i.lastSuccess,
+	}, true
+}
+
+// Role returns the most recently observed role of the instance's cluster
+// without waiting on a refresh in progress, or the empty string if unknown.
+func (i *Instance) Role() string {
+	i.resultGuard.RLock()
+	defer i.resultGuard.RUnlock()
+	return i.role
+}
+
+// ClusterRole returns the role of the instance's cluster as of the most recent
+// refresh, waiting for the refresh to complete if necessary. If cluster role
+// detection is disabled or the role could not be determined, ClusterRole
+// returns the empty string.
+func (i *Instance) ClusterRole(ctx context.Context) (string, error) {
+	res, err := i.result(ctx)
+	if err != nil {
+		return "", err
+	}
+	return res.result.role, nil
 }
 
 // ForceRefresh triggers an immediate refresh operation to be scheduled and used for future connection attempts.
+// At most one refresh operation is in progress at a time: a forced refresh
+// while one is due or in progress shares its result or, if the instance was
+// configured with WithForcedRefreshPreemption, restarts it.
 func (i *Instance) ForceRefresh() {
+	if i.source != nil {
+		i.source.ForceRefresh()
+	}
 	i.resultGuard.Lock()
 	defer i.resultGuard.Unlock()
-	// If the next refresh hasn't started yet, we can cancel it and start an immediate one
-	if i.next.Cancel() {
-		i.next = i.scheduleRefresh(0)
+	// A refresh that is due may already have started, and connection
+	// attempts may be waiting on it, so it is never canceled.
+	if i.next.due.After(time.Now()) && i.next.Cancel() {
+		// If the next refresh hasn't started yet, we can cancel it and start an immediate one
+		i.next = i.scheduleRefresh(0, trace.RefreshTriggerForced)
+	} else if i.preemptForced && i.next.preempt() {
+		i.r.logger.Log(logging.Event{
+			Instance: i.String(),
+			Name:     logging.EventRefreshScheduled,
+			Message:  "refresh in progress restarted by forced refresh",
+		})
 	}
 	// block all sequential connection attempts on the next refresh result
 	i.cur = i.next
 }
 
+// refresh performs a single refresh operation using the configured source of
+// connection info. The trigger describes why the refresh was started.
+func (i *Instance) refresh(ctx context.Context, trigger string) (refreshResult, error) {
+	if i.source == nil {
+		return i.r.performRefresh(ctx, i.instanceURI, i.key, trigger)
+	}
+	i.r.logger.Log(logging.Event{
+		Instance: i.String(),
+		Name:     logging.EventRefreshStart,
+		Message:  trigger + " refresh started",
+	})
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, i.r.timeout)
+	defer cancel()
+	res, err := i.source.ConnectInfo(ctx)
+	if err != nil {
+		err = errtype.NewRefreshError(
+			"failed to get connection info from source", i.String(), err,
+		)
+	}
+	msg := "refresh succeeded"
+	if err != nil {
+		msg = "refresh failed"
+	}
+	i.r.logger.Log(logging.Event{
+		Instance: i.String(),
+		Name:     logging.EventRefresh,
+		Duration: time.Since(start),
+		Message:  msg,
+		Expiry:   res.Expiry,
+		Err:      err,
+	})
+	if err != nil {
+		return refreshResult{}, err
+	}
+	return refreshResult{
+		endpoints: res.Endpoints,
+		conf:      res.TLSConfig,
+		expiry:    res.Expiry,
+	}, nil
+}
+
 // result returns the most recent refresh result (waiting for it to complete if necessary)
 func (i *Instance) result(ctx context.Context) (*refreshOperation, error) {
 	i.resultGuard.RLock()
 	res := i.cur
 	i.resultGuard.RUnlock()
+	select {
+	case <-res.ready:
+	default:
+		if s, ok := i.staleResult(); ok {
+			return s, nil
+		}
+		// A refresh is in flight. Queue behind it rather than starting
+		// another, so that a burst of dials shares a single refresh.
+		i.recordQueued(ctx, atomic.AddInt64(&i.queued, 1))
+		defer func() { i.recordQueued(ctx, atomic.AddInt64(&i.queued, -1)) }()
+	}
 	err := res.Wait(ctx)
 	if err != nil {
-		return nil, err
+		return nil, i.circuitError(res, err)
 	}
 	return res, nil
 }
 
+// circuitError returns err, the error of the refresh operation res, as an
+// *errtype.CircuitOpenError if refresh operations are suspended because of
+// it.
+func (i *Instance) circuitError(res *refreshOperation, err error) error {
+	i.resultGuard.RLock()
+	open := i.breakerOpen && i.cur == res
+	i.resultGuard.RUnlock()
+	if !open {
+		return err
+	}
+	return errtype.NewCircuitOpenError(
+		"refresh operations suspended after repeated permanent failures", i.String(), err,
+	)
+}
+
+// recordFailure counts the permanent refresh failures for the circuit
+// breaker and reports whether refresh operations are suspended. Callers must
+// hold resultGuard.
+func (i *Instance) recordFailure(err error) bool {
+	if i.breakerThreshold <= 0 {
+		return false
+	}
+	var t interface{ Temporary() bool }
+	if errors.As(err, &t) && !t.Temporary() {
+		i.permanentFailures++
+	} else {
+		i.permanentFailures = 0
+	}
+	if !i.breakerOpen && i.permanentFailures >= i.breakerThreshold {
+		i.breakerOpen = true
+		i.r.logger.Log(logging.Event{
+			Instance: i.String(),
+			Name:     logging.EventCircuitBreaker,
+			Message: fmt.Sprintf(
+				"refresh suspended after %v permanent failures, probing every %v",
+				i.permanentFailures, i.probeInterval,
+			),
+			Err: err,
+		})
+	}
+	return i.breakerOpen
+}
+
+// recordSuccess resumes refresh operations suspended by the circuit breaker.
+// Callers must hold resultGuard.
+func (i *Instance) recordSuccess() {
+	if i.breakerOpen {
+		i.r.logger.Log(logging.Event{
+			Instance: i.String(),
+			Name:     logging.EventCircuitBreaker,
+			Message:  "refresh resumed",
+		})
+	}
+	i.breakerOpen = false
+	i.permanentFailures = 0
+}
+
+// staleResult returns the result of the most recent successful refresh
+// operation as a completed operation if the Instance is configured with
+// WithStaleWhileRevalidate and the result may still be used.
+func (i *Instance) staleResult() (*refreshOperation, bool) {
+	if !i.serveStale {
+		return nil, false
+	}
+	i.resultGuard.RLock()
+	latest, at := i.latest, i.lastSuccess
+	i.resultGuard.RUnlock()
+	now := time.Now()
+	if latest == nil || !now.Before(latest.expiry) {
+		return nil, false
+	}
+	if i.maxStaleness > 0 && now.Sub(at) > i.maxStaleness {
+		return nil, false
+	}
+	op := &refreshOperation{result: *latest, ready: make(chan struct{})}
+	close(op.ready)
+	return op, true
+}
+
+// recordQueued reports the number of dials waiting on an in-flight refresh
+// operation.
+func (i *Instance) recordQueued(ctx context.Context, n int64) {
+	i.r.recorder.RecordDialQueueDepth(ctx, i.String(), i.r.dialerID, n)
+}
+
+// RefreshStrategy determines when the next refresh operation starts.
+type RefreshStrategy interface {
+	// NextRefresh returns how long to wait before starting the next refresh
+	// given the current time and the expiration of the current client
+	// certificate.
+	NextRefresh(now, certExpiry time.Time) time.Duration
+}
+
+// NewRefreshStrategy returns the default RefreshStrategy. When buffer is zero,
+// the next refresh starts roughly halfway to certificate expiration.
+// Otherwise, the next refresh starts buffer before the certificate expires.
+// When jitter is non-zero, a random duration up to jitter is subtracted from
+// the wait so that many clients do not refresh in lockstep.
+func NewRefreshStrategy(buffer, jitter time.Duration) RefreshStrategy {
+	return defaultRefreshStrategy{buffer: buffer, jitter: jitter}
+}
+
+// jitterRand is seeded per process so that jitter differs across clients.
+var jitterRand = &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// lockedRand is a *rand.Rand that is safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) int63n(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63n(n)
+}
+
+type defaultRefreshStrategy struct {
+	buffer time.Duration
+	jitter time.Duration
+}
+
+func (s defaultRefreshStrategy) NextRefresh(now, certExpiry time.Time) time.Duration {
+	var d time.Duration
+	if s.buffer > 0 {
+		d = certExpiry.Sub(now) - s.buffer
+	} else {
+		d = refreshDuration(now, certExpiry)
+	}
+	if s.jitter > 0 && d > 0 {
+		d -= time.Duration(jitterRand.int63n(int64(s.jitter)))
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
 // refreshDuration returns the duration to wait before starting the next
 // refresh. Usually that duration will be half of the time until certificate
 // expiration.
@@ -226,45 +955,110 @@ func refreshDuration(now, certExpiry time.Time) time.Duration {
 }
 
 // scheduleRefresh schedules a refresh operation to be triggered after a given
-// duration. The returned refreshOperation can be used to either Cancel or Wait
-// for the operations result.
-func (i *Instance) scheduleRefresh(d time.Duration) *refreshOperation {
-	res := &refreshOperation{}
+// duration. The trigger (one of the trace.RefreshTrigger constants) describes
+// why the refresh is needed. The returned refreshOperation can be used to
+// either Cancel or Wait for the operations result.
+func (i *Instance) scheduleRefresh(d time.Duration, trigger string) *refreshOperation {
+	i.r.logger.Log(logging.Event{
+		Instance: i.String(),
+		Name:     logging.EventRefreshScheduled,
+		Message: fmt.Sprintf(
+			"%v refresh scheduled to start at %v",
+			trigger, time.Now().Add(d).UTC().Format(time.RFC3339),
+		),
+	})
+	res := &refreshOperation{due: time.Now().Add(d)}
 	res.ready = make(chan struct{})
 	res.timer = time.AfterFunc(d, func() {
-		res.result, res.err = i.r.performRefresh(i.ctx, i.instanceURI, i.key)
+		res.run(i, trigger)
+		// Record the outcome before any waiting caller can observe it.
+		i.recordResult(res.result, res.err)
 		close(res.ready)
+		if res.err == nil && i.onSnapshot != nil {
+			if s, ok := i.snapshot(res.result); ok {
+				i.onSnapshot(s)
+			}
+		}
 
 		// Once the refresh is complete, update "current" with working result and schedule a new refresh
 		i.resultGuard.Lock()
 		defer i.resultGuard.Unlock()
 		// if failed, scheduled the next refresh immediately
 		if res.err != nil {
-			i.next = i.scheduleRefresh(0)
+			open := i.recordFailure(res.err)
+			// Retries keep the trigger of the failed refresh. Once the
+			// instance has been closed, there is nothing to retry.
+			if i.ctx.Err() == nil {
+				var d time.Duration
+				if open {
+					d = i.probeInterval
+				}
+				i.next = i.scheduleRefresh(d, trigger)
+			}
 			// If the latest result is bad, avoid replacing the used result while it's
 			// still valid and potentially able to provide successful connections.
 			// TODO: This means that errors while the current result is still valid are
 			// surpressed. We should try to surface errors in a more meaningful way.
-			if !i.cur.IsValid() {
+			// Once refresh operations are suspended, connection attempts
+			// fail rather than use the result until it expires.
+			if open || !i.cur.IsValid() {
 				i.cur = res
 			}
 			return
 		}
 		// Update the current results, and schedule the next refresh in the future
 		i.cur = res
+		i.warm = true
+		i.recordSuccess()
 		select {
 		case <-i.ctx.Done():
 			// instance has been closed, don't schedule anything
 			return
 		default:
 		}
-		t := refreshDuration(time.Now(), i.cur.result.expiry)
-		i.next = i.scheduleRefresh(t)
+		t := i.strategy.NextRefresh(time.Now(), i.cur.result.expiry)
+		if t < 0 {
+			t = 0
+		}
+		next := trace.RefreshTriggerScheduled
+		if i.roleChanged(res.result.role) {
+			// The cluster was promoted (or demoted) while the refresh was
+			// running. The connection info may predate the change, so
+			// re-resolve it immediately.
+			t = 0
+			next = trace.RefreshTriggerFailover
+		}
+		i.next = i.scheduleRefresh(t, next)
 	})
 	return res
 }
 
+// roleChanged records the most recently observed cluster role and reports
+// whether it differs from a previously known role. Callers must hold
+// resultGuard.
+func (i *Instance) roleChanged(role string) bool {
+	prev := i.role
+	if role == "" {
+		return false
+	}
+	i.role = role
+	if prev == "" || prev == role {
+		return false
+	}
+	i.r.logger.Log(logging.Event{
+		Instance: i.String(),
+		Name:     logging.EventClusterRole,
+		Message:  fmt.Sprintf("cluster role changed from %v to %v", prev, role),
+	})
+	return true
+}
+
 // String returns the instance's URI.
 func (i *Instance) String() string {
-	return i.instanceURI.String()
+	return i.name
+}
+
+// URI returns the instance URI as passed to NewInstance.
+func (i *Instance) URI() string {
+	return i.given
 }
@@ -18,13 +18,18 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
 	"cloud.google.com/go/alloydbconn/errtype"
-	"cloud.google.com/go/alloydbconn/internal/alloydbapi"
 	"cloud.google.com/go/alloydbconn/internal/mock"
+	"cloud.google.com/go/alloydbconn/internal/trace"
 	"golang.org/x/oauth2"
 	"google.golang.org/api/option"
 )
@@ -140,7 +145,7 @@ func TestConnectInfo(t *testing.T) {
 			t.Fatalf("%v", err)
 		}
 	}()
-	c, err := alloydbapi.NewClient(ctx, option.WithHTTPClient(mc),
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc),
 		option.WithEndpoint(url),
 		option.WithTokenSource(stubTokenSource{}),
 	)
@@ -156,7 +161,7 @@ func TestConnectInfo(t *testing.T) {
 		t.Fatalf("failed to create mock instance: %v", err)
 	}
 
-	gotAddr, _, err := i.ConnectInfo(ctx)
+	gotAddr, _, err := i.ConnectInfo(ctx, "")
 	if err != nil {
 		t.Fatalf("failed to retrieve connect info: %v", err)
 	}
@@ -169,9 +174,72 @@ func TestConnectInfo(t *testing.T) {
 	}
 }
 
+func TestEndpoints(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithIPAddr("10.0.0.1"),
+		mock.WithPublicIPAddr("34.0.0.1"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	i, err := NewInstance(
+		"/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+		c, RSAKey, 30*time.Second, "dialer-id",
+	)
+	if err != nil {
+		t.Fatalf("failed to create mock instance: %v", err)
+	}
+	defer i.Close()
+
+	addr, _, err := i.ConnectInfo(ctx, "")
+	if err != nil {
+		t.Fatalf("failed to retrieve connect info: %v", err)
+	}
+	if addr != "10.0.0.1" {
+		t.Fatalf("want private IP to be preferred, got = %v", addr)
+	}
+	addr, _, err = i.ConnectInfo(ctx, PublicIP)
+	if err != nil {
+		t.Fatalf("failed to retrieve public connect info: %v", err)
+	}
+	if addr != "34.0.0.1" {
+		t.Fatalf("want public IP, got = %v", addr)
+	}
+	if _, _, err = i.ConnectInfo(ctx, PSC); err == nil {
+		t.Fatal("want error for missing PSC endpoint, got nil")
+	}
+
+	i.RecordDial("34.0.0.1", errors.New("connection refused"))
+	eps, err := i.Endpoints(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve endpoints: %v", err)
+	}
+	if len(eps) != 2 {
+		t.Fatalf("want 2 endpoints, got = %v", eps)
+	}
+	if eps[0].IPType != PrivateIP || eps[0].Health != 1 {
+		t.Errorf("private endpoint: want healthy %v, got = %+v", PrivateIP, eps[0])
+	}
+	if eps[1].IPType != PublicIP || eps[1].Health >= 1 {
+		t.Errorf("public endpoint: want degraded %v, got = %+v", PublicIP, eps[1])
+	}
+}
+
 func TestConnectInfoErrors(t *testing.T) {
 	ctx := context.Background()
-	c, err := alloydbapi.NewClient(ctx, option.WithTokenSource(stubTokenSource{}))
+	c, err := alloydbadmin.NewClient(ctx, option.WithTokenSource(stubTokenSource{}))
 	if err != nil {
 		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
 	}
@@ -185,7 +253,7 @@ func TestConnectInfoErrors(t *testing.T) {
 		t.Fatalf("failed to initialize Instance: %v", err)
 	}
 
-	_, _, err = im.ConnectInfo(ctx)
+	_, _, err = im.ConnectInfo(ctx, "")
 	var wantErr *errtype.DialError
 	if !errors.As(err, &wantErr) {
 		t.Fatalf("when connect info fails, want = %T, got = %v", wantErr, err)
@@ -194,7 +262,7 @@ func TestConnectInfoErrors(t *testing.T) {
 
 func TestClose(t *testing.T) {
 	ctx := context.Background()
-	c, err := alloydbapi.NewClient(ctx, option.WithTokenSource(stubTokenSource{}))
+	c, err := alloydbadmin.NewClient(ctx, option.WithTokenSource(stubTokenSource{}))
 	if err != nil {
 		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
 	}
@@ -209,7 +277,7 @@ func TestClose(t *testing.T) {
 	}
 	im.Close()
 
-	_, _, err = im.ConnectInfo(ctx)
+	_, _, err = im.ConnectInfo(ctx, "")
 	if !errors.Is(err, context.Canceled) {
 		t.Fatalf("failed to retrieve connect info: %v", err)
 	}
@@ -258,3 +326,534 @@ func TestRefreshDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshStrategy(t *testing.T) {
+	now := time.Now()
+	expiry := now.Add(time.Hour)
+	tcs := []struct {
+		desc    string
+		buffer  time.Duration
+		jitter  time.Duration
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{
+			desc:    "with defaults",
+			wantMin: 30 * time.Minute,
+			wantMax: 30 * time.Minute,
+		},
+		{
+			desc:    "with a buffer",
+			buffer:  10 * time.Minute,
+			wantMin: 50 * time.Minute,
+			wantMax: 50 * time.Minute,
+		},
+		{
+			desc:    "with a buffer longer than the certificate lifetime",
+			buffer:  2 * time.Hour,
+			wantMin: 0,
+			wantMax: 0,
+		},
+		{
+			desc:    "with jitter",
+			jitter:  5 * time.Minute,
+			wantMin: 25 * time.Minute,
+			wantMax: 30 * time.Minute,
+		},
+		{
+			desc:    "with a buffer and jitter",
+			buffer:  10 * time.Minute,
+			jitter:  5 * time.Minute,
+			wantMin: 45 * time.Minute,
+			wantMax: 50 * time.Minute,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := NewRefreshStrategy(tc.buffer, tc.jitter)
+			for n := 0; n < 10; n++ {
+				got := s.NextRefresh(now, expiry).Round(time.Second)
+				if got < tc.wantMin || got > tc.wantMax {
+					t.Fatalf("want between %v and %v, got = %v", tc.wantMin, tc.wantMax, got)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterRole(t *testing.T) {
+	ctx := context.Background()
+	secondary := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithClusterType("SECONDARY"),
+	)
+	primary := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// Don't use the cleanup function. The promotion triggers an additional
+	// refresh that is throttled and canceled when the instance is closed.
+	mc, url, _ := mock.HTTPClient(
+		mock.InstanceGetSuccess(secondary, 3),
+		mock.CreateEphemeralSuccess(secondary, 3),
+		mock.ClusterGetSuccess(secondary, 1),
+		mock.ClusterGetSuccess(primary, 2),
+	)
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc),
+		option.WithEndpoint(url),
+		option.WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	i, err := NewInstance(
+		"/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+		c, RSAKey, 30*time.Second, "dialer-id",
+		WithClusterRoleDetection(),
+	)
+	if err != nil {
+		t.Fatalf("failed to create mock instance: %v", err)
+	}
+	defer i.Close()
+
+	got, err := i.ClusterRole(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve cluster role: %v", err)
+	}
+	if got != ClusterRoleSecondary {
+		t.Fatalf("want = %v, got = %v", ClusterRoleSecondary, got)
+	}
+
+	// simulate a promotion of the secondary cluster
+	i.ForceRefresh()
+	got, err = i.ClusterRole(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve cluster role: %v", err)
+	}
+	if got != ClusterRolePrimary {
+		t.Fatalf("want = %v, got = %v", ClusterRolePrimary, got)
+	}
+
+	// the promotion schedules an immediate refresh to re-resolve the
+	// connection info
+	var next *refreshOperation
+	for start := time.Now(); time.Since(start) < time.Second; {
+		i.resultGuard.RLock()
+		cur := i.cur
+		next = i.next
+		i.resultGuard.RUnlock()
+		if next != cur {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if next.Cancel() {
+		t.Fatal("want re-resolving refresh to have started, but it was pending")
+	}
+}
+
+func TestClusterRoleDisabled(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc),
+		option.WithEndpoint(url),
+		option.WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	i, err := NewInstance(
+		"/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+		c, RSAKey, 30*time.Second, "dialer-id",
+	)
+	if err != nil {
+		t.Fatalf("failed to create mock instance: %v", err)
+	}
+	defer i.Close()
+
+	got, err := i.ClusterRole(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve cluster role: %v", err)
+	}
+	if got != "" {
+		t.Fatalf("want unknown role, got = %v", got)
+	}
+}
+
+// triggerRecorder records the trigger of each refresh result.
+type triggerRecorder struct {
+	trace.Recorder
+	mu       sync.Mutex
+	triggers []string
+}
+
+func (r *triggerRecorder) RecordRefreshResult(_ context.Context, _, _, trigger string, _ error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.triggers = append(r.triggers, trigger)
+}
+
+func (r *triggerRecorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.triggers...)
+}
+
+func TestRefreshTriggers(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance("my-project", "my-region", "my-cluster", "my-instance")
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	rec := &triggerRecorder{Recorder: trace.OpenCensus()}
+	i, err := NewInstance(
+		"/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+		c, RSAKey, 30*time.Second, "dialer-id",
+		WithRecorder(rec),
+	)
+	if err != nil {
+		t.Fatalf("failed to create mock instance: %v", err)
+	}
+	defer i.Close()
+
+	if _, _, err := i.ConnectInfo(ctx, ""); err != nil {
+		t.Fatalf("failed to retrieve connect info: %v", err)
+	}
+	i.ForceRefresh()
+	if _, _, err := i.ConnectInfo(ctx, ""); err != nil {
+		t.Fatalf("failed to retrieve connect info: %v", err)
+	}
+
+	want := []string{trace.RefreshTriggerDialDemand, trace.RefreshTriggerForced}
+	var got []string
+	// Results are recorded asynchronously.
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		if got = rec.recorded(); len(got) >= len(want) {
+			break
+		}
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+}
+
+// blockingSource is a ConnectInfoSource that blocks until release is closed
+// and counts how often it is called.
+type blockingSource struct {
+	release chan struct{}
+	calls   int32
+
+	// inFlight and maxInFlight are the current and largest number of
+	// concurrent calls.
+	inFlight, maxInFlight int32
+}
+
+func (s *blockingSource) ConnectInfo(ctx context.Context) (ConnectInfoResult, error) {
+	atomic.AddInt32(&s.calls, 1)
+	n := atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+	for {
+		m := atomic.LoadInt32(&s.maxInFlight)
+		if n <= m || atomic.CompareAndSwapInt32(&s.maxInFlight, m, n) {
+			break
+		}
+	}
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		return ConnectInfoResult{}, ctx.Err()
+	}
+	return ConnectInfoResult{
+		Endpoints: []Endpoint{{IPType: "PRIVATE", Addr: "127.0.0.1"}},
+		TLSConfig: &tls.Config{},
+		Expiry:    time.Now().Add(time.Hour),
+	}, nil
+}
+
+func (s *blockingSource) ForceRefresh() {}
+
+// queueRecorder records the largest reported dial queue depth.
+type queueRecorder struct {
+	trace.Recorder
+	mu       sync.Mutex
+	cur, max int64
+}
+
+func (r *queueRecorder) RecordDialQueueDepth(_ context.Context, _, _ string, depth int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cur = depth
+	if depth > r.max {
+		r.max = depth
+	}
+}
+
+func (r *queueRecorder) depths() (cur, max int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cur, r.max
+}
+
+func TestConnectInfoCoalescesWaits(t *testing.T) {
+	ctx := context.Background()
+	src := &blockingSource{release: make(chan struct{})}
+	rec := &queueRecorder{Recorder: trace.OpenCensus()}
+	i, err := NewInstance(
+		"/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+		nil, RSAKey, 30*time.Second, "dialer-id",
+		WithConnectInfoSource(src),
+		WithRecorder(rec),
+	)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	defer i.Close()
+
+	const dials = 10
+	errs := make(chan error, dials)
+	for n := 0; n < dials; n++ {
+		go func() {
+			_, _, err := i.ConnectInfo(ctx, "")
+			errs <- err
+		}()
+	}
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		if cur, _ := rec.depths(); cur == dials {
+			break
+		}
+	}
+	if cur, _ := rec.depths(); cur != dials {
+		t.Fatalf("dial queue depth: want = %v, got = %v", dials, cur)
+	}
+	close(src.release)
+	for n := 0; n < dials; n++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("failed to retrieve connect info: %v", err)
+		}
+	}
+	if got := atomic.LoadInt32(&src.calls); got != 1 {
+		t.Fatalf("want 1 refresh, got = %v", got)
+	}
+	if cur, max := rec.depths(); cur != 0 || max != dials {
+		t.Fatalf("dial queue depth: want final = 0 and max = %v, got = %v and %v", dials, cur, max)
+	}
+}
+
+// waitForCalls waits until src has been called n times.
+func waitForCalls(t *testing.T, src *blockingSource, n int32) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		if atomic.LoadInt32(&src.calls) >= n {
+			return
+		}
+	}
+	t.Fatalf("want %v refreshes, got = %v", n, atomic.LoadInt32(&src.calls))
+}
+
+func TestForceRefreshCoalesces(t *testing.T) {
+	ctx := context.Background()
+	src := &blockingSource{release: make(chan struct{})}
+	i, err := NewInstance(
+		"/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+		nil, RSAKey, 30*time.Second, "dialer-id",
+		WithConnectInfoSource(src),
+	)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	defer i.Close()
+
+	// Forced refreshes while the initial refresh is due or in progress
+	// share its result.
+	var wg sync.WaitGroup
+	for n := 0; n < 5; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i.ForceRefresh()
+		}()
+	}
+	waitForCalls(t, src, 1)
+	for n := 0; n < 5; n++ {
+		i.ForceRefresh()
+	}
+	wg.Wait()
+	close(src.release)
+	if _, _, err := i.ConnectInfo(ctx, ""); err != nil {
+		t.Fatalf("failed to retrieve connect info: %v", err)
+	}
+	if got := atomic.LoadInt32(&src.calls); got != 1 {
+		t.Fatalf("want 1 refresh, got = %v", got)
+	}
+}
+
+func TestForceRefreshPreemption(t *testing.T) {
+	ctx := context.Background()
+	src := &blockingSource{release: make(chan struct{})}
+	i, err := NewInstance(
+		"/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+		nil, RSAKey, 30*time.Second, "dialer-id",
+		WithConnectInfoSource(src),
+		WithForcedRefreshPreemption(),
+	)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	defer i.Close()
+
+	waitForCalls(t, src, 1)
+	i.ForceRefresh()
+	// The refresh in progress is canceled and started over.
+	waitForCalls(t, src, 2)
+	close(src.release)
+	if _, _, err := i.ConnectInfo(ctx, ""); err != nil {
+		t.Fatalf("want the restarted refresh's result, got error: %v", err)
+	}
+	if got := atomic.LoadInt32(&src.calls); got != 2 {
+		t.Fatalf("want 2 refreshes, got = %v", got)
+	}
+	if got := atomic.LoadInt32(&src.maxInFlight); got != 1 {
+		t.Fatalf("want at most 1 refresh in flight, got = %v", got)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	tcs := []struct {
+		desc         string
+		maxStaleness time.Duration
+		wantStale    bool
+	}{
+		{desc: "within maximum staleness", maxStaleness: 0, wantStale: true},
+		{desc: "exceeds maximum staleness", maxStaleness: time.Nanosecond},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			src := &blockingSource{release: make(chan struct{})}
+			i, err := NewInstance(
+				"/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+				nil, RSAKey, 30*time.Second, "dialer-id",
+				WithConnectInfoSource(src),
+				WithStaleWhileRevalidate(tc.maxStaleness),
+			)
+			if err != nil {
+				t.Fatalf("failed to create instance: %v", err)
+			}
+			defer i.Close()
+
+			// The initial refresh is always waited on.
+			src.release <- struct{}{}
+			if _, _, err := i.ConnectInfo(context.Background(), ""); err != nil {
+				t.Fatalf("failed to retrieve connect info: %v", err)
+			}
+			i.ForceRefresh()
+			waitForCalls(t, src, 2)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			addr, _, err := i.ConnectInfo(ctx, "")
+			if tc.wantStale {
+				if err != nil || addr != "127.0.0.1" {
+					t.Fatalf("want stale connect info, got = %v, %v", addr, err)
+				}
+				return
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("want %v, got = %v", context.DeadlineExceeded, err)
+			}
+		})
+	}
+}
+
+// failingSource is a ConnectInfoSource whose refreshes fail with err.
+type failingSource struct {
+	err   error
+	calls int32
+}
+
+func (s *failingSource) ConnectInfo(context.Context) (ConnectInfoResult, error) {
+	atomic.AddInt32(&s.calls, 1)
+	return ConnectInfoResult{}, s.err
+}
+
+func (s *failingSource) ForceRefresh() {}
+
+func TestCircuitBreaker(t *testing.T) {
+	src := &failingSource{err: errtype.NewConfigError("instance deleted", "my-instance")}
+	i, err := NewInstance(
+		"/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+		nil, RSAKey, 30*time.Second, "dialer-id",
+		WithConnectInfoSource(src),
+		WithCircuitBreaker(3, time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	defer i.Close()
+
+	for start := time.Now(); !i.Status().CircuitOpen; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("want circuit open, got closed")
+		}
+	}
+	_, _, err = i.ConnectInfo(context.Background(), "")
+	var cErr *errtype.CircuitOpenError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+	// Refreshes are suspended until the probe interval passes.
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt32(&src.calls); got != 3 {
+		t.Fatalf("want 3 refreshes, got = %v", got)
+	}
+	// A forced refresh probes immediately.
+	i.ForceRefresh()
+	i.ConnectInfo(context.Background(), "")
+	if got := atomic.LoadInt32(&src.calls); got != 4 {
+		t.Fatalf("want 4 refreshes, got = %v", got)
+	}
+}
+
+func TestCircuitBreakerIgnoresTemporaryFailures(t *testing.T) {
+	src := &failingSource{err: errors.New("connection reset")}
+	i, err := NewInstance(
+		"/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+		nil, RSAKey, 30*time.Second, "dialer-id",
+		WithConnectInfoSource(src),
+		WithCircuitBreaker(1, time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	defer i.Close()
+
+	for start := time.Now(); atomic.LoadInt32(&src.calls) < 3; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("want failed refreshes to be retried")
+		}
+	}
+	if i.Status().CircuitOpen {
+		t.Fatal("want circuit closed, got open")
+	}
+}
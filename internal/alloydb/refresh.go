@@ -17,25 +17,37 @@ package alloydb
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
 	"cloud.google.com/go/alloydbconn/errtype"
-	"cloud.google.com/go/alloydbconn/internal/alloydbapi"
+	"cloud.google.com/go/alloydbconn/internal/logging"
 	"cloud.google.com/go/alloydbconn/internal/trace"
 	"golang.org/x/time/rate"
 )
 
+// AdminAPI is the subset of the AlloyDB Admin API used to connect to
+// instances. It is satisfied by *alloydbadmin.Client.
+type AdminAPI interface {
+	ConnectionInfo(ctx context.Context, project, region, cluster, instance string) (alloydbadmin.ConnectionInfoResponse, error)
+	GenerateClientCert(ctx context.Context, project, region, cluster string, csr []byte) (alloydbadmin.GenerateClientCertificateResponse, error)
+	Cluster(ctx context.Context, project, region, cluster string) (alloydbadmin.ClusterResponse, error)
+}
+
 type connectInfo struct {
-	// ipAddr is the instance's IP addresss
-	ipAddr string
+	// endpoints are the instance's addresses in order of preference.
+	endpoints []Endpoint
 	// uid is the instance UID
 	uid string
 }
@@ -43,15 +55,72 @@ type connectInfo struct {
 // fetchMetadata uses the AlloyDB Admin APIs get method to retreive the
 // information about an AlloyDB instance that is used to create secure
 // connections.
-func fetchMetadata(ctx context.Context, cl *alloydbapi.Client, inst instanceURI) (i connectInfo, err error) {
+func fetchMetadata(ctx context.Context, cl AdminAPI, tr trace.Recorder, inst instanceURI) (i connectInfo, err error) {
 	var end trace.EndSpanFunc
-	ctx, end = trace.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.FetchMetadata")
+	ctx, end = tr.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.FetchMetadata")
 	defer func() { end(err) }()
-	resp, err := cl.ConnectionInfo(ctx, inst.project, inst.region, inst.cluster, inst.name)
+	var resp alloydbadmin.ConnectionInfoResponse
+	err = alloydbadmin.Retry(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = cl.ConnectionInfo(ctx, inst.project, inst.region, inst.cluster, inst.name)
+		return err
+	})
 	if err != nil {
 		return connectInfo{}, errtype.NewRefreshError("failed to get instance metadata", inst.String(), err)
 	}
-	return connectInfo{ipAddr: resp.IPAddress, uid: resp.InstanceUID}, nil
+	var eps []Endpoint
+	for _, e := range []Endpoint{
+		{IPType: PrivateIP, Addr: resp.IPAddress},
+		{IPType: PublicIP, Addr: resp.PublicIPAddress},
+		{IPType: PSC, Addr: resp.PSCDNSName},
+	} {
+		if e.Addr != "" {
+			e.Addr = unbracket(e.Addr)
+			eps = append(eps, e)
+		}
+	}
+	return connectInfo{endpoints: eps, uid: resp.InstanceUID}, nil
+}
+
+// unbracket removes the brackets from an IPv6 literal, e.g., "[2001:db8::1]",
+// so that addresses can always be joined with a port by net.JoinHostPort.
+func unbracket(addr string) string {
+	if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		return addr[1 : len(addr)-1]
+	}
+	return addr
+}
+
+const (
+	// ClusterRolePrimary indicates an instance belongs to a primary cluster.
+	ClusterRolePrimary = "PRIMARY"
+	// ClusterRoleSecondary indicates an instance belongs to a secondary
+	// (read-only) cluster.
+	ClusterRoleSecondary = "SECONDARY"
+)
+
+// fetchClusterRole uses the AlloyDB Admin API's get method to retrieve the
+// role of the instance's cluster. Any cluster type other than PRIMARY or
+// SECONDARY is reported as unknown (the empty string).
+func fetchClusterRole(ctx context.Context, cl AdminAPI, tr trace.Recorder, inst instanceURI) (role string, err error) {
+	var end trace.EndSpanFunc
+	ctx, end = tr.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.FetchClusterRole")
+	defer func() { end(err) }()
+	var resp alloydbadmin.ClusterResponse
+	err = alloydbadmin.Retry(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = cl.Cluster(ctx, inst.project, inst.region, inst.cluster)
+		return err
+	})
+	if err != nil {
+		return "", errtype.NewRefreshError("failed to get cluster", inst.String(), err)
+	}
+	switch resp.ClusterType {
+	case ClusterRolePrimary, ClusterRoleSecondary:
+		return resp.ClusterType, nil
+	default:
+		return "", nil
+	}
 }
 
 var errInvalidPEM = errors.New("certificate is not a valid PEM")
@@ -69,12 +138,15 @@ func parseCert(cert string) (*x509.Certificate, error) {
 // AlloyDB instance's serverside proxy. The cert is valid for twenty four hours.
 func fetchEphemeralCert(
 	ctx context.Context,
-	cl *alloydbapi.Client,
+	cl AdminAPI,
+	tr trace.Recorder,
 	inst instanceURI,
-	key *rsa.PrivateKey,
+	key crypto.Signer,
+	dialerID string,
+	rnd io.Reader,
 ) (cc certChain, err error) {
 	var end trace.EndSpanFunc
-	ctx, end = trace.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.FetchEphemeralCert")
+	ctx, end = tr.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.FetchEphemeralCert")
 	defer func() { end(err) }()
 
 	subj := pkix.Name{
@@ -89,13 +161,27 @@ func fetchEphemeralCert(
 		Subject:            subj,
 		SignatureAlgorithm: x509.SHA256WithRSA,
 	}
-	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &tmpl, key)
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	csrBytes, err := x509.CreateCertificateRequest(rnd, &tmpl, key)
 	if err != nil {
 		return certChain{}, err
 	}
 	buf := &bytes.Buffer{}
 	pem.Encode(buf, &pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrBytes})
-	resp, err := cl.GenerateClientCert(ctx, inst.project, inst.region, inst.cluster, buf.Bytes())
+	var resp alloydbadmin.GenerateClientCertificateResponse
+	err = alloydbadmin.Retry(ctx, func(ctx context.Context) error {
+		start := time.Now()
+		var err error
+		resp, err = cl.GenerateClientCert(ctx, inst.project, inst.region, inst.cluster, buf.Bytes())
+		// Only successful calls are recorded, so that fast failures do not
+		// skew the distribution.
+		if err == nil {
+			tr.RecordCertIssuanceLatency(ctx, inst.String(), dialerID, time.Since(start).Milliseconds())
+		}
+		return err
+	})
 	if err != nil {
 		return certChain{}, errtype.NewRefreshError(
 			"create ephemeral cert failed",
@@ -103,50 +189,69 @@ func fetchEphemeralCert(
 			err,
 		)
 	}
-	// There should always be two certs in the chain. If this fails, the API has
-	// broken its contract with the client.
-	if len(resp.PemCertificateChain) != 2 {
-		return certChain{}, errtype.NewRefreshError(
-			"missing instance and root certificates",
-			inst.String(),
-			err,
-		)
-	}
-	rc, err := parseCert(resp.PemCertificateChain[1]) // root cert
+	cc, err = parseCertChain(resp.PemCertificate, resp.PemCertificateChain)
 	if err != nil {
 		return certChain{}, errtype.NewRefreshError(
-			"failed to parse root cert",
+			"invalid client certificate chain",
 			inst.String(),
 			err,
 		)
 	}
-	ic, err := parseCert(resp.PemCertificateChain[0]) // intermediate cert
+	return cc, nil
+}
+
+// parseCertChain parses the PEM encoded client certificate and its chain,
+// which holds any number of intermediate CAs, starting with the issuer of the
+// client certificate, followed by the root CA. The depth of the chain is not
+// fixed, as the AlloyDB Admin API may change it. The client certificate must
+// chain to the root CA through the intermediate CAs.
+func parseCertChain(client string, chain []string) (certChain, error) {
+	if len(chain) == 0 {
+		return certChain{}, errors.New("missing root certificate")
+	}
+	last := len(chain) - 1
+	rc, err := parseCert(chain[last])
 	if err != nil {
-		return certChain{}, errtype.NewRefreshError(
-			"failed to parse intermediate cert",
-			inst.String(),
-			err,
-		)
+		return certChain{}, fmt.Errorf("failed to parse root cert: %w", err)
+	}
+	ics := make([]*x509.Certificate, 0, last)
+	for _, p := range chain[:last] {
+		ic, err := parseCert(p)
+		if err != nil {
+			return certChain{}, fmt.Errorf("failed to parse intermediate cert: %w", err)
+		}
+		ics = append(ics, ic)
 	}
-	c, err := parseCert(resp.PemCertificate) // client cert
+	c, err := parseCert(client)
 	if err != nil {
-		return certChain{}, errtype.NewRefreshError(
-			"failed to parse client cert",
-			inst.String(),
-			err,
-		)
+		return certChain{}, fmt.Errorf("failed to parse client cert: %w", err)
 	}
-
-	return certChain{
-		root:         rc,
-		intermediate: ic,
-		client:       c,
-	}, nil
+	cc := certChain{root: rc, intermediates: ics, client: c}
+	if err := cc.verify(); err != nil {
+		return certChain{}, err
+	}
+	return cc, nil
 }
 
+// nameCheck determines how the server certificate is checked against the
+// instance, once its chain has been verified.
+type nameCheck int
+
+const (
+	// nameCheckDefault verifies the certificate's subject alternative names
+	// or, if it has none, its CN; see verifyServerName.
+	nameCheckDefault nameCheck = iota
+	// nameCheckRequireSAN rejects certificates without subject alternative
+	// names rather than verifying their CN.
+	nameCheckRequireSAN
+	// nameCheckSkip accepts any certificate issued by the instance's CA.
+	nameCheckSkip
+)
+
 // createTLSConfig returns a *tls.Config for connecting securely to the AlloyDB
-// instance.
-func createTLSConfig(inst instanceURI, cc certChain, info connectInfo, k *rsa.PrivateKey) *tls.Config {
+// instance. The server certificate's chain is always verified; check
+// determines whether and how it must identify the instance.
+func createTLSConfig(inst instanceURI, cc certChain, info connectInfo, k crypto.Signer, check nameCheck) *tls.Config {
 	certs := x509.NewCertPool()
 	certs.AddCert(cc.root)
 
@@ -172,19 +277,16 @@ func createTLSConfig(inst instanceURI, cc certChain, info connectInfo, k *rsa.Pr
 				return errtype.NewDialError("failed to verify certificate", inst.String(), err)
 			}
 
-			serverName := fmt.Sprintf("%v.server.alloydb", info.uid)
-			if server.Subject.CommonName != serverName {
-				return errtype.NewDialError(
-					fmt.Sprintf("certificate had CN %q, expected %q",
-						server.Subject.CommonName, serverName),
-					inst.String(),
-					nil,
-				)
+			if check == nameCheckSkip {
+				return nil
+			}
+			if err := verifyServerName(server, info, check == nameCheckRequireSAN); err != nil {
+				return errtype.NewDialError(err.Error(), inst.String(), errtype.ErrTLSVerificationFailed)
 			}
 			return nil
 		},
 		Certificates: []tls.Certificate{tls.Certificate{
-			Certificate: [][]byte{cc.client.Raw, cc.intermediate.Raw},
+			Certificate: cc.der(),
 			PrivateKey:  k,
 			Leaf:        cc.client,
 		}},
@@ -193,9 +295,38 @@ func createTLSConfig(inst instanceURI, cc certChain, info connectInfo, k *rsa.Pr
 	}
 }
 
+// verifyServerName verifies that the server certificate identifies the
+// instance. A certificate with subject alternative names is verified against
+// them, as for any TLS server, and must name the instance's server name
+// (<UID>.server.alloydb) or one of its addresses. Otherwise, the certificate's
+// CN must be the server name, unless requireSAN is set.
+func verifyServerName(server *x509.Certificate, info connectInfo, requireSAN bool) error {
+	serverName := fmt.Sprintf("%v.server.alloydb", info.uid)
+	if len(server.DNSNames) > 0 || len(server.IPAddresses) > 0 {
+		names := []string{serverName}
+		for _, e := range info.endpoints {
+			names = append(names, e.Addr)
+		}
+		for _, n := range names {
+			if server.VerifyHostname(n) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("certificate is not valid for %v", strings.Join(names, ", "))
+	}
+	if requireSAN {
+		return errors.New("certificate has no subject alternative names")
+	}
+	if server.Subject.CommonName != serverName {
+		return fmt.Errorf("certificate had CN %q, expected %q",
+			server.Subject.CommonName, serverName)
+	}
+	return nil
+}
+
 // newRefresher creates a Refresher.
 func newRefresher(
-	client *alloydbapi.Client,
+	client AdminAPI,
 	timeout time.Duration,
 	interval time.Duration,
 	burst int,
@@ -204,16 +335,28 @@ func newRefresher(
 	return refresher{
 		client:        client,
 		timeout:       timeout,
-		clientLimiter: rate.NewLimiter(rate.Every(interval), burst),
+		clientLimiter: newLimiter(interval, burst),
 		dialerID:      dialerID,
+		logger:        logging.Nop(),
+		recorder:      trace.OpenCensus(),
+		exec:          goExecutor{},
 	}
 }
 
+// newLimiter returns a limiter that allows burst events at once and then one
+// per interval. An interval of zero allows all events.
+func newLimiter(interval time.Duration, burst int) *rate.Limiter {
+	if interval <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Every(interval), burst)
+}
+
 // refresher manages the AlloyDB Admin API access to instance metadata and to
 // ephemeral certificates.
 type refresher struct {
 	// client provides access to the AlloyDB Admin API
-	client *alloydbapi.Client
+	client AdminAPI
 
 	// timeout is the maximum amount of time a refresh operation should be allowed to take.
 	timeout time.Duration
@@ -223,27 +366,155 @@ type refresher struct {
 
 	// clientLimiter limits the number of refreshes.
 	clientLimiter *rate.Limiter
+
+	// logger receives debug events about refresh operations.
+	logger logging.Logger
+
+	// recorder reports metrics and traces about refresh operations.
+	recorder trace.Recorder
+
+	// exec runs the Admin API calls of refresh operations.
+	exec Executor
+
+	// detectRole enables fetching the role of the instance's cluster as part
+	// of each refresh.
+	detectRole bool
+
+	// nameCheck determines how server certificates are checked against the
+	// instance.
+	nameCheck nameCheck
+
+	// rand is the entropy source for certificate signing requests and TLS
+	// handshakes. Nil means crypto/rand.
+	rand io.Reader
+
+	// pinnedCAs are the SHA-256 fingerprints of the allowed root CAs. Any
+	// root CA is allowed when empty.
+	pinnedCAs [][sha256.Size]byte
+
+	// onCert, when set, is called with the expiration of each client
+	// certificate retrieved.
+	onCert func(notAfter time.Time)
+
+	// group, when set, deduplicates refresh operations of the same instance
+	// that run at the same time.
+	group *RefreshGroup
+}
+
+// checkPinnedCA verifies the root CA matches one of the pinned CAs.
+func (r refresher) checkPinnedCA(inst instanceURI, root *x509.Certificate) error {
+	if len(r.pinnedCAs) == 0 {
+		return nil
+	}
+	fp := sha256.Sum256(root.Raw)
+	for _, p := range r.pinnedCAs {
+		if p == fp {
+			return nil
+		}
+	}
+	return errtype.NewRefreshError(
+		fmt.Sprintf("root CA with fingerprint %x does not match any pinned CA", fp),
+		inst.String(),
+		errtype.ErrTLSVerificationFailed,
+	)
 }
 
 type refreshResult struct {
-	instanceIPAddr string
-	conf           *tls.Config
-	expiry         time.Time
+	// endpoints are the instance's addresses in order of preference.
+	endpoints []Endpoint
+	conf      *tls.Config
+	expiry    time.Time
+	// role is the role of the instance's cluster, or the empty string when
+	// unknown.
+	role string
+
+	// certs, uid, and key are the inputs to conf, kept so the result can be
+	// exported with Snapshot. They are unset for results supplied by a
+	// ConnectInfoSource.
+	certs certChain
+	uid   string
+	key   crypto.Signer
 }
 
 type certChain struct {
-	root         *x509.Certificate
-	intermediate *x509.Certificate
-	client       *x509.Certificate
+	root *x509.Certificate
+	// intermediates are the intermediate CAs, starting with the issuer of
+	// client.
+	intermediates []*x509.Certificate
+	client        *x509.Certificate
+}
+
+// verify checks that the client certificate chains to the root CA through
+// the intermediate CAs. The chain is verified as of the time the client
+// certificate was issued, so that clock skew cannot fail a refresh; the
+// certificate's expiration is handled by the refresh cycle.
+func (cc certChain) verify() error {
+	roots := x509.NewCertPool()
+	roots.AddCert(cc.root)
+	inters := x509.NewCertPool()
+	for _, ic := range cc.intermediates {
+		inters.AddCert(ic)
+	}
+	_, err := cc.client.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: inters,
+		CurrentTime:   cc.client.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("client certificate does not chain to the root certificate: %w", err)
+	}
+	return nil
+}
+
+// der returns the DER encoded certificates of the client certificate and its
+// intermediate CAs, as presented in the TLS handshake.
+func (cc certChain) der() [][]byte {
+	certs := [][]byte{cc.client.Raw}
+	for _, ic := range cc.intermediates {
+		certs = append(certs, ic.Raw)
+	}
+	return certs
 }
 
-func (r refresher) performRefresh(ctx context.Context, cn instanceURI, k *rsa.PrivateKey) (res refreshResult, err error) {
+// performRefresh retrieves the instance's connection info and a new client
+// certificate. It waits on the refresher's rate limiter first. If the
+// refresher belongs to a RefreshGroup, the AlloyDB Admin API calls of a
+// refresh of the same instance already in progress are shared rather than
+// repeated, unless the refresh is forced.
+func (r refresher) performRefresh(ctx context.Context, cn instanceURI, k crypto.Signer, trigger string) (res refreshResult, err error) {
 	var refreshEnd trace.EndSpanFunc
-	ctx, refreshEnd = trace.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.RefreshConnection",
+	ctx, refreshEnd = r.recorder.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.RefreshConnection",
 		trace.AddInstanceName(cn.String()),
 	)
+	start := time.Now()
+	r.logger.Log(logging.Event{
+		Instance: cn.String(),
+		Name:     logging.EventRefreshStart,
+		Message:  trigger + " refresh started",
+	})
 	defer func() {
-		go trace.RecordRefreshResult(context.Background(), cn.String(), r.dialerID, err)
+		r.recorder.RecordRefreshResult(context.Background(), cn.String(), r.dialerID, trigger, err)
+		msg := "refresh succeeded"
+		if err != nil {
+			msg = "refresh failed"
+		}
+		r.logger.Log(logging.Event{
+			Instance: cn.String(),
+			Name:     logging.EventRefresh,
+			Duration: time.Since(start),
+			Message:  msg,
+			Expiry:   res.expiry,
+			Err:      err,
+		})
+		if errors.Is(err, errtype.ErrCredentials) {
+			r.logger.Log(logging.Event{
+				Instance: cn.String(),
+				Name:     logging.EventCredentials,
+				Message:  "credentials failed to produce a token",
+				Err:      err,
+			})
+		}
 		refreshEnd(err)
 	}()
 
@@ -254,7 +525,25 @@ func (r refresher) performRefresh(ctx context.Context, cn instanceURI, k *rsa.Pr
 	}
 
 	// avoid refreshing too often to try not to tax the AlloyDB Admin API quotas
+	throttled := r.clientLimiter.Tokens() < 1
+	if throttled {
+		r.logger.Log(logging.Event{
+			Instance: cn.String(),
+			Name:     logging.EventRateLimit,
+			Message:  "refresh is throttled, waiting on rate limiter",
+		})
+	}
+	waitStart := time.Now()
 	err = r.clientLimiter.Wait(ctx)
+	if throttled {
+		r.logger.Log(logging.Event{
+			Instance: cn.String(),
+			Name:     logging.EventRateLimit,
+			Duration: time.Since(waitStart),
+			Message:  "rate limiter wait complete",
+			Err:      err,
+		})
+	}
 	if err != nil {
 		return refreshResult{}, errtype.NewDialError(
 			"refresh was throttled until context expired",
@@ -263,27 +552,66 @@ func (r refresher) performRefresh(ctx context.Context, cn instanceURI, k *rsa.Pr
 		)
 	}
 
+	if r.group == nil {
+		return r.fetch(ctx, cn, k)
+	}
+	// Each caller waits on its own rate limiter above, so the shared call
+	// only makes the API calls. Refresh operations that detect the
+	// cluster's role return more than those that do not, so they are not
+	// shared with them.
+	key := cn.String()
+	if r.detectRole {
+		key += "#role"
+	}
+	return r.group.do(ctx, key, trigger == trace.RefreshTriggerForced, r.exec,
+		func(ctx context.Context) (refreshResult, error) {
+			// The shared call does not inherit the caller's deadline.
+			ctx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+			return r.fetch(ctx, cn, k)
+		},
+	)
+}
+
+// fetch makes the AlloyDB Admin API calls of a refresh operation and builds
+// its result.
+func (r refresher) fetch(ctx context.Context, cn instanceURI, k crypto.Signer) (refreshResult, error) {
 	type mdRes struct {
 		info connectInfo
 		err  error
 	}
 	mdCh := make(chan mdRes, 1)
-	go func() {
+	r.exec.Go(func() {
 		defer close(mdCh)
-		c, err := fetchMetadata(ctx, r.client, cn)
+		c, err := fetchMetadata(ctx, r.client, r.recorder, cn)
 		mdCh <- mdRes{info: c, err: err}
-	}()
+	})
 
 	type certRes struct {
 		cc  certChain
 		err error
 	}
 	certCh := make(chan certRes, 1)
-	go func() {
+	r.exec.Go(func() {
 		defer close(certCh)
-		cc, err := fetchEphemeralCert(ctx, r.client, cn, k)
+		cc, err := fetchEphemeralCert(ctx, r.client, r.recorder, cn, k, r.dialerID, r.rand)
 		certCh <- certRes{cc: cc, err: err}
-	}()
+	})
+
+	type roleRes struct {
+		role string
+		err  error
+	}
+	roleCh := make(chan roleRes, 1)
+	if r.detectRole {
+		r.exec.Go(func() {
+			defer close(roleCh)
+			role, err := fetchClusterRole(ctx, r.client, r.recorder, cn)
+			roleCh <- roleRes{role: role, err: err}
+		})
+	} else {
+		close(roleCh)
+	}
 
 	var info connectInfo
 	select {
@@ -306,12 +634,46 @@ func (r refresher) performRefresh(ctx context.Context, cn instanceURI, k *rsa.Pr
 	case <-ctx.Done():
 		return refreshResult{}, fmt.Errorf("refresh failed: %w", ctx.Err())
 	}
+	if err := r.checkPinnedCA(cn, cc.root); err != nil {
+		return refreshResult{}, err
+	}
 
-	c := createTLSConfig(cn, cc, info, k)
+	// The cluster role is informational, so a failure to retrieve it does not
+	// fail the refresh.
+	var role string
+	select {
+	case res := <-roleCh:
+		role = res.role
+		if res.err != nil {
+			r.logger.Log(logging.Event{
+				Instance: cn.String(),
+				Name:     logging.EventClusterRole,
+				Message:  "failed to get cluster role",
+				Err:      res.err,
+			})
+		}
+	case <-ctx.Done():
+		return refreshResult{}, fmt.Errorf("refresh failed: %w", ctx.Err())
+	}
+
+	// The certificate is reported only once the refresh can no longer fail.
+	if r.onCert != nil {
+		r.onCert(cc.client.NotAfter)
+	}
+	c := createTLSConfig(cn, cc, info, k, r.nameCheck)
+	c.Rand = r.rand
 	var expiry time.Time
 	// This should never not be the case, but we check to avoid a potential nil-pointer
 	if len(c.Certificates) > 0 {
 		expiry = c.Certificates[0].Leaf.NotAfter
 	}
-	return refreshResult{instanceIPAddr: info.ipAddr, conf: c, expiry: expiry}, nil
+	return refreshResult{
+		endpoints: info.endpoints,
+		conf:      c,
+		expiry:    expiry,
+		role:      role,
+		certs:     cc,
+		uid:       info.uid,
+		key:       k,
+	}, nil
 }
@@ -15,14 +15,17 @@
 package alloydb
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 	"time"
 
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
 	"cloud.google.com/go/alloydbconn/errtype"
-	"cloud.google.com/go/alloydbconn/internal/alloydbapi"
 	"cloud.google.com/go/alloydbconn/internal/mock"
+	"cloud.google.com/go/alloydbconn/internal/trace"
 	"google.golang.org/api/option"
 )
 
@@ -37,6 +40,8 @@ func TestRefresh(t *testing.T) {
 	inst := mock.NewFakeInstance(
 		"my-project", "my-region", "my-cluster", "my-instance",
 		mock.WithIPAddr(wantIP),
+		mock.WithPublicIPAddr("34.0.0.1"),
+		mock.WithPSCDNSName("x.y.alloydb.goog"),
 		mock.WithCertExpiry(wantExpiry),
 	)
 	mc, url, cleanup := mock.HTTPClient(
@@ -49,7 +54,7 @@ func TestRefresh(t *testing.T) {
 		}
 	}()
 
-	cl, err := alloydbapi.NewClient(
+	cl, err := alloydbadmin.NewClient(
 		context.Background(),
 		option.WithHTTPClient(mc),
 		option.WithEndpoint(url),
@@ -58,19 +63,66 @@ func TestRefresh(t *testing.T) {
 		t.Fatalf("admin API client error: %v", err)
 	}
 	r := newRefresher(cl, time.Hour, 30*time.Second, 2, "some-id")
-	res, err := r.performRefresh(context.Background(), cn, RSAKey)
+	res, err := r.performRefresh(context.Background(), cn, RSAKey, trace.RefreshTriggerScheduled)
 	if err != nil {
 		t.Fatalf("performRefresh unexpectedly failed with error: %v", err)
 	}
 
-	if got := res.instanceIPAddr; wantIP != got {
-		t.Fatalf("metadata IP mismatch, want = %v, got = %v", wantIP, got)
+	wantEndpoints := []Endpoint{
+		{IPType: PrivateIP, Addr: wantIP},
+		{IPType: PublicIP, Addr: "34.0.0.1"},
+		{IPType: PSC, Addr: "x.y.alloydb.goog"},
+	}
+	if got := res.endpoints; !reflect.DeepEqual(wantEndpoints, got) {
+		t.Fatalf("metadata endpoints mismatch, want = %v, got = %v", wantEndpoints, got)
 	}
 	if got := res.expiry; wantExpiry != got {
 		t.Fatalf("expiry mismatch, want = %v, got = %v", wantExpiry, got)
 	}
 }
 
+func TestRefreshIPv6(t *testing.T) {
+	cn, err := parseInstURI("projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
+	if err != nil {
+		t.Fatalf("parseInstURI failed: %v", err)
+	}
+	// Addresses are stored without brackets, however the API reports them.
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithIPAddr("fd00::1"),
+		mock.WithPublicIPAddr("[2001:db8::1]"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	cl, err := alloydbadmin.NewClient(
+		context.Background(),
+		option.WithHTTPClient(mc),
+		option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("admin API client error: %v", err)
+	}
+	r := newRefresher(cl, time.Hour, 30*time.Second, 2, "some-id")
+	res, err := r.performRefresh(context.Background(), cn, RSAKey, trace.RefreshTriggerScheduled)
+	if err != nil {
+		t.Fatalf("performRefresh unexpectedly failed with error: %v", err)
+	}
+	want := []Endpoint{
+		{IPType: PrivateIP, Addr: "fd00::1"},
+		{IPType: PublicIP, Addr: "2001:db8::1"},
+	}
+	if got := res.endpoints; !reflect.DeepEqual(want, got) {
+		t.Fatalf("metadata endpoints mismatch, want = %v, got = %v", want, got)
+	}
+}
+
 func TestRefreshFailsFast(t *testing.T) {
 	wantInstURI := "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
 	cn, err := parseInstURI(wantInstURI)
@@ -90,7 +142,7 @@ func TestRefreshFailsFast(t *testing.T) {
 		}
 	}()
 
-	cl, err := alloydbapi.NewClient(
+	cl, err := alloydbadmin.NewClient(
 		context.Background(),
 		option.WithHTTPClient(mc),
 		option.WithEndpoint(url),
@@ -100,7 +152,7 @@ func TestRefreshFailsFast(t *testing.T) {
 	}
 	r := newRefresher(cl, time.Hour, 30*time.Second, 1, "some-id")
 
-	_, err = r.performRefresh(context.Background(), cn, RSAKey)
+	_, err = r.performRefresh(context.Background(), cn, RSAKey, trace.RefreshTriggerScheduled)
 	if err != nil {
 		t.Fatalf("expected no error, got = %v", err)
 	}
@@ -108,7 +160,7 @@ func TestRefreshFailsFast(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 	// context is canceled
-	_, err = r.performRefresh(ctx, cn, RSAKey)
+	_, err = r.performRefresh(ctx, cn, RSAKey, trace.RefreshTriggerScheduled)
 	if !errors.Is(err, context.Canceled) {
 		t.Fatalf("expected context.Canceled error, got = %v", err)
 	}
@@ -116,10 +168,77 @@ func TestRefreshFailsFast(t *testing.T) {
 	// force the rate limiter to throttle with a timed out context
 	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
 	defer cancel()
-	_, err = r.performRefresh(ctx, cn, RSAKey)
+	_, err = r.performRefresh(ctx, cn, RSAKey, trace.RefreshTriggerScheduled)
 
 	var wantErr *errtype.DialError
 	if !errors.As(err, &wantErr) {
 		t.Fatalf("when refresh is throttled, want = %T, got = %v", wantErr, err)
 	}
 }
+
+// issuedChain returns a PEM encoded client certificate issued by inst and its
+// chain.
+func issuedChain(t *testing.T, inst mock.FakeAlloyDBInstance) (string, []string) {
+	t.Helper()
+	doc, err := mock.StaticConnectionInfo(inst, RSAKey)
+	if err != nil {
+		t.Fatalf("failed to create static connection info: %v", err)
+	}
+	sc, err := alloydbadmin.NewStaticClient(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatalf("failed to create static client: %v", err)
+	}
+	resp, err := sc.GenerateClientCert(context.Background(), "my-project", "my-region", "my-cluster", nil)
+	if err != nil {
+		t.Fatalf("failed to generate client cert: %v", err)
+	}
+	return resp.PemCertificate, resp.PemCertificateChain
+}
+
+func TestParseCertChain(t *testing.T) {
+	for _, n := range []int{0, 1, 3} {
+		inst := mock.NewFakeInstance(
+			"my-project", "my-region", "my-cluster", "my-instance",
+			mock.WithIntermediateCAs(n),
+		)
+		client, chain := issuedChain(t, inst)
+		cc, err := parseCertChain(client, chain)
+		if err != nil {
+			t.Fatalf("%v intermediate CAs: want success, got error: %v", n, err)
+		}
+		if got := len(cc.intermediates); got != n {
+			t.Fatalf("want %v intermediate CAs, got = %v", n, got)
+		}
+		if got := len(cc.der()); got != n+1 {
+			t.Fatalf("want %v certificates presented, got = %v", n+1, got)
+		}
+	}
+
+	inst := mock.NewFakeInstance("my-project", "my-region", "my-cluster", "my-instance")
+	client, chain := issuedChain(t, inst)
+	// The fake instances share their root CA's key, so another instance's
+	// root would verify. Its chain's intermediate CA with a key of its own
+	// does not.
+	other := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithIntermediateCAs(2),
+	)
+	_, otherChain := issuedChain(t, other)
+	tcs := []struct {
+		desc   string
+		client string
+		chain  []string
+	}{
+		{desc: "empty chain", client: client},
+		{desc: "invalid root", client: client, chain: []string{chain[0], "not a certificate"}},
+		{desc: "invalid intermediate", client: client, chain: []string{"", chain[1]}},
+		{desc: "invalid client", client: "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n", chain: chain},
+		{desc: "missing intermediate", client: client, chain: chain[1:]},
+		{desc: "different root", client: client, chain: []string{chain[0], otherChain[1]}},
+	}
+	for _, tc := range tcs {
+		if _, err := parseCertChain(tc.client, tc.chain); err == nil {
+			t.Errorf("%v: want error, got nil", tc.desc)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydb
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"cloud.google.com/go/alloydbconn/internal/trace"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// flakyAdminAPI responds to the first calls to each method, up to fails,
+// with a 503.
+type flakyAdminAPI struct {
+	AdminAPI
+	fails  int32
+	infoCt int32
+	certCt int32
+}
+
+func (f *flakyAdminAPI) ConnectionInfo(ctx context.Context, project, region, cluster, instance string) (alloydbadmin.ConnectionInfoResponse, error) {
+	if atomic.AddInt32(&f.infoCt, 1) <= f.fails {
+		return alloydbadmin.ConnectionInfoResponse{}, &googleapi.Error{Code: http.StatusServiceUnavailable}
+	}
+	return f.AdminAPI.ConnectionInfo(ctx, project, region, cluster, instance)
+}
+
+func (f *flakyAdminAPI) GenerateClientCert(ctx context.Context, project, region, cluster string, csr []byte) (alloydbadmin.GenerateClientCertificateResponse, error) {
+	if atomic.AddInt32(&f.certCt, 1) <= f.fails {
+		return alloydbadmin.GenerateClientCertificateResponse{}, &googleapi.Error{Code: http.StatusServiceUnavailable}
+	}
+	return f.AdminAPI.GenerateClientCert(ctx, project, region, cluster, csr)
+}
+
+func TestRefreshRetriesTransientErrors(t *testing.T) {
+	cn, err := parseInstURI("/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
+	if err != nil {
+		t.Fatalf("parseInstURI failed: %v", err)
+	}
+	inst := mock.NewFakeInstance("my-project", "my-region", "my-cluster", "my-instance")
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	cl, err := alloydbadmin.NewClient(context.Background(), option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("admin API client error: %v", err)
+	}
+
+	flaky := &flakyAdminAPI{AdminAPI: cl, fails: 2}
+	r := newRefresher(flaky, time.Hour, 30*time.Second, 2, "some-id")
+	if _, err := r.performRefresh(context.Background(), cn, RSAKey, trace.RefreshTriggerScheduled); err != nil {
+		t.Fatalf("performRefresh unexpectedly failed with error: %v", err)
+	}
+	if flaky.infoCt != 3 || flaky.certCt != 3 {
+		t.Fatalf("want 3 calls to each method, got ConnectionInfo = %d, GenerateClientCert = %d",
+			flaky.infoCt, flaky.certCt)
+	}
+}
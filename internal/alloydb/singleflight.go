@@ -0,0 +1,127 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RefreshGroup deduplicates refresh operations of the same instance that run
+// at the same time, e.g., the first refresh of an instance's cache and calls
+// to FetchConnectInfo, so that they share a single set of AlloyDB Admin API
+// calls. Instances that share a RefreshGroup must use the same credentials.
+// The zero value is not usable; use NewRefreshGroup.
+type RefreshGroup struct {
+	mu    sync.Mutex
+	calls map[string]*refreshCall
+}
+
+// NewRefreshGroup returns an empty RefreshGroup.
+func NewRefreshGroup() *RefreshGroup {
+	return &RefreshGroup{calls: make(map[string]*refreshCall)}
+}
+
+// refreshCall is a refresh operation in progress and the callers waiting on
+// it.
+type refreshCall struct {
+	done chan struct{}
+	res  refreshResult
+	err  error
+
+	// waiters is the number of callers waiting on the call, guarded by the
+	// group's mu. The call is canceled once none are left.
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// do runs f for key, or waits on the call for key already in progress, and
+// returns its result. When fresh is set, do never waits on a call that is
+// already in progress, e.g., because the result of a refresh started before
+// a forced refresh may be stale, but later callers wait on the new call.
+//
+// f runs on exec with a context that keeps the values of the first caller's
+// ctx but is canceled only once every caller has given up waiting, so that
+// one caller's cancellation does not fail the others.
+func (g *RefreshGroup) do(
+	ctx context.Context, key string, fresh bool, exec Executor,
+	f func(context.Context) (refreshResult, error),
+) (refreshResult, error) {
+	g.mu.Lock()
+	c, ok := g.calls[key]
+	if !ok || fresh {
+		c = g.start(ctx, key, exec, f)
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.res, c.err
+	case <-ctx.Done():
+		g.leave(key, c)
+		return refreshResult{}, ctx.Err()
+	}
+}
+
+// start starts a call of f for key on exec. Callers must hold mu.
+func (g *RefreshGroup) start(
+	ctx context.Context, key string, exec Executor,
+	f func(context.Context) (refreshResult, error),
+) *refreshCall {
+	callCtx, cancel := context.WithCancel(detachedContext{ctx})
+	c := &refreshCall{done: make(chan struct{}), cancel: cancel}
+	g.calls[key] = c
+	exec.Go(func() {
+		defer cancel()
+		c.res, c.err = f(callCtx)
+		g.mu.Lock()
+		if g.calls[key] == c {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(c.done)
+	})
+	return c
+}
+
+// leave removes a caller that stopped waiting on c and cancels c once no
+// callers are left.
+func (g *RefreshGroup) leave(key string, c *refreshCall) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c.waiters--
+	if c.waiters > 0 {
+		return
+	}
+	c.cancel()
+	// Later callers start a new call rather than wait on the canceled one.
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+}
+
+// detachedContext keeps the values of a context, e.g., trace spans and
+// request headers, without its deadline or cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
@@ -0,0 +1,190 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingRefresh returns a refresh function that counts its calls and
+// blocks until release is closed or its context is canceled.
+func blockingRefresh(calls *int32, release chan struct{}) func(context.Context) (refreshResult, error) {
+	return func(ctx context.Context) (refreshResult, error) {
+		n := atomic.AddInt32(calls, 1)
+		select {
+		case <-release:
+			return refreshResult{expiry: time.Unix(int64(n), 0)}, nil
+		case <-ctx.Done():
+			return refreshResult{}, ctx.Err()
+		}
+	}
+}
+
+// waitForWaiters blocks until n callers wait on the call for key.
+func waitForWaiters(g *RefreshGroup, key string, n int) {
+	for {
+		g.mu.Lock()
+		c, ok := g.calls[key]
+		done := ok && c.waiters == n
+		g.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRefreshGroupSharesCall(t *testing.T) {
+	g := NewRefreshGroup()
+	var calls int32
+	release := make(chan struct{})
+	f := blockingRefresh(&calls, release)
+
+	const n = 10
+	var wg sync.WaitGroup
+	results := make(chan refreshResult, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := g.do(context.Background(), "inst", false, goExecutor{}, f)
+			if err != nil {
+				t.Errorf("want no error, got = %v", err)
+			}
+			results <- res
+		}()
+	}
+	waitForWaiters(g, "inst", n)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("want 1 call, got = %v", got)
+	}
+	for res := range results {
+		if !res.expiry.Equal(time.Unix(1, 0)) {
+			t.Fatalf("want every caller to get the result of the call, got = %v", res.expiry)
+		}
+	}
+}
+
+func TestRefreshGroupFreshStartsNewCall(t *testing.T) {
+	g := NewRefreshGroup()
+	var calls int32
+	release := make(chan struct{})
+	f := blockingRefresh(&calls, release)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = g.do(context.Background(), "inst", false, goExecutor{}, f)
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	if _, err := g.do(context.Background(), "inst", true, goExecutor{}, f); err != nil {
+		t.Fatalf("want no error, got = %v", err)
+	}
+	<-done
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("want 2 calls, got = %v", got)
+	}
+}
+
+func TestRefreshGroupCancellation(t *testing.T) {
+	g := NewRefreshGroup()
+	var calls int32
+	release := make(chan struct{})
+	f := blockingRefresh(&calls, release)
+
+	// The first caller gives up, but the call continues for the second.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := g.do(ctx, "inst", false, goExecutor{}, f)
+		first <- err
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan error, 1)
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	go func() {
+		_, err := g.do(ctx2, "inst", false, goExecutor{}, f)
+		second <- err
+	}()
+	waitForWaiters(g, "inst", 2)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("want = %v, got = %v", context.Canceled, err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("want the call to continue for the remaining caller, got = %v", err)
+	}
+
+	// Once every caller gives up, the call is canceled.
+	ctx3, cancel3 := context.WithCancel(context.Background())
+	canceled := make(chan struct{})
+	go func() {
+		_, _ = g.do(ctx3, "other", false, goExecutor{}, func(ctx context.Context) (refreshResult, error) {
+			<-ctx.Done()
+			close(canceled)
+			return refreshResult{}, ctx.Err()
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel3()
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the call to be canceled once no callers are left")
+	}
+}
+
+// countExecutor counts the functions it runs.
+type countExecutor struct {
+	n int32
+}
+
+func (e *countExecutor) Go(f func()) {
+	atomic.AddInt32(&e.n, 1)
+	go f()
+}
+
+func TestRefreshGroupRunsOnExecutor(t *testing.T) {
+	g := NewRefreshGroup()
+	e := &countExecutor{}
+	_, err := g.do(context.Background(), "inst", false, e, func(context.Context) (refreshResult, error) {
+		return refreshResult{}, nil
+	})
+	if err != nil {
+		t.Fatalf("want no error, got = %v", err)
+	}
+	if got := atomic.LoadInt32(&e.n); got != 1 {
+		t.Fatalf("want the call to run on the executor, got = %v calls to Go", got)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydb
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/internal/logging"
+	"cloud.google.com/go/alloydbconn/internal/trace"
+)
+
+// Snapshot is the serializable form of a successful refresh operation. It
+// holds the private key the client certificate was issued for, so it must be
+// protected like any other credential.
+type Snapshot struct {
+	// Instance is the instance URI.
+	Instance string
+	// Endpoints are the instance's addresses in order of preference.
+	Endpoints []Endpoint
+	// UID is the instance UID, used to verify the server certificate.
+	UID string
+	// Root and Client are the DER encoded root CA and client certificates.
+	Root, Client []byte
+	// Intermediates are the DER encoded intermediate CAs of the client
+	// certificate chain, starting with the issuer of Client.
+	Intermediates [][]byte
+	// Intermediate is the issuer of Client. It is written for earlier
+	// versions, which expect exactly one intermediate CA, and only read from
+	// snapshots without Intermediates.
+	Intermediate []byte
+	// Key is the PKCS #1 DER encoded private key of the client certificate.
+	Key []byte
+	// Expiry is when the client certificate expires.
+	Expiry time.Time
+	// Role is the role of the instance's cluster, if known.
+	Role string
+}
+
+// WithSnapshot configures the Instance to start with the connection info in
+// s rather than refreshing it. Invalid or expired snapshots are ignored.
+func WithSnapshot(s Snapshot) Option {
+	return func(i *Instance) {
+		i.seed = &s
+	}
+}
+
+// WithSnapshotHandler configures the Instance to call h with the connection
+// info of each successful refresh operation that can be exported; see
+// Snapshot. h is called on the goroutine that ran the refresh operation.
+func WithSnapshotHandler(h func(Snapshot)) Option {
+	return func(i *Instance) {
+		i.onSnapshot = h
+	}
+}
+
+// Snapshot returns the instance's current connection info. It reports false
+// if there is no valid connection info or if the connection info was
+// supplied by a ConnectInfoSource or the instance's key cannot be exported.
+func (i *Instance) Snapshot() (Snapshot, bool) {
+	i.resultGuard.RLock()
+	cur := i.cur
+	i.resultGuard.RUnlock()
+	if !cur.IsValid() {
+		return Snapshot{}, false
+	}
+	return i.snapshot(cur.result)
+}
+
+// snapshot converts a successful refresh result into a Snapshot.
+func (i *Instance) snapshot(res refreshResult) (Snapshot, bool) {
+	if res.certs.client == nil {
+		return Snapshot{}, false
+	}
+	// Only keys held in memory can be exported, not, e.g., keys held in a
+	// KMS.
+	key, ok := res.key.(*rsa.PrivateKey)
+	if !ok {
+		return Snapshot{}, false
+	}
+	eps := make([]Endpoint, len(res.endpoints))
+	for n, e := range res.endpoints {
+		eps[n] = Endpoint{IPType: e.IPType, Addr: e.Addr}
+	}
+	s := Snapshot{
+		Instance:  i.uri(),
+		Endpoints: eps,
+		UID:       res.uid,
+		Root:      res.certs.root.Raw,
+		Client:    res.certs.client.Raw,
+		Key:       x509.MarshalPKCS1PrivateKey(key),
+		Expiry:    res.expiry,
+		Role:      res.role,
+	}
+	for _, ic := range res.certs.intermediates {
+		s.Intermediates = append(s.Intermediates, ic.Raw)
+	}
+	if len(s.Intermediates) > 0 {
+		s.Intermediate = s.Intermediates[0]
+	}
+	return s, true
+}
+
+// restore converts s back into a refresh result for the instance. See
+// createTLSConfig for check.
+func (s Snapshot) restore(inst instanceURI, check nameCheck) (refreshResult, error) {
+	if si, err := parseInstURI(s.Instance); err != nil || si != inst {
+		return refreshResult{}, fmt.Errorf("snapshot is for instance %v", s.Instance)
+	}
+	if len(s.Endpoints) == 0 {
+		return refreshResult{}, errors.New("snapshot has no endpoints")
+	}
+	k, err := x509.ParsePKCS1PrivateKey(s.Key)
+	if err != nil {
+		return refreshResult{}, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	inters := s.Intermediates
+	if len(inters) == 0 && s.Intermediate != nil {
+		inters = [][]byte{s.Intermediate}
+	}
+	var cc certChain
+	if cc.root, err = x509.ParseCertificate(s.Root); err != nil {
+		return refreshResult{}, fmt.Errorf("failed to parse certificate: %v", err)
+	}
+	if cc.client, err = x509.ParseCertificate(s.Client); err != nil {
+		return refreshResult{}, fmt.Errorf("failed to parse certificate: %v", err)
+	}
+	for _, der := range inters {
+		ic, err := x509.ParseCertificate(der)
+		if err != nil {
+			return refreshResult{}, fmt.Errorf("failed to parse certificate: %v", err)
+		}
+		cc.intermediates = append(cc.intermediates, ic)
+	}
+	if err := cc.verify(); err != nil {
+		return refreshResult{}, err
+	}
+	info := connectInfo{endpoints: s.Endpoints, uid: s.UID}
+	return refreshResult{
+		endpoints: s.Endpoints,
+		conf:      createTLSConfig(inst, cc, info, k, check),
+		expiry:    cc.client.NotAfter,
+		role:      s.Role,
+		certs:     cc,
+		uid:       s.UID,
+		key:       k,
+	}, nil
+}
+
+// restoreSeed starts the refresh cycle from the configured snapshot. It
+// reports false if there is no usable snapshot, in which case the caller
+// must start the refresh cycle itself. Callers must hold resultGuard.
+func (i *Instance) restoreSeed() bool {
+	if i.seed == nil {
+		return false
+	}
+	res, err := i.seed.restore(i.instanceURI, i.r.nameCheck)
+	i.seed = nil
+	if err == nil {
+		res.conf.Rand = i.r.rand
+		err = i.r.checkPinnedCA(i.instanceURI, res.certs.root)
+	}
+	if err == nil && !time.Now().Before(res.expiry) {
+		err = errors.New("snapshot has expired")
+	}
+	if err != nil {
+		i.r.logger.Log(logging.Event{
+			Instance: i.String(),
+			Name:     logging.EventSnapshot,
+			Message:  "ignoring imported connection info",
+			Err:      err,
+		})
+		return false
+	}
+	op := &refreshOperation{
+		result: res,
+		timer:  time.NewTimer(0),
+		ready:  make(chan struct{}),
+	}
+	op.timer.Stop()
+	close(op.ready)
+	i.cur = op
+	i.latest = &res
+	i.warm = true
+	i.role = res.role
+	t := i.strategy.NextRefresh(time.Now(), res.expiry)
+	if t < 0 {
+		t = 0
+	}
+	i.next = i.scheduleRefresh(t, trace.RefreshTriggerScheduled)
+	return true
+}
@@ -0,0 +1,307 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging provides the structured debug logging used by the
+// connector.
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+	"google.golang.org/api/googleapi"
+)
+
+// Event names used across the connector. These values are part of the JSON
+// output format and should not be changed.
+const (
+	// EventRefreshStart is logged when a refresh operation starts.
+	EventRefreshStart = "refresh_start"
+	// EventRefresh is logged when a refresh operation completes.
+	EventRefresh = "refresh"
+	// EventDialStart is logged when a call to Dial starts.
+	EventDialStart = "dial_start"
+	// EventDial is logged when a call to Dial completes.
+	EventDial = "dial"
+	// EventClusterRole is logged when the role of an instance's cluster
+	// cannot be determined or has changed.
+	EventClusterRole = "cluster_role"
+	// EventRefreshScheduled is logged when a refresh operation is scheduled.
+	EventRefreshScheduled = "refresh_scheduled"
+	// EventRateLimit is logged when a refresh operation waits on the rate
+	// limiter.
+	EventRateLimit = "rate_limit"
+	// EventDialAttempt is logged when a connection to an instance's IP
+	// address is attempted.
+	EventDialAttempt = "dial_attempt"
+	// EventTLSHandshake is logged when a TLS handshake completes or fails.
+	EventTLSHandshake = "tls_handshake"
+	// EventSnapshot is logged when imported connection info cannot be used.
+	EventSnapshot = "snapshot"
+	// EventConnClosed is logged when a connection returned by Dial is
+	// closed.
+	EventConnClosed = "conn_closed"
+	// EventFailover is logged when a dial to a failover group moves on to
+	// the group's next instance.
+	EventFailover = "failover"
+	// EventPoolRetry is logged when a dial to a read pool instance fails and
+	// is retried against another instance of the pool.
+	EventPoolRetry = "pool_retry"
+	// EventAddrChange is logged when a dial to an unreachable address is
+	// retried at the instance's new address.
+	EventAddrChange = "addr_change"
+	// EventEvict is logged when an instance is removed from the Dialer's
+	// cache.
+	EventEvict = "evict"
+	// EventCircuitBreaker is logged when refresh operations of an instance
+	// are suspended after repeated permanent failures, or resumed.
+	EventCircuitBreaker = "circuit_breaker"
+	// EventConnExpired is logged when the client certificate of a connection
+	// that is still open expires.
+	EventConnExpired = "conn_expired"
+	// EventIPFallback is logged when a dial moves on to the next IP type of
+	// its fallback order.
+	EventIPFallback = "ip_fallback"
+	// EventCredentials is logged when a refresh operation fails because the
+	// Dialer's credentials could not produce a token.
+	EventCredentials = "credentials"
+)
+
+// ErrorCodeCredentials is the error code of errors caused by the Dialer's
+// credentials rather than the AlloyDB Admin API.
+const ErrorCodeCredentials = "CREDENTIALS"
+
+// Event is a single debug log entry.
+type Event struct {
+	// Time is when the event occurred. If zero, the time of logging is used.
+	Time time.Time
+	// Instance is the instance URI the event relates to.
+	Instance string
+	// Addr is the address dialed, if applicable.
+	Addr string
+	// Name identifies the kind of event (e.g., "refresh").
+	Name string
+	// Duration is the time the operation took, if applicable.
+	Duration time.Duration
+	// Message is a human readable description of the event.
+	Message string
+	// Expiry is the expiration time of the client certificate, if
+	// applicable.
+	Expiry time.Time
+	// Err is the error associated with the event, if any.
+	Err error
+	// Label is the label the caller attached to the dial the event relates
+	// to, if any.
+	Label string
+	// DialerID identifies the Dialer that logged the event; see
+	// WithDialerID.
+	DialerID string
+}
+
+// Logger records debug events.
+type Logger interface {
+	Log(e Event)
+}
+
+// nopLogger discards all events.
+type nopLogger struct{}
+
+func (nopLogger) Log(Event) {}
+
+// Nop returns a Logger that discards all events.
+func Nop() Logger { return nopLogger{} }
+
+// jsonEntry is the wire format of the JSON logger. The field names are
+// stable, and "severity", "message", and "time" follow the Cloud Logging
+// structured logging conventions.
+type jsonEntry struct {
+	Time      string `json:"time"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Event     string `json:"event"`
+	Duration  *int64 `json:"duration,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Expiry    string `json:"cert_expiry,omitempty"`
+	Label     string `json:"label,omitempty"`
+	DialerID  string `json:"dialer_id,omitempty"`
+}
+
+// NewJSONLogger returns a Logger that writes each event as a single line of
+// JSON to w. Durations are reported in milliseconds.
+func NewJSONLogger(w io.Writer) Logger {
+	return &jsonLogger{enc: json.NewEncoder(w)}
+}
+
+type jsonLogger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (l *jsonLogger) Log(e Event) {
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	entry := jsonEntry{
+		Time:     t.UTC().Format(time.RFC3339Nano),
+		Severity: "DEBUG",
+		Message:  e.Message,
+		Instance: e.Instance,
+		Event:    e.Name,
+		Label:    e.Label,
+		DialerID: e.DialerID,
+	}
+	if e.Duration > 0 {
+		ms := e.Duration.Milliseconds()
+		entry.Duration = &ms
+	}
+	if e.Err != nil {
+		entry.ErrorCode = ErrorCode(e.Err)
+		entry.Error = e.Err.Error()
+	}
+	if !e.Expiry.IsZero() {
+		entry.Expiry = e.Expiry.UTC().Format(time.RFC3339Nano)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Errors writing debug logs are not actionable and so are dropped.
+	_ = l.enc.Encode(entry)
+}
+
+// debugfLogger is satisfied by debug.Logger.
+type debugfLogger interface {
+	Debugf(format string, args ...interface{})
+}
+
+// NewDebugfLogger returns a Logger that formats each event as a single line
+// of text and writes it to l.
+func NewDebugfLogger(l debugfLogger) Logger {
+	return &textLogger{l: l}
+}
+
+type textLogger struct {
+	l debugfLogger
+}
+
+func (t *textLogger) Log(e Event) {
+	var b strings.Builder
+	if e.Instance != "" {
+		b.WriteString("[" + e.Instance + "] ")
+	}
+	if e.Message != "" {
+		b.WriteString(e.Message)
+	} else {
+		b.WriteString(e.Name)
+	}
+	if e.Label != "" {
+		b.WriteString(" (label = " + e.Label + ")")
+	}
+	if e.Duration > 0 {
+		b.WriteString(" (duration = " + e.Duration.String() + ")")
+	}
+	if !e.Expiry.IsZero() {
+		b.WriteString(" (cert expiry = " + e.Expiry.UTC().Format(time.RFC3339) + ")")
+	}
+	if e.Err != nil {
+		b.WriteString(": " + e.Err.Error())
+	}
+	t.l.Debugf("%s", b.String())
+}
+
+// Multi returns a Logger that forwards events to each of ls in turn.
+func Multi(ls ...Logger) Logger {
+	return multiLogger(ls)
+}
+
+type multiLogger []Logger
+
+func (m multiLogger) Log(e Event) {
+	for _, l := range m {
+		l.Log(e)
+	}
+}
+
+// WithDialerID returns a Logger that sets the DialerID of each event that
+// does not have one to id and forwards it to l.
+func WithDialerID(l Logger, id string) Logger {
+	return dialerIDLogger{l: l, id: id}
+}
+
+type dialerIDLogger struct {
+	l  Logger
+	id string
+}
+
+func (d dialerIDLogger) Log(e Event) {
+	if e.DialerID == "" {
+		e.DialerID = d.id
+	}
+	d.l.Log(e)
+}
+
+// Swappable is a Logger that forwards events to another Logger, which may be
+// replaced while in use.
+type Swappable struct {
+	v atomic.Value // holds loggerBox
+}
+
+// loggerBox gives every value stored in Swappable the same concrete type, as
+// required by atomic.Value.
+type loggerBox struct {
+	l Logger
+}
+
+// NewSwappable returns a Swappable that forwards events to l.
+func NewSwappable(l Logger) *Swappable {
+	s := &Swappable{}
+	s.Swap(l)
+	return s
+}
+
+// Swap replaces the Logger events are forwarded to.
+func (s *Swappable) Swap(l Logger) {
+	s.v.Store(loggerBox{l: l})
+}
+
+// Log forwards e to the current Logger.
+func (s *Swappable) Log(e Event) {
+	s.v.Load().(loggerBox).l.Log(e)
+}
+
+// ErrorCode returns an error code as given from the AlloyDB Admin API, provided
+// the error wraps a googleapi.Error type. If multiple error codes are returned
+// from the API, then a comma-separated string of all codes is returned. Errors
+// caused by the Dialer's credentials return ErrorCodeCredentials.
+func ErrorCode(err error) string {
+	if errors.Is(err, errtype.ErrCredentials) {
+		return ErrorCodeCredentials
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	var codes []string
+	for _, e := range apiErr.Errors {
+		codes = append(codes, e.Reason)
+	}
+	return strings.Join(codes, ",")
+}
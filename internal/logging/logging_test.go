@@ -0,0 +1,167 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+	"google.golang.org/api/googleapi"
+)
+
+func TestJSONLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewJSONLogger(buf)
+	l.Log(Event{
+		Time:     time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC),
+		Instance: "my-project/my-region/my-cluster/my-instance",
+		Name:     EventRefresh,
+		Duration: 1500 * time.Millisecond,
+		Err: fmt.Errorf("outer: %w", &googleapi.Error{
+			Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}},
+		}),
+	})
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("want valid JSON, got error = %v (%q)", err, buf.String())
+	}
+	want := map[string]interface{}{
+		"time":       "2022-12-01T00:00:00Z",
+		"severity":   "DEBUG",
+		"instance":   "my-project/my-region/my-cluster/my-instance",
+		"event":      "refresh",
+		"duration":   float64(1500),
+		"error_code": "rateLimitExceeded",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("field %q: want = %v, got = %v", k, v, got[k])
+		}
+	}
+	if _, ok := got["error"]; !ok {
+		t.Errorf("want error field, got = %v", got)
+	}
+}
+
+func TestJSONLoggerOmitsEmptyFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewJSONLogger(buf)
+	l.Log(Event{Name: EventDial})
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("want valid JSON, got error = %v (%q)", err, buf.String())
+	}
+	for _, k := range []string{"duration", "error_code", "error", "instance"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("want field %q to be omitted, got = %v", k, got)
+		}
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	tcs := []struct {
+		desc string
+		in   error
+		want string
+	}{
+		{
+			desc: "without an API error",
+			in:   errors.New("not an API error"),
+			want: "",
+		},
+		{
+			desc: "with a single API error",
+			in: fmt.Errorf("outer: %w", &googleapi.Error{
+				Errors: []googleapi.ErrorItem{
+					{Reason: "instanceDoesNotExist"},
+				},
+			}),
+			want: "instanceDoesNotExist",
+		},
+		{
+			desc: "with multiple API errors",
+			in: fmt.Errorf("outer: %w", &googleapi.Error{
+				Errors: []googleapi.ErrorItem{
+					{Reason: "instanceDoesNotExist"},
+					{Reason: "someOtherError"},
+				},
+			}),
+			want: "instanceDoesNotExist,someOtherError",
+		},
+		{
+			desc: "with a credentials error",
+			in:   errtype.NewRefreshError("msg", "inst", fmt.Errorf("%w: token expired", errtype.ErrCredentials)),
+			want: ErrorCodeCredentials,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := ErrorCode(tc.in); got != tc.want {
+				t.Errorf("want = %v, got = %v", got, tc.want)
+			}
+		})
+	}
+}
+
+type spyDebugLogger struct {
+	lines []string
+}
+
+func (s *spyDebugLogger) Debugf(format string, args ...interface{}) {
+	s.lines = append(s.lines, fmt.Sprintf(format, args...))
+}
+
+func TestDebugfLogger(t *testing.T) {
+	spy := &spyDebugLogger{}
+	l := NewDebugfLogger(spy)
+	l.Log(Event{
+		Instance: "my-project/my-region/my-cluster/my-instance",
+		Name:     EventRefresh,
+		Message:  "refresh failed",
+		Duration: 2 * time.Second,
+		Expiry:   time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC),
+		Err:      errors.New("boom"),
+		Label:    "pool=payments",
+	})
+
+	want := "[my-project/my-region/my-cluster/my-instance] refresh failed " +
+		"(label = pool=payments) (duration = 2s) (cert expiry = 2022-12-01T00:00:00Z): boom"
+	if len(spy.lines) != 1 || spy.lines[0] != want {
+		t.Fatalf("want = %q, got = %q", want, spy.lines)
+	}
+}
+
+func TestSwappable(t *testing.T) {
+	first, second := &spyDebugLogger{}, &spyDebugLogger{}
+	l := NewSwappable(NewDebugfLogger(first))
+	l.Log(Event{Name: EventDial})
+	l.Swap(NewDebugfLogger(second))
+	l.Log(Event{Name: EventRefresh})
+
+	if len(first.lines) != 1 || first.lines[0] != EventDial {
+		t.Fatalf("first logger: want = [%q], got = %q", EventDial, first.lines)
+	}
+	if len(second.lines) != 1 || second.lines[0] != EventRefresh {
+		t.Fatalf("second logger: want = [%q], got = %q", EventRefresh, second.lines)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger returns a Logger that writes each event to l as a record
+// with structured attributes. Events with an error are logged at
+// slog.LevelWarn, all others at slog.LevelDebug.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l: l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Log(e Event) {
+	level := slog.LevelDebug
+	if e.Err != nil {
+		level = slog.LevelWarn
+	}
+	ctx := context.Background()
+	if !s.l.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("event", e.Name)}
+	if e.Instance != "" {
+		attrs = append(attrs, slog.String("instance", e.Instance))
+	}
+	if e.DialerID != "" {
+		attrs = append(attrs, slog.String("dialer_id", e.DialerID))
+	}
+	if e.Addr != "" {
+		attrs = append(attrs, slog.String("addr", e.Addr))
+	}
+	if e.Label != "" {
+		attrs = append(attrs, slog.String("label", e.Label))
+	}
+	if e.Duration > 0 {
+		attrs = append(attrs, slog.Duration("duration", e.Duration))
+	}
+	if !e.Expiry.IsZero() {
+		attrs = append(attrs, slog.Time("refresh_expiry", e.Expiry))
+	}
+	if e.Err != nil {
+		if code := ErrorCode(e.Err); code != "" {
+			attrs = append(attrs, slog.String("error_code", code))
+		}
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+	msg := e.Message
+	if msg == "" {
+		msg = e.Name
+	}
+	s.l.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := WithDialerID(NewSlogLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))), "dialer-id")
+	l.Log(Event{
+		Instance: "my-project/my-region/my-cluster/my-instance",
+		Name:     EventRefresh,
+		Message:  "refresh failed",
+		Duration: 1500 * time.Millisecond,
+		Expiry:   time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC),
+		Err:      errors.New("boom"),
+	})
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("want valid JSON, got error = %v (%q)", err, buf.String())
+	}
+	want := map[string]interface{}{
+		"level":          "WARN",
+		"msg":            "refresh failed",
+		"event":          "refresh",
+		"instance":       "my-project/my-region/my-cluster/my-instance",
+		"dialer_id":      "dialer-id",
+		"duration":       float64(1500 * time.Millisecond),
+		"refresh_expiry": "2022-12-01T00:00:00Z",
+		"error":          "boom",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("attribute %q: want = %v, got = %v", k, v, got[k])
+		}
+	}
+}
+
+func TestSlogLoggerLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	// The default level drops events without errors.
+	l := NewSlogLogger(slog.New(slog.NewJSONHandler(buf, nil)))
+	l.Log(Event{Name: EventRefresh, Message: "refresh succeeded"})
+	if buf.Len() != 0 {
+		t.Fatalf("want no output at the default level, got = %q", buf.String())
+	}
+	l.Log(Event{Name: EventRefresh, Message: "refresh failed", Err: errors.New("boom")})
+	if buf.Len() == 0 {
+		t.Fatal("want failed events logged at the default level")
+	}
+}
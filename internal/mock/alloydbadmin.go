@@ -17,6 +17,9 @@ package mock
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
@@ -34,7 +37,7 @@ import (
 	"testing"
 	"time"
 
-	"cloud.google.com/go/alloydbconn/internal/alloydbapi"
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
 )
 
 // Option configures a FakeAlloyDBInstance
@@ -47,6 +50,20 @@ func WithIPAddr(addr string) Option {
 	}
 }
 
+// WithPublicIPAddr sets the public IP address of the instance.
+func WithPublicIPAddr(addr string) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.publicIPAddr = addr
+	}
+}
+
+// WithPSCDNSName sets the Private Service Connect DNS name of the instance.
+func WithPSCDNSName(name string) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.pscDNSName = name
+	}
+}
+
 // WithServerName sets the name that server uses to identify itself in the TLS
 // handshake.
 func WithServerName(name string) Option {
@@ -55,6 +72,24 @@ func WithServerName(name string) Option {
 	}
 }
 
+// WithServerSANs sets the subject alternative names of the server
+// certificate. Names that parse as IP addresses are added as IP SANs, the
+// rest as DNS SANs.
+func WithServerSANs(names ...string) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.serverSANs = names
+	}
+}
+
+// WithUID sets the UID of the instance. The server name is unchanged, so
+// clients of the instance reject the server's certificate unless WithServerName
+// is set to match.
+func WithUID(uid string) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.uid = uid
+	}
+}
+
 // WithCertExpiry sets the expiration time of the fake instance
 func WithCertExpiry(expiry time.Time) Option {
 	return func(f *FakeAlloyDBInstance) {
@@ -62,6 +97,38 @@ func WithCertExpiry(expiry time.Time) Option {
 	}
 }
 
+// WithClusterType sets the type of the instance's cluster, i.e., PRIMARY or
+// SECONDARY.
+func WithClusterType(t string) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.clusterType = t
+	}
+}
+
+// WithInstanceType sets the type of the instance, i.e., PRIMARY, READ_POOL,
+// or SECONDARY.
+func WithInstanceType(t string) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.instanceType = t
+	}
+}
+
+// WithDatabaseVersion sets the database version of the instance's cluster,
+// e.g., POSTGRES_15.
+func WithDatabaseVersion(v string) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.databaseVersion = v
+	}
+}
+
+// WithIntermediateCAs sets the number of intermediate CAs between the root CA
+// and client certificates. The default is one.
+func WithIntermediateCAs(n int) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.numIntermeds = n
+	}
+}
+
 // FakeAlloyDBInstance represents the server side proxy.
 type FakeAlloyDBInstance struct {
 	project string
@@ -69,21 +136,39 @@ type FakeAlloyDBInstance struct {
 	cluster string
 	name    string
 
-	ipAddr     string
-	uid        string
-	serverName string
-	certExpiry time.Time
+	ipAddr       string
+	publicIPAddr string
+	pscDNSName   string
+	uid          string
+	serverName   string
+	serverSANs   []string
+	certExpiry   time.Time
+	clusterType  string
+	instanceType string
+	// databaseVersion is the cluster's database version.
+	databaseVersion string
 
 	rootCACert *x509.Certificate
 	rootKey    *rsa.PrivateKey
 
-	intermedCert *x509.Certificate
-	intermedKey  *rsa.PrivateKey
+	// intermedCerts are the intermediate CAs, starting with the issuer of
+	// client certificates, whose key is intermedKey.
+	numIntermeds  int
+	intermedCerts []*x509.Certificate
+	intermedKey   crypto.Signer
 
 	serverCert *x509.Certificate
 	serverKey  *rsa.PrivateKey
 }
 
+func mustGenerateECKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
 func mustGenerateKey() *rsa.PrivateKey {
 	key, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
@@ -101,14 +186,18 @@ var (
 // NewFakeInstance creates a Fake AlloyDB instance.
 func NewFakeInstance(proj, reg, clust, name string, opts ...Option) FakeAlloyDBInstance {
 	f := FakeAlloyDBInstance{
-		project:    proj,
-		region:     reg,
-		cluster:    clust,
-		name:       name,
-		ipAddr:     "127.0.0.1",
-		uid:        "00000000-0000-0000-0000-000000000000",
-		serverName: "00000000-0000-0000-0000-000000000000.server.alloydb",
-		certExpiry: time.Now().Add(24 * time.Hour),
+		project:         proj,
+		region:          reg,
+		cluster:         clust,
+		name:            name,
+		ipAddr:          "127.0.0.1",
+		uid:             "00000000-0000-0000-0000-000000000000",
+		serverName:      "00000000-0000-0000-0000-000000000000.server.alloydb",
+		certExpiry:      time.Now().Add(24 * time.Hour),
+		clusterType:     "PRIMARY",
+		instanceType:    "PRIMARY",
+		databaseVersion: "POSTGRES_15",
+		numIntermeds:    1,
 	}
 
 	for _, o := range opts {
@@ -137,27 +226,44 @@ func NewFakeInstance(proj, reg, clust, name string, opts ...Option) FakeAlloyDBI
 	if err != nil {
 		panic(err)
 	}
-	// create an intermediate CA, signed by the root
-	// This CA signs all client certs.
-	intermedTemplate := &x509.Certificate{
-		SerialNumber: &big.Int{},
-		Subject: pkix.Name{
-			CommonName: "client.alloydb",
-		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(0, 0, 1),
-		IsCA:                  true,
-		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
-		BasicConstraintsValid: true,
-	}
-	signedIntermed, err := x509.CreateCertificate(
-		rand.Reader, intermedTemplate, rootCert, &intermedCAKey.PublicKey, rootCAKey)
-	if err != nil {
-		panic(err)
-	}
-	intermedCert, err := x509.ParseCertificate(signedIntermed)
-	if err != nil {
-		panic(err)
+	// create the intermediate CAs, each signed by the previous one, starting
+	// with the root. The last one signs all client certs.
+	var (
+		issuer                      = rootCert
+		issuerKey     crypto.Signer = rootCAKey
+		intermedCerts []*x509.Certificate
+	)
+	for n := 0; n < f.numIntermeds; n++ {
+		// Only the CA that signs client certs uses the shared key, as
+		// generating RSA keys is slow.
+		var key crypto.Signer = intermedCAKey
+		name := "client.alloydb"
+		if n < f.numIntermeds-1 {
+			key = mustGenerateECKey()
+			name = fmt.Sprintf("intermediate-%d.alloydb", n)
+		}
+		intermedTemplate := &x509.Certificate{
+			SerialNumber: &big.Int{},
+			Subject: pkix.Name{
+				CommonName: name,
+			},
+			NotBefore:             time.Now(),
+			NotAfter:              time.Now().AddDate(0, 0, 1),
+			IsCA:                  true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+			BasicConstraintsValid: true,
+		}
+		signedIntermed, err := x509.CreateCertificate(
+			rand.Reader, intermedTemplate, issuer, key.Public(), issuerKey)
+		if err != nil {
+			panic(err)
+		}
+		c, err := x509.ParseCertificate(signedIntermed)
+		if err != nil {
+			panic(err)
+		}
+		intermedCerts = append([]*x509.Certificate{c}, intermedCerts...)
+		issuer, issuerKey = c, key
 	}
 	// create a server certificate, signed by the root
 	// This is what the server side proxy uses.
@@ -172,6 +278,13 @@ func NewFakeInstance(proj, reg, clust, name string, opts ...Option) FakeAlloyDBI
 		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		BasicConstraintsValid: true,
 	}
+	for _, n := range f.serverSANs {
+		if ip := net.ParseIP(n); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+			continue
+		}
+		serverTemplate.DNSNames = append(serverTemplate.DNSNames, n)
+	}
 	signedServer, err := x509.CreateCertificate(
 		rand.Reader, serverTemplate, rootCert, &serverKey.PublicKey, rootCAKey)
 	if err != nil {
@@ -185,14 +298,24 @@ func NewFakeInstance(proj, reg, clust, name string, opts ...Option) FakeAlloyDBI
 	// save all TLS certificates for later use.
 	f.rootCACert = rootCert
 	f.rootKey = rootCAKey
-	f.intermedCert = intermedCert
-	f.intermedKey = intermedCAKey
+	f.intermedCerts = intermedCerts
+	f.intermedKey = issuerKey
 	f.serverCert = serverCert
 	f.serverKey = serverKey
 
 	return f
 }
 
+// RootCA returns the root CA certificate of the instance's certificate
+// chains.
+func (f FakeAlloyDBInstance) RootCA() *x509.Certificate {
+	return f.rootCACert
+}
+
+// Unlimited may be passed as the count of a Request to respond to any number
+// of matching calls.
+const Unlimited = -1
+
 // Request represents a HTTP request for a test Server to mock responses for.
 //
 // Use NewRequest to initialize new Requests.
@@ -216,10 +339,12 @@ func (r *Request) matches(hR *http.Request) bool {
 	if r.reqPath != "" && r.reqPath != hR.URL.Path {
 		return false
 	}
-	if r.reqCt <= 0 {
+	if r.reqCt == 0 {
 		return false
 	}
-	r.reqCt--
+	if r.reqCt > 0 {
+		r.reqCt--
+	}
 	return true
 }
 
@@ -234,7 +359,58 @@ func InstanceGetSuccess(i FakeAlloyDBInstance, ct int) *Request {
 		reqCt:     ct,
 		handle: func(resp http.ResponseWriter, req *http.Request) {
 			resp.WriteHeader(http.StatusOK)
-			resp.Write([]byte(fmt.Sprintf(`{"ipAddress":"%s","instanceUid":"%s"}`, i.ipAddr, i.uid)))
+			resp.Write([]byte(fmt.Sprintf(
+				`{"ipAddress":"%s","publicIpAddress":"%s","pscDnsName":"%s","instanceUid":"%s"}`,
+				i.ipAddr, i.publicIPAddr, i.pscDNSName, i.uid,
+			)))
+		},
+	}
+}
+
+// InstanceListSuccess returns a Request that responds to the `instances.list`
+// AlloyDB Admin API endpoint with the provided instances, which must all
+// belong to the same cluster.
+func InstanceListSuccess(ct int, insts ...FakeAlloyDBInstance) *Request {
+	if len(insts) == 0 {
+		panic("InstanceListSuccess requires at least one instance")
+	}
+	p := fmt.Sprintf("/projects/%s/locations/%s/clusters/%s/instances",
+		insts[0].project, insts[0].region, insts[0].cluster)
+	var resp alloydbadmin.ListInstancesResponse
+	for _, i := range insts {
+		resp.Instances = append(resp.Instances, alloydbadmin.Instance{
+			Name: fmt.Sprintf("projects/%s/locations/%s/clusters/%s/instances/%s",
+				i.project, i.region, i.cluster, i.name),
+			InstanceType: i.instanceType,
+			State:        "READY",
+		})
+	}
+	return &Request{
+		reqMethod: http.MethodGet,
+		reqPath:   p,
+		reqCt:     ct,
+		handle: func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(&resp)
+		},
+	}
+}
+
+// ClusterGetSuccess returns a Request that responds to the `cluster.get`
+// AlloyDB Admin API endpoint.
+func ClusterGetSuccess(i FakeAlloyDBInstance, ct int) *Request {
+	p := fmt.Sprintf("/projects/%s/locations/%s/clusters/%s",
+		i.project, i.region, i.cluster)
+	return &Request{
+		reqMethod: http.MethodGet,
+		reqPath:   p,
+		reqCt:     ct,
+		handle: func(resp http.ResponseWriter, req *http.Request) {
+			resp.WriteHeader(http.StatusOK)
+			resp.Write([]byte(fmt.Sprintf(
+				`{"name":"projects/%s/locations/%s/clusters/%s","clusterType":"%s","databaseVersion":"%s"}`,
+				i.project, i.region, i.cluster, i.clusterType, i.databaseVersion,
+			)))
 		},
 	}
 }
@@ -256,7 +432,7 @@ func CreateEphemeralSuccess(i FakeAlloyDBInstance, ct int) *Request {
 				http.Error(resp, fmt.Errorf("unable to read body: %w", err).Error(), http.StatusBadRequest)
 				return
 			}
-			var rreq alloydbapi.GenerateClientCertificateRequest
+			var rreq alloydbadmin.GenerateClientCertificateRequest
 			err = json.Unmarshal(b, &rreq)
 			if err != nil {
 				http.Error(resp, fmt.Errorf("invalid or unexpected json: %w", err).Error(), http.StatusBadRequest)
@@ -273,39 +449,15 @@ func CreateEphemeralSuccess(i FakeAlloyDBInstance, ct int) *Request {
 				return
 			}
 
-			template := &x509.Certificate{
-				Signature:          csr.Signature,
-				SignatureAlgorithm: csr.SignatureAlgorithm,
-				PublicKeyAlgorithm: csr.PublicKeyAlgorithm,
-				PublicKey:          csr.PublicKey,
-				SerialNumber:       &big.Int{},
-				Issuer:             i.intermedCert.Subject,
-				Subject:            csr.Subject,
-				NotBefore:          time.Now(),
-				NotAfter:           i.certExpiry,
-				KeyUsage:           x509.KeyUsageDigitalSignature,
-				ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
-			}
-
-			cert, err := x509.CreateCertificate(
-				rand.Reader, template, i.intermedCert, template.PublicKey, i.intermedKey)
+			chain, err := i.clientCertChain(csr.PublicKey, csr.Subject)
 			if err != nil {
 				http.Error(resp, fmt.Errorf("unable to create certificate: %w", err).Error(), http.StatusBadRequest)
 				return
 			}
 
-			certPEM := &bytes.Buffer{}
-			pem.Encode(certPEM, &pem.Block{Type: "CERTIFICATE", Bytes: cert})
-
-			instancePEM := &bytes.Buffer{}
-			pem.Encode(instancePEM, &pem.Block{Type: "CERTIFICATE", Bytes: i.intermedCert.Raw})
-
-			caPEM := &bytes.Buffer{}
-			pem.Encode(caPEM, &pem.Block{Type: "CERTIFICATE", Bytes: i.rootCACert.Raw})
-
-			rresp := alloydbapi.GenerateClientCertificateResponse{
-				PemCertificate:      certPEM.String(),
-				PemCertificateChain: []string{instancePEM.String(), caPEM.String()},
+			rresp := alloydbadmin.GenerateClientCertificateResponse{
+				PemCertificate:      chain[0],
+				PemCertificateChain: chain[1:],
 			}
 			if err := json.NewEncoder(resp).Encode(&rresp); err != nil {
 				http.Error(resp, fmt.Errorf("unable to encode response: %w", err).Error(), http.StatusBadRequest)
@@ -315,6 +467,68 @@ func CreateEphemeralSuccess(i FakeAlloyDBInstance, ct int) *Request {
 	}
 }
 
+// issuer returns the CA that signs client certificates.
+func (i FakeAlloyDBInstance) issuer() *x509.Certificate {
+	if len(i.intermedCerts) == 0 {
+		return i.rootCACert
+	}
+	return i.intermedCerts[0]
+}
+
+// clientCertChain signs a client certificate for the public key and returns
+// the PEM encoded client, intermediate, and root CA certificates.
+func (i FakeAlloyDBInstance) clientCertChain(pub interface{}, subj pkix.Name) ([]string, error) {
+	template := &x509.Certificate{
+		SerialNumber: &big.Int{},
+		Issuer:       i.issuer().Subject,
+		Subject:      subj,
+		NotBefore:    time.Now(),
+		NotAfter:     i.certExpiry,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(
+		rand.Reader, template, i.issuer(), pub, i.intermedKey)
+	if err != nil {
+		return nil, err
+	}
+	ders := [][]byte{cert}
+	for _, c := range i.intermedCerts {
+		ders = append(ders, c.Raw)
+	}
+	ders = append(ders, i.rootCACert.Raw)
+	var chain []string
+	for _, der := range ders {
+		buf := &bytes.Buffer{}
+		pem.Encode(buf, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+		chain = append(chain, buf.String())
+	}
+	return chain, nil
+}
+
+// StaticConnectionInfo returns a static connection info document for the
+// instance, with a client certificate issued for the provided key.
+func StaticConnectionInfo(i FakeAlloyDBInstance, key *rsa.PrivateKey) ([]byte, error) {
+	chain, err := i.clientCertChain(&key.PublicKey, pkix.Name{CommonName: "alloydb-proxy"})
+	if err != nil {
+		return nil, err
+	}
+	keyPEM := &bytes.Buffer{}
+	pem.Encode(keyPEM, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	uri := fmt.Sprintf("projects/%s/locations/%s/clusters/%s/instances/%s",
+		i.project, i.region, i.cluster, i.name)
+	return json.Marshal(map[string]interface{}{
+		"privateKey": keyPEM.String(),
+		uri: alloydbadmin.StaticInstanceInfo{
+			IPAddress:           i.ipAddr,
+			PublicIPAddress:     i.publicIPAddr,
+			PSCDNSName:          i.pscDNSName,
+			InstanceUID:         i.uid,
+			PemCertificateChain: chain,
+		},
+	})
+}
+
 // HTTPClient returns an *http.Client, URL, and cleanup function. The http.Client is
 // configured to connect to test SSL Server at the returned URL. This server will
 // respond to HTTP requests defined, or return a 5xx server error for unexpected ones.
@@ -352,31 +566,43 @@ func HTTPClient(requests ...*Request) (*http.Client, string, func() error) {
 
 }
 
+// serverTLSConfig returns the TLS configuration of the server proxy for the
+// provided instance.
+func serverTLSConfig(inst FakeAlloyDBInstance) *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(inst.rootCACert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{
+			tls.Certificate{
+				Certificate: [][]byte{inst.serverCert.Raw, inst.rootCACert.Raw},
+				PrivateKey:  inst.serverKey,
+				Leaf:        inst.serverCert,
+			},
+		},
+		ServerName: "FIXME", // FIXME: this will become the instance UID
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+	}
+}
+
+// ListenServerProxy returns a listener on an ephemeral localhost port that
+// accepts TLS connections as the server proxy of the provided instance.
+func ListenServerProxy(inst FakeAlloyDBInstance) (net.Listener, error) {
+	return tls.Listen("tcp", "127.0.0.1:0", serverTLSConfig(inst))
+}
+
 // StartServerProxy starts a fake server proxy and listens on the provided port
 // on all interfaces, configured with TLS as specified by the
 // FakeAlloyDBInstance. Callers should invoke the returned function to clean up
 // all resources.
 func StartServerProxy(t *testing.T, inst FakeAlloyDBInstance) func() {
-	pool := x509.NewCertPool()
-	pool.AddCert(inst.rootCACert)
 	tryListen := func(t *testing.T, attempts int) net.Listener {
 		var (
 			ln  net.Listener
 			err error
 		)
 		for i := 0; i < attempts; i++ {
-			ln, err = tls.Listen("tcp", ":5433", &tls.Config{
-				Certificates: []tls.Certificate{
-					tls.Certificate{
-						Certificate: [][]byte{inst.serverCert.Raw, inst.rootCACert.Raw},
-						PrivateKey:  inst.serverKey,
-						Leaf:        inst.serverCert,
-					},
-				},
-				ServerName: "FIXME", // FIXME: this will become the instance UID
-				ClientAuth: tls.RequireAndVerifyClientCert,
-				ClientCAs:  pool,
-			})
+			ln, err = tls.Listen("tcp", ":5433", serverTLSConfig(inst))
 			if err != nil {
 				t.Log("listener failed to start, waiting 100ms")
 				time.Sleep(500 * time.Millisecond)
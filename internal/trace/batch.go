@@ -0,0 +1,137 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queuedRecord is a measurement waiting to be reported.
+type queuedRecord struct {
+	at     time.Time
+	record func(Recorder)
+}
+
+// batchRecorder reports measurements to a Recorder from a single worker
+// goroutine. Measurements are queued and return immediately, so callers never
+// block on a telemetry backend. The worker starts when a measurement is
+// queued and exits once the queue is empty, so an idle batchRecorder holds no
+// goroutine. Spans are started synchronously.
+type batchRecorder struct {
+	// dropped is the number of measurements discarded because the queue was
+	// full or they expired. It is accessed atomically and kept first in the
+	// struct for 64-bit alignment.
+	dropped uint64
+
+	r     Recorder
+	queue chan queuedRecord
+	// ttl is how long a measurement may wait in the queue before it is
+	// discarded. Zero means measurements never expire.
+	ttl time.Duration
+
+	// mu protects running.
+	mu      sync.Mutex
+	running bool
+}
+
+// NewBatchRecorder returns a Recorder that reports measurements to r
+// asynchronously from a single worker goroutine. At most size measurements
+// are queued; any more are discarded until the worker catches up. When ttl is
+// non-zero, measurements that waited longer than ttl are discarded rather
+// than reported late.
+func NewBatchRecorder(r Recorder, size int, ttl time.Duration) Recorder {
+	return &batchRecorder{
+		r:     r,
+		queue: make(chan queuedRecord, size),
+		ttl:   ttl,
+	}
+}
+
+// enqueue queues f to be called with the underlying Recorder, starting the
+// worker if it is not running.
+func (b *batchRecorder) enqueue(f func(Recorder)) {
+	select {
+	case b.queue <- queuedRecord{at: time.Now(), record: f}:
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.running {
+		b.running = true
+		go b.run()
+	}
+}
+
+// run reports queued measurements until the queue is empty.
+func (b *batchRecorder) run() {
+	for {
+		select {
+		case q := <-b.queue:
+			if b.ttl > 0 && time.Since(q.at) > b.ttl {
+				atomic.AddUint64(&b.dropped, 1)
+				continue
+			}
+			q.record(b.r)
+		default:
+			// Check for a measurement queued after the select while holding
+			// mu, so that enqueue either sees the worker running or starts
+			// a new one.
+			b.mu.Lock()
+			if len(b.queue) > 0 {
+				b.mu.Unlock()
+				continue
+			}
+			b.running = false
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (b *batchRecorder) StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, EndSpanFunc) {
+	return b.r.StartSpan(ctx, name, attrs...)
+}
+
+func (b *batchRecorder) RecordDialLatency(ctx context.Context, instance, dialerID string, latency int64) {
+	b.enqueue(func(r Recorder) { r.RecordDialLatency(ctx, instance, dialerID, latency) })
+}
+
+func (b *batchRecorder) RecordOpenConnections(ctx context.Context, num int64, dialerID, instance string) {
+	b.enqueue(func(r Recorder) { r.RecordOpenConnections(ctx, num, dialerID, instance) })
+}
+
+func (b *batchRecorder) RecordDialError(ctx context.Context, instance, dialerID string, err error) {
+	if err == nil {
+		return
+	}
+	b.enqueue(func(r Recorder) { r.RecordDialError(ctx, instance, dialerID, err) })
+}
+
+func (b *batchRecorder) RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error) {
+	b.enqueue(func(r Recorder) { r.RecordRefreshResult(ctx, instance, dialerID, trigger, err) })
+}
+
+func (b *batchRecorder) RecordDialQueueDepth(ctx context.Context, instance, dialerID string, depth int64) {
+	b.enqueue(func(r Recorder) { r.RecordDialQueueDepth(ctx, instance, dialerID, depth) })
+}
+
+func (b *batchRecorder) RecordCertIssuanceLatency(ctx context.Context, instance, dialerID string, latency int64) {
+	b.enqueue(func(r Recorder) { r.RecordCertIssuanceLatency(ctx, instance, dialerID, latency) })
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// depthRecorder records reported dial queue depths and blocks while gate is
+// held.
+type depthRecorder struct {
+	Recorder
+	gate sync.Mutex
+
+	mu     sync.Mutex
+	depths []int64
+}
+
+func (r *depthRecorder) RecordDialQueueDepth(_ context.Context, _, _ string, depth int64) {
+	r.gate.Lock()
+	defer r.gate.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.depths = append(r.depths, depth)
+}
+
+func (r *depthRecorder) recorded() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int64(nil), r.depths...)
+}
+
+// waitFor polls until the batchRecorder's worker has exited.
+func waitFor(t *testing.T, b *batchRecorder) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		b.mu.Lock()
+		running := b.running
+		b.mu.Unlock()
+		if !running {
+			return
+		}
+	}
+	t.Fatal("worker did not exit")
+}
+
+func TestBatchRecorderReportsInOrder(t *testing.T) {
+	ctx := context.Background()
+	r := &depthRecorder{Recorder: OpenCensus()}
+	b := NewBatchRecorder(r, 10, 0).(*batchRecorder)
+
+	for n := int64(1); n <= 5; n++ {
+		b.RecordDialQueueDepth(ctx, "my-instance", "dialer-id", n)
+	}
+	waitFor(t, b)
+
+	if want, got := []int64{1, 2, 3, 4, 5}, r.recorded(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+	if got := atomic.LoadUint64(&b.dropped); got != 0 {
+		t.Fatalf("want no dropped measurements, got = %v", got)
+	}
+}
+
+func TestBatchRecorderDropsWhenFull(t *testing.T) {
+	ctx := context.Background()
+	r := &depthRecorder{Recorder: OpenCensus()}
+	b := NewBatchRecorder(r, 2, 0).(*batchRecorder)
+
+	// Block the worker on the first measurement so that the queue fills.
+	r.gate.Lock()
+	b.RecordDialQueueDepth(ctx, "my-instance", "dialer-id", 1)
+	for start := time.Now(); len(b.queue) > 0 && time.Since(start) < time.Second; {
+		time.Sleep(time.Millisecond)
+	}
+	for n := int64(2); n <= 5; n++ {
+		b.RecordDialQueueDepth(ctx, "my-instance", "dialer-id", n)
+	}
+	r.gate.Unlock()
+	waitFor(t, b)
+
+	if want, got := []int64{1, 2, 3}, r.recorded(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+	if got := atomic.LoadUint64(&b.dropped); got != 2 {
+		t.Fatalf("want 2 dropped measurements, got = %v", got)
+	}
+}
+
+func TestBatchRecorderDropsExpired(t *testing.T) {
+	ctx := context.Background()
+	r := &depthRecorder{Recorder: OpenCensus()}
+	b := NewBatchRecorder(r, 10, 10*time.Millisecond).(*batchRecorder)
+
+	r.gate.Lock()
+	b.RecordDialQueueDepth(ctx, "my-instance", "dialer-id", 1)
+	for start := time.Now(); len(b.queue) > 0 && time.Since(start) < time.Second; {
+		time.Sleep(time.Millisecond)
+	}
+	b.RecordDialQueueDepth(ctx, "my-instance", "dialer-id", 2)
+	time.Sleep(50 * time.Millisecond)
+	r.gate.Unlock()
+	waitFor(t, b)
+
+	if want, got := []int64{1}, r.recorded(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+	if got := atomic.LoadUint64(&b.dropped); got != 1 {
+		t.Fatalf("want 1 dropped measurement, got = %v", got)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// LatencyWindow holds the most recent latency samples, in milliseconds, and
+// computes percentiles over them.
+type LatencyWindow struct {
+	mu      sync.Mutex
+	samples []int64
+	// next is the index the next sample is written to once samples is
+	// full.
+	next int
+	size int
+}
+
+// NewLatencyWindow returns a LatencyWindow that holds up to size samples.
+func NewLatencyWindow(size int) *LatencyWindow {
+	return &LatencyWindow{samples: make([]int64, 0, size), size: size}
+}
+
+// Add records a sample, replacing the oldest one if the window is full.
+func (w *LatencyWindow) Add(ms int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) < w.size {
+		w.samples = append(w.samples, ms)
+		return
+	}
+	w.samples[w.next] = ms
+	w.next = (w.next + 1) % w.size
+}
+
+// Percentiles returns the nearest-rank percentile of the samples for each of
+// ps, which must be in (0, 100], along with the number of samples. The
+// percentiles are zero if there are no samples.
+func (w *LatencyWindow) Percentiles(ps ...float64) ([]int64, int) {
+	w.mu.Lock()
+	sorted := append([]int64(nil), w.samples...)
+	w.mu.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	res := make([]int64, len(ps))
+	if len(sorted) == 0 {
+		return res, 0
+	}
+	for n, p := range ps {
+		// The nearest rank is ceil(p/100 * N), counting from one.
+		rank := int(p/100*float64(len(sorted)) + 0.999999)
+		if rank < 1 {
+			rank = 1
+		}
+		if rank > len(sorted) {
+			rank = len(sorted)
+		}
+		res[n] = sorted[rank-1]
+	}
+	return res, len(sorted)
+}
+
+// certLatencyRecorder adds certificate issuance latencies to a LatencyWindow
+// and ignores all other telemetry.
+type certLatencyRecorder struct {
+	w *LatencyWindow
+}
+
+// CertIssuanceRecorder returns a Recorder that adds the latency of each
+// certificate issuance to w and ignores all other telemetry.
+func CertIssuanceRecorder(w *LatencyWindow) Recorder {
+	return certLatencyRecorder{w: w}
+}
+
+func (certLatencyRecorder) StartSpan(ctx context.Context, _ string, _ ...Attribute) (context.Context, EndSpanFunc) {
+	return ctx, func(error) {}
+}
+
+func (certLatencyRecorder) RecordDialLatency(context.Context, string, string, int64) {}
+
+func (certLatencyRecorder) RecordOpenConnections(context.Context, int64, string, string) {}
+
+func (certLatencyRecorder) RecordDialError(context.Context, string, string, error) {}
+
+func (certLatencyRecorder) RecordRefreshResult(context.Context, string, string, string, error) {}
+
+func (certLatencyRecorder) RecordDialQueueDepth(context.Context, string, string, int64) {}
+
+func (r certLatencyRecorder) RecordCertIssuanceLatency(_ context.Context, _, _ string, latency int64) {
+	r.w.Add(latency)
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLatencyWindowPercentiles(t *testing.T) {
+	w := NewLatencyWindow(100)
+	if ps, n := w.Percentiles(50); n != 0 || ps[0] != 0 {
+		t.Fatalf("want no samples, got = %v, %v", ps, n)
+	}
+	// Add 1 through 100 in reverse order.
+	for ms := int64(100); ms > 0; ms-- {
+		w.Add(ms)
+	}
+	ps, n := w.Percentiles(50, 95, 99, 100)
+	if n != 100 {
+		t.Fatalf("want 100 samples, got = %v", n)
+	}
+	want := []int64{50, 95, 99, 100}
+	for i := range want {
+		if ps[i] != want[i] {
+			t.Errorf("percentile %v: want = %v, got = %v", i, want[i], ps[i])
+		}
+	}
+}
+
+func TestLatencyWindowEvictsOldest(t *testing.T) {
+	w := NewLatencyWindow(3)
+	r := CertIssuanceRecorder(w)
+	for _, ms := range []int64{1000, 1000, 1000, 1, 2, 3} {
+		r.RecordCertIssuanceLatency(context.Background(), "my-instance", "dialer-id", ms)
+	}
+	ps, n := w.Percentiles(100)
+	if n != 3 {
+		t.Fatalf("want 3 samples, got = %v", n)
+	}
+	if ps[0] != 3 {
+		t.Fatalf("want max of the newest samples = 3, got = %v", ps[0])
+	}
+}
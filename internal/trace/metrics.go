@@ -16,21 +16,21 @@ package trace
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"strings"
 	"sync"
 
+	"cloud.google.com/go/alloydbconn/internal/logging"
+
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
-	"google.golang.org/api/googleapi"
 )
 
 var (
 	keyInstance, _  = tag.NewKey("alloydb_instance")
 	keyDialerID, _  = tag.NewKey("alloydb_dialer_id")
 	keyErrorCode, _ = tag.NewKey("alloydb_error_code")
+	keyTrigger, _   = tag.NewKey("alloydb_refresh_trigger")
 
 	mLatencyMS = stats.Int64(
 		"/alloydbconn/latency",
@@ -57,6 +57,16 @@ var (
 		"A failed certificate refresh operation",
 		stats.UnitDimensionless,
 	)
+	mDialQueueDepth = stats.Int64(
+		"/alloydbconn/dial_queue",
+		"The number of dials waiting on an in-flight refresh operation",
+		stats.UnitDimensionless,
+	)
+	mCertIssuanceMS = stats.Int64(
+		"/alloydbconn/cert_issuance_latency",
+		"The latency in milliseconds per client certificate issued by the AlloyDB Admin API",
+		stats.UnitMilliseconds,
+	)
 
 	latencyView = &view.View{
 		Name:        "/alloydbconn/dial_latency",
@@ -85,14 +95,28 @@ var (
 		Measure:     mSuccessfulRefresh,
 		Description: "The number of successful certificate refresh operations",
 		Aggregation: view.Count(),
-		TagKeys:     []tag.Key{keyInstance, keyDialerID},
+		TagKeys:     []tag.Key{keyInstance, keyDialerID, keyTrigger},
 	}
 	failedRefreshCountView = &view.View{
 		Name:        "/alloydbconn/refresh_failure_count",
 		Measure:     mFailedRefresh,
 		Description: "The number of failed certificate refresh operations",
 		Aggregation: view.Count(),
-		TagKeys:     []tag.Key{keyInstance, keyDialerID, keyErrorCode},
+		TagKeys:     []tag.Key{keyInstance, keyDialerID, keyErrorCode, keyTrigger},
+	}
+	dialQueueDepthView = &view.View{
+		Name:        "/alloydbconn/dial_queue_depth",
+		Measure:     mDialQueueDepth,
+		Description: "The current number of dials waiting on an in-flight refresh operation",
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{keyInstance, keyDialerID},
+	}
+	certIssuanceLatencyView = &view.View{
+		Name:        "/alloydbconn/cert_issuance_latency",
+		Measure:     mCertIssuanceMS,
+		Description: "The distribution of client certificate issuance latencies (ms)",
+		Aggregation: view.Distribution(0, 50, 100, 250, 500, 1000, 2000, 5000, 10000, 30000),
+		TagKeys:     []tag.Key{keyInstance, keyDialerID},
 	}
 
 	registerOnce sync.Once
@@ -110,6 +134,8 @@ func InitMetrics() error {
 			dialFailureView,
 			refreshCountView,
 			failedRefreshCountView,
+			dialQueueDepthView,
+			certIssuanceLatencyView,
 		); rErr != nil {
 			registerErr = fmt.Errorf("failed to initialize metrics: %v", rErr)
 		}
@@ -144,11 +170,15 @@ func RecordDialError(ctx context.Context, instance, dialerID string, err error)
 }
 
 // RecordRefreshResult reports the result of a refresh operation, either
-// successfull or failed.
-func RecordRefreshResult(ctx context.Context, instance, dialerID string, err error) {
-	ctx, _ = tag.New(ctx, tag.Upsert(keyInstance, instance), tag.Upsert(keyDialerID, dialerID))
+// successfull or failed, tagged with what triggered it.
+func RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error) {
+	ctx, _ = tag.New(ctx,
+		tag.Upsert(keyInstance, instance),
+		tag.Upsert(keyDialerID, dialerID),
+		tag.Upsert(keyTrigger, trigger),
+	)
 	if err != nil {
-		if c := errorCode(err); c != "" {
+		if c := logging.ErrorCode(err); c != "" {
 			ctx, _ = tag.New(ctx, tag.Upsert(keyErrorCode, c))
 		}
 		stats.Record(ctx, mFailedRefresh.M(1))
@@ -157,18 +187,16 @@ func RecordRefreshResult(ctx context.Context, instance, dialerID string, err err
 	stats.Record(ctx, mSuccessfulRefresh.M(1))
 }
 
-// errorCode returns an error code as given from the AlloyDB Admin API, provided
-// the error wraps a googleapi.Error type. If multiple error codes are returned
-// from the API, then a comma-separated string of all codes is returned.
-func errorCode(err error) string {
-	var apiErr *googleapi.Error
-	ok := errors.As(err, &apiErr)
-	if !ok {
-		return ""
-	}
-	var codes []string
-	for _, e := range apiErr.Errors {
-		codes = append(codes, e.Reason)
-	}
-	return strings.Join(codes, ",")
+// RecordDialQueueDepth records the number of dials waiting on an in-flight
+// refresh operation.
+func RecordDialQueueDepth(ctx context.Context, instance, dialerID string, depth int64) {
+	ctx, _ = tag.New(ctx, tag.Upsert(keyInstance, instance), tag.Upsert(keyDialerID, dialerID))
+	stats.Record(ctx, mDialQueueDepth.M(depth))
+}
+
+// RecordCertIssuanceLatency records the latency of a successful call to the
+// AlloyDB Admin API to issue a client certificate.
+func RecordCertIssuanceLatency(ctx context.Context, instance, dialerID string, latency int64) {
+	ctx, _ = tag.New(ctx, tag.Upsert(keyInstance, instance), tag.Upsert(keyDialerID, dialerID))
+	stats.Record(ctx, mCertIssuanceMS.M(latency))
 }
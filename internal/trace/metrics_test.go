@@ -14,57 +14,10 @@
 
 package trace
 
-import (
-	"errors"
-	"fmt"
-	"testing"
-
-	"google.golang.org/api/googleapi"
-)
+import "testing"
 
 func TestMetricsInitializes(t *testing.T) {
 	if err := InitMetrics(); err != nil {
 		t.Fatalf("want no error, got = %v", err)
 	}
 }
-
-func TestErrorCodes(t *testing.T) {
-	tcs := []struct {
-		desc string
-		in   error
-		want string
-	}{
-		{
-			desc: "without an API error",
-			in:   errors.New("not an API error"),
-			want: "",
-		},
-		{
-			desc: "with a single API error",
-			in: fmt.Errorf("outer: %w", &googleapi.Error{
-				Errors: []googleapi.ErrorItem{
-					{Reason: "instanceDoesNotExist"},
-				},
-			}),
-			want: "instanceDoesNotExist",
-		},
-		{
-			desc: "with multiple API errors",
-			in: fmt.Errorf("outer: %w", &googleapi.Error{
-				Errors: []googleapi.ErrorItem{
-					{Reason: "instanceDoesNotExist"},
-					{Reason: "someOtherError"},
-				},
-			}),
-			want: "instanceDoesNotExist,someOtherError",
-		},
-	}
-
-	for _, tc := range tcs {
-		t.Run(tc.desc, func(t *testing.T) {
-			if got := errorCode(tc.in); got != tc.want {
-				t.Errorf("want = %v, got = %v", got, tc.want)
-			}
-		})
-	}
-}
@@ -0,0 +1,203 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/alloydbconn/internal/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/nonrecording"
+	"go.opentelemetry.io/otel/metric/unit"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the connector to OpenTelemetry.
+const instrumentationName = "cloud.google.com/go/alloydbconn"
+
+var (
+	attrInstance  = attribute.Key("alloydb_instance")
+	attrDialerID  = attribute.Key("alloydb_dialer_id")
+	attrErrorCode = attribute.Key("alloydb_error_code")
+	attrTrigger   = attribute.Key("alloydb_refresh_trigger")
+)
+
+// otelRecorder reports metrics and traces to OpenTelemetry.
+type otelRecorder struct {
+	tracer oteltrace.Tracer
+
+	dialLatency    syncint64.Histogram
+	openConns      syncint64.UpDownCounter
+	dialFailures   syncint64.Counter
+	refreshSuccess syncint64.Counter
+	refreshFailure syncint64.Counter
+	dialQueue      syncint64.UpDownCounter
+	certIssuance   syncint64.Histogram
+
+	// mu protects openConnCounts and queueDepths.
+	mu sync.Mutex
+	// openConnCounts holds the last reported number of open connections per
+	// dialer and instance, which is used to convert absolute counts into the
+	// deltas an UpDownCounter expects. Dialers may share a recorder and the
+	// instances it reports on.
+	openConnCounts map[gaugeKey]int64
+	// queueDepths holds the last reported dial queue depth per dialer and
+	// instance, for the same reason.
+	queueDepths map[gaugeKey]int64
+}
+
+// gaugeKey identifies the series of an absolute measurement.
+type gaugeKey struct {
+	dialerID, instance string
+}
+
+// NewOTelRecorder returns a Recorder that reports to the provided
+// OpenTelemetry providers. Either provider may be nil, in which case the
+// corresponding telemetry is not recorded.
+func NewOTelRecorder(mp metric.MeterProvider, tp oteltrace.TracerProvider) (Recorder, error) {
+	if mp == nil {
+		mp = nonrecording.NewNoopMeterProvider()
+	}
+	if tp == nil {
+		tp = oteltrace.NewNoopTracerProvider()
+	}
+	m := mp.Meter(instrumentationName).SyncInt64()
+	r := &otelRecorder{
+		tracer:         tp.Tracer(instrumentationName),
+		openConnCounts: make(map[gaugeKey]int64),
+		queueDepths:    make(map[gaugeKey]int64),
+	}
+	var err error
+	if r.dialLatency, err = m.Histogram(
+		"alloydbconn/dial_latency",
+		instrument.WithDescription("The distribution of dialer latencies (ms)"),
+		instrument.WithUnit(unit.Milliseconds),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create dial latency instrument: %v", err)
+	}
+	if r.openConns, err = m.UpDownCounter(
+		"alloydbconn/open_connections",
+		instrument.WithDescription("The current number of open AlloyDB connections"),
+		instrument.WithUnit(unit.Dimensionless),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create open connections instrument: %v", err)
+	}
+	if r.dialFailures, err = m.Counter(
+		"alloydbconn/dial_failure_count",
+		instrument.WithDescription("The number of failed dial attempts"),
+		instrument.WithUnit(unit.Dimensionless),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create dial failure instrument: %v", err)
+	}
+	if r.refreshSuccess, err = m.Counter(
+		"alloydbconn/refresh_success_count",
+		instrument.WithDescription("The number of successful certificate refresh operations"),
+		instrument.WithUnit(unit.Dimensionless),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create refresh success instrument: %v", err)
+	}
+	if r.refreshFailure, err = m.Counter(
+		"alloydbconn/refresh_failure_count",
+		instrument.WithDescription("The number of failed certificate refresh operations"),
+		instrument.WithUnit(unit.Dimensionless),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create refresh failure instrument: %v", err)
+	}
+	if r.dialQueue, err = m.UpDownCounter(
+		"alloydbconn/dial_queue_depth",
+		instrument.WithDescription("The current number of dials waiting on an in-flight refresh operation"),
+		instrument.WithUnit(unit.Dimensionless),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create dial queue instrument: %v", err)
+	}
+	if r.certIssuance, err = m.Histogram(
+		"alloydbconn/cert_issuance_latency",
+		instrument.WithDescription("The distribution of client certificate issuance latencies (ms)"),
+		instrument.WithUnit(unit.Milliseconds),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create cert issuance latency instrument: %v", err)
+	}
+	return r, nil
+}
+
+func (r *otelRecorder) StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, EndSpanFunc) {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		kvs = append(kvs, attribute.String(a.key, a.value.(string)))
+	}
+	ctx, span := r.tracer.Start(ctx, name, oteltrace.WithAttributes(kvs...))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func (r *otelRecorder) RecordDialLatency(ctx context.Context, instance, dialerID string, latency int64) {
+	r.dialLatency.Record(ctx, latency, attrInstance.String(instance), attrDialerID.String(dialerID))
+}
+
+func (r *otelRecorder) RecordOpenConnections(ctx context.Context, num int64, dialerID, instance string) {
+	k := gaugeKey{dialerID: dialerID, instance: instance}
+	r.mu.Lock()
+	delta := num - r.openConnCounts[k]
+	r.openConnCounts[k] = num
+	r.mu.Unlock()
+	r.openConns.Add(ctx, delta, attrInstance.String(instance), attrDialerID.String(dialerID))
+}
+
+func (r *otelRecorder) RecordDialError(ctx context.Context, instance, dialerID string, err error) {
+	if err == nil {
+		return
+	}
+	r.dialFailures.Add(ctx, 1, attrInstance.String(instance), attrDialerID.String(dialerID))
+}
+
+func (r *otelRecorder) RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error) {
+	attrs := []attribute.KeyValue{
+		attrInstance.String(instance),
+		attrDialerID.String(dialerID),
+		attrTrigger.String(trigger),
+	}
+	if err != nil {
+		if c := logging.ErrorCode(err); c != "" {
+			attrs = append(attrs, attrErrorCode.String(c))
+		}
+		r.refreshFailure.Add(ctx, 1, attrs...)
+		return
+	}
+	r.refreshSuccess.Add(ctx, 1, attrs...)
+}
+
+func (r *otelRecorder) RecordDialQueueDepth(ctx context.Context, instance, dialerID string, depth int64) {
+	k := gaugeKey{dialerID: dialerID, instance: instance}
+	r.mu.Lock()
+	delta := depth - r.queueDepths[k]
+	r.queueDepths[k] = depth
+	r.mu.Unlock()
+	r.dialQueue.Add(ctx, delta, attrInstance.String(instance), attrDialerID.String(dialerID))
+}
+
+func (r *otelRecorder) RecordCertIssuanceLatency(ctx context.Context, instance, dialerID string, latency int64) {
+	r.certIssuance.Record(ctx, latency, attrInstance.String(instance), attrDialerID.String(dialerID))
+}
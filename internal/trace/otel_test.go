@@ -0,0 +1,160 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOTelRecorderMetrics(t *testing.T) {
+	ctx := context.Background()
+	mp, exp := metrictest.NewTestMeterProvider()
+	r, err := NewOTelRecorder(mp, nil)
+	if err != nil {
+		t.Fatalf("want no error, got = %v", err)
+	}
+
+	r.RecordDialLatency(ctx, "my-instance", "dialer-id", 10)
+	r.RecordDialError(ctx, "my-instance", "dialer-id", errors.New("dial failed"))
+	r.RecordDialError(ctx, "my-instance", "dialer-id", nil)
+	r.RecordRefreshResult(ctx, "my-instance", "dialer-id", RefreshTriggerScheduled, nil)
+	r.RecordRefreshResult(ctx, "my-instance", "dialer-id", RefreshTriggerForced, errors.New("refresh failed"))
+	r.RecordOpenConnections(ctx, 2, "dialer-id", "my-instance")
+	r.RecordOpenConnections(ctx, 1, "dialer-id", "my-instance")
+	r.RecordDialQueueDepth(ctx, "my-instance", "dialer-id", 3)
+	r.RecordDialQueueDepth(ctx, "my-instance", "dialer-id", 2)
+
+	if err := exp.Collect(ctx); err != nil {
+		t.Fatalf("collect failed: %v", err)
+	}
+
+	tcs := []struct {
+		name string
+		want int64
+	}{
+		{name: "alloydbconn/dial_failure_count", want: 1},
+		{name: "alloydbconn/refresh_success_count", want: 1},
+		{name: "alloydbconn/refresh_failure_count", want: 1},
+		{name: "alloydbconn/open_connections", want: 1},
+		{name: "alloydbconn/dial_queue_depth", want: 2},
+	}
+	for _, tc := range tcs {
+		rec, err := exp.GetByName(tc.name)
+		if err != nil {
+			t.Errorf("want metric %v, got error = %v", tc.name, err)
+			continue
+		}
+		if got := rec.Sum.AsInt64(); got != tc.want {
+			t.Errorf("metric %v: want = %v, got = %v", tc.name, tc.want, got)
+		}
+	}
+	rec, err := exp.GetByName("alloydbconn/refresh_success_count")
+	if err != nil {
+		t.Fatalf("want refresh success metric, got error = %v", err)
+	}
+	var trigger string
+	for _, a := range rec.Attributes {
+		if a.Key == attrTrigger {
+			trigger = a.Value.AsString()
+		}
+	}
+	if trigger != RefreshTriggerScheduled {
+		t.Errorf("refresh trigger: want = %v, got = %q", RefreshTriggerScheduled, trigger)
+	}
+	rec, err = exp.GetByName("alloydbconn/dial_latency")
+	if err != nil {
+		t.Fatalf("want dial latency metric, got error = %v", err)
+	}
+	if rec.Count != 1 {
+		t.Errorf("dial latency count: want = 1, got = %v", rec.Count)
+	}
+}
+
+func TestOTelRecorderGaugesPerDialer(t *testing.T) {
+	ctx := context.Background()
+	mp, exp := metrictest.NewTestMeterProvider()
+	r, err := NewOTelRecorder(mp, nil)
+	if err != nil {
+		t.Fatalf("want no error, got = %v", err)
+	}
+
+	// Two dialers, e.g., a Dialer and its scope, report the same instance.
+	r.RecordOpenConnections(ctx, 2, "dialer-a", "my-instance")
+	r.RecordOpenConnections(ctx, 1, "dialer-b", "my-instance")
+	r.RecordOpenConnections(ctx, 3, "dialer-a", "my-instance")
+	r.RecordDialQueueDepth(ctx, "my-instance", "dialer-a", 4)
+	r.RecordDialQueueDepth(ctx, "my-instance", "dialer-b", 1)
+
+	if err := exp.Collect(ctx); err != nil {
+		t.Fatalf("collect failed: %v", err)
+	}
+
+	tcs := []struct {
+		name     string
+		dialerID string
+		want     int64
+	}{
+		{name: "alloydbconn/open_connections", dialerID: "dialer-a", want: 3},
+		{name: "alloydbconn/open_connections", dialerID: "dialer-b", want: 1},
+		{name: "alloydbconn/dial_queue_depth", dialerID: "dialer-a", want: 4},
+		{name: "alloydbconn/dial_queue_depth", dialerID: "dialer-b", want: 1},
+	}
+	for _, tc := range tcs {
+		rec, err := exp.GetByNameAndAttributes(tc.name, []attribute.KeyValue{attrDialerID.String(tc.dialerID)})
+		if err != nil {
+			t.Errorf("want metric %v for %v, got error = %v", tc.name, tc.dialerID, err)
+			continue
+		}
+		if got := rec.Sum.AsInt64(); got != tc.want {
+			t.Errorf("metric %v for %v: want = %v, got = %v", tc.name, tc.dialerID, tc.want, got)
+		}
+	}
+}
+
+func TestOTelRecorderSpans(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	r, err := NewOTelRecorder(nil, tp)
+	if err != nil {
+		t.Fatalf("want no error, got = %v", err)
+	}
+
+	_, end := r.StartSpan(context.Background(), "span-name", AddInstanceName("my-instance"))
+	end(errors.New("span failed"))
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got = %v", len(spans))
+	}
+	s := spans[0]
+	if s.Name() != "span-name" {
+		t.Errorf("span name: want = span-name, got = %v", s.Name())
+	}
+	if s.Status().Code != codes.Error {
+		t.Errorf("span status: want = %v, got = %v", codes.Error, s.Status().Code)
+	}
+	attrs := s.Attributes()
+	if len(attrs) != 1 || attrs[0].Value.AsString() != "my-instance" {
+		t.Errorf("span attributes: want instance name, got = %v", attrs)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import "context"
+
+// Values of the refresh trigger reported with refresh metrics.
+const (
+	// RefreshTriggerScheduled is a refresh started by the regular refresh
+	// cycle, ahead of certificate expiration.
+	RefreshTriggerScheduled = "scheduled"
+	// RefreshTriggerForced is a refresh started out of cycle, e.g., after a
+	// failed dial invalidated the cached connection info.
+	RefreshTriggerForced = "forced"
+	// RefreshTriggerDialDemand is a refresh started because connection info
+	// was needed and none was cached, e.g., on the first dial of an instance.
+	RefreshTriggerDialDemand = "dial_demand"
+	// RefreshTriggerFailover is a refresh started because the role of the
+	// instance's cluster changed.
+	RefreshTriggerFailover = "failover"
+)
+
+// Recorder reports connector metrics and traces to a telemetry backend.
+type Recorder interface {
+	// StartSpan begins a span with the provided name and returns a context
+	// and a function to end the created span.
+	StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, EndSpanFunc)
+	// RecordDialLatency records a latency value for a call to dial.
+	RecordDialLatency(ctx context.Context, instance, dialerID string, latency int64)
+	// RecordOpenConnections records the number of open connections.
+	RecordOpenConnections(ctx context.Context, num int64, dialerID, instance string)
+	// RecordDialError reports a failed dial attempt. If err is nil,
+	// RecordDialError is a no-op.
+	RecordDialError(ctx context.Context, instance, dialerID string, err error)
+	// RecordRefreshResult reports the result of a refresh operation, either
+	// successful or failed, along with what triggered it (one of the
+	// RefreshTrigger constants).
+	RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error)
+	// RecordDialQueueDepth records the number of dials waiting on an
+	// in-flight refresh operation.
+	RecordDialQueueDepth(ctx context.Context, instance, dialerID string, depth int64)
+	// RecordCertIssuanceLatency records the latency in milliseconds of a
+	// successful call to the AlloyDB Admin API to issue a client
+	// certificate.
+	RecordCertIssuanceLatency(ctx context.Context, instance, dialerID string, latency int64)
+}
+
+// openCensus is the default Recorder and uses the package level OpenCensus
+// functions.
+type openCensus struct{}
+
+// OpenCensus returns a Recorder that reports to OpenCensus.
+func OpenCensus() Recorder { return openCensus{} }
+
+func (openCensus) StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, EndSpanFunc) {
+	return StartSpan(ctx, name, attrs...)
+}
+
+func (openCensus) RecordDialLatency(ctx context.Context, instance, dialerID string, latency int64) {
+	RecordDialLatency(ctx, instance, dialerID, latency)
+}
+
+func (openCensus) RecordOpenConnections(ctx context.Context, num int64, dialerID, instance string) {
+	RecordOpenConnections(ctx, num, dialerID, instance)
+}
+
+func (openCensus) RecordDialError(ctx context.Context, instance, dialerID string, err error) {
+	RecordDialError(ctx, instance, dialerID, err)
+}
+
+func (openCensus) RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error) {
+	RecordRefreshResult(ctx, instance, dialerID, trigger, err)
+}
+
+func (openCensus) RecordDialQueueDepth(ctx context.Context, instance, dialerID string, depth int64) {
+	RecordDialQueueDepth(ctx, instance, dialerID, depth)
+}
+
+func (openCensus) RecordCertIssuanceLatency(ctx context.Context, instance, dialerID string, latency int64) {
+	RecordCertIssuanceLatency(ctx, instance, dialerID, latency)
+}
+
+// multiRecorder reports to each of its Recorders in order.
+type multiRecorder []Recorder
+
+// MultiRecorder returns a Recorder that reports to all the provided
+// Recorders.
+func MultiRecorder(rs ...Recorder) Recorder {
+	if len(rs) == 1 {
+		return rs[0]
+	}
+	return multiRecorder(rs)
+}
+
+func (m multiRecorder) StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, EndSpanFunc) {
+	ends := make([]EndSpanFunc, 0, len(m))
+	for _, r := range m {
+		var end EndSpanFunc
+		ctx, end = r.StartSpan(ctx, name, attrs...)
+		ends = append(ends, end)
+	}
+	return ctx, func(err error) {
+		// end spans in the reverse order they were started
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i](err)
+		}
+	}
+}
+
+func (m multiRecorder) RecordDialLatency(ctx context.Context, instance, dialerID string, latency int64) {
+	for _, r := range m {
+		r.RecordDialLatency(ctx, instance, dialerID, latency)
+	}
+}
+
+func (m multiRecorder) RecordOpenConnections(ctx context.Context, num int64, dialerID, instance string) {
+	for _, r := range m {
+		r.RecordOpenConnections(ctx, num, dialerID, instance)
+	}
+}
+
+func (m multiRecorder) RecordDialError(ctx context.Context, instance, dialerID string, err error) {
+	for _, r := range m {
+		r.RecordDialError(ctx, instance, dialerID, err)
+	}
+}
+
+func (m multiRecorder) RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error) {
+	for _, r := range m {
+		r.RecordRefreshResult(ctx, instance, dialerID, trigger, err)
+	}
+}
+
+func (m multiRecorder) RecordDialQueueDepth(ctx context.Context, instance, dialerID string, depth int64) {
+	for _, r := range m {
+		r.RecordDialQueueDepth(ctx, instance, dialerID, depth)
+	}
+}
+
+func (m multiRecorder) RecordCertIssuanceLatency(ctx context.Context, instance, dialerID string, latency int64) {
+	for _, r := range m {
+		r.RecordCertIssuanceLatency(ctx, instance, dialerID, latency)
+	}
+}
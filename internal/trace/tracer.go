@@ -0,0 +1,118 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"time"
+)
+
+// Tracer is the subset of Recorder that applications implement to report to
+// their own tracing systems. See alloydbconn.Tracer.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string, attrs map[string]string) (context.Context, func(error))
+	RecordDialError(ctx context.Context, instance, dialerID string, err error)
+	RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error)
+}
+
+// tracerRecorder reports spans, dial errors, and refresh results to a Tracer
+// and drops all other measurements.
+type tracerRecorder struct {
+	t Tracer
+}
+
+// TracerRecorder returns a Recorder that reports to t.
+func TracerRecorder(t Tracer) Recorder { return tracerRecorder{t: t} }
+
+func (r tracerRecorder) StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, EndSpanFunc) {
+	m := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		m[a.key] = a.value.(string)
+	}
+	ctx, end := r.t.StartSpan(ctx, name, m)
+	if end == nil {
+		return ctx, func(error) {}
+	}
+	return ctx, end
+}
+
+func (tracerRecorder) RecordDialLatency(context.Context, string, string, int64) {}
+
+func (tracerRecorder) RecordOpenConnections(context.Context, int64, string, string) {}
+
+func (r tracerRecorder) RecordDialError(ctx context.Context, instance, dialerID string, err error) {
+	if err == nil {
+		return
+	}
+	r.t.RecordDialError(ctx, instance, dialerID, err)
+}
+
+func (r tracerRecorder) RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error) {
+	r.t.RecordRefreshResult(ctx, instance, dialerID, trigger, err)
+}
+
+func (tracerRecorder) RecordDialQueueDepth(context.Context, string, string, int64) {}
+
+func (tracerRecorder) RecordCertIssuanceLatency(context.Context, string, string, int64) {}
+
+// Metrics is the subset of Recorder that applications implement to report
+// metrics to their own systems. See alloydbconn.MetricsRecorder.
+type Metrics interface {
+	RecordDialLatency(ctx context.Context, instance, dialerID string, latency time.Duration)
+	RecordOpenConnections(ctx context.Context, instance, dialerID string, num int64)
+	RecordDialError(ctx context.Context, instance, dialerID string, err error)
+	RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error)
+	RecordDialQueueDepth(ctx context.Context, instance, dialerID string, depth int64)
+	RecordCertIssuanceLatency(ctx context.Context, instance, dialerID string, latency time.Duration)
+}
+
+// metricsRecorder reports metrics to a Metrics and drops spans.
+type metricsRecorder struct {
+	m Metrics
+}
+
+// MetricsRecorder returns a Recorder that reports metrics to m.
+func MetricsRecorder(m Metrics) Recorder { return metricsRecorder{m: m} }
+
+func (metricsRecorder) StartSpan(ctx context.Context, _ string, _ ...Attribute) (context.Context, EndSpanFunc) {
+	return ctx, func(error) {}
+}
+
+func (r metricsRecorder) RecordDialLatency(ctx context.Context, instance, dialerID string, latency int64) {
+	r.m.RecordDialLatency(ctx, instance, dialerID, time.Duration(latency)*time.Millisecond)
+}
+
+func (r metricsRecorder) RecordOpenConnections(ctx context.Context, num int64, dialerID, instance string) {
+	r.m.RecordOpenConnections(ctx, instance, dialerID, num)
+}
+
+func (r metricsRecorder) RecordDialError(ctx context.Context, instance, dialerID string, err error) {
+	if err == nil {
+		return
+	}
+	r.m.RecordDialError(ctx, instance, dialerID, err)
+}
+
+func (r metricsRecorder) RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error) {
+	r.m.RecordRefreshResult(ctx, instance, dialerID, trigger, err)
+}
+
+func (r metricsRecorder) RecordDialQueueDepth(ctx context.Context, instance, dialerID string, depth int64) {
+	r.m.RecordDialQueueDepth(ctx, instance, dialerID, depth)
+}
+
+func (r metricsRecorder) RecordCertIssuanceLatency(ctx context.Context, instance, dialerID string, latency int64) {
+	r.m.RecordCertIssuanceLatency(ctx, instance, dialerID, time.Duration(latency)*time.Millisecond)
+}
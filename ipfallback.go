@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+	"cloud.google.com/go/alloydbconn/internal/logging"
+)
+
+// IPType is the type of an instance's address.
+type IPType string
+
+const (
+	// PrivateIP is the instance's private IP address in its VPC network.
+	PrivateIP IPType = alloydb.PrivateIP
+	// PublicIP is the instance's public IP address.
+	PublicIP IPType = alloydb.PublicIP
+	// PSC is the instance's Private Service Connect endpoint.
+	PSC IPType = alloydb.PSC
+)
+
+// defaultFallbackTimeout bounds each attempt of a dial with an IP type
+// fallback order when no dial timeout is set, so that an unreachable address
+// leaves time for the next.
+const defaultFallbackTimeout = 10 * time.Second
+
+// WithIPTypeFallback returns a DialOption that connects to the instance's
+// address of the first IP type in order and, if that address cannot be
+// reached, e.g., because the client is outside the instance's VPC network,
+// falls back to the address of the next type, so that one configuration
+// works for clients in different networks:
+//
+//	alloydbconn.WithDefaultDialOptions(
+//		alloydbconn.WithIPTypeFallback(alloydbconn.PrivateIP, alloydbconn.PublicIP, alloydbconn.PSC),
+//	)
+//
+// Types the instance has no address of are skipped. Only a failed connection
+// to an address moves on to the next type: a failed TLS handshake or refresh
+// ends the dial without trying the remaining types. An address that cannot be
+// reached, other than that of the last type, does not refresh the instance's
+// connection info, so that clients that cannot reach the first type do not
+// call the AlloyDB Admin API on every dial. Each attempt is bounded by
+// WithDialTimeout or, if unset, by 10 seconds. WithPrivateIP, WithPublicIP,
+// and WithPSC replace the fallback order.
+func WithIPTypeFallback(order ...IPType) DialOption {
+	return func(cfg *dialCfg) {
+		cfg.ipType = ""
+		cfg.ipFallback = nil
+		if len(order) == 0 {
+			return
+		}
+		cfg.ipType = string(order[0])
+		for _, t := range order[1:] {
+			cfg.ipFallback = append(cfg.ipFallback, string(t))
+		}
+	}
+}
+
+// connect establishes a TLS connection to the instance, trying the IP types
+// of the fallback order, if any, in turn.
+func (d *Dialer) connect(ctx context.Context, i *alloydb.Instance, cfg dialCfg) (net.Conn, error) {
+	if len(cfg.ipFallback) == 0 {
+		return d.connectIPType(ctx, i, cfg)
+	}
+	types := append([]string{cfg.ipType}, cfg.ipFallback...)
+	var err error
+	for n, t := range types {
+		c := cfg
+		c.ipType, c.ipFallback = t, nil
+		c.fallbackAttempt = n < len(types)-1
+		if c.dialTimeout == 0 {
+			c.dialTimeout = defaultFallbackTimeout
+		}
+		var conn net.Conn
+		conn, err = d.connectIPType(ctx, i, c)
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil || !isIPFallbackError(err) || n == len(types)-1 {
+			break
+		}
+		d.logger.Log(logging.Event{
+			Instance: i.String(),
+			Label:    cfg.label,
+			Name:     logging.EventIPFallback,
+			Message:  fmt.Sprintf("failed to connect with IP type %v, trying %v", t, types[n+1]),
+			Err:      err,
+		})
+	}
+	return nil, err
+}
+
+// connectError is the error of a failed connection to an instance's
+// address, before the TLS handshake.
+type connectError struct {
+	err error
+}
+
+func (e *connectError) Error() string { return e.err.Error() }
+
+func (e *connectError) Unwrap() error { return e.err }
+
+// isIPFallbackError reports whether a failed dial should be retried with the
+// next IP type: the instance has no address of the type or the address could
+// not be reached.
+func isIPFallbackError(err error) bool {
+	var (
+		cErr *errtype.ConfigError
+		ce   *connectError
+	)
+	return errors.As(err, &cErr) || errors.As(err, &ce)
+}
@@ -0,0 +1,213 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestDialerWithIPTypeFallback(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithIPAddr("10.0.0.1"),
+		mock.WithPublicIPAddr("127.0.0.1"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, mock.Unlimited),
+		mock.CreateEphemeralSuccess(inst, mock.Unlimited),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		cleanup()
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		addrs []string
+	)
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRateLimiter(0, 0),
+		WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			addrs = append(addrs, addr)
+			mu.Unlock()
+			// The private IP is not reachable from this client.
+			if addr == "10.0.0.1:5433" {
+				return nil, errors.New("i/o timeout")
+			}
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	tcs := []struct {
+		desc    string
+		opts    []DialOption
+		want    []string
+		wantErr bool
+	}{
+		{
+			desc: "private then public",
+			opts: []DialOption{WithIPTypeFallback(PrivateIP, PublicIP)},
+			want: []string{"10.0.0.1:5433", "127.0.0.1:5433"},
+		},
+		{
+			desc: "missing PSC is skipped",
+			opts: []DialOption{WithIPTypeFallback(PSC, PublicIP)},
+			want: []string{"127.0.0.1:5433"},
+		},
+		{
+			desc:    "WithPrivateIP replaces the fallback order",
+			opts:    []DialOption{WithIPTypeFallback(PrivateIP, PublicIP), WithPrivateIP()},
+			want:    []string{"10.0.0.1:5433"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			mu.Lock()
+			addrs = nil
+			mu.Unlock()
+			conn, err := d.Dial(ctx, testInstanceURI, tc.opts...)
+			if tc.wantErr {
+				var dErr *errtype.DialError
+				if !errors.As(err, &dErr) {
+					t.Fatalf("want = %T, got = %v", dErr, err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("expected Dial to succeed, but got error: %v", err)
+				}
+				conn.Close()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(addrs, tc.want) {
+				t.Fatalf("want dials = %v, got = %v", tc.want, addrs)
+			}
+		})
+	}
+}
+
+func TestDialerIPTypeFallbackDoesNotRefresh(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithIPAddr("10.0.0.1"),
+		mock.WithPublicIPAddr("127.0.0.1"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	ct := &countingTransport{counts: make(map[string]int), rt: mc.Transport}
+	mc.Transport = ct
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithDefaultDialOptions(WithIPTypeFallback(PrivateIP, PublicIP)),
+		WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == "10.0.0.1:5433" {
+				return nil, errors.New("i/o timeout")
+			}
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	for i := 0; i < 3; i++ {
+		conn, err := d.Dial(ctx, testInstanceURI)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		conn.Close()
+	}
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	for path, n := range ct.counts {
+		if n != 1 {
+			t.Errorf("want 1 call to %v, got = %v", path, n)
+		}
+	}
+}
+
+func TestIsIPFallbackError(t *testing.T) {
+	tcs := []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{
+			desc: "missing address",
+			err:  errtype.NewConfigError("instance does not have an IP address", "inst"),
+			want: true,
+		},
+		{
+			desc: "unreachable address",
+			err:  errtype.NewDialError("failed to dial", "inst", &connectError{err: errors.New("i/o timeout")}),
+			want: true,
+		},
+		{
+			desc: "failed handshake",
+			err:  errtype.NewDialError("handshake failed", "inst", &handshakeError{err: errors.New("bad certificate")}),
+		},
+		{
+			desc: "failed refresh",
+			err:  errtype.NewDialError("failed to dial", "inst", errtype.NewRefreshError("throttled", "inst", context.DeadlineExceeded)),
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := isIPFallbackError(tc.err); got != tc.want {
+				t.Fatalf("want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
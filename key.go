@@ -0,0 +1,112 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+)
+
+// instanceKey identifies an instance in the Dialer's cache. URIs that differ
+// only by a leading slash have the same key. Deriving a key does not
+// allocate: its fields are substrings of the URI.
+type instanceKey struct {
+	project, region, cluster, name string
+}
+
+// uriSegments are the collection names of an instance URI, in order.
+var uriSegments = [...]string{"projects/", "locations/", "clusters/", "instances/"}
+
+// parseInstanceKey returns the key of an instance URI in the format
+// projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>,
+// optionally with a leading slash. It reports false if uri is not in that
+// format.
+func parseInstanceKey(uri string) (instanceKey, bool) {
+	var ids [len(uriSegments)]string
+	rest := strings.TrimPrefix(uri, "/")
+	for n, seg := range uriSegments {
+		if !strings.HasPrefix(rest, seg) {
+			return instanceKey{}, false
+		}
+		rest = rest[len(seg):]
+		end := strings.IndexByte(rest, '/')
+		last := n == len(uriSegments)-1
+		if last {
+			if end >= 0 {
+				return instanceKey{}, false
+			}
+			end = len(rest)
+		}
+		if end <= 0 {
+			return instanceKey{}, false
+		}
+		ids[n] = rest[:end]
+		if !last {
+			rest = rest[end+1:]
+		}
+	}
+	return instanceKey{project: ids[0], region: ids[1], cluster: ids[2], name: ids[3]}, true
+}
+
+// invalidInstanceURIError is returned when an instance URI cannot be parsed.
+func invalidInstanceURIError(uri string) error {
+	return errtype.NewConfigError(
+		"invalid instance URI, expected projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>",
+		uri,
+	)
+}
+
+// InstanceURI identifies an AlloyDB instance by its project, region, cluster,
+// and instance IDs. The zero value is not a valid instance; use
+// ParseInstanceURI.
+type InstanceURI struct {
+	k instanceKey
+}
+
+// ParseInstanceURI parses an instance URI in the format accepted by Dial,
+// projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>,
+// optionally with a leading slash. Project IDs may be domain scoped, e.g.,
+// example.com:my-project. It returns an *errtype.ConfigError if s is not in
+// that format.
+func ParseInstanceURI(s string) (InstanceURI, error) {
+	k, ok := parseInstanceKey(s)
+	if !ok {
+		return InstanceURI{}, invalidInstanceURIError(s)
+	}
+	return InstanceURI{k: k}, nil
+}
+
+// Project returns the ID of the instance's project.
+func (u InstanceURI) Project() string { return u.k.project }
+
+// Region returns the region of the instance's cluster.
+func (u InstanceURI) Region() string { return u.k.region }
+
+// Cluster returns the ID of the instance's cluster.
+func (u InstanceURI) Cluster() string { return u.k.cluster }
+
+// Instance returns the ID of the instance.
+func (u InstanceURI) Instance() string { return u.k.name }
+
+// String returns the instance URI in its normalized form, without a leading
+// slash, e.g., for use as a map key or with Dial.
+func (u InstanceURI) String() string {
+	return fmt.Sprintf(
+		"projects/%s/locations/%s/clusters/%s/instances/%s",
+		u.k.project, u.k.region, u.k.cluster, u.k.name,
+	)
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestParseInstanceKey(t *testing.T) {
+	want := instanceKey{project: "my-project", region: "my-region", cluster: "my-cluster", name: "my-instance"}
+	for _, uri := range []string{testInstanceURI, "/" + testInstanceURI} {
+		got, ok := parseInstanceKey(uri)
+		if !ok || got != want {
+			t.Errorf("parseInstanceKey(%q): want = %+v, got = %+v, %v", uri, want, got, ok)
+		}
+	}
+	domain, ok := parseInstanceKey("projects/example.com:my-project/locations/r/clusters/c/instances/i")
+	if !ok || domain.project != "example.com:my-project" {
+		t.Errorf("want domain-scoped project, got = %+v, %v", domain, ok)
+	}
+
+	for _, uri := range []string{
+		"",
+		"my-project/my-region/my-cluster/my-instance",
+		"projects/my-project/locations/my-region/clusters/my-cluster",
+		"projects/my-project/locations/my-region/clusters/my-cluster/instances/",
+		"projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance/extra",
+		"projects//locations/my-region/clusters/my-cluster/instances/my-instance",
+		"projects/my-project/regions/my-region/clusters/my-cluster/instances/my-instance",
+		"//projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+	} {
+		if got, ok := parseInstanceKey(uri); ok {
+			t.Errorf("parseInstanceKey(%q): want failure, got = %+v", uri, got)
+		}
+	}
+}
+
+func TestParseInstanceURI(t *testing.T) {
+	for _, s := range []string{testInstanceURI, "/" + testInstanceURI} {
+		u, err := ParseInstanceURI(s)
+		if err != nil {
+			t.Fatalf("ParseInstanceURI(%q): expected success, got error: %v", s, err)
+		}
+		got := []string{u.Project(), u.Region(), u.Cluster(), u.Instance(), u.String()}
+		want := []string{"my-project", "my-region", "my-cluster", "my-instance", testInstanceURI}
+		for n := range want {
+			if got[n] != want[n] {
+				t.Errorf("ParseInstanceURI(%q): want = %v, got = %v", s, want, got)
+				break
+			}
+		}
+	}
+	var cErr *errtype.ConfigError
+	if _, err := ParseInstanceURI("my-project/my-region/my-cluster/my-instance"); !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+}
+
+// newBenchDialer returns a Dialer whose cache holds the test instance.
+func newBenchDialer(tb testing.TB) (*Dialer, func()) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, mock.Unlimited),
+		mock.CreateEphemeralSuccess(inst, mock.Unlimited),
+	)
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		tb.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		tb.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	if _, err := d.instance(testInstanceURI); err != nil {
+		tb.Fatalf("expected instance to succeed, but got error: %v", err)
+	}
+	return d, func() {
+		d.Close()
+		cleanup()
+	}
+}
+
+func TestDialerInstanceSharesKey(t *testing.T) {
+	d, cleanup := newBenchDialer(t)
+	defer cleanup()
+
+	i1, err := d.instance(testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected instance to succeed, but got error: %v", err)
+	}
+	i2, err := d.instance("/" + testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected instance to succeed, but got error: %v", err)
+	}
+	if i1 != i2 {
+		t.Error("want URIs with and without a leading slash to share an instance")
+	}
+	if n := testing.AllocsPerRun(100, func() {
+		_, _ = d.instance(testInstanceURI)
+	}); n != 0 {
+		t.Errorf("want cached instance lookup without allocations, got = %v", n)
+	}
+}
+
+func BenchmarkParseInstanceKey(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		if _, ok := parseInstanceKey(testInstanceURI); !ok {
+			b.Fatal("want parseInstanceKey to succeed")
+		}
+	}
+}
+
+func BenchmarkDialerInstance(b *testing.B) {
+	d, cleanup := newBenchDialer(b)
+	defer cleanup()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := d.instance(testInstanceURI); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
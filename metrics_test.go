@@ -19,9 +19,12 @@ import (
 	"testing"
 	"time"
 
-	"cloud.google.com/go/alloydbconn/internal/alloydbapi"
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
 	"cloud.google.com/go/alloydbconn/internal/mock"
 	"go.opencensus.io/stats/view"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"google.golang.org/api/option"
 )
 
@@ -116,7 +119,7 @@ func TestDialerWithMetrics(t *testing.T) {
 			t.Fatalf("%v", err)
 		}
 	}()
-	c, err := alloydbapi.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
 	if err != nil {
 		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
 	}
@@ -150,3 +153,202 @@ func TestDialerWithMetrics(t *testing.T) {
 	wantCountMetric(t, "/alloydbconn/dial_failure_count", spy.Data())
 	wantCountMetric(t, "/alloydbconn/refresh_failure_count", spy.Data())
 }
+
+func TestDialerWithOpenTelemetry(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	mp, exp := metrictest.NewTestMeterProvider()
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithOpenTelemetry(mp, tp),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	conn, err := d.Dial(ctx, "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+
+	// Metrics are recorded asynchronously, so collect until all are seen.
+	// Each collection only reports what was recorded since the last one.
+	want := map[string]bool{
+		"alloydbconn/dial_latency":          false,
+		"alloydbconn/open_connections":      false,
+		"alloydbconn/refresh_success_count": false,
+	}
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if err := exp.Collect(ctx); err != nil {
+			t.Fatalf("collect failed: %v", err)
+		}
+		missing := false
+		for name, seen := range want {
+			if !seen {
+				_, err := exp.GetByName(name)
+				want[name] = err == nil
+				missing = missing || err != nil
+			}
+		}
+		if !missing {
+			break
+		}
+	}
+	for name, seen := range want {
+		if !seen {
+			t.Errorf("want metric %v, got none", name)
+		}
+	}
+
+	var gotDialSpan bool
+	for _, s := range sr.Ended() {
+		if s.Name() == "cloud.google.com/go/alloydbconn.Dial" {
+			gotDialSpan = true
+		}
+	}
+	if !gotDialSpan {
+		t.Errorf("want Dial span, got = %v", sr.Ended())
+	}
+}
+
+// spyMetricsRecorder is a MetricsRecorder that records the names of the
+// metrics it receives.
+type spyMetricsRecorder struct {
+	mu  sync.Mutex
+	got map[string]bool
+}
+
+func (r *spyMetricsRecorder) record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.got == nil {
+		r.got = make(map[string]bool)
+	}
+	r.got[name] = true
+}
+
+func (r *spyMetricsRecorder) seen(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.got[name]
+}
+
+func (r *spyMetricsRecorder) RecordDialLatency(context.Context, string, string, time.Duration) {
+	r.record("dial_latency")
+}
+
+func (r *spyMetricsRecorder) RecordOpenConnections(context.Context, string, string, int64) {
+	r.record("open_connections")
+}
+
+func (r *spyMetricsRecorder) RecordDialError(context.Context, string, string, error) {
+	r.record("dial_error")
+}
+
+func (r *spyMetricsRecorder) RecordRefreshResult(_ context.Context, _, _, _ string, err error) {
+	if err == nil {
+		r.record("refresh_success")
+	}
+}
+
+func (r *spyMetricsRecorder) RecordDialQueueDepth(context.Context, string, string, int64) {
+	r.record("dial_queue_depth")
+}
+
+func (r *spyMetricsRecorder) RecordCertIssuanceLatency(context.Context, string, string, time.Duration) {
+	r.record("cert_issuance_latency")
+}
+
+func TestDialerWithMetricsRecorder(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	spy := &spyMetricsRecorder{}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithMetricsRecorder(spy),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+
+	// Metrics are recorded asynchronously, so wait until all are seen.
+	want := []string{
+		"dial_latency",
+		"open_connections",
+		"refresh_success",
+		"cert_issuance_latency",
+	}
+	var missing []string
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		missing = missing[:0]
+		for _, name := range want {
+			if !spy.seen(name) {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+	}
+	if len(missing) > 0 {
+		t.Fatalf("want metrics %v, missing %v", want, missing)
+	}
+}
+
+func TestWithMetricsRecorderNil(t *testing.T) {
+	_, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithMetricsRecorder(nil),
+	)
+	if err == nil {
+		t.Fatal("want NewDialer to fail with a nil MetricsRecorder, got nil error")
+	}
+}
@@ -16,13 +16,31 @@ package alloydbconn
 
 import (
 	"context"
+	"crypto"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/debug"
 	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+	"cloud.google.com/go/alloydbconn/internal/logging"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	apiopt "google.golang.org/api/option"
@@ -31,17 +49,76 @@ import (
 // CloudPlatformScope is the default OAuth2 scope set on the API client.
 const CloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
 
+// AlloyDBLoginScope is the OAuth2 scope required of tokens used to log in to
+// a database with IAM database authentication.
+const AlloyDBLoginScope = "https://www.googleapis.com/auth/alloydb.login"
+
 // An Option is an option for configuring a Dialer.
 type Option func(d *dialerConfig)
 
 type dialerConfig struct {
-	rsaKey         *rsa.PrivateKey
+	key            crypto.Signer
 	adminOpts      []apiopt.ClientOption
+	httpClient     *http.Client
 	dialOpts       []DialOption
 	dialFunc       func(ctx context.Context, network, addr string) (net.Conn, error)
+	proxyURL       *url.URL
+	noEnvProxy     bool
 	refreshTimeout time.Duration
 	tokenSource    oauth2.TokenSource
-	useragents     []string
+	// credsOpt holds the most recently configured credentials for the Admin
+	// API client, if any.
+	credsOpt         apiopt.ClientOption
+	useragents       []string
+	logger           logging.Logger
+	eventHandler     func(DialEvent)
+	otelEnabled      bool
+	meterProvider    otelmetric.MeterProvider
+	metricsRecorders []MetricsRecorder
+	tracer           Tracer
+	tracerProvider   oteltrace.TracerProvider
+	// telemetryQueueSize and telemetryTTL bound the metric measurements
+	// waiting to be reported.
+	telemetryQueueSize int
+	telemetryTTL       time.Duration
+	// detectClusterRole enables retrieving each instance's cluster role.
+	detectClusterRole bool
+	preemptForced     bool
+	serveStale        bool
+	maxStaleness      time.Duration
+	coldStartBudget   time.Duration
+	refreshStrategy   RefreshStrategy
+	refreshBuffer     time.Duration
+	refreshJitter     time.Duration
+	certHook          func(instance string, notAfter time.Time)
+	tlsPatch          func(*tls.Config)
+	connExpiryHandler func(instance string, conn net.Conn)
+	breakerThreshold  int
+	probeInterval     time.Duration
+	iamAuthN          bool
+	iamTokenSource    oauth2.TokenSource
+	softFail          bool
+	maxConns          uint64
+	primedConns       int
+	universeDomain    string
+	quotaProject      string
+	adminHeaders      http.Header
+	staticInfo        *alloydbadmin.StaticClient
+	cache             ConnectionInfoCache
+	imported          map[string]alloydb.Snapshot
+	certCacheDir      string
+	caPins            map[string][][sha256.Size]byte
+	requireSAN        bool
+	skipNameCheck     bool
+	rateLimit         *rateLimit
+	rateLimits        map[string]rateLimit
+	refreshTimeouts   map[string]time.Duration
+	rand              io.Reader
+	resolver          Resolver
+	exec              Executor
+	// impersonateTarget is the service account to impersonate, if any.
+	impersonateTarget    string
+	impersonateDelegates []string
 	// err tracks any dialer options that may have failed.
 	err error
 }
@@ -55,9 +132,9 @@ func WithOptions(opts ...Option) Option {
 	}
 }
 
-// WithCredentialsFile returns an Option that specifies a service account
-// or refresh token JSON credentials file to be used as the basis for
-// authentication.
+// WithCredentialsFile returns an Option that specifies a JSON credentials
+// file to be used as the basis for authentication. See WithCredentialsJSON for
+// the supported credential types.
 func WithCredentialsFile(filename string) Option {
 	return func(d *dialerConfig) {
 		b, err := os.ReadFile(filename)
@@ -65,23 +142,122 @@ func WithCredentialsFile(filename string) Option {
 			d.err = errtype.NewConfigError(err.Error(), "n/a")
 			return
 		}
-		opt := WithCredentialsJSON(b)
-		opt(d)
+		c, err := credentialsFromJSON(b)
+		if err != nil {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("invalid credentials file %v: %v", filename, err), "n/a",
+			)
+			return
+		}
+		d.tokenSource = c.TokenSource
+		d.credsOpt = apiopt.WithCredentials(c)
 	}
 }
 
-// WithCredentialsJSON returns an Option that specifies a service account
-// or refresh token JSON credentials to be used as the basis for authentication.
+// WithCredentialsJSON returns an Option that specifies JSON credentials to be
+// used as the basis for authentication, both for the AlloyDB Admin API and
+// for the tokens used for IAM database authentication (see
+// WithIAMAuthNTokenSource). The supported credential types are service
+// account keys (service_account), user refresh tokens (authorized_user),
+// Workload Identity Federation configurations (external_account), and
+// impersonated service accounts (impersonated_service_account). Other types,
+// and external_account configurations without the fields needed to exchange
+// tokens, are rejected with a *errtype.ConfigError.
 func WithCredentialsJSON(b []byte) Option {
 	return func(d *dialerConfig) {
-		// TODO: Use AlloyDB-specfic scope
-		c, err := google.CredentialsFromJSON(context.Background(), b, CloudPlatformScope)
+		c, err := credentialsFromJSON(b)
 		if err != nil {
 			d.err = errtype.NewConfigError(err.Error(), "n/a")
 			return
 		}
 		d.tokenSource = c.TokenSource
-		d.adminOpts = append(d.adminOpts, apiopt.WithCredentials(c))
+		d.credsOpt = apiopt.WithCredentials(c)
+	}
+}
+
+// credentialsFromJSON validates and parses JSON credentials. The errors of the
+// returned credentials' token source wrap errtype.ErrCredentials.
+func credentialsFromJSON(b []byte) (*google.Credentials, error) {
+	if err := validateCredentialsJSON(b); err != nil {
+		return nil, err
+	}
+	// TODO: Use AlloyDB-specfic scope
+	c, err := google.CredentialsFromJSON(context.Background(), b, CloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+	c.TokenSource = credentialsTokenSource{ts: c.TokenSource}
+	return c, nil
+}
+
+// supportedCredentialTypes are the values of the "type" field of the JSON
+// credentials accepted by WithCredentialsJSON.
+var supportedCredentialTypes = []string{
+	"service_account",
+	"authorized_user",
+	"external_account",
+	"impersonated_service_account",
+}
+
+// validateCredentialsJSON checks the type of JSON credentials and, for
+// Workload Identity Federation, that the fields needed to exchange tokens are
+// present, so that misconfigurations fail when the Dialer is created rather
+// than on the first refresh.
+func validateCredentialsJSON(b []byte) error {
+	var f struct {
+		Type             string          `json:"type"`
+		Audience         string          `json:"audience"`
+		SubjectTokenType string          `json:"subject_token_type"`
+		TokenURL         string          `json:"token_url"`
+		CredentialSource json.RawMessage `json:"credential_source"`
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return fmt.Errorf("failed to parse credentials: %v", err)
+	}
+	supported := false
+	for _, t := range supportedCredentialTypes {
+		supported = supported || f.Type == t
+	}
+	if f.Type == "" {
+		return errors.New("credentials are missing the \"type\" field")
+	}
+	if !supported {
+		return fmt.Errorf("unsupported credential type %q, expected one of %v",
+			f.Type, strings.Join(supportedCredentialTypes, ", "))
+	}
+	if f.Type != "external_account" {
+		return nil
+	}
+	for _, field := range []struct {
+		name    string
+		missing bool
+	}{
+		{"audience", f.Audience == ""},
+		{"subject_token_type", f.SubjectTokenType == ""},
+		{"token_url", f.TokenURL == ""},
+		{"credential_source", len(f.CredentialSource) == 0 || string(f.CredentialSource) == "null"},
+	} {
+		if field.missing {
+			return fmt.Errorf("external_account credentials are missing the %q field", field.name)
+		}
+	}
+	return nil
+}
+
+// WithImpersonatedCredentials returns an Option that impersonates the target
+// service account using the Dialer's base credentials. The base credentials
+// are those set with WithCredentialsFile, WithCredentialsJSON, or
+// WithTokenSource, or Application Default Credentials otherwise, and must be
+// granted the Service Account Token Creator role on the target (or on the
+// last delegate). Delegates, if any, form the chain of service accounts
+// through which the target is impersonated.
+//
+// Impersonation has no effect when WithHTTPClient is used, as the provided
+// client is expected to handle authentication.
+func WithImpersonatedCredentials(targetSA string, delegates ...string) Option {
+	return func(d *dialerConfig) {
+		d.impersonateTarget = targetSA
+		d.impersonateDelegates = delegates
 	}
 }
 
@@ -104,15 +280,150 @@ func WithDefaultDialOptions(opts ...DialOption) Option {
 // to be used as the basis for authentication.
 func WithTokenSource(s oauth2.TokenSource) Option {
 	return func(d *dialerConfig) {
-		d.tokenSource = s
-		d.adminOpts = append(d.adminOpts, apiopt.WithTokenSource(s))
+		ts := credentialsTokenSource{ts: s}
+		d.tokenSource = ts
+		d.credsOpt = apiopt.WithTokenSource(ts)
 	}
 }
 
 // WithRSAKey returns an Option that specifies a rsa.PrivateKey used to represent the client.
 func WithRSAKey(k *rsa.PrivateKey) Option {
 	return func(d *dialerConfig) {
-		d.rsaKey = k
+		d.key = k
+	}
+}
+
+// WithRandReader returns an Option that uses r, rather than crypto/rand, as
+// the source of entropy for generating the Dialer's RSA key, the certificate
+// signing requests sent to the AlloyDB Admin API, and TLS handshakes, e.g.,
+// to satisfy a certified entropy source requirement. Without WithRSAKey or
+// WithClientSigner, the Dialer generates its own key from r rather than using
+// the key shared by Dialers in the process. Recent Go versions ignore r in
+// some operations, such as RSA key generation, so r does not make them
+// deterministic.
+func WithRandReader(r io.Reader) Option {
+	return func(d *dialerConfig) {
+		d.rand = r
+	}
+}
+
+// WithClientSigner returns an Option that uses s in place of an in-memory
+// private key to represent the client, e.g., a key held in Cloud KMS or an
+// HSM that cannot be exported. The signer signs the request for each client
+// certificate and the TLS handshake of each connection, so it must return
+// promptly. Its public key must be an RSA key, and it must support both
+// PKCS #1 v1.5 signatures with SHA-256, for certificate requests, and RSA-PSS
+// signatures, which TLS 1.3 requires for the handshake. Connection info
+// for a Dialer with a client signer is not included by ExportCache.
+func WithClientSigner(s crypto.Signer) Option {
+	return func(d *dialerConfig) {
+		if _, ok := s.Public().(*rsa.PublicKey); !ok {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("client signer must have an RSA public key, got %T", s.Public()), "n/a",
+			)
+			return
+		}
+		d.key = s
+	}
+}
+
+// WithPinnedCAFingerprint returns an Option that pins the root CA of the
+// instance to the certificate with the provided SHA-256 fingerprint, written
+// in hex with optional colons (e.g., "AB:CD:..."). Connection info for the
+// instance whose certificate chain has any other root CA is rejected, so that
+// a tampered AlloyDB Admin API response cannot substitute its own trust
+// anchor. The Option may be repeated to allow several root CAs, e.g., during
+// a CA rotation. Pins are not checked against connection info supplied by a
+// ConnectionInfoCache.
+func WithPinnedCAFingerprint(instance, fingerprint string) Option {
+	return func(d *dialerConfig) {
+		b, err := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+		if err != nil || len(b) != sha256.Size {
+			d.err = errtype.NewConfigError(
+				"invalid CA fingerprint, expected a hex encoded SHA-256 digest", instance,
+			)
+			return
+		}
+		var fp [sha256.Size]byte
+		copy(fp[:], b)
+		d.addCAPin(instance, fp)
+	}
+}
+
+// WithPinnedCAPEM returns an Option that pins the root CA of the instance to
+// the PEM encoded certificates in pemCerts. See WithPinnedCAFingerprint.
+func WithPinnedCAPEM(instance string, pemCerts []byte) Option {
+	return func(d *dialerConfig) {
+		var n int
+		for rest := pemCerts; ; {
+			var b *pem.Block
+			b, rest = pem.Decode(rest)
+			if b == nil {
+				break
+			}
+			c, err := x509.ParseCertificate(b.Bytes)
+			if err != nil {
+				d.err = errtype.NewConfigError(
+					fmt.Sprintf("failed to parse pinned CA certificate: %v", err), instance,
+				)
+				return
+			}
+			d.addCAPin(instance, sha256.Sum256(c.Raw))
+			n++
+		}
+		if n == 0 {
+			d.err = errtype.NewConfigError("no PEM encoded CA certificates found", instance)
+		}
+	}
+}
+
+// addCAPin records a pinned CA fingerprint for the instance.
+func (d *dialerConfig) addCAPin(instance string, fp [sha256.Size]byte) {
+	if !isInstanceURI(instance) {
+		d.err = errtype.NewConfigError(
+			"invalid instance URI, expected projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>",
+			instance,
+		)
+		return
+	}
+	if d.caPins == nil {
+		d.caPins = make(map[string][][sha256.Size]byte)
+	}
+	key := strings.TrimPrefix(instance, "/")
+	d.caPins[key] = append(d.caPins[key], fp)
+}
+
+// WithStrictSANVerification returns an Option that requires the server
+// certificate of every instance to include subject alternative names (SANs).
+// By default, a server certificate with SANs is verified against them with
+// the standard library verifier, and must name the instance's server name or
+// the address dialed; a certificate without SANs is verified by its CN
+// instead. As AlloyDB rolls out server certificates with SANs, clients harden
+// automatically. WithStrictSANVerification disables the CN fallback, so that
+// only certificates with SANs are accepted.
+func WithStrictSANVerification() Option {
+	return func(d *dialerConfig) {
+		d.requireSAN = true
+	}
+}
+
+// WithOptOutOfAdvancedConnectionCheck returns an Option that stops the Dialer
+// from checking that an instance's server certificate names the instance,
+// i.e., its UID based server name (<UID>.server.alloydb) or its address. The
+// certificate chain is still verified against the instance's CA, and CA pins
+// set with WithPinnedCAFingerprint or WithPinnedCAPEM still apply. It is
+// intended for intermediaries that re-terminate TLS with a certificate issued
+// by the instance's CA but for a different name.
+//
+// Security: without the check, any server holding a certificate issued by
+// the CA of the instance's cluster is accepted, so a server of another
+// instance that shares the CA, or an instance recreated with the same name,
+// can impersonate the instance. Only use this option when the network path
+// between the Dialer and the instance is trusted. It takes precedence over
+// WithStrictSANVerification.
+func WithOptOutOfAdvancedConnectionCheck() Option {
+	return func(d *dialerConfig) {
+		d.skipNameCheck = true
 	}
 }
 
@@ -123,12 +434,224 @@ func WithRefreshTimeout(t time.Duration) Option {
 	}
 }
 
+// WithInstanceRefreshTimeout returns an Option that sets a timeout on the
+// refresh operations of the instance, overriding WithRefreshTimeout, e.g., for
+// an instance in a distant region whose AlloyDB Admin API calls are slower.
+func WithInstanceRefreshTimeout(instance string, t time.Duration) Option {
+	return func(d *dialerConfig) {
+		if !isInstanceURI(instance) {
+			d.err = errtype.NewConfigError(
+				"invalid instance URI, expected projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>",
+				instance,
+			)
+			return
+		}
+		if t <= 0 {
+			d.err = errtype.NewConfigError("invalid refresh timeout, must be positive", instance)
+			return
+		}
+		if d.refreshTimeouts == nil {
+			d.refreshTimeouts = make(map[string]time.Duration)
+		}
+		d.refreshTimeouts[strings.TrimPrefix(instance, "/")] = t
+	}
+}
+
+// RefreshStrategy determines when the Dialer refreshes the information used
+// to connect to an instance, e.g., the client certificate and IP address.
+type RefreshStrategy interface {
+	// NextRefresh returns how long to wait before starting the next refresh
+	// given the current time and the expiration of the current client
+	// certificate. A negative duration is treated as zero.
+	NextRefresh(now, certExpiry time.Time) time.Duration
+}
+
+// WithRefreshStrategy returns an Option that replaces the default refresh
+// schedule with the provided RefreshStrategy. When set, WithRefreshBuffer and
+// WithRefreshJitter have no effect.
+func WithRefreshStrategy(s RefreshStrategy) Option {
+	return func(d *dialerConfig) {
+		d.refreshStrategy = s
+	}
+}
+
+// WithRefreshBuffer returns an Option that starts each refresh the provided
+// duration before the current client certificate expires. By default, a
+// refresh starts roughly halfway to certificate expiration. A smaller buffer
+// reduces the number of AlloyDB Admin API calls, while a larger buffer allows
+// more time to recover from failed refresh attempts.
+func WithRefreshBuffer(d time.Duration) Option {
+	return func(cfg *dialerConfig) {
+		cfg.refreshBuffer = d
+	}
+}
+
+// WithRefreshJitter returns an Option that starts each refresh up to the
+// provided duration earlier than otherwise scheduled, chosen at random. Jitter
+// spreads refresh operations from many clients over time.
+func WithRefreshJitter(d time.Duration) Option {
+	return func(cfg *dialerConfig) {
+		cfg.refreshJitter = d
+	}
+}
+
+// WithCertRefreshHook returns an Option that calls h each time a refresh
+// succeeds with a new client certificate for an instance, with the instance
+// URI, without a leading slash, and the certificate's expiration. A refresh
+// that fails, e.g., because the instance's CA does not match a pinned CA,
+// does not call h. It allows, e.g., a sidecar to export the new certificate
+// with ExportCache to co-located processes that connect as the same
+// identity. h is called on the goroutine that runs the refresh and delays its
+// completion, so it should return quickly. Connection info retrieved through
+// a ConnectionInfoCache or imported with WithImportedCache or
+// WithCertCacheDir does not call h.
+func WithCertRefreshHook(h func(instance string, notAfter time.Time)) Option {
+	return func(d *dialerConfig) {
+		d.certHook = h
+	}
+}
+
+// rateLimit configures the throttling of refresh operations for an instance.
+type rateLimit struct {
+	interval time.Duration
+	burst    int
+}
+
+// defaultRateLimit is the limit of each instance's refresh operations when
+// none is configured; see WithRateLimiter.
+var defaultRateLimit = rateLimit{interval: 30 * time.Second, burst: 2}
+
+// validRateLimit reports whether interval and burst configure a usable
+// limiter.
+func validRateLimit(interval time.Duration, burst int) bool {
+	return interval == 0 || (interval > 0 && burst > 0)
+}
+
+// WithRateLimiter returns an Option that throttles the refresh operations of
+// each instance, and so calls to the AlloyDB Admin API, to burst operations at
+// once followed by one per interval. By default, each instance allows a burst
+// of 2 and one refresh every 30 seconds. An interval of zero disables
+// throttling, e.g., for tests. WithInstanceRateLimiter overrides the setting
+// for a single instance.
+func WithRateLimiter(interval time.Duration, burst int) Option {
+	return func(d *dialerConfig) {
+		if !validRateLimit(interval, burst) {
+			d.err = errtype.NewConfigError(
+				"invalid rate limit, interval must not be negative and burst must be positive", "n/a",
+			)
+			return
+		}
+		d.rateLimit = &rateLimit{interval: interval, burst: burst}
+	}
+}
+
+// WithInstanceRateLimiter returns an Option that throttles the refresh
+// operations of the instance, overriding WithRateLimiter. See
+// WithRateLimiter.
+func WithInstanceRateLimiter(instance string, interval time.Duration, burst int) Option {
+	return func(d *dialerConfig) {
+		if !isInstanceURI(instance) {
+			d.err = errtype.NewConfigError(
+				"invalid instance URI, expected projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>",
+				instance,
+			)
+			return
+		}
+		if !validRateLimit(interval, burst) {
+			d.err = errtype.NewConfigError(
+				"invalid rate limit, interval must not be negative and burst must be positive", instance,
+			)
+			return
+		}
+		if d.rateLimits == nil {
+			d.rateLimits = make(map[string]rateLimit)
+		}
+		d.rateLimits[strings.TrimPrefix(instance, "/")] = rateLimit{interval: interval, burst: burst}
+	}
+}
+
+// An Executor runs the Dialer's background work.
+type Executor interface {
+	// Go runs f asynchronously. Go may queue f, e.g., until a worker of a
+	// pool is free, but must not wait for f to complete.
+	Go(f func())
+}
+
+// goExecutor runs each function on a new goroutine.
+type goExecutor struct{}
+
+func (goExecutor) Go(f func()) { go f() }
+
+// WithExecutor returns an Option that runs the Dialer's background work on
+// e rather than on new goroutines, so that the Dialer's concurrency can be
+// bounded. The work includes the AlloyDB Admin API calls made by refresh
+// operations. Metrics are reported separately; see WithTelemetryQueue.
+// Refresh operations themselves are started by timers, at most one per
+// instance at a time, and run on e as well, where they wait on e for their
+// API calls; an Executor that bounds concurrency must therefore allow more
+// functions to run at once than the number of instances refreshing at once.
+// Connections kept ready by WithConnectionPriming are established on a
+// dedicated goroutine per instance.
+func WithExecutor(e Executor) Option {
+	return func(d *dialerConfig) {
+		d.exec = e
+	}
+}
+
+// WithIAMAuthN returns an Option that indicates the instances reached by the
+// Dialer require IAM database authentication rather than built-in password
+// authentication. Drivers provided by this module reject connection strings
+// that include a password when this option is set, so a password is never
+// sent to such instances.
+func WithIAMAuthN() Option {
+	return func(d *dialerConfig) {
+		d.iamAuthN = true
+	}
+}
+
+// WithIAMAuthNTokenSource returns an Option that enables IAM database
+// authentication, as WithIAMAuthN does, and logs in to databases with tokens
+// from ts rather than from the credentials used to call the AlloyDB Admin
+// API. This allows, e.g., client certificates to be requested with an
+// infrastructure identity while each user logs in as themselves. The tokens
+// must include AlloyDBLoginScope or CloudPlatformScope.
+func WithIAMAuthNTokenSource(ts oauth2.TokenSource) Option {
+	return func(d *dialerConfig) {
+		d.iamAuthN = true
+		d.iamTokenSource = ts
+	}
+}
+
+// WithMaxConnections returns an Option that limits the number of connections
+// the Dialer holds open across all instances. Once n connections are open,
+// Dial fails with an *errtype.ConnectionLimitError until a connection is
+// closed. Zero, the default, means there is no limit.
+func WithMaxConnections(n uint64) Option {
+	return func(d *dialerConfig) {
+		d.maxConns = n
+	}
+}
+
+// WithConnectionPriming returns an Option that keeps n established
+// connections ready for each instance the Dialer has connected to. Dial hands
+// out a primed connection when one is available, avoiding the latency of
+// connecting, and a replacement is established in the background. Primed
+// connections use the Dialer's default DialOptions, so they are only handed
+// out to dials whose DialOptions, including those set for the instance with
+// Configure, match the defaults exactly. They do not count toward
+// WithMaxConnections until handed out.
+func WithConnectionPriming(n int) Option {
+	return func(d *dialerConfig) {
+		d.primedConns = n
+	}
+}
+
 // WithHTTPClient configures the underlying AlloyDB Admin API client with the
 // provided HTTP client. This option is generally unnecessary except for
 // advanced use-cases.
 func WithHTTPClient(client *http.Client) Option {
 	return func(d *dialerConfig) {
-		d.adminOpts = append(d.adminOpts, apiopt.WithHTTPClient(client))
+		d.httpClient = client
 	}
 }
 
@@ -140,20 +663,329 @@ func WithAdminAPIEndpoint(url string) Option {
 	}
 }
 
+// WithUniverseDomain returns an Option that configures the Dialer to use the
+// AlloyDB Admin API within the provided universe domain (e.g.,
+// "example-universe.com") rather than googleapis.com. An endpoint set with
+// WithAdminAPIEndpoint takes precedence over the universe domain. The
+// Dialer's credentials must be valid within the same universe.
+func WithUniverseDomain(domain string) Option {
+	return func(d *dialerConfig) {
+		d.universeDomain = domain
+	}
+}
+
+// WithQuotaProject returns an Option that bills the AlloyDB Admin API calls
+// made by the Dialer to project, and counts them against its quota, rather
+// than the project of the Dialer's credentials. The credentials must be
+// granted the serviceusage.services.use permission on project. The option
+// also applies to clients supplied with WithHTTPClient and to credentials set
+// with WithCredentialsTokenSource.
+func WithQuotaProject(project string) Option {
+	return func(d *dialerConfig) {
+		if project == "" {
+			d.err = errtype.NewConfigError("quota project must not be empty", "n/a")
+			return
+		}
+		d.quotaProject = project
+	}
+}
+
+// WithAdminAPIHeaders returns an Option that adds h to the headers of every
+// AlloyDB Admin API request the Dialer sends, including those of background
+// refresh operations, so that audit pipelines can attribute calls such as
+// GenerateClientCert to the service that made them:
+//
+//	alloydbconn.WithAdminAPIHeaders(http.Header{
+//		"X-Goog-Request-Reason": {"payments-service"},
+//	})
+//
+// Calls made on behalf of a single call to FetchConnectionInfo, EngineVersion,
+// or Diagnose also carry the headers attached to its context with
+// alloydbadmin.WithRequestHeaders. Refresh operations are shared by all
+// dials of an instance, so headers attached to the context passed to Dial do
+// not apply. Repeated calls add to the headers.
+func WithAdminAPIHeaders(h http.Header) Option {
+	return func(d *dialerConfig) {
+		if d.adminHeaders == nil {
+			d.adminHeaders = make(http.Header, len(h))
+		}
+		for k, vs := range h {
+			for _, v := range vs {
+				d.adminHeaders.Add(k, v)
+			}
+		}
+	}
+}
+
+// quotaProjectHeader is the header that attributes a request to a quota
+// project.
+const quotaProjectHeader = "X-Goog-User-Project"
+
+// quotaProjectTransport sets the quota project of each request sent with a
+// client supplied with WithHTTPClient.
+type quotaProjectTransport struct {
+	project string
+	base    http.RoundTripper
+}
+
+func (t *quotaProjectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the request.
+	req = req.Clone(req.Context())
+	req.Header.Set(quotaProjectHeader, t.project)
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// WithStaticConnectionInfo returns an Option that configures the Dialer to
+// connect using the static connection info read from r instead of calling the
+// AlloyDB Admin API. This is useful for hermetic tests and for environments
+// where the Admin API is unreachable. The document is read once, so its
+// certificates must remain valid for as long as the Dialer is used. The
+// document is JSON in the following format:
+//
+//	{
+//	  "privateKey": "<PEM encoded RSA private key>",
+//	  "projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>": {
+//	    "ipAddress": "<private IP address>",
+//	    "publicIpAddress": "<public IP address>",
+//	    "pscDnsName": "<PSC DNS name>",
+//	    "instanceUid": "<instance UID>",
+//	    "pemCertificateChain": ["<client cert>", "<intermediate CA cert>", ..., "<root CA cert>"]
+//	  }
+//	}
+//
+// The client certificates must be issued for the private key, which replaces
+// any key set with WithRSAKey.
+func WithStaticConnectionInfo(r io.Reader) Option {
+	return func(d *dialerConfig) {
+		s, err := alloydbadmin.NewStaticClient(r)
+		if err != nil {
+			d.err = errtype.NewConfigError(err.Error(), "n/a")
+			return
+		}
+		d.staticInfo = s
+	}
+}
+
 // WithDialFunc configures the function used to connect to the address on the
 // named network. This option is generally unnecessary except for advanced
-// use-cases.
+// use-cases, such as routing connections through a VPN. Proxies configured in
+// the environment are not used with a custom dial function; see WithProxyURL
+// to route connections through a proxy. The TLS handshake is performed over
+// the returned connection. TCP keep-alive (see WithTCPKeepAlive) is applied if
+// the connection is a *net.TCPConn or otherwise has SetKeepAlive and
+// SetKeepAlivePeriod methods.
 func WithDialFunc(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
 	return func(d *dialerConfig) {
 		d.dialFunc = dial
 	}
 }
 
+// WithDebugLogger returns an Option that enables debug logging, reporting
+// internal operations such as refresh scheduling, certificate expiry times,
+// rate limiter waits, the IP address dialed, and TLS handshake failures to
+// the provided Logger. WithDebugLogger replaces any logger configured with
+// WithJSONDebugLogger.
+func WithDebugLogger(l debug.Logger) Option {
+	return func(d *dialerConfig) {
+		d.logger = logging.NewDebugfLogger(l)
+	}
+}
+
+// WithJSONDebugLogger returns an Option that enables debug logging, writing
+// one JSON object per line to w. Each entry includes the stable fields
+// "instance", "event", "dialer_id", "duration" (in milliseconds), and
+// "error_code" (the AlloyDB Admin API error reason, if any), alongside the
+// Cloud Logging fields "time", "severity", and "message". This makes the
+// output suitable for Cloud Logging queries and log-based metrics.
+// WithJSONDebugLogger replaces any logger configured with WithDebugLogger.
+func WithJSONDebugLogger(w io.Writer) Option {
+	return func(d *dialerConfig) {
+		d.logger = logging.NewJSONLogger(w)
+	}
+}
+
+// WithOpenTelemetry returns an Option that reports metrics and traces to the
+// provided OpenTelemetry providers in addition to OpenCensus. The connector
+// emits dial latency, dial failures, refresh successes and failures, and open
+// connections per instance, along with spans for each dial and refresh
+// operation. Either provider may be nil to disable the corresponding
+// telemetry.
+func WithOpenTelemetry(mp otelmetric.MeterProvider, tp oteltrace.TracerProvider) Option {
+	return func(d *dialerConfig) {
+		d.otelEnabled = true
+		d.meterProvider = mp
+		d.tracerProvider = tp
+	}
+}
+
+// WithTracer returns an Option that reports spans, dial failures, and refresh
+// results to t instead of OpenCensus, e.g., to plug in an in-house tracing
+// system. Metrics that the Tracer interface does not cover, such as dial
+// latency and open connections, are then only reported to the backends
+// configured with WithOpenTelemetry or WithMetricsRecorder, if any.
+func WithTracer(t Tracer) Option {
+	return func(d *dialerConfig) {
+		if t == nil {
+			d.err = errtype.NewConfigError("tracer must not be nil", "n/a")
+			return
+		}
+		d.tracer = t
+	}
+}
+
+// WithMetricsRecorder returns an Option that reports the connector's metrics
+// to r, in addition to OpenCensus, e.g., to scrape them with Prometheus using
+// the cloud.google.com/go/alloydbconn/promexporter module. The option may be
+// used more than once to report to several recorders.
+func WithMetricsRecorder(r MetricsRecorder) Option {
+	return func(d *dialerConfig) {
+		if r == nil {
+			d.err = errtype.NewConfigError("metrics recorder must not be nil", "n/a")
+			return
+		}
+		d.metricsRecorders = append(d.metricsRecorders, r)
+	}
+}
+
+// WithTelemetryQueue returns an Option that bounds the metric measurements
+// waiting to be reported. Measurements are reported in order by a single
+// worker goroutine, which runs only while measurements are queued, so that
+// dials and refresh operations never wait on a telemetry backend. At most size
+// measurements are queued (by default, 1024); any more are discarded until the
+// worker catches up. When ttl is non-zero, measurements that waited longer
+// than ttl are discarded rather than reported late. Spans are not affected.
+func WithTelemetryQueue(size int, ttl time.Duration) Option {
+	return func(d *dialerConfig) {
+		if size < 1 || ttl < 0 {
+			d.err = errtype.NewConfigError(
+				"invalid telemetry queue, size must be positive and ttl must not be negative", "n/a",
+			)
+			return
+		}
+		d.telemetryQueueSize = size
+		d.telemetryTTL = ttl
+	}
+}
+
+// WithClusterRoleDetection returns an Option that retrieves the role of each
+// instance's cluster (primary or secondary) as part of every refresh and
+// makes it available through Dialer.ClusterRole. When a secondary cluster is
+// promoted to primary, the instance's connection info is re-resolved
+// immediately instead of at the next scheduled refresh.
+//
+// Retrieving the cluster requires the alloydb.clusters.get permission in
+// addition to the permissions needed to connect.
+func WithClusterRoleDetection() Option {
+	return func(d *dialerConfig) {
+		d.detectClusterRole = true
+	}
+}
+
+// WithForcedRefreshPreemption returns an Option that changes how a forced
+// refresh, e.g., after a failed dial or a call to Dialer.ForceRefresh,
+// interacts with a refresh already in progress for the instance. The Dialer
+// never has more than one refresh in progress per instance, so by default the
+// forced refresh shares the result of the one in progress, which may have
+// started before the failure. With this option, the refresh in progress is
+// canceled and started over instead; dials waiting on it receive the result
+// of the new attempt.
+func WithForcedRefreshPreemption() Option {
+	return func(d *dialerConfig) {
+		d.preemptForced = true
+	}
+}
+
+// WithStaleWhileRevalidate returns an Option that configures Dial not to wait
+// for a refresh in progress, e.g., one forced by a failed dial, when the
+// instance's previous connection info is still usable. Such dials use the
+// connection info of the most recent successful refresh, as long as its
+// client certificate has not expired and it was retrieved no longer than
+// maxStaleness ago, while the refresh completes in the background. A
+// maxStaleness of zero limits the connection info by certificate expiry
+// alone. The connection info may be out of date, e.g., if the instance's IP
+// address changed, in which case the dial fails and forces another refresh.
+//
+// Dials to an instance that has not completed its first refresh always wait
+// for it; see WithColdStartBudget.
+func WithStaleWhileRevalidate(maxStaleness time.Duration) Option {
+	return func(d *dialerConfig) {
+		if maxStaleness < 0 {
+			d.err = errtype.NewConfigError("maximum staleness must not be negative", "n/a")
+			return
+		}
+		d.serveStale = true
+		d.maxStaleness = maxStaleness
+	}
+}
+
+// WithRefreshCircuitBreaker returns an Option that stops the Dialer from
+// retrying the refresh of an instance every few seconds once threshold
+// consecutive refreshes have failed in a way that retrying cannot fix, e.g.,
+// because the instance was deleted or permission to connect to it was
+// revoked. Dials to the instance then fail immediately with an
+// *errtype.CircuitOpenError, even if its client certificate has not yet
+// expired, and the Dialer attempts a refresh only once every probeInterval.
+// The first successful refresh resumes the usual refresh cycle. To probe an
+// instance immediately, e.g., after granting the missing permission, call
+// Dialer.ForceRefresh.
+//
+// Permanent failures are those whose errors report Temporary() = false, such
+// as an *errtype.RefreshError caused by errtype.ErrPermissionDenied or
+// errtype.ErrInstanceNotFound. By default, failed refreshes are retried
+// indefinitely, subject to the rate limit.
+func WithRefreshCircuitBreaker(threshold int, probeInterval time.Duration) Option {
+	return func(d *dialerConfig) {
+		if threshold < 1 || probeInterval <= 0 {
+			d.err = errtype.NewConfigError(
+				"invalid circuit breaker, threshold and probe interval must be positive", "n/a",
+			)
+			return
+		}
+		d.breakerThreshold = threshold
+		d.probeInterval = probeInterval
+	}
+}
+
+// WithColdStartBudget returns an Option that bounds how long Dial waits for
+// the information needed to connect to an instance that has not yet completed
+// its first refresh. If the budget is exceeded, Dial returns an
+// *errtype.WarmupError immediately while the refresh continues in the
+// background, allowing latency-sensitive callers to degrade gracefully rather
+// than block. Once an instance has been refreshed successfully, the budget no
+// longer applies.
+func WithColdStartBudget(d time.Duration) Option {
+	return func(cfg *dialerConfig) {
+		cfg.coldStartBudget = d
+	}
+}
+
 // A DialOption is an option for configuring how a Dialer's Dial call is executed.
 type DialOption func(d *dialCfg)
 
 type dialCfg struct {
 	tcpKeepAlive time.Duration
+	// ipType is the IP type to connect with. Empty means the instance's
+	// preferred address.
+	ipType string
+	// ipFallback are the IP types to try, in order, if the address of ipType
+	// cannot be reached; see WithIPTypeFallback.
+	ipFallback []string
+	// fallbackAttempt is set for an attempt of a fallback order that is
+	// followed by another IP type. A failed connection then moves on to the
+	// next type rather than refresh the instance's connection info.
+	fallbackAttempt bool
+	// tokenSource replaces the Dialer's credentials for the dial. Nil means
+	// the Dialer's credentials are used.
+	tokenSource oauth2.TokenSource
+	// dialTimeout bounds the TCP dial and TLS handshake. Zero means only the
+	// dial's context applies.
+	dialTimeout time.Duration
+	// label is the caller's label for the connection, if any.
+	label string
 }
 
 // DialOptions turns a list of DialOption instances into an DialOption.
@@ -166,8 +998,58 @@ func DialOptions(opts ...DialOption) DialOption {
 }
 
 // WithTCPKeepAlive returns a DialOption that specifies the tcp keep alive period for the connection returned by Dial.
+// A negative duration disables keep-alive, and zero uses the operating system's default period.
 func WithTCPKeepAlive(d time.Duration) DialOption {
 	return func(cfg *dialCfg) {
 		cfg.tcpKeepAlive = d
 	}
 }
+
+// WithConnectionLabel returns a DialOption that attaches a free-form label,
+// e.g., "pool=payments-readonly", to the connection, so that the connections
+// of the many pools sharing a Dialer can be told apart. The label appears in
+// the Dialer's debug log events and DialEvents for the dial and the
+// connection, and Stats reports the open connections of each label.
+func WithConnectionLabel(label string) DialOption {
+	return func(cfg *dialCfg) {
+		cfg.label = label
+	}
+}
+
+// WithDialTimeout returns a DialOption that bounds the TCP dial and TLS
+// handshake with the instance, separately from the context passed to Dial.
+// Time spent waiting for the instance's connection info, e.g., on the first
+// dial, does not count toward the timeout. A dial that times out returns a
+// *errtype.DialError. Zero, the default, means only the context applies.
+func WithDialTimeout(d time.Duration) DialOption {
+	return func(cfg *dialCfg) {
+		cfg.dialTimeout = d
+	}
+}
+
+// WithPrivateIP returns a DialOption that specifies a private IP (VPC) will be
+// used to connect.
+func WithPrivateIP() DialOption {
+	return func(cfg *dialCfg) {
+		cfg.ipType = alloydb.PrivateIP
+		cfg.ipFallback = nil
+	}
+}
+
+// WithPublicIP returns a DialOption that specifies a public IP will be used
+// to connect.
+func WithPublicIP() DialOption {
+	return func(cfg *dialCfg) {
+		cfg.ipType = alloydb.PublicIP
+		cfg.ipFallback = nil
+	}
+}
+
+// WithPSC returns a DialOption that specifies a Private Service Connect
+// endpoint will be used to connect.
+func WithPSC() DialOption {
+	return func(cfg *dialCfg) {
+		cfg.ipType = alloydb.PSC
+		cfg.ipFallback = nil
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/logging"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// poolDiscoveryInterval is how long the read pool instances of a cluster are
+// cached before they are listed again.
+const poolDiscoveryInterval = time.Minute
+
+// poolDialAttempts is the maximum number of read pool instances DialPool
+// tries before giving up.
+const poolDialAttempts = 3
+
+var clusterURIRegex = regexp.MustCompile("^/?projects/([^/]+)/locations/([^/]+)/clusters/([^/]+)$")
+
+// PoolPolicy determines which read pool instance DialPool connects to.
+type PoolPolicy int
+
+const (
+	// RoundRobin cycles through the read pool instances in turn.
+	RoundRobin PoolPolicy = iota
+	// Random picks a read pool instance at random.
+	Random
+	// LeastConnections picks the read pool instance with the fewest
+	// connections open from this Dialer.
+	LeastConnections
+)
+
+// readPool caches the read pool instances of a cluster.
+type readPool struct {
+	mu sync.Mutex
+	// instances are the URIs of the cluster's ready read pool instances.
+	instances []string
+	// fetched is when instances were last listed.
+	fetched time.Time
+	// next is the position of the next round robin pick. It is accessed
+	// atomically.
+	next uint64
+}
+
+// DialPool returns a net.Conn connected to one of the read pool instances of
+// the specified cluster, chosen according to policy. The cluster argument
+// must be the cluster's URI, which is in the format
+// projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>
+//
+// Read pool instances are discovered with the AlloyDB Admin API and cached
+// for a minute, so instances added to or removed from the cluster are picked
+// up without restarting the application. If an instance refuses the
+// connection or no longer exists, e.g., because the read pool was scaled
+// down, the cached instances are discovered again and the dial is retried
+// against a different instance, up to three instances in total. If opts
+// include WithCredentialsTokenSource, its credentials are also used to
+// discover the instances.
+func (d *Dialer) DialPool(ctx context.Context, cluster string, policy PoolPolicy, opts ...DialOption) (net.Conn, error) {
+	// The read pool is discovered with the credentials the dials use.
+	cfg := d.currentSettings().defaultDialCfg
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	ts := cfg.tokenSource
+	p, insts, err := d.poolInstances(ctx, cluster, ts)
+	if err != nil {
+		return nil, err
+	}
+	tried := make(map[string]bool)
+	for attempt := 1; ; attempt++ {
+		inst := d.pick(p, insts, policy)
+		conn, err := d.Dial(ctx, inst, opts...)
+		if err == nil || !isDrainError(err) || attempt == poolDialAttempts || ctx.Err() != nil {
+			return conn, err
+		}
+		tried[inst] = true
+		if isNotFound(err) {
+			// The instance was deleted, so stop refreshing it.
+			d.evictInstance(inst)
+		}
+		p.invalidate()
+		if _, fresh, lerr := d.poolInstances(ctx, cluster, ts); lerr == nil {
+			insts = fresh
+		}
+		insts = untried(insts, tried)
+		if len(insts) == 0 {
+			return nil, err
+		}
+		d.logger.Log(logging.Event{
+			Instance: inst,
+			Name:     logging.EventPoolRetry,
+			Message:  fmt.Sprintf("retrying dial to read pool of %v (attempt %d)", cluster, attempt+1),
+			Err:      err,
+		})
+	}
+}
+
+// isDrainError reports whether a failed dial to a read pool instance suggests
+// the instance is going away, so the dial should be retried against another
+// instance of the pool.
+func isDrainError(err error) bool {
+	return isFailoverError(err) || errors.Is(err, syscall.ECONNRESET) || isNotFound(err)
+}
+
+// isNotFound reports whether err is the AlloyDB Admin API reporting that the
+// instance does not exist.
+func isNotFound(err error) bool {
+	var gErr *googleapi.Error
+	return errors.As(err, &gErr) && gErr.Code == http.StatusNotFound
+}
+
+// untried returns the instances not in tried.
+func untried(insts []string, tried map[string]bool) []string {
+	var res []string
+	for _, i := range insts {
+		if !tried[i] {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// invalidate marks the cached instances as stale, so that they are listed
+// again on next use.
+func (p *readPool) invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetched = time.Time{}
+}
+
+// evictInstance closes and forgets the instance, if the Dialer's cache has
+// it, along with the instance's primed connections and the copies of it that
+// use credentials set with WithCredentialsTokenSource, in the Dialer and in
+// the other Dialers sharing its cache, so that none of them keeps refreshing
+// the instance.
+func (d *Dialer) evictInstance(uri string) {
+	k, ok := parseInstanceKey(uri)
+	if !ok {
+		return
+	}
+	o := d.cacheOwner()
+	o.lock.Lock()
+	i, cached := o.instances[k]
+	if cached {
+		i.Close()
+		delete(o.instances, k)
+	}
+	o.lock.Unlock()
+
+	var primers []*primer
+	for _, dd := range o.scopeDialers() {
+		primers = append(primers, dd.forgetInstance(k)...)
+	}
+	// Primers wait for their connection attempts to finish, so they are
+	// closed without holding any lock.
+	for _, p := range primers {
+		p.close()
+	}
+	if cached {
+		d.logger.Log(logging.Event{
+			Instance: i.String(),
+			Name:     logging.EventEvict,
+			Message:  "instance evicted from cache",
+		})
+	}
+}
+
+// scopeDialers returns the Dialer and all Dialers created from it with Scope.
+func (d *Dialer) scopeDialers() []*Dialer {
+	d.lock.RLock()
+	children := append([]*Dialer{}, d.children...)
+	d.lock.RUnlock()
+	res := []*Dialer{d}
+	for _, c := range children {
+		res = append(res, c.scopeDialers()...)
+	}
+	return res
+}
+
+// forgetInstance closes and forgets the Dialer's own copies of the instance
+// with key k, i.e., those of its tenants, and returns the instance's primers,
+// which the caller must close.
+func (d *Dialer) forgetInstance(k instanceKey) []*primer {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, t := range d.tenants {
+		if i, ok := t.instances[k]; ok {
+			i.Close()
+			delete(t.instances, k)
+		}
+	}
+	var primers []*primer
+	for uri, p := range d.primers {
+		if pk, ok := parseInstanceKey(uri); ok && pk == k {
+			primers = append(primers, p)
+			delete(d.primers, uri)
+		}
+	}
+	return primers
+}
+
+// poolKey identifies the read pool instances of a cluster as listed with the
+// credentials of a token source set with WithCredentialsTokenSource, or with
+// the Dialer's if ts is nil.
+type poolKey struct {
+	cluster string
+	ts      oauth2.TokenSource
+}
+
+// readPool returns the cache of the cluster's read pool instances listed with
+// the credentials of ts, creating it if necessary.
+func (d *Dialer) readPool(cluster string, ts oauth2.TokenSource) *readPool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	k := poolKey{cluster: cluster, ts: ts}
+	p, ok := d.pools[k]
+	if !ok {
+		p = &readPool{}
+		d.pools[k] = p
+	}
+	return p
+}
+
+// poolInstances returns the cluster's read pool and the URIs of its ready
+// instances, listing them with the Admin API if the cached list is stale. If
+// listing fails, a stale list is used when available. If ts is not nil, the
+// instances are listed with its credentials rather than the Dialer's.
+func (d *Dialer) poolInstances(ctx context.Context, cluster string, ts oauth2.TokenSource) (*readPool, []string, error) {
+	m := clusterURIRegex.FindStringSubmatch(cluster)
+	if m == nil {
+		return nil, nil, errtype.NewConfigError(
+			"invalid cluster URI, expected projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>",
+			cluster,
+		)
+	}
+	if d.client == nil {
+		return nil, nil, errtype.NewConfigError(
+			"read pool discovery requires the AlloyDB Admin API", cluster,
+		)
+	}
+	client := d.client
+	if ts != nil && d.staticInfo == nil {
+		var err error
+		if client, err = d.tenantClient(ts, cluster); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		ts = nil
+	}
+	p := d.readPool(cluster, ts)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.fetched) < poolDiscoveryInterval && len(p.instances) > 0 {
+		return p, p.instances, nil
+	}
+	listed, err := client.ListInstances(d.adminContext(ctx), m[1], m[2], m[3])
+	if err != nil {
+		if len(p.instances) > 0 {
+			return p, p.instances, nil
+		}
+		return nil, nil, errtype.NewRefreshError("failed to list read pool instances", cluster, err)
+	}
+	var insts []string
+	for _, inst := range listed {
+		if inst.InstanceType == "READ_POOL" && inst.State == "READY" {
+			insts = append(insts, inst.Name)
+		}
+	}
+	if len(insts) == 0 {
+		return nil, nil, errtype.NewConfigError("cluster has no ready read pool instances", cluster)
+	}
+	p.instances = insts
+	p.fetched = time.Now()
+	return p, insts, nil
+}
+
+// pick chooses one of insts according to policy.
+func (d *Dialer) pick(p *readPool, insts []string, policy PoolPolicy) string {
+	switch policy {
+	case Random:
+		return insts[rand.Intn(len(insts))]
+	case LeastConnections:
+		best, bestConns := insts[0], uint64(0)
+		for n, uri := range insts {
+			var conns uint64
+			if i, ok := d.cachedInstance(uri); ok {
+				conns = atomic.LoadUint64(&i.OpenConns)
+			}
+			if n == 0 || conns < bestConns {
+				best, bestConns = uri, conns
+			}
+		}
+		return best
+	default:
+		n := atomic.AddUint64(&p.next, 1) - 1
+		return insts[n%uint64(len(insts))]
+	}
+}
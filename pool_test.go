@@ -0,0 +1,316 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestDialerDialPool(t *testing.T) {
+	ctx := context.Background()
+	primary := mock.NewFakeInstance("my-project", "my-region", "my-cluster", "my-primary")
+	rp1 := mock.NewFakeInstance("my-project", "my-region", "my-cluster", "my-pool-1",
+		mock.WithInstanceType("READ_POOL"),
+	)
+	rp2 := mock.NewFakeInstance("my-project", "my-region", "my-cluster", "my-pool-2",
+		mock.WithInstanceType("READ_POOL"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceListSuccess(1, primary, rp1, rp2),
+		mock.InstanceGetSuccess(rp1, 1),
+		mock.InstanceGetSuccess(rp2, 1),
+		mock.CreateEphemeralSuccess(rp1, 2),
+	)
+	stop := mock.StartServerProxy(t, rp1)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	cluster := "projects/my-project/locations/my-region/clusters/my-cluster"
+	pool1 := cluster + "/instances/my-pool-1"
+	pool2 := cluster + "/instances/my-pool-2"
+
+	// Round robin spreads connections across both read pool instances.
+	conn1, err := d.DialPool(ctx, cluster, RoundRobin)
+	if err != nil {
+		t.Fatalf("expected DialPool to succeed, but got error: %v", err)
+	}
+	defer conn1.Close()
+	conn2, err := d.DialPool(ctx, cluster, RoundRobin)
+	if err != nil {
+		t.Fatalf("expected DialPool to succeed, but got error: %v", err)
+	}
+	defer conn2.Close()
+	s := d.Stats()
+	if s.Instances[pool1].OpenConnections != 1 || s.Instances[pool2].OpenConnections != 1 {
+		t.Fatalf("want one connection to each read pool instance, got stats = %+v", s)
+	}
+
+	// Least connections picks the instance that no longer has a connection.
+	conn2.Close()
+	conn3, err := d.DialPool(ctx, cluster, LeastConnections)
+	if err != nil {
+		t.Fatalf("expected DialPool to succeed, but got error: %v", err)
+	}
+	defer conn3.Close()
+	if got := d.Stats().Instances[pool2].OpenConnections; got != 1 {
+		t.Fatalf("want least connections to pick %v, got stats = %+v", pool2, d.Stats())
+	}
+
+	conn4, err := d.DialPool(ctx, cluster, Random)
+	if err != nil {
+		t.Fatalf("expected DialPool to succeed, but got error: %v", err)
+	}
+	conn4.Close()
+}
+
+func TestDialerDialPoolErrors(t *testing.T) {
+	ctx := context.Background()
+	primary := mock.NewFakeInstance("my-project", "my-region", "my-cluster", "my-primary")
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceListSuccess(1, primary),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	var wantErr *errtype.ConfigError
+	if _, err := d.DialPool(ctx, "my-cluster", RoundRobin); !errors.As(err, &wantErr) {
+		t.Fatalf("with invalid cluster URI, want = %T, got = %v", wantErr, err)
+	}
+	_, err = d.DialPool(ctx, "projects/my-project/locations/my-region/clusters/my-cluster", RoundRobin)
+	if !errors.As(err, &wantErr) {
+		t.Fatalf("with no read pool instances, want = %T, got = %v", wantErr, err)
+	}
+}
+
+func TestDialerDialPoolRetriesDrainedInstance(t *testing.T) {
+	ctx := context.Background()
+	// drained has no server listening, as though the read pool was scaled
+	// down while the dial was in progress.
+	drained := mock.NewFakeInstance("my-project", "my-region", "my-cluster", "my-pool-1",
+		mock.WithInstanceType("READ_POOL"),
+		mock.WithIPAddr("10.0.0.1"),
+	)
+	healthy := mock.NewFakeInstance("my-project", "my-region", "my-cluster", "my-pool-2",
+		mock.WithInstanceType("READ_POOL"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceListSuccess(2, drained, healthy),
+		// The failed dial forces the drained instance to refresh in the
+		// background, racing the healthy instance for client certificates.
+		mock.InstanceGetSuccess(drained, mock.Unlimited),
+		mock.InstanceGetSuccess(healthy, 1),
+		mock.CreateEphemeralSuccess(healthy, mock.Unlimited),
+	)
+	stop := mock.StartServerProxy(t, healthy)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == "10.0.0.1:5433" {
+				return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+			}
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	cluster := "projects/my-project/locations/my-region/clusters/my-cluster"
+	conn, err := d.DialPool(ctx, cluster, RoundRobin)
+	if err != nil {
+		t.Fatalf("expected DialPool to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	s := d.Stats()
+	if got := s.Instances[cluster+"/instances/my-pool-2"].OpenConnections; got != 1 {
+		t.Fatalf("want connection to healthy instance, got stats = %+v", s)
+	}
+}
+
+func TestDialerEvictInstanceStopsRefreshes(t *testing.T) {
+	ctx := context.Background()
+	// Certificates close to expiry are refreshed right away, so the
+	// instance refreshes continuously until it is closed.
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithCertExpiry(time.Now().Add(2*time.Minute)),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, mock.Unlimited),
+		mock.CreateEphemeralSuccess(inst, mock.Unlimited),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	ct := &countingTransport{counts: make(map[string]int), rt: mc.Transport}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithHTTPClient(&http.Client{Transport: ct}),
+		WithAdminAPIEndpoint(url),
+		WithRateLimiter(10*time.Millisecond, 1),
+		WithConnectionPriming(1),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	// Dial with the Dialer's credentials, which starts a primer, and with a
+	// tenant's, which caches a separate copy of the instance.
+	for _, opts := range [][]DialOption{
+		nil,
+		{WithCredentialsTokenSource(&tenantTokenSource{token: "a"})},
+	} {
+		conn, err := d.Dial(ctx, testInstanceURI, opts...)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		conn.Close()
+	}
+
+	d.evictInstance(testInstanceURI)
+
+	d.lock.RLock()
+	primers := len(d.primers)
+	var tenantInstances int
+	for _, tn := range d.tenants {
+		tenantInstances += len(tn.instances)
+	}
+	d.lock.RUnlock()
+	if primers != 0 || tenantInstances != 0 {
+		t.Fatalf("want no primers or tenant instances after eviction, got %d primers and %d tenant instances",
+			primers, tenantInstances)
+	}
+
+	requests := func() int {
+		ct.mu.Lock()
+		defer ct.mu.Unlock()
+		var n int
+		for _, c := range ct.counts {
+			n += c
+		}
+		return n
+	}
+	// Let refresh operations in progress at eviction finish.
+	time.Sleep(100 * time.Millisecond)
+	before := requests()
+	time.Sleep(300 * time.Millisecond)
+	if after := requests(); after != before {
+		t.Fatalf("want no refresh after eviction, got %d requests", after-before)
+	}
+}
+
+func TestDialerDialPoolWithCredentialsTokenSource(t *testing.T) {
+	ctx := context.Background()
+	rp := mock.NewFakeInstance("my-project", "my-region", "my-cluster", "my-pool-1",
+		mock.WithInstanceType("READ_POOL"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceListSuccess(1, rp),
+		mock.InstanceGetSuccess(rp, 1),
+		mock.CreateEphemeralSuccess(rp, 1),
+	)
+	stop := mock.StartServerProxy(t, rp)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	rec := &authRecorder{base: mc.Transport, auths: make(map[string]int)}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithHTTPClient(&http.Client{Transport: rec}),
+		WithAdminAPIEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	cluster := "projects/my-project/locations/my-region/clusters/my-cluster"
+	conn, err := d.DialPool(ctx, cluster, RoundRobin,
+		WithCredentialsTokenSource(&tenantTokenSource{token: "a"}),
+	)
+	if err != nil {
+		t.Fatalf("expected DialPool to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	// The list, get, and certificate requests all use the tenant's
+	// credentials.
+	if got := rec.auths["Bearer a"]; got != 3 {
+		t.Errorf("want 3 requests with the tenant's credentials, got = %v", rec.auths)
+	}
+	if got := len(rec.auths); got != 1 {
+		t.Errorf("want requests with 1 credential, got = %v", rec.auths)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+)
+
+const (
+	// primeRetryInterval is how long a primer waits before retrying a
+	// failed connection attempt.
+	primeRetryInterval = time.Second
+	// primeCheckTimeout is how long a primed connection is read from to
+	// check that it is still open.
+	primeCheckTimeout = time.Millisecond
+)
+
+// primer keeps a number of established connections to an instance ready to
+// be handed out by Dial, replacing each one as it is taken.
+type primer struct {
+	conns  chan net.Conn
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// primer returns the primer for the instance, starting one if necessary. If
+// connection priming is disabled, primer returns nil.
+func (d *Dialer) primer(instance string, i *alloydb.Instance) *primer {
+	if d.primedConns <= 0 {
+		return nil
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return nil
+	}
+	p, ok := d.primers[instance]
+	if !ok {
+		p = newPrimer(d.primedConns, func(ctx context.Context) (net.Conn, error) {
+			return d.connect(ctx, i, d.currentSettings().defaultDialCfg)
+		})
+		d.primers[instance] = p
+	}
+	return p
+}
+
+func newPrimer(n int, connect func(context.Context) (net.Conn, error)) *primer {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &primer{
+		conns:  make(chan net.Conn, n),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go p.fill(ctx, connect)
+	return p
+}
+
+// fill establishes connections until the primer is closed, blocking while
+// the primer is full.
+func (p *primer) fill(ctx context.Context, connect func(context.Context) (net.Conn, error)) {
+	defer close(p.done)
+	for {
+		conn, err := connect(ctx)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(primeRetryInterval):
+				continue
+			}
+		}
+		select {
+		case p.conns <- conn:
+		case <-ctx.Done():
+			_ = conn.Close()
+			return
+		}
+	}
+}
+
+// take returns a primed connection that is still open, or nil if none are
+// available.
+func (p *primer) take() net.Conn {
+	for {
+		select {
+		case conn := <-p.conns:
+			if isOpen(conn) {
+				return conn
+			}
+			_ = conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// close stops the primer and closes any connections it holds.
+func (p *primer) close() {
+	p.cancel()
+	<-p.done
+	for {
+		select {
+		case conn := <-p.conns:
+			_ = conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// isOpen reports whether an idle connection is still usable. The server does
+// not send data before the client does, so a read that times out indicates
+// the connection is open, while data or an error indicates it is not.
+func isOpen(conn net.Conn) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(primeCheckTimeout)); err != nil {
+		return false
+	}
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		return false
+	}
+	return conn.SetReadDeadline(time.Time{}) == nil
+}
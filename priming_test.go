@@ -0,0 +1,141 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestIsOpen(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	if !isOpen(client) {
+		t.Fatal("idle connection: want open, got closed")
+	}
+
+	go server.Write([]byte("x"))
+	if isOpen(client) {
+		t.Fatal("connection with unexpected data: want closed, got open")
+	}
+
+	server.Close()
+	if isOpen(client) {
+		t.Fatal("connection closed by peer: want closed, got open")
+	}
+}
+
+func TestDialerWithConnectionPriming(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	ln, err := mock.ListenServerProxy(inst)
+	if err != nil {
+		t.Fatalf("failed to start server proxy: %v", err)
+	}
+	var accepted int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// Complete the handshake and then hold the connection idle.
+			if err := conn.(*tls.Conn).Handshake(); err != nil {
+				conn.Close()
+				continue
+			}
+			atomic.AddInt32(&accepted, 1)
+			defer conn.Close()
+		}
+	}()
+	defer func() {
+		ln.Close()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	var failDials int32
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithConnectionPriming(2),
+		WithDialFunc(func(ctx context.Context, network, _ string) (net.Conn, error) {
+			if atomic.LoadInt32(&failDials) == 1 {
+				return nil, errors.New("dial failed")
+			}
+			var nd net.Dialer
+			return nd.DialContext(ctx, network, ln.Addr().String())
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	uri := "/projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+	conn, err := d.Dial(ctx, uri)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	// wait for the dialed connection and both primed connections
+	for start := time.Now(); atomic.LoadInt32(&accepted) < 3; {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("primed connections were not established in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// With new connections failing, Dial succeeds only with a primed
+	// connection.
+	atomic.StoreInt32(&failDials, 1)
+	// Dials with other DialOptions than the Dialer's defaults do not use
+	// primed connections.
+	for _, opt := range []DialOption{
+		WithConnectionLabel("pool=other"),
+		WithDialTimeout(time.Second),
+	} {
+		if conn, err := d.Dial(ctx, uri, opt); err == nil {
+			conn.Close()
+			t.Fatal("expected Dial with non-default options to connect on its own and fail, but it succeeded")
+		}
+	}
+	conn, err = d.Dial(ctx, uri)
+	if err != nil {
+		t.Fatalf("expected Dial to use a primed connection, but got error: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,226 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package promexporter reports the AlloyDB Go Connector's metrics to
+// Prometheus. It is a separate module so that only applications that use it
+// depend on the Prometheus client, which requires Go 1.17.
+//
+// Use it with alloydbconn.WithMetricsRecorder:
+//
+//	r, err := promexporter.NewRecorder(prometheus.DefaultRegisterer)
+//	if err != nil {
+//		// handle error
+//	}
+//	d, err := alloydbconn.NewDialer(ctx, alloydbconn.WithMetricsRecorder(r))
+package promexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/alloydbconn"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/googleapi"
+)
+
+// Prometheus label names. The target label "instance" is reserved by
+// Prometheus, so the AlloyDB instance uses a prefixed name.
+const (
+	labelInstance  = "alloydb_instance"
+	labelDialerID  = "alloydb_dialer_id"
+	labelTrigger   = "alloydb_refresh_trigger"
+	labelErrorCode = "alloydb_error_code"
+)
+
+// Recorder is an alloydbconn.MetricsRecorder that reports metrics to a
+// Prometheus registry.
+type Recorder struct {
+	dialLatency    *prometheus.HistogramVec
+	openConns      *prometheus.GaugeVec
+	dialFailures   *prometheus.CounterVec
+	refreshSuccess *prometheus.CounterVec
+	refreshFailure *prometheus.CounterVec
+	dialQueue      *prometheus.GaugeVec
+	certIssuance   *prometheus.HistogramVec
+}
+
+// NewRecorder returns a Recorder that registers the connector's metrics with
+// reg. If the metrics are already registered, e.g., by another Recorder, the
+// registered metrics are shared.
+func NewRecorder(reg prometheus.Registerer) (*Recorder, error) {
+	r := &Recorder{
+		dialLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "alloydbconn_dial_latency_milliseconds",
+			Help:    "The distribution of dialer latencies (ms).",
+			Buckets: []float64{5, 25, 100, 250, 500, 1000, 2000, 5000, 30000},
+		}, []string{labelInstance, labelDialerID}),
+		openConns: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "alloydbconn_open_connections",
+			Help: "The current number of open AlloyDB connections.",
+		}, []string{labelInstance, labelDialerID}),
+		dialFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "alloydbconn_dial_failures_total",
+			Help: "The number of failed dial attempts.",
+		}, []string{labelInstance, labelDialerID}),
+		refreshSuccess: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "alloydbconn_refresh_successes_total",
+			Help: "The number of successful certificate refresh operations.",
+		}, []string{labelInstance, labelDialerID, labelTrigger}),
+		refreshFailure: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "alloydbconn_refresh_failures_total",
+			Help: "The number of failed certificate refresh operations.",
+		}, []string{labelInstance, labelDialerID, labelTrigger, labelErrorCode}),
+		dialQueue: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "alloydbconn_dial_queue_depth",
+			Help: "The current number of dials waiting on an in-flight refresh operation.",
+		}, []string{labelInstance, labelDialerID}),
+		certIssuance: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "alloydbconn_cert_issuance_latency_milliseconds",
+			Help:    "The distribution of client certificate issuance latencies (ms).",
+			Buckets: []float64{50, 100, 250, 500, 1000, 2000, 5000, 10000, 30000},
+		}, []string{labelInstance, labelDialerID}),
+	}
+	var err error
+	if r.dialLatency, err = registerHistogram(reg, r.dialLatency); err != nil {
+		return nil, err
+	}
+	if r.openConns, err = registerGauge(reg, r.openConns); err != nil {
+		return nil, err
+	}
+	if r.dialFailures, err = registerCounter(reg, r.dialFailures); err != nil {
+		return nil, err
+	}
+	if r.refreshSuccess, err = registerCounter(reg, r.refreshSuccess); err != nil {
+		return nil, err
+	}
+	if r.refreshFailure, err = registerCounter(reg, r.refreshFailure); err != nil {
+		return nil, err
+	}
+	if r.dialQueue, err = registerGauge(reg, r.dialQueue); err != nil {
+		return nil, err
+	}
+	if r.certIssuance, err = registerHistogram(reg, r.certIssuance); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// register registers c with reg, returning the collector registered
+// previously if there is one.
+func register(reg prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	err := reg.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		return are.ExistingCollector, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to register Prometheus metric: %v", err)
+	}
+	return c, nil
+}
+
+func registerHistogram(reg prometheus.Registerer, h *prometheus.HistogramVec) (*prometheus.HistogramVec, error) {
+	c, err := register(reg, h)
+	if err != nil {
+		return nil, err
+	}
+	if h, ok := c.(*prometheus.HistogramVec); ok {
+		return h, nil
+	}
+	return nil, fmt.Errorf("failed to register Prometheus metric: conflicting collector %T", c)
+}
+
+func registerGauge(reg prometheus.Registerer, g *prometheus.GaugeVec) (*prometheus.GaugeVec, error) {
+	c, err := register(reg, g)
+	if err != nil {
+		return nil, err
+	}
+	if g, ok := c.(*prometheus.GaugeVec); ok {
+		return g, nil
+	}
+	return nil, fmt.Errorf("failed to register Prometheus metric: conflicting collector %T", c)
+}
+
+func registerCounter(reg prometheus.Registerer, cv *prometheus.CounterVec) (*prometheus.CounterVec, error) {
+	c, err := register(reg, cv)
+	if err != nil {
+		return nil, err
+	}
+	if cv, ok := c.(*prometheus.CounterVec); ok {
+		return cv, nil
+	}
+	return nil, fmt.Errorf("failed to register Prometheus metric: conflicting collector %T", c)
+}
+
+var _ alloydbconn.MetricsRecorder = (*Recorder)(nil)
+
+// RecordDialLatency implements alloydbconn.MetricsRecorder.
+func (r *Recorder) RecordDialLatency(_ context.Context, instance, dialerID string, latency time.Duration) {
+	r.dialLatency.WithLabelValues(instance, dialerID).Observe(milliseconds(latency))
+}
+
+// RecordOpenConnections implements alloydbconn.MetricsRecorder.
+func (r *Recorder) RecordOpenConnections(_ context.Context, instance, dialerID string, num int64) {
+	r.openConns.WithLabelValues(instance, dialerID).Set(float64(num))
+}
+
+// RecordDialError implements alloydbconn.MetricsRecorder.
+func (r *Recorder) RecordDialError(_ context.Context, instance, dialerID string, _ error) {
+	r.dialFailures.WithLabelValues(instance, dialerID).Inc()
+}
+
+// RecordRefreshResult implements alloydbconn.MetricsRecorder.
+func (r *Recorder) RecordRefreshResult(_ context.Context, instance, dialerID, trigger string, err error) {
+	if err != nil {
+		r.refreshFailure.WithLabelValues(instance, dialerID, trigger, errorCode(err)).Inc()
+		return
+	}
+	r.refreshSuccess.WithLabelValues(instance, dialerID, trigger).Inc()
+}
+
+// RecordDialQueueDepth implements alloydbconn.MetricsRecorder.
+func (r *Recorder) RecordDialQueueDepth(_ context.Context, instance, dialerID string, depth int64) {
+	r.dialQueue.WithLabelValues(instance, dialerID).Set(float64(depth))
+}
+
+// RecordCertIssuanceLatency implements alloydbconn.MetricsRecorder.
+func (r *Recorder) RecordCertIssuanceLatency(_ context.Context, instance, dialerID string, latency time.Duration) {
+	r.certIssuance.WithLabelValues(instance, dialerID).Observe(milliseconds(latency))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// errorCode returns the error code label of a failed refresh: CREDENTIALS
+// for invalid credentials, or else the reasons of the AlloyDB Admin API error,
+// if any. It matches the error codes the connector logs.
+func errorCode(err error) string {
+	if errors.Is(err, errtype.ErrCredentials) {
+		return "CREDENTIALS"
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	var codes []string
+	for _, e := range apiErr.Errors {
+		codes = append(codes, e.Reason)
+	}
+	return strings.Join(codes, ",")
+}
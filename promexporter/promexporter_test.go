@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPrometheusRecorder(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewRegistry()
+	r, err := NewRecorder(reg)
+	if err != nil {
+		t.Fatalf("want no error, got = %v", err)
+	}
+	// A second recorder shares the registered metrics.
+	r2, err := NewRecorder(reg)
+	if err != nil {
+		t.Fatalf("want no error registering twice, got = %v", err)
+	}
+
+	r.RecordDialLatency(ctx, "my-instance", "dialer-id", 10*time.Millisecond)
+	r.RecordDialError(ctx, "my-instance", "dialer-id", errors.New("dial failed"))
+	r2.RecordDialError(ctx, "my-instance", "dialer-id", errors.New("dial failed"))
+	r.RecordRefreshResult(ctx, "my-instance", "dialer-id", alloydbconn.RefreshTriggerScheduled, nil)
+	r.RecordRefreshResult(ctx, "my-instance", "dialer-id", alloydbconn.RefreshTriggerForced, errors.New("refresh failed"))
+	r.RecordOpenConnections(ctx, "my-instance", "dialer-id", 2)
+	r.RecordOpenConnections(ctx, "my-instance", "dialer-id", 1)
+	r.RecordDialQueueDepth(ctx, "my-instance", "dialer-id", 3)
+	r.RecordCertIssuanceLatency(ctx, "my-instance", "dialer-id", 200*time.Millisecond)
+
+	tcs := []struct {
+		name string
+		c    prometheus.Collector
+		want float64
+	}{
+		{name: "dial failures", c: r.dialFailures.WithLabelValues("my-instance", "dialer-id"), want: 2},
+		{name: "refresh successes", c: r.refreshSuccess.WithLabelValues("my-instance", "dialer-id", alloydbconn.RefreshTriggerScheduled), want: 1},
+		{name: "refresh failures", c: r.refreshFailure.WithLabelValues("my-instance", "dialer-id", alloydbconn.RefreshTriggerForced, ""), want: 1},
+		{name: "open connections", c: r.openConns.WithLabelValues("my-instance", "dialer-id"), want: 1},
+		{name: "dial queue depth", c: r.dialQueue.WithLabelValues("my-instance", "dialer-id"), want: 3},
+	}
+	for _, tc := range tcs {
+		if got := testutil.ToFloat64(tc.c); got != tc.want {
+			t.Errorf("%v: want = %v, got = %v", tc.name, tc.want, got)
+		}
+	}
+	if got := testutil.CollectAndCount(reg, "alloydbconn_dial_latency_milliseconds"); got != 1 {
+		t.Errorf("dial latency series: want = 1, got = %v", got)
+	}
+	if got := testutil.CollectAndCount(reg, "alloydbconn_cert_issuance_latency_milliseconds"); got != 1 {
+		t.Errorf("cert issuance latency series: want = 1, got = %v", got)
+	}
+
+	r.RecordRefreshResult(ctx, "my-instance", "dialer-id", alloydbconn.RefreshTriggerForced,
+		fmt.Errorf("refresh failed: %w", errtype.ErrCredentials))
+	got := testutil.ToFloat64(r.refreshFailure.WithLabelValues(
+		"my-instance", "dialer-id", alloydbconn.RefreshTriggerForced, "CREDENTIALS",
+	))
+	if got != 1 {
+		t.Errorf("refresh failures with invalid credentials: want = 1, got = %v", got)
+	}
+
+	// Other metrics with the same name cannot be replaced.
+	conflict := prometheus.NewRegistry()
+	conflict.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "alloydbconn_dial_failures_total",
+		Help: "Conflicting metric.",
+	}))
+	if _, err := NewRecorder(conflict); err == nil {
+		t.Error("want error for conflicting metric, got nil")
+	}
+}
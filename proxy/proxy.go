@@ -0,0 +1,300 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy runs a local proxy in-process that accepts connections on a
+// Unix socket or TCP address and forwards them to AlloyDB instances with an
+// alloydbconn.Connector. It allows tools without a custom dialer hook, such
+// as psql, to connect to an instance through localhost:
+//
+//	d, err := alloydbconn.NewDialer(ctx)
+//	// ...
+//	p, err := proxy.Listen(d,
+//		proxy.TCP("projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>", "127.0.0.1:5432"),
+//	)
+//	// ...
+//	defer p.Close()
+//	go p.Serve(ctx)
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/alloydbconn"
+)
+
+// Mount maps a local listening address to an instance.
+type Mount struct {
+	// Instance is the instance URI passed to the Connector's Dial method.
+	Instance string
+	// Network is "unix" or "tcp".
+	Network string
+	// Address is the path of the Unix socket or the TCP address to listen
+	// on, e.g., "127.0.0.1:5432". A TCP port of zero picks a free port; see
+	// Proxy.Addrs.
+	Address string
+	// DialOptions are passed to the Connector's Dial method for each
+	// connection.
+	DialOptions []alloydbconn.DialOption
+	// BufferSize is the size of the buffers used to copy the data of each
+	// connection. Zero means DefaultBufferSize. See Relay.
+	BufferSize int
+}
+
+// ConnStats describes a connection forwarded by a Proxy.
+type ConnStats struct {
+	// Instance is the instance URI of the connection's Mount.
+	Instance string
+	// ClientAddr is the address of the local client.
+	ClientAddr string
+	// Opened is when the connection to the instance was established.
+	Opened time.Time
+	// BytesSent is the number of bytes copied from the client to the
+	// instance.
+	BytesSent uint64
+	// BytesReceived is the number of bytes copied from the instance to the
+	// client.
+	BytesReceived uint64
+}
+
+// relay is a connection being forwarded.
+type relay struct {
+	instance   string
+	clientAddr string
+	opened     time.Time
+	counters   Counters
+}
+
+// TCP returns a Mount that listens on the TCP address addr.
+func TCP(instance, addr string, opts ...alloydbconn.DialOption) Mount {
+	return Mount{Instance: instance, Network: "tcp", Address: addr, DialOptions: opts}
+}
+
+// UnixSocket returns a Mount that listens on the Unix socket at path. To
+// point psql or libpq at the socket, name it .s.PGSQL.5432 within a
+// directory and pass the directory as the host.
+func UnixSocket(instance, path string, opts ...alloydbconn.DialOption) Mount {
+	return Mount{Instance: instance, Network: "unix", Address: path, DialOptions: opts}
+}
+
+// ErrProxyClosed is returned by Serve after Close is called.
+var ErrProxyClosed = errors.New("proxy: proxy is closed")
+
+// Proxy accepts local connections and forwards them to instances.
+type Proxy struct {
+	c         alloydbconn.Connector
+	listeners []mountListener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	relays map[*relay]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type mountListener struct {
+	net.Listener
+	m Mount
+}
+
+// Listen opens a listener for each Mount. Connections are not accepted until
+// Serve is called. If any listener cannot be opened, those already opened are
+// closed and the error is returned. For Unix sockets, a stale socket file at
+// the path is removed first.
+func Listen(c alloydbconn.Connector, mounts ...Mount) (*Proxy, error) {
+	p := &Proxy{
+		c:      c,
+		conns:  make(map[net.Conn]struct{}),
+		relays: make(map[*relay]struct{}),
+	}
+	for _, m := range mounts {
+		if m.Network != "tcp" && m.Network != "unix" {
+			p.Close()
+			return nil, fmt.Errorf("proxy: unsupported network %q for %v", m.Network, m.Instance)
+		}
+		if m.Network == "unix" {
+			removeStaleSocket(m.Address)
+		}
+		l, err := net.Listen(m.Network, m.Address)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("proxy: failed to listen for %v: %v", m.Instance, err)
+		}
+		p.listeners = append(p.listeners, mountListener{Listener: l, m: m})
+	}
+	return p, nil
+}
+
+// removeStaleSocket removes the file at path if it is a Unix socket, e.g.,
+// left behind by a process that exited without cleaning up.
+func removeStaleSocket(path string) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+}
+
+// Addrs returns the address of each listener, in the order of the Mounts
+// passed to Listen.
+func (p *Proxy) Addrs() []net.Addr {
+	addrs := make([]net.Addr, len(p.listeners))
+	for n, l := range p.listeners {
+		addrs[n] = l.Addr()
+	}
+	return addrs
+}
+
+// Serve accepts connections on every listener and forwards each one to its
+// instance until ctx is done or Close is called. It then closes the proxy
+// and returns ErrProxyClosed, or the first error accepting a connection.
+func (p *Proxy) Serve(ctx context.Context) error {
+	errCh := make(chan error, len(p.listeners))
+	for _, l := range p.listeners {
+		l := l
+		go func() { errCh <- p.serveListener(ctx, l) }()
+	}
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errCh:
+	}
+	p.Close()
+	if err == nil || p.isClosed() {
+		err = ErrProxyClosed
+	}
+	return err
+}
+
+func (p *Proxy) serveListener(ctx context.Context, l mountListener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if p.isClosed() {
+				return ErrProxyClosed
+			}
+			return err
+		}
+		if !p.track(conn) {
+			conn.Close()
+			return ErrProxyClosed
+		}
+		go func() {
+			defer p.wg.Done()
+			defer p.untrack(conn)
+			p.forward(ctx, conn, l.m)
+		}()
+	}
+}
+
+// forward dials the instance and copies bytes in both directions until
+// either side closes.
+func (p *Proxy) forward(ctx context.Context, client net.Conn, m Mount) {
+	defer client.Close()
+	server, err := p.c.Dial(ctx, m.Instance, m.DialOptions...)
+	if err != nil {
+		return
+	}
+	if !p.track(server) {
+		server.Close()
+		return
+	}
+	defer p.wg.Done()
+	defer p.untrack(server)
+	defer server.Close()
+
+	r := &relay{
+		instance:   m.Instance,
+		clientAddr: client.RemoteAddr().String(),
+		opened:     time.Now(),
+	}
+	p.mu.Lock()
+	p.relays[r] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.relays, r)
+		p.mu.Unlock()
+	}()
+	Relay(client, server, m.BufferSize, &r.counters)
+}
+
+// ConnStats returns the statistics of each connection the Proxy is
+// forwarding. Throughput may be derived from the bytes copied since Opened.
+func (p *Proxy) ConnStats() []ConnStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]ConnStats, 0, len(p.relays))
+	for r := range p.relays {
+		stats = append(stats, ConnStats{
+			Instance:      r.instance,
+			ClientAddr:    r.clientAddr,
+			Opened:        r.opened,
+			BytesSent:     r.counters.Sent(),
+			BytesReceived: r.counters.Received(),
+		})
+	}
+	return stats
+}
+
+// track registers conn so that Close can close it. It reports false if the
+// proxy is closed.
+func (p *Proxy) track(conn net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.conns[conn] = struct{}{}
+	p.wg.Add(1)
+	return true
+}
+
+func (p *Proxy) untrack(conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.conns, conn)
+}
+
+func (p *Proxy) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// Close stops accepting connections, closes all forwarded connections, and
+// waits for them to finish. It does not close the Connector.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	var err error
+	for _, l := range p.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for c := range p.conns {
+		c.Close()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	return err
+}
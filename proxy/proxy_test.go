@@ -0,0 +1,120 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/alloydbconntest"
+)
+
+const testInstance = "projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+
+func echo(_ string, conn net.Conn) {
+	defer conn.Close()
+	_, _ = io.Copy(conn, conn)
+}
+
+func TestProxy(t *testing.T) {
+	d := alloydbconntest.NewDialer(alloydbconntest.WithHandler(echo))
+	sock := filepath.Join(t.TempDir(), ".s.PGSQL.5432")
+	p, err := Listen(d,
+		TCP(testInstance, "127.0.0.1:0"),
+		UnixSocket(testInstance, sock),
+	)
+	if err != nil {
+		t.Fatalf("expected Listen to succeed, but got error: %v", err)
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- p.Serve(context.Background()) }()
+
+	for _, addr := range p.Addrs() {
+		conn, err := net.Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Fatalf("failed to connect to %v: %v", addr, err)
+		}
+		if _, err := conn.Write([]byte("hello")); err != nil {
+			t.Fatalf("write to %v failed: %v", addr, err)
+		}
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Fatalf("read from %v failed: %v", addr, err)
+		}
+		if string(buf) != "hello" {
+			t.Fatalf("want echo of hello, got = %q", buf)
+		}
+		conn.Close()
+	}
+	if n := len(d.Calls()); n != 2 {
+		t.Fatalf("want 2 dials, got = %v", n)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("expected Close to succeed, but got error: %v", err)
+	}
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, ErrProxyClosed) {
+			t.Fatalf("want = %v, got = %v", ErrProxyClosed, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after Close")
+	}
+}
+
+func TestProxyDialError(t *testing.T) {
+	d := alloydbconntest.NewDialer(
+		alloydbconntest.WithDialError(testInstance, errors.New("connection refused")),
+	)
+	p, err := Listen(d, TCP(testInstance, "127.0.0.1:0"))
+	if err != nil {
+		t.Fatalf("expected Listen to succeed, but got error: %v", err)
+	}
+	defer p.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Serve(ctx)
+
+	conn, err := net.Dial("tcp", p.Addrs()[0].String())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("want the connection closed when the dial fails, got = %v", err)
+	}
+}
+
+func TestListenErrors(t *testing.T) {
+	d := alloydbconntest.NewDialer()
+	if _, err := Listen(d, Mount{Instance: testInstance, Network: "udp", Address: "127.0.0.1:0"}); err == nil {
+		t.Error("with unsupported network, want error, got nil")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if _, err := Listen(d, TCP(testInstance, l.Addr().String())); err == nil {
+		t.Error("with address in use, want error, got nil")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBufferSize is the size of the buffers Relay uses to copy data when
+// no size is given.
+const DefaultBufferSize = 32 * 1024
+
+// Counters counts the bytes Relay copies in each direction. The counts are
+// updated as data is copied, so they may be read while the relay runs.
+type Counters struct {
+	sent, received uint64
+}
+
+// Sent returns the number of bytes copied from the client to the server.
+func (c *Counters) Sent() uint64 {
+	return atomic.LoadUint64(&c.sent)
+}
+
+// Received returns the number of bytes copied from the server to the client.
+func (c *Counters) Received() uint64 {
+	return atomic.LoadUint64(&c.received)
+}
+
+// Relay copies data between client and server in both directions until
+// either side closes the connection or fails, then closes both connections
+// and waits for the other direction to finish. It is the relay used by
+// Proxy and may be used to build other relays on top of the connections
+// returned by Dial.
+//
+// Data is copied through buffers of bufSize bytes, or DefaultBufferSize if
+// bufSize is not positive, that are reused across relays. A direction whose
+// ends are both plain TCP or Unix sockets is copied by the operating system
+// instead, e.g., with splice(2) on Linux; connections returned by Dial use
+// TLS and so are always copied through a buffer. If c is not nil, its counts
+// are updated as data is copied.
+func Relay(client, server net.Conn, bufSize int, c *Counters) {
+	if bufSize <= 0 {
+		bufSize = DefaultBufferSize
+	}
+	if c == nil {
+		c = &Counters{}
+	}
+	done := make(chan struct{}, 2)
+	cp := func(dst, src net.Conn, n *uint64) {
+		_, _ = copyConn(dst, src, bufSize, n)
+		done <- struct{}{}
+	}
+	go cp(server, client, &c.sent)
+	go cp(client, server, &c.received)
+	// Once either direction finishes, close both connections to end the
+	// other.
+	<-done
+	client.Close()
+	server.Close()
+	<-done
+}
+
+// copyConn copies from src to dst, adding the bytes copied to n.
+func copyConn(dst, src net.Conn, bufSize int, n *uint64) (int64, error) {
+	if spliceable(dst) && spliceable(src) {
+		// The runtime splices TCP and Unix sockets with ReadFrom, so n is
+		// only updated once the copy ends.
+		written, err := io.Copy(dst, src)
+		atomic.AddUint64(n, uint64(written))
+		return written, err
+	}
+	buf := getBuffer(bufSize)
+	defer putBuffer(buf)
+	// Hide ReadFrom and WriteTo so that io.CopyBuffer uses buf rather than
+	// allocate its own.
+	return io.CopyBuffer(countingWriter{w: dst, n: n}, readerOnly{src}, *buf)
+}
+
+// spliceable reports whether the runtime can copy data to or from conn
+// without a user space buffer.
+func spliceable(conn net.Conn) bool {
+	switch conn.(type) {
+	case *net.TCPConn, *net.UnixConn:
+		return true
+	}
+	return false
+}
+
+type readerOnly struct {
+	io.Reader
+}
+
+// countingWriter adds the bytes written to n.
+type countingWriter struct {
+	w io.Writer
+	n *uint64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddUint64(c.n, uint64(n))
+	return n, err
+}
+
+var (
+	bufferPoolsMu sync.Mutex
+	// bufferPools holds a pool of *[]byte for each buffer size in use.
+	bufferPools = make(map[int]*sync.Pool)
+)
+
+func bufferPool(size int) *sync.Pool {
+	bufferPoolsMu.Lock()
+	defer bufferPoolsMu.Unlock()
+	p, ok := bufferPools[size]
+	if !ok {
+		p = &sync.Pool{New: func() interface{} {
+			b := make([]byte, size)
+			return &b
+		}}
+		bufferPools[size] = p
+	}
+	return p
+}
+
+func getBuffer(size int) *[]byte {
+	return bufferPool(size).Get().(*[]byte)
+}
+
+func putBuffer(b *[]byte) {
+	bufferPool(len(*b)).Put(b)
+}
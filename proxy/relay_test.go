@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/alloydbconntest"
+)
+
+// tcpPair returns both ends of a TCP connection over loopback.
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, s
+}
+
+func TestRelay(t *testing.T) {
+	tcs := []struct {
+		desc string
+		pair func(t *testing.T) (net.Conn, net.Conn)
+	}{
+		{
+			desc: "buffered",
+			pair: func(*testing.T) (net.Conn, net.Conn) { return net.Pipe() },
+		},
+		{
+			desc: "spliced",
+			pair: tcpPair,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			// app <-> client ... server <-> instance, with the relay between
+			// client and server.
+			app, client := tc.pair(t)
+			server, instance := tc.pair(t)
+			go echo("", instance)
+
+			var c Counters
+			done := make(chan struct{})
+			go func() {
+				Relay(client, server, 16, &c)
+				close(done)
+			}()
+
+			// The payload spans several buffers.
+			want := bytes.Repeat([]byte("hello "), 100)
+			go app.Write(want)
+			got := make([]byte, len(want))
+			if _, err := io.ReadFull(app, got); err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("want echo of payload, got = %q", got)
+			}
+			app.Close()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Relay did not return after the client closed")
+			}
+			n := uint64(len(want))
+			if c.Sent() != n || c.Received() != n {
+				t.Fatalf("want %v bytes each way, got sent = %v, received = %v", n, c.Sent(), c.Received())
+			}
+		})
+	}
+}
+
+func TestProxyConnStats(t *testing.T) {
+	d := alloydbconntest.NewDialer(alloydbconntest.WithHandler(echo))
+	p, err := Listen(d, Mount{
+		Instance:   testInstance,
+		Network:    "tcp",
+		Address:    "127.0.0.1:0",
+		BufferSize: 1024,
+	})
+	if err != nil {
+		t.Fatalf("expected Listen to succeed, but got error: %v", err)
+	}
+	defer p.Close()
+	go p.Serve(context.Background())
+
+	conn, err := net.Dial("tcp", p.Addrs()[0].String())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := io.ReadFull(conn, make([]byte, 5)); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	// The counts are updated once each write completes.
+	var stats []ConnStats
+	for i := 0; i < 100; i++ {
+		stats = p.ConnStats()
+		if len(stats) == 1 && stats[0].BytesSent == 5 && stats[0].BytesReceived == 5 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("want 1 connection, got = %v", stats)
+	}
+	s := stats[0]
+	if s.Instance != testInstance || s.ClientAddr != conn.LocalAddr().String() {
+		t.Errorf("want connection from %v to %v, got = %+v", conn.LocalAddr(), testInstance, s)
+	}
+	if s.BytesSent != 5 || s.BytesReceived != 5 {
+		t.Errorf("want 5 bytes each way, got = %+v", s)
+	}
+
+	conn.Close()
+	for i := 0; i < 100 && len(p.ConnStats()) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(p.ConnStats()); n != 0 {
+		t.Fatalf("want no connections after close, got = %v", n)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+// connectProxy is an HTTP CONNECT proxy that records the requests it serves.
+type connectProxy struct {
+	l net.Listener
+
+	mu   sync.Mutex
+	reqs []*http.Request
+}
+
+func startConnectProxy(t *testing.T) *connectProxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &connectProxy{l: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *connectProxy) serve(conn net.Conn) {
+	defer conn.Close()
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	p.reqs = append(p.reqs, req)
+	p.mu.Unlock()
+	target, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer target.Close()
+	io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n")
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+func (p *connectProxy) requests() []*http.Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*http.Request(nil), p.reqs...)
+}
+
+func TestDialerWithProxyURL(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	p := startConnectProxy(t)
+	defer p.l.Close()
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithProxyURL("http://user:secret@"+p.l.Addr().String()),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	reqs := p.requests()
+	if len(reqs) != 1 {
+		t.Fatalf("want 1 CONNECT request, got = %v", len(reqs))
+	}
+	if got := reqs[0].Host; got != "127.0.0.1:5433" {
+		t.Errorf("want CONNECT to 127.0.0.1:5433, got = %v", got)
+	}
+	if got, want := reqs[0].Header.Get("Proxy-Authorization"), "Basic dXNlcjpzZWNyZXQ="; got != want {
+		t.Errorf("want Proxy-Authorization = %v, got = %v", want, got)
+	}
+}
+
+func TestEnvProxyFunc(t *testing.T) {
+	tcs := []struct {
+		desc string
+		env  map[string]string
+		addr string
+		want string
+	}{
+		{
+			desc: "no proxy configured",
+			addr: "10.0.0.1:5433",
+		},
+		{
+			desc: "HTTPS_PROXY",
+			env:  map[string]string{"HTTPS_PROXY": "http://proxy:3128"},
+			addr: "10.0.0.1:5433",
+			want: "http://proxy:3128",
+		},
+		{
+			desc: "ALL_PROXY",
+			env:  map[string]string{"all_proxy": "socks5://proxy:1080"},
+			addr: "10.0.0.1:5433",
+			want: "socks5://proxy:1080",
+		},
+		{
+			desc: "HTTPS_PROXY takes precedence",
+			env: map[string]string{
+				"HTTPS_PROXY": "http://proxy:3128",
+				"ALL_PROXY":   "socks5://proxy:1080",
+			},
+			addr: "10.0.0.1:5433",
+			want: "http://proxy:3128",
+		},
+		{
+			desc: "NO_PROXY",
+			env: map[string]string{
+				"HTTPS_PROXY": "http://proxy:3128",
+				"NO_PROXY":    "10.0.0.0/8",
+			},
+			addr: "10.0.0.1:5433",
+		},
+		{
+			desc: "loopback",
+			env:  map[string]string{"HTTPS_PROXY": "http://proxy:3128"},
+			addr: "127.0.0.1:5433",
+		},
+		{
+			desc: "IPv6",
+			env:  map[string]string{"HTTPS_PROXY": "http://proxy:3128"},
+			addr: "[2001:db8::1]:5433",
+			want: "http://proxy:3128",
+		},
+		{
+			desc: "IPv6 NO_PROXY",
+			env: map[string]string{
+				"HTTPS_PROXY": "http://proxy:3128",
+				"NO_PROXY":    "2001:db8::/32",
+			},
+			addr: "[2001:db8::1]:5433",
+		},
+		{
+			desc: "IPv6 loopback",
+			env:  map[string]string{"HTTPS_PROXY": "http://proxy:3128"},
+			addr: "[::1]:5433",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			env := func(names ...string) string {
+				for _, n := range names {
+					if v := tc.env[n]; v != "" {
+						return v
+					}
+				}
+				return ""
+			}
+			u, err := envProxyFunc(env)(tc.addr)
+			if err != nil {
+				t.Fatalf("expected envProxyFunc to succeed, got error: %v", err)
+			}
+			var got string
+			if u != nil {
+				got = u.String()
+			}
+			if got != tc.want {
+				t.Fatalf("want = %q, got = %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWithProxyURLErrors(t *testing.T) {
+	for _, u := range []string{"ftp://proxy:21", "://proxy"} {
+		_, err := NewDialer(context.Background(),
+			WithTokenSource(stubTokenSource{}),
+			WithProxyURL(u),
+		)
+		var cErr *errtype.ConfigError
+		if !errors.As(err, &cErr) {
+			t.Errorf("%v: want = %T, got = %v", u, cErr, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+)
+
+// Warmup starts the background refresh of the information needed to connect
+// to the instance and waits for it to complete, so that a later Dial does not
+// wait on it. Unlike WaitForReady, Warmup does not connect to the instance
+// and returns the error of the first refresh, if any, rather than retrying.
+// DialOptions select the IP type and credentials as they would for Dial.
+func (d *Dialer) Warmup(ctx context.Context, instance string, opts ...DialOption) error {
+	cfg := d.currentSettings().defaultDialCfg
+	instance, err := d.resolve(ctx, instance, &cfg)
+	if err != nil {
+		return err
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var i *alloydb.Instance
+	if cfg.tokenSource != nil && d.staticInfo == nil {
+		i, err = d.tenantInstance(instance, cfg.tokenSource)
+	} else {
+		i, err = d.instance(instance)
+	}
+	if err != nil {
+		return err
+	}
+	_, _, err = i.ConnectInfo(ctx, cfg.ipType)
+	return err
+}
+
+// WarmupAll warms up each instance in uris as Warmup does, with at most
+// concurrency warmups in progress at once, so that an application that
+// connects to many instances does not wait on their first refreshes one at a
+// time. Each refresh is still subject to the instance's rate limiter. A
+// concurrency below one is treated as one. WarmupAll returns once every
+// warmup has completed or ctx is done, with the error of each instance that
+// failed keyed by its entry in uris; the map is empty if all succeeded.
+func (d *Dialer) WarmupAll(ctx context.Context, uris []string, concurrency int) map[string]error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var (
+		mu   sync.Mutex
+		errs = make(map[string]error)
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, concurrency)
+	)
+	for _, uri := range uris {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			errs[uri] = ctx.Err()
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(uri string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := d.Warmup(ctx, uri); err != nil {
+				mu.Lock()
+				errs[uri] = err
+				mu.Unlock()
+			}
+		}(uri)
+	}
+	wg.Wait()
+	return errs
+}
+
+// readyPollInterval is how long WaitForReady waits between failed checks.
+const readyPollInterval = time.Second
+
+// WaitForReady blocks until the Dialer holds a valid client certificate for
+// the instance and a connection to the instance, including the TLS
+// handshake, succeeds, or until ctx expires. It is intended as a readiness
+// gate, e.g., for deployment hooks and init containers. The connection made
+// to check the instance is closed immediately and does not count toward the
+// Dialer's connection limit. DialOptions select the IP type to check as they
+// would for Dial.
+//
+// If ctx expires first, WaitForReady returns a *errtype.DialError wrapping
+// the most recent failure, or the context's error if no check completed.
+func (d *Dialer) WaitForReady(ctx context.Context, instance string, opts ...DialOption) error {
+	cfg := d.currentSettings().defaultDialCfg
+	instance, err := d.resolve(ctx, instance, &cfg)
+	if err != nil {
+		return err
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	i, err := d.instance(instance)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for {
+		conn, err := d.connect(ctx, i, cfg)
+		if err == nil {
+			_ = conn.Close()
+			return nil
+		}
+		if ctx.Err() == nil {
+			lastErr = err
+		}
+		t := time.NewTimer(readyPollInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			if lastErr == nil {
+				return ctx.Err()
+			}
+			return errtype.NewDialError("instance did not become ready", i.String(), lastErr)
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+)
+
+// dnsService is the service name used when looking up SRV records.
+const dnsService = "alloydb"
+
+// ResolvedInstance is the result of resolving a name passed to Dial.
+type ResolvedInstance struct {
+	// URI is the instance URI, in the format
+	// projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>
+	URI string
+	// IPType is the preferred IP type of the instance: "PRIVATE", "PUBLIC",
+	// "PSC", or empty to use the Dialer's default.
+	IPType string
+}
+
+// A Resolver maps names passed to Dial that are not instance URIs to
+// instances.
+type Resolver interface {
+	Resolve(ctx context.Context, name string) (ResolvedInstance, error)
+}
+
+// WithResolver returns an Option that sets the Resolver used to map names
+// passed to Dial that are not instance URIs. Names are resolved on every
+// call to Dial, so that changes to the target take effect for subsequent
+// connections.
+func WithResolver(r Resolver) Option {
+	return func(d *dialerConfig) {
+		d.resolver = r
+	}
+}
+
+// WithDNSResolver returns an Option that resolves names passed to Dial that
+// are not instance URIs using DNS. See DNSResolver for the expected records.
+func WithDNSResolver() Option {
+	return WithResolver(NewDNSResolver(net.DefaultResolver))
+}
+
+// resolve maps instance to an instance URI with the Dialer's Resolver, if it
+// is not one already, and applies the resolved IP type and the DialOptions
+// set for the instance with Configure to cfg.
+func (d *Dialer) resolve(ctx context.Context, instance string, cfg *dialCfg) (string, error) {
+	if d.resolver != nil && !isInstanceURI(instance) {
+		res, err := d.resolver.Resolve(ctx, instance)
+		if err != nil {
+			return "", errtype.NewDialError("failed to resolve instance", instance, err)
+		}
+		if res.IPType != "" {
+			cfg.ipType = res.IPType
+		}
+		instance = res.URI
+	}
+	if s, ok := d.instanceSettings(instance); ok {
+		for _, opt := range s.dialOpts {
+			opt(cfg)
+		}
+	}
+	return instance, nil
+}
+
+// dnsLookup is the subset of *net.Resolver used by DNSResolver.
+type dnsLookup interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// DNSResolver resolves names to instances using DNS TXT records. A name
+// resolves to the first valid TXT record found, which holds an instance URI
+// optionally followed by the preferred IP type:
+//
+//	my-db.example.com. IN TXT "projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE> PUBLIC"
+//
+// The instance may also be given in the short form
+// <PROJECT>/<REGION>/<CLUSTER>/<INSTANCE>.
+//
+// If the name has SRV records for the "alloydb" service over TCP, the TXT
+// records of the SRV targets are tried instead, in order of priority:
+//
+//	_alloydb._tcp.my-db.example.com. IN SRV 10 0 5433 primary.example.com.
+type DNSResolver struct {
+	r dnsLookup
+}
+
+// NewDNSResolver returns a DNSResolver that uses r for lookups.
+func NewDNSResolver(r *net.Resolver) *DNSResolver {
+	return &DNSResolver{r: r}
+}
+
+// Resolve looks up the instance for the provided name.
+func (r *DNSResolver) Resolve(ctx context.Context, name string) (ResolvedInstance, error) {
+	targets := []string{name}
+	if _, srvs, err := r.r.LookupSRV(ctx, dnsService, "tcp", name); err == nil && len(srvs) > 0 {
+		targets = targets[:0]
+		for _, s := range srvs {
+			targets = append(targets, s.Target)
+		}
+	}
+	var errs []string
+	for _, t := range targets {
+		res, err := r.resolveTXT(ctx, t)
+		if err == nil {
+			return res, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return ResolvedInstance{}, errors.New(strings.Join(errs, "; "))
+}
+
+func (r *DNSResolver) resolveTXT(ctx context.Context, name string) (ResolvedInstance, error) {
+	recs, err := r.r.LookupTXT(ctx, name)
+	if err != nil {
+		return ResolvedInstance{}, fmt.Errorf("failed to look up TXT records for %v: %v", name, err)
+	}
+	for _, rec := range recs {
+		if res, ok := parseTXTRecord(rec); ok {
+			return res, nil
+		}
+	}
+	return ResolvedInstance{}, fmt.Errorf("no valid instance TXT record found for %v", name)
+}
+
+// parseTXTRecord parses a record of the form "<instance> [<IP type>]", where
+// the instance is an instance URI or its short form.
+func parseTXTRecord(rec string) (ResolvedInstance, bool) {
+	fields := strings.Fields(rec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return ResolvedInstance{}, false
+	}
+	uri, ok := expandInstanceName(fields[0])
+	if !ok {
+		return ResolvedInstance{}, false
+	}
+	res := ResolvedInstance{URI: uri}
+	if len(fields) == 2 {
+		t := strings.ToUpper(fields[1])
+		if !validIPType(t) {
+			return ResolvedInstance{}, false
+		}
+		res.IPType = t
+	}
+	return res, true
+}
+
+// isInstanceURI reports whether s looks like an instance URI rather than a
+// name to be resolved.
+func isInstanceURI(s string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(s, "/"), "projects/")
+}
+
+// expandInstanceName returns the instance URI for s, which is either an
+// instance URI or of the form <PROJECT>/<REGION>/<CLUSTER>/<INSTANCE>.
+func expandInstanceName(s string) (string, bool) {
+	if isInstanceURI(s) {
+		return s, true
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) != 4 {
+		return "", false
+	}
+	for _, p := range parts {
+		if p == "" {
+			return "", false
+		}
+	}
+	return fmt.Sprintf(
+		"projects/%s/locations/%s/clusters/%s/instances/%s",
+		parts[0], parts[1], parts[2], parts[3],
+	), true
+}
+
+func validIPType(t string) bool {
+	switch t {
+	case alloydb.PrivateIP, alloydb.PublicIP, alloydb.PSC:
+		return true
+	}
+	return false
+}
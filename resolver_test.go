@@ -0,0 +1,178 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+const testInstanceURI = "projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+
+type fakeDNS struct {
+	txt map[string][]string
+	srv map[string][]*net.SRV
+}
+
+func (f fakeDNS) LookupTXT(_ context.Context, name string) ([]string, error) {
+	recs, ok := f.txt[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return recs, nil
+}
+
+func (f fakeDNS) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	srvs, ok := f.srv["_"+service+"._"+proto+"."+name]
+	if !ok {
+		return "", nil, errors.New("no such host")
+	}
+	return "", srvs, nil
+}
+
+func TestDNSResolver(t *testing.T) {
+	dns := fakeDNS{
+		txt: map[string][]string{
+			"db.example.com":      {testInstanceURI},
+			"public.example.com":  {"v=spf1 -all", testInstanceURI + " public"},
+			"invalid.example.com": {"not-an-instance", testInstanceURI + " CARRIER_PIGEON"},
+			"primary.example.com": {testInstanceURI + " PSC"},
+			"short.example.com":   {"my-project/my-region/my-cluster/my-instance"},
+			"partial.example.com": {"my-project/my-region/my-cluster"},
+		},
+		srv: map[string][]*net.SRV{
+			"_alloydb._tcp.srv.example.com": {
+				{Target: "missing.example.com", Port: 5433, Priority: 1},
+				{Target: "primary.example.com", Port: 5433, Priority: 2},
+			},
+		},
+	}
+	tcs := []struct {
+		desc string
+		name string
+		want ResolvedInstance
+	}{
+		{
+			desc: "TXT record with instance URI",
+			name: "db.example.com",
+			want: ResolvedInstance{URI: testInstanceURI},
+		},
+		{
+			desc: "TXT record with IP type",
+			name: "public.example.com",
+			want: ResolvedInstance{URI: testInstanceURI, IPType: "PUBLIC"},
+		},
+		{
+			desc: "TXT record with short instance name",
+			name: "short.example.com",
+			want: ResolvedInstance{URI: testInstanceURI},
+		},
+		{
+			desc: "SRV record falls through to next target",
+			name: "srv.example.com",
+			want: ResolvedInstance{URI: testInstanceURI, IPType: "PSC"},
+		},
+	}
+	r := &DNSResolver{r: dns}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := r.Resolve(context.Background(), tc.name)
+			if err != nil {
+				t.Fatalf("expected Resolve to succeed, got error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("want = %+v, got = %+v", tc.want, got)
+			}
+		})
+	}
+
+	for _, name := range []string{"invalid.example.com", "missing.example.com", "partial.example.com"} {
+		if _, err := r.Resolve(context.Background(), name); err == nil {
+			t.Errorf("want error resolving %v, got nil", name)
+		}
+	}
+}
+
+type staticResolver ResolvedInstance
+
+func (s staticResolver) Resolve(context.Context, string) (ResolvedInstance, error) {
+	return ResolvedInstance(s), nil
+}
+
+func TestDialerWithResolver(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithIPAddr("10.0.0.1"),
+		mock.WithPublicIPAddr("127.0.0.1"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		addrs []string
+	)
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithResolver(staticResolver{URI: testInstanceURI, IPType: "PUBLIC"}),
+		WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			addrs = append(addrs, addr)
+			mu.Unlock()
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, "db.example.com")
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(addrs) != 1 || addrs[0] != "127.0.0.1:5433" {
+		t.Fatalf("want dial to public IP 127.0.0.1:5433, got = %v", addrs)
+	}
+	if _, ok := d.Stats().Instances[testInstanceURI]; !ok {
+		t.Fatalf("want connection to resolved instance, got stats = %+v", d.Stats())
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+	"cloud.google.com/go/alloydbconn/internal/logging"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
+)
+
+// Scope returns a child Dialer, e.g., for a tenant or a priority class, that
+// shares the Dialer's key, Admin API client, credentials, connection info
+// cache, logger, and telemetry but has its own rate limits and connection
+// limit. The refreshes a noisy child forces in the shared cache, e.g., after
+// failed dials, are throttled by the child's own rate limits on top of the
+// Dialer's, so they cannot use up the limits of instances dialed through the
+// Dialer or its other children, and the child's connections count only
+// toward its own limit.
+//
+// The child starts with the Dialer's current settings, including those of
+// instances set with Configure. The following Options take effect for the
+// child:
+//
+//   - WithRateLimiter and WithInstanceRateLimiter limit the refreshes the
+//     child forces.
+//   - WithMaxConnections limits the connections of the child alone.
+//   - WithInstanceRefreshTimeout, WithRefreshTimeout, and
+//     WithColdStartBudget apply to the child's calls, e.g., to
+//     FetchConnectionInfo, but not to the refreshes of the shared cache.
+//   - WithDefaultDialOptions, as for ApplyOptions.
+//
+// All other Options are ignored. Closing or shutting down the Dialer closes
+// its children; closing a child does not affect the Dialer or the shared
+// cache.
+func (d *Dialer) Scope(opts ...Option) (*Dialer, error) {
+	cur := d.currentSettings()
+	cfg := &dialerConfig{
+		refreshTimeout:  cur.refreshTimeout,
+		coldStartBudget: cur.coldStartBudget,
+		maxConns:        atomic.LoadUint64(&d.maxConns),
+		rateLimit:       d.rateLimit,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+		if cfg.err != nil {
+			return nil, cfg.err
+		}
+	}
+	rateLimits := make(map[string]rateLimit, len(d.rateLimits)+len(cfg.rateLimits))
+	for k, l := range d.rateLimits {
+		rateLimits[k] = l
+	}
+	for k, l := range cfg.rateLimits {
+		rateLimits[k] = l
+	}
+	refreshTimeouts := make(map[string]time.Duration, len(d.refreshTimeouts)+len(cfg.refreshTimeouts))
+	for k, t := range d.refreshTimeouts {
+		refreshTimeouts[k] = t
+	}
+	for k, t := range cfg.refreshTimeouts {
+		refreshTimeouts[k] = t
+	}
+	s := &settings{
+		refreshTimeout:  cfg.refreshTimeout,
+		defaultDialCfg:  cur.defaultDialCfg,
+		coldStartBudget: cfg.coldStartBudget,
+		instances:       cur.instances,
+	}
+	for _, opt := range cfg.dialOpts {
+		opt(&s.defaultDialCfg)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return nil, ErrDialerClosed
+	}
+	id := uuid.New().String()
+	c := &Dialer{
+		maxConns:          cfg.maxConns,
+		primers:           make(map[string]*primer),
+		primedConns:       d.primedConns,
+		key:               d.key,
+		rand:              d.rand,
+		client:            d.client,
+		staticInfo:        d.staticInfo,
+		cache:             d.cache,
+		certCacheDir:      d.certCacheDir,
+		caPins:            d.caPins,
+		requireSAN:        d.requireSAN,
+		skipNameCheck:     d.skipNameCheck,
+		rateLimit:         cfg.rateLimit,
+		rateLimits:        rateLimits,
+		refreshTimeouts:   refreshTimeouts,
+		resolver:          d.resolver,
+		tenantOpts:        d.tenantOpts,
+		httpClient:        d.httpClient,
+		tenants:           make(map[oauth2.TokenSource]*tenant),
+		groups:            make(map[string][]string),
+		pools:             make(map[poolKey]*readPool),
+		dialerID:          id,
+		dialFunc:          d.dialFunc,
+		logger:            logging.WithDialerID(d.logger, id),
+		debugLogger:       d.debugLogger,
+		recorder:          d.recorder,
+		certLatency:       d.certLatency,
+		refreshGroup:      d.refreshGroup,
+		exec:              d.exec,
+		detectClusterRole: d.detectClusterRole,
+		preemptForced:     d.preemptForced,
+		serveStale:        d.serveStale,
+		maxStaleness:      d.maxStaleness,
+		certHook:          d.certHook,
+		tlsPatch:          d.tlsPatch,
+		connExpiryHandler: d.connExpiryHandler,
+		adminHeaders:      d.adminHeaders,
+		breakerThreshold:  d.breakerThreshold,
+		probeInterval:     d.probeInterval,
+		refreshStrategy:   d.refreshStrategy,
+		iamAuthN:          d.iamAuthN,
+		iamTokens:         d.iamTokens,
+		credsTokenSource:  d.credsTokenSource,
+		softFail:          d.softFail,
+		parent:            d,
+	}
+	c.settings.Store(s)
+	d.children = append(d.children, c)
+	return c, nil
+}
+
+// cacheOwner returns the Dialer that owns the cache of instances, i.e., the
+// Dialer itself unless it was created with Scope.
+func (d *Dialer) cacheOwner() *Dialer {
+	for d.parent != nil {
+		d = d.parent
+	}
+	return d
+}
+
+// cachedInstance returns the instance for instanceURI from the Dialer's
+// cache, without creating it.
+func (d *Dialer) cachedInstance(instanceURI string) (*alloydb.Instance, bool) {
+	o := d.cacheOwner()
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	return o.lookupInstance(instanceURI)
+}
+
+// cachedInstances returns the instances in the Dialer's cache.
+func (d *Dialer) cachedInstances() []*alloydb.Instance {
+	o := d.cacheOwner()
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	insts := make([]*alloydb.Instance, 0, len(o.instances))
+	for _, i := range o.instances {
+		insts = append(insts, i)
+	}
+	return insts
+}
+
+// forceRefresh forces a refresh of the instance after a failed dial. A
+// Dialer created with Scope skips the refresh once it has used up its own
+// rate limit for the instance, which the refresh in progress or the next one
+// covers.
+func (d *Dialer) forceRefresh(i *alloydb.Instance) {
+	if d.parent != nil && !d.allowRefresh(i.URI()) {
+		d.logger.Log(logging.Event{
+			Instance: i.String(),
+			Name:     logging.EventRefreshScheduled,
+			Message:  "forced refresh skipped, rate limit of the scope reached",
+		})
+		return
+	}
+	i.ForceRefresh()
+}
+
+// allowRefresh reports whether the Dialer's rate limit for the instance
+// allows it to force a refresh now.
+func (d *Dialer) allowRefresh(instanceURI string) bool {
+	key := strings.TrimPrefix(instanceURI, "/")
+	d.limiterMu.Lock()
+	defer d.limiterMu.Unlock()
+	l, ok := d.refreshLimiters[key]
+	if !ok {
+		lim, ok := d.instanceRateLimit(key)
+		if !ok {
+			lim = defaultRateLimit
+		}
+		l = rate.NewLimiter(rate.Inf, 0)
+		if lim.interval > 0 {
+			l = rate.NewLimiter(rate.Every(lim.interval), lim.burst)
+		}
+		if d.refreshLimiters == nil {
+			d.refreshLimiters = make(map[string]*rate.Limiter)
+		}
+		d.refreshLimiters[key] = l
+	}
+	return l.Allow()
+}
+
+// removeChild forgets c, a child of the Dialer that was closed.
+func (d *Dialer) removeChild(c *Dialer) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for n, child := range d.children {
+		if child == c {
+			d.children = append(d.children[:n], d.children[n+1:]...)
+			return
+		}
+	}
+}
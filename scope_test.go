@@ -0,0 +1,181 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestDialerScope(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// The child shares the Dialer's copy of the instance's connection info.
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRateLimiter(time.Hour, 1),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	child, err := d.Scope(WithMaxConnections(1), WithRateLimiter(0, 0))
+	if err != nil {
+		t.Fatalf("expected Scope to succeed, but got error: %v", err)
+	}
+	conn, err := child.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	// The child's limit does not apply to the parent.
+	_, err = child.Dial(ctx, testInstanceURI)
+	var wantErr *errtype.ConnectionLimitError
+	if !errors.As(err, &wantErr) {
+		t.Fatalf("want = %T, got = %v", wantErr, err)
+	}
+	pconn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	pconn.Close()
+
+	if got := d.Stats().OpenConnections; got != 0 {
+		t.Errorf("want parent OpenConnections = 0, got = %v", got)
+	}
+	if got := child.Stats().OpenConnections; got != 1 {
+		t.Errorf("want child OpenConnections = 1, got = %v", got)
+	}
+	if got := child.Stats().Instances[testInstanceURI].OpenConnections; got != 1 {
+		t.Errorf("want child instance OpenConnections = 1, got = %v", got)
+	}
+	if _, err := d.ConnectionInfo(testInstanceURI); err != nil {
+		t.Errorf("want the child's dial to fill the Dialer's cache, got error: %v", err)
+	}
+}
+
+func TestDialerScopeRateLimitsForcedRefreshes(t *testing.T) {
+	d, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithRateLimiter(0, 0),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	child, err := d.Scope(WithRateLimiter(time.Hour, 1))
+	if err != nil {
+		t.Fatalf("expected Scope to succeed, but got error: %v", err)
+	}
+	other, err := d.Scope()
+	if err != nil {
+		t.Fatalf("expected Scope to succeed, but got error: %v", err)
+	}
+
+	if !child.allowRefresh(testInstanceURI) {
+		t.Fatal("want the child's first forced refresh to be allowed")
+	}
+	if child.allowRefresh(testInstanceURI) {
+		t.Fatal("want the child's second forced refresh to be throttled")
+	}
+	// The child's limit does not apply to its siblings.
+	for n := 0; n < 3; n++ {
+		if !other.allowRefresh(testInstanceURI) {
+			t.Fatalf("want forced refresh %v of the other child to be allowed", n)
+		}
+	}
+}
+
+func TestDialerScopeClose(t *testing.T) {
+	d, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	var children []*Dialer
+	for n := 0; n < 3; n++ {
+		c, err := d.Scope()
+		if err != nil {
+			t.Fatalf("expected Scope to succeed, but got error: %v", err)
+		}
+		children = append(children, c)
+	}
+	if err := children[1].Close(); err != nil {
+		t.Fatalf("expected Close to succeed, but got error: %v", err)
+	}
+	d.lock.RLock()
+	got := append([]*Dialer(nil), d.children...)
+	d.lock.RUnlock()
+	if len(got) != 2 || got[0] != children[0] || got[1] != children[2] {
+		t.Fatalf("want the closed child to be removed, got %v children", len(got))
+	}
+
+	// Closing the Dialer closes and forgets the remaining children.
+	if err := d.Close(); err != nil {
+		t.Fatalf("expected Close to succeed, but got error: %v", err)
+	}
+	d.lock.RLock()
+	n := len(d.children)
+	d.lock.RUnlock()
+	if n != 0 {
+		t.Fatalf("want no children after Close, got = %v", n)
+	}
+}
+
+func TestDialerScopeErrors(t *testing.T) {
+	d, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	_, err = d.Scope(WithRateLimiter(-time.Second, 1))
+	var cErr *errtype.ConfigError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("expected Shutdown to succeed, but got error: %v", err)
+	}
+	if _, err := d.Scope(); !errors.Is(err, ErrDialerClosed) {
+		t.Fatalf("want = %v, got = %v", ErrDialerClosed, err)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// shared is the Dialer used by the package-level Dial and SharedDialer. It
+// is created on first use and closed once its last reference is released.
+var shared struct {
+	mu sync.Mutex
+	d  *Dialer
+	// refs is the number of unreleased SharedDialer calls plus the number
+	// of open connections returned by Dial.
+	refs int
+	// opts are passed to NewDialer when the shared Dialer is created.
+	opts []Option
+}
+
+// SetSharedDialerOptions sets the Options used to create the shared Dialer
+// behind Dial and SharedDialer. They take effect when the shared Dialer is
+// next created, so applications should call SetSharedDialerOptions during
+// initialization, before any framework integration dials.
+func SetSharedDialerOptions(opts ...Option) {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	shared.opts = append([]Option(nil), opts...)
+}
+
+// SharedDialer returns the Dialer shared by the package, creating it if
+// needed, so that integrations, e.g., ORM plugins, that each need a Dialer
+// share its cached connection info and refresh operations rather than
+// duplicate them. Callers must not close the returned Dialer; instead, they
+// call release once done with it. The shared Dialer is closed once every
+// caller has released it and every connection returned by Dial is closed.
+func SharedDialer(ctx context.Context) (d *Dialer, release func() error, err error) {
+	d, err = acquireShared(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var once sync.Once
+	release = func() error {
+		var err error
+		once.Do(func() { err = releaseShared(d) })
+		return err
+	}
+	return d, release, nil
+}
+
+// Dial connects to the instance with the shared Dialer; see SharedDialer.
+// The shared Dialer is created on first use and remains open while any
+// connection returned by Dial is open.
+func Dial(ctx context.Context, instance string, opts ...DialOption) (net.Conn, error) {
+	d, err := acquireShared(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.Dial(ctx, instance, opts...)
+	if err != nil {
+		_ = releaseShared(d)
+		return nil, err
+	}
+	release := func() { _ = releaseShared(d) }
+	// Chain onto the connection's close hook rather than wrap it, so that
+	// ConnValid continues to work.
+	if c, ok := conn.(*instrumentedConn); ok {
+		closeFunc := c.closeFunc
+		c.closeFunc = func() {
+			closeFunc()
+			release()
+		}
+		return c, nil
+	}
+	return newInstrumentedConn(conn, release), nil
+}
+
+// acquireShared returns the shared Dialer, creating it if needed, and adds a
+// reference to it.
+func acquireShared(ctx context.Context) (*Dialer, error) {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	if shared.d == nil {
+		d, err := NewDialer(ctx, shared.opts...)
+		if err != nil {
+			return nil, err
+		}
+		shared.d = d
+	}
+	shared.refs++
+	return shared.d, nil
+}
+
+// releaseShared removes a reference to d, closing it if it was the last one.
+func releaseShared(d *Dialer) error {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	if shared.d != d {
+		return nil
+	}
+	shared.refs--
+	if shared.refs > 0 {
+		return nil
+	}
+	shared.d = nil
+	return d.Close()
+}
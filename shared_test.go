@@ -0,0 +1,90 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/internal/mock"
+)
+
+// sharedState returns the shared Dialer and its reference count.
+func sharedState() (*Dialer, int) {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	return shared.d, shared.refs
+}
+
+func TestSharedDialer(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// A single refresh serves every user of the shared Dialer.
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	SetSharedDialerOptions(
+		WithTokenSource(stubTokenSource{}),
+		WithHTTPClient(mc),
+		WithAdminAPIEndpoint(url),
+	)
+	defer SetSharedDialerOptions()
+
+	d, release, err := SharedDialer(ctx)
+	if err != nil {
+		t.Fatalf("expected SharedDialer to succeed, but got error: %v", err)
+	}
+	var conns []net.Conn
+	for n := 0; n < 2; n++ {
+		conn, err := Dial(ctx, testInstanceURI)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	if got, refs := sharedState(); got != d || refs != 3 {
+		t.Fatalf("want the same Dialer with 3 references, got = %p (want %p) with %v", got, d, refs)
+	}
+	if !ConnValid(conns[0]) {
+		t.Fatal("want connection from Dial to be valid")
+	}
+
+	if err := release(); err != nil {
+		t.Fatalf("expected release to succeed, but got error: %v", err)
+	}
+	// Releasing twice has no effect.
+	if err := release(); err != nil {
+		t.Fatalf("expected release to succeed, but got error: %v", err)
+	}
+	conns[0].Close()
+	if got, refs := sharedState(); got != d || refs != 1 {
+		t.Fatalf("want the Dialer to stay open with 1 reference, got = %p with %v", got, refs)
+	}
+	conns[1].Close()
+	if got, refs := sharedState(); got != nil || refs != 0 {
+		t.Fatalf("want the Dialer closed after the last reference, got = %p with %v", got, refs)
+	}
+}
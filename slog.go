@@ -0,0 +1,38 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package alloydbconn
+
+import (
+	"log/slog"
+
+	"cloud.google.com/go/alloydbconn/internal/logging"
+)
+
+// WithSlogLogger returns an Option that enables debug logging to l. Each
+// record carries the structured attributes "event", "instance", "dialer_id",
+// "addr", "label", "duration", "refresh_expiry" (the expiration of the client
+// certificate), "error_code" (the AlloyDB Admin API error reason, if any), and
+// "error", where applicable, so that logs can be queried by field. Records of
+// failed operations are logged at slog.LevelWarn and all others at
+// slog.LevelDebug. WithSlogLogger replaces any logger configured with
+// WithDebugLogger or WithJSONDebugLogger.
+func WithSlogLogger(l *slog.Logger) Option {
+	return func(d *dialerConfig) {
+		d.logger = logging.NewSlogLogger(l)
+	}
+}
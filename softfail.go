@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+)
+
+// WithSoftFail returns an Option that configures the Dialer to report the
+// last connection info it retrieved for an instance when a dial fails because
+// the instance's connection info cannot be refreshed and none of it is still
+// valid. Such dials return a *StaleConnectionInfoError rather than the
+// refresh error alone, so that callers, e.g., an orchestration layer, can
+// decide how to fall back based on the instance's last known addresses and
+// certificate expiry. Dials to instances whose connection info has never been
+// retrieved return the refresh error as usual.
+func WithSoftFail() Option {
+	return func(d *dialerConfig) {
+		d.softFail = true
+	}
+}
+
+// StaleConnectionInfoError is returned by Dial, when the Dialer is configured
+// with WithSoftFail, if the instance's connection info could not be refreshed
+// and the cached connection info is no longer usable. It wraps the refresh
+// error, so errors.As and errors.Is see through it, e.g., to an
+// *errtype.RefreshError.
+type StaleConnectionInfoError struct {
+	// Instance is the instance URI, without a leading slash.
+	Instance string
+	// LastKnown is the most recent connection info retrieved for the
+	// instance. Its certificate has expired or was invalidated.
+	LastKnown CachedConnectionInfo
+	// Err is the error that prevented the connection info from being
+	// refreshed.
+	Err error
+}
+
+func (e *StaleConnectionInfoError) Error() string {
+	return fmt.Sprintf(
+		"%v (last known connection info for %v refreshed at %v, certificate expiry %v)",
+		e.Err, e.Instance, e.LastKnown.RefreshedAt.Format(time.RFC3339),
+		e.LastKnown.CertExpiry.Format(time.RFC3339),
+	)
+}
+
+// Unwrap returns the refresh error.
+func (e *StaleConnectionInfoError) Unwrap() error {
+	return e.Err
+}
+
+// softFailError returns err, the error retrieving the instance's connection
+// info, with the instance's last known connection info attached if soft-fail
+// mode is enabled and the instance has any.
+func (d *Dialer) softFailError(i *alloydb.Instance, err error) error {
+	if !d.softFail {
+		return err
+	}
+	c, ok := i.CachedInfo()
+	if !ok {
+		return err
+	}
+	return &StaleConnectionInfoError{
+		Instance:  strings.TrimPrefix(i.URI(), "/"),
+		LastKnown: cachedConnectionInfo(c),
+		Err:       err,
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestDialerWithSoftFail(t *testing.T) {
+	tcs := []struct {
+		desc     string
+		softFail bool
+	}{
+		{desc: "soft-fail enabled", softFail: true},
+		{desc: "soft-fail disabled"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			inst := mock.NewFakeInstance(
+				"my-project", "my-region", "my-cluster", "my-instance",
+			)
+			// Only the first refresh succeeds.
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				mock.CreateEphemeralSuccess(inst, 1),
+			)
+			stop := mock.StartServerProxy(t, inst)
+			defer func() {
+				stop()
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			opts := []Option{WithTokenSource(stubTokenSource{})}
+			if tc.softFail {
+				opts = append(opts, WithSoftFail())
+			}
+			d, err := NewDialer(ctx, opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
+
+			conn, err := d.Dial(ctx, testInstanceURI)
+			if err != nil {
+				t.Fatalf("expected Dial to succeed, but got error: %v", err)
+			}
+			conn.Close()
+			if err := d.ForceRefresh(testInstanceURI); err != nil {
+				t.Fatalf("expected ForceRefresh to succeed, but got error: %v", err)
+			}
+
+			_, err = d.Dial(ctx, testInstanceURI)
+			var rErr *errtype.RefreshError
+			if !errors.As(err, &rErr) {
+				t.Fatalf("want = %T, got = %v", rErr, err)
+			}
+			var sErr *StaleConnectionInfoError
+			if got := errors.As(err, &sErr); got != tc.softFail {
+				t.Fatalf("want StaleConnectionInfoError = %v, got error = %v", tc.softFail, err)
+			}
+			if !tc.softFail {
+				return
+			}
+			if sErr.Instance != testInstanceURI {
+				t.Errorf("want instance = %v, got = %v", testInstanceURI, sErr.Instance)
+			}
+			want := []InstanceAddress{{Type: "PRIVATE", Addr: "127.0.0.1"}}
+			if got := sErr.LastKnown.Addresses; len(got) != 1 || got[0] != want[0] {
+				t.Errorf("want last known addresses = %v, got = %v", want, got)
+			}
+			if sErr.LastKnown.CertExpiry.IsZero() || sErr.LastKnown.RefreshedAt.IsZero() {
+				t.Errorf("want last known cert expiry and refresh time, got = %+v", sErr.LastKnown)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+// WithCredentialsTokenSource returns a DialOption that uses the credentials
+// of ts, rather than the Dialer's, to retrieve the information needed to
+// connect, e.g., so that a multi-tenant service can connect to each tenant's
+// instances with that tenant's credentials. The connection info and client
+// certificate of an instance are cached separately for each token source, so
+// callers should reuse a token source across dials rather than create one per
+// dial. The token source is used as a map key and so must be comparable,
+// e.g., a pointer. If a client was set with WithHTTPClient, its transport
+// is used to send the authorized requests.
+//
+// Primed connections and the connection info exported by ExportCache,
+// imported with WithImportedCache, or supplied by WithConnectionInfoCache
+// only apply to dials that use the Dialer's credentials. The option has no
+// effect on Dialers configured with WithStaticConnectionInfo.
+func WithCredentialsTokenSource(ts oauth2.TokenSource) DialOption {
+	return func(cfg *dialCfg) {
+		cfg.tokenSource = ts
+	}
+}
+
+// tenant holds the Admin API client and cached instances for a token source
+// set with WithCredentialsTokenSource.
+type tenant struct {
+	client    *alloydbadmin.Client
+	instances map[instanceKey]*alloydb.Instance
+	// group deduplicates concurrent refresh operations of the tenant's
+	// instances.
+	group *alloydb.RefreshGroup
+}
+
+// close stops the refresh cycles of the tenant's instances.
+func (t *tenant) close() {
+	for _, i := range t.instances {
+		i.Close()
+	}
+}
+
+// newTenant creates the Admin API client for ts.
+func (d *Dialer) newTenant(ts oauth2.TokenSource) (*tenant, error) {
+	ts = credentialsTokenSource{ts: ts}
+	opts := append([]option.ClientOption{}, d.tenantOpts...)
+	if d.httpClient != nil {
+		hc := *d.httpClient
+		hc.Transport = &oauth2.Transport{
+			Source: oauth2.ReuseTokenSource(nil, ts),
+			Base:   d.httpClient.Transport,
+		}
+		opts = append(opts, option.WithHTTPClient(&hc))
+	} else {
+		opts = append(opts, option.WithTokenSource(ts))
+	}
+	// The client outlives any one dial, so it is not bound to the dial's
+	// context.
+	c, err := alloydbadmin.NewClient(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AlloyDB Admin API client: %v", err)
+	}
+	return &tenant{
+		client:    c,
+		instances: make(map[instanceKey]*alloydb.Instance),
+		group:     alloydb.NewRefreshGroup(),
+	}, nil
+}
+
+// tenantInstance returns the instance for instanceURI that uses the
+// credentials of ts, creating it and the client for ts if needed.
+func (d *Dialer) tenantInstance(instanceURI string, ts oauth2.TokenSource) (*alloydb.Instance, error) {
+	if err := checkTokenSource(ts, instanceURI); err != nil {
+		return nil, err
+	}
+	k, ok := parseInstanceKey(instanceURI)
+	if !ok {
+		return nil, invalidInstanceURIError(instanceURI)
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	t, err := d.tenantLocked(ts)
+	if err != nil {
+		return nil, err
+	}
+	i, ok := t.instances[k]
+	if !ok {
+		i, err = alloydb.NewInstance(
+			instanceURI, t.client, d.key, d.refreshTimeout(instanceURI), d.dialerID,
+			append(d.instanceOpts(instanceURI), alloydb.WithRefreshGroup(t.group))...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		t.instances[k] = i
+	}
+	return i, nil
+}
+
+// tenantClient returns the Admin API client for ts, creating it if needed.
+// name identifies what the client is for in errors.
+func (d *Dialer) tenantClient(ts oauth2.TokenSource, name string) (*alloydbadmin.Client, error) {
+	if err := checkTokenSource(ts, name); err != nil {
+		return nil, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	t, err := d.tenantLocked(ts)
+	if err != nil {
+		return nil, err
+	}
+	return t.client, nil
+}
+
+// tenantLocked returns the tenant for ts, creating it if needed. Callers must
+// hold lock.
+func (d *Dialer) tenantLocked(ts oauth2.TokenSource) (*tenant, error) {
+	if d.closed {
+		return nil, ErrDialerClosed
+	}
+	t, ok := d.tenants[ts]
+	if !ok {
+		var err error
+		if t, err = d.newTenant(ts); err != nil {
+			return nil, err
+		}
+		d.tenants[ts] = t
+	}
+	return t, nil
+}
+
+// checkTokenSource reports an error if ts cannot be used as a map key.
+func checkTokenSource(ts oauth2.TokenSource, name string) error {
+	if !reflect.TypeOf(ts).Comparable() {
+		return errtype.NewConfigError(
+			fmt.Sprintf("token source of type %T is not comparable", ts), name,
+		)
+	}
+	return nil
+}
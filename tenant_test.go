@@ -0,0 +1,124 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"golang.org/x/oauth2"
+)
+
+// tenantTokenSource returns a fixed access token.
+type tenantTokenSource struct {
+	token string
+}
+
+func (s *tenantTokenSource) Token() (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: s.token}, nil
+}
+
+// authRecorder records the Authorization header of each request.
+type authRecorder struct {
+	base http.RoundTripper
+
+	mu    sync.Mutex
+	auths map[string]int
+}
+
+func (a *authRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	a.mu.Lock()
+	a.auths[req.Header.Get("Authorization")]++
+	a.mu.Unlock()
+	return a.base.RoundTrip(req)
+}
+
+func TestDialerWithCredentialsTokenSource(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// Each token source retrieves its own connection info.
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	rec := &authRecorder{base: mc.Transport, auths: make(map[string]int)}
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithHTTPClient(&http.Client{Transport: rec}),
+		WithAdminAPIEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	tenantA := &tenantTokenSource{token: "a"}
+	tenantB := &tenantTokenSource{token: "b"}
+	for _, ts := range []oauth2.TokenSource{tenantA, tenantB, tenantA} {
+		conn, err := d.Dial(ctx, testInstanceURI, WithCredentialsTokenSource(ts))
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		conn.Close()
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for _, auth := range []string{"Bearer a", "Bearer b"} {
+		if got := rec.auths[auth]; got != 2 {
+			t.Errorf("want 2 requests with %q, got = %v", auth, got)
+		}
+	}
+	if got := len(rec.auths); got != 2 {
+		t.Errorf("want requests with 2 credentials, got = %v", rec.auths)
+	}
+}
+
+// funcTokenSource is a token source that cannot be used as a map key.
+type funcTokenSource func() (*oauth2.Token, error)
+
+func (f funcTokenSource) Token() (*oauth2.Token, error) {
+	return f()
+}
+
+func TestWithCredentialsTokenSourceErrors(t *testing.T) {
+	d, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	ts := funcTokenSource(func() (*oauth2.Token, error) { return nil, nil })
+	_, err = d.Dial(context.Background(), testInstanceURI, WithCredentialsTokenSource(ts))
+	var cErr *errtype.ConfigError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+}
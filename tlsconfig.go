@@ -0,0 +1,56 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import "crypto/tls"
+
+// WithTLSConfigPatch returns an Option that calls patch with a copy of the
+// TLS configuration of each connection Dial establishes, before the TLS
+// handshake, e.g., to enforce an organization's curve preferences, set a
+// ClientSessionCache, or set a KeyLogWriter to debug handshakes with tools
+// such as Wireshark. A KeyLogWriter exposes the connection's traffic to anyone
+// who can read it and must never be set in production.
+//
+// The patch cannot weaken how the Dialer authenticates the instance or
+// itself: after patch returns, the certificates, root CAs, certificate
+// verification, and minimum TLS version set by the Dialer are restored. As
+// connections use TLS 1.3, CipherSuites has no effect. The patch does not
+// apply to the TLS configuration returned by ConnectionInfo or
+// FetchConnectionInfo.
+func WithTLSConfigPatch(patch func(*tls.Config)) Option {
+	return func(d *dialerConfig) {
+		d.tlsPatch = patch
+	}
+}
+
+// patchTLSConfig returns cfg as modified by the patch set with
+// WithTLSConfigPatch, or cfg itself if there is none. cfg is shared by all
+// connections to the instance and so is never modified.
+func (d *Dialer) patchTLSConfig(cfg *tls.Config) *tls.Config {
+	if d.tlsPatch == nil {
+		return cfg
+	}
+	c := cfg.Clone()
+	d.tlsPatch(c)
+	c.Certificates = cfg.Certificates
+	c.GetClientCertificate = cfg.GetClientCertificate
+	c.RootCAs = cfg.RootCAs
+	c.InsecureSkipVerify = cfg.InsecureSkipVerify
+	c.VerifyPeerCertificate = cfg.VerifyPeerCertificate
+	if c.MinVersion < cfg.MinVersion {
+		c.MinVersion = cfg.MinVersion
+	}
+	return c
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestDialerWithTLSConfigPatch(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	var keyLog bytes.Buffer
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithTLSConfigPatch(func(cfg *tls.Config) {
+			cfg.KeyLogWriter = &keyLog
+			// The Dialer's verification is restored.
+			cfg.MinVersion = tls.VersionTLS10
+			cfg.RootCAs = x509.NewCertPool()
+			cfg.InsecureSkipVerify = false
+			cfg.VerifyPeerCertificate = func([][]byte, [][]*x509.Certificate) error {
+				return errors.New("rejected by patch")
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+	if keyLog.Len() == 0 {
+		t.Fatal("want the handshake's secrets written to the key log, got none")
+	}
+
+	// The TLS configuration shared by the instance's connections is
+	// unmodified.
+	i, err := d.instance(testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected instance to succeed, but got error: %v", err)
+	}
+	_, cfg, err := i.ConnectInfo(ctx, "")
+	if err != nil {
+		t.Fatalf("expected ConnectInfo to succeed, but got error: %v", err)
+	}
+	if cfg.KeyLogWriter != nil {
+		t.Fatal("want the cached TLS configuration unmodified, got a KeyLogWriter")
+	}
+}
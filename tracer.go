@@ -0,0 +1,82 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/internal/trace"
+)
+
+// Values of the trigger passed to Tracer.RecordRefreshResult.
+const (
+	// RefreshTriggerScheduled is a refresh started ahead of certificate
+	// expiration.
+	RefreshTriggerScheduled = trace.RefreshTriggerScheduled
+	// RefreshTriggerForced is a refresh started out of cycle, e.g., after a
+	// failed dial or a call to ForceRefresh.
+	RefreshTriggerForced = trace.RefreshTriggerForced
+	// RefreshTriggerDialDemand is a refresh started because a dial needed
+	// connection info and none was cached.
+	RefreshTriggerDialDemand = trace.RefreshTriggerDialDemand
+	// RefreshTriggerFailover is a refresh started because the role of the
+	// instance's cluster changed.
+	RefreshTriggerFailover = trace.RefreshTriggerFailover
+)
+
+// Tracer reports the Dialer's spans, failed dials, and refresh results to a
+// tracing system. By default, the Dialer reports to OpenCensus; use
+// WithTracer to report to another system. Methods may be called concurrently.
+// Dial errors and refresh results are reported from a single background
+// worker, so a slow Tracer delays only other measurements, never dials.
+type Tracer interface {
+	// StartSpan begins a span with the provided name and attributes, e.g.,
+	// "/alloydb/instance" with the instance URI, and returns a context that
+	// carries the span and a function that ends it. The function is passed
+	// the error of the traced operation, or nil if it succeeded.
+	StartSpan(ctx context.Context, name string, attrs map[string]string) (context.Context, func(error))
+	// RecordDialError reports a failed call to Dial. err is not nil.
+	RecordDialError(ctx context.Context, instance, dialerID string, err error)
+	// RecordRefreshResult reports the result of a refresh operation, with
+	// err nil if it succeeded, and what triggered it, one of the
+	// RefreshTrigger constants.
+	RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error)
+}
+
+// MetricsRecorder receives the Dialer's metrics, e.g., to report them to a
+// metrics system the Dialer does not support itself; the promexporter module
+// implements it for Prometheus. Use WithMetricsRecorder to add one. Methods
+// may be called concurrently. Like a Tracer's, measurements are reported from
+// a single background worker, so a slow MetricsRecorder never delays dials.
+type MetricsRecorder interface {
+	// RecordDialLatency reports the latency of a successful call to Dial.
+	RecordDialLatency(ctx context.Context, instance, dialerID string, latency time.Duration)
+	// RecordOpenConnections reports the number of connections the Dialer
+	// has open to the instance.
+	RecordOpenConnections(ctx context.Context, instance, dialerID string, num int64)
+	// RecordDialError reports a failed call to Dial. err is not nil.
+	RecordDialError(ctx context.Context, instance, dialerID string, err error)
+	// RecordRefreshResult reports the result of a refresh operation, with
+	// err nil if it succeeded, and what triggered it, one of the
+	// RefreshTrigger constants.
+	RecordRefreshResult(ctx context.Context, instance, dialerID, trigger string, err error)
+	// RecordDialQueueDepth reports the number of dials waiting on a refresh
+	// operation of the instance.
+	RecordDialQueueDepth(ctx context.Context, instance, dialerID string, depth int64)
+	// RecordCertIssuanceLatency reports the latency of a successful call to
+	// the AlloyDB Admin API to issue a client certificate.
+	RecordCertIssuanceLatency(ctx context.Context, instance, dialerID string, latency time.Duration)
+}
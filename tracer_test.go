@@ -0,0 +1,137 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+type fakeTracer struct {
+	mu         sync.Mutex
+	spans      map[string]map[string]string
+	ended      map[string]error
+	dialErrs   []error
+	refreshErr []error
+	triggers   []string
+}
+
+func newFakeTracer() *fakeTracer {
+	return &fakeTracer{
+		spans: make(map[string]map[string]string),
+		ended: make(map[string]error),
+	}
+}
+
+func (f *fakeTracer) StartSpan(ctx context.Context, name string, attrs map[string]string) (context.Context, func(error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.spans[name] = attrs
+	return ctx, func(err error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.ended[name] = err
+	}
+}
+
+func (f *fakeTracer) RecordDialError(_ context.Context, _, _ string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dialErrs = append(f.dialErrs, err)
+}
+
+func (f *fakeTracer) RecordRefreshResult(_ context.Context, _, _, trigger string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refreshErr = append(f.refreshErr, err)
+	f.triggers = append(f.triggers, trigger)
+}
+
+func TestDialerWithTracer(t *testing.T) {
+	ctx := context.Background()
+	// The API rejects every request, so the refresh and the dial fail.
+	mc, url, cleanup := mock.HTTPClient()
+	defer func() { _ = cleanup() }()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	tr := newFakeTracer()
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithTracer(tr))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if _, err := d.Dial(ctx, testInstanceURI); err == nil {
+		t.Fatal("want Dial to fail, got no error")
+	}
+
+	// Dial errors and refresh results are reported in the background.
+	for i := 0; i < 100; i++ {
+		tr.mu.Lock()
+		n, m := len(tr.dialErrs), len(tr.refreshErr)
+		tr.mu.Unlock()
+		if n > 0 && m > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	const dialSpan = "cloud.google.com/go/alloydbconn.Dial"
+	attrs, ok := tr.spans[dialSpan]
+	if !ok {
+		t.Fatalf("want span %v, got = %v", dialSpan, tr.spans)
+	}
+	if got := attrs["/alloydb/instance"]; got != testInstanceURI {
+		t.Errorf("want instance attribute = %v, got = %v", testInstanceURI, got)
+	}
+	if tr.ended[dialSpan] == nil {
+		t.Errorf("want span %v to end with an error", dialSpan)
+	}
+	if len(tr.dialErrs) != 1 || tr.dialErrs[0] == nil {
+		t.Errorf("want 1 dial error, got = %v", tr.dialErrs)
+	}
+	if len(tr.refreshErr) == 0 {
+		t.Fatal("want a refresh result, got none")
+	}
+	var rErr *errtype.RefreshError
+	if !errors.As(tr.refreshErr[0], &rErr) {
+		t.Errorf("want refresh error = %T, got = %v", rErr, tr.refreshErr[0])
+	}
+	if tr.triggers[0] != RefreshTriggerDialDemand {
+		t.Errorf("want trigger = %v, got = %v", RefreshTriggerDialDemand, tr.triggers[0])
+	}
+}
+
+func TestWithTracerRejectsNil(t *testing.T) {
+	_, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}), WithTracer(nil))
+	var cErr *errtype.ConfigError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// connProbeWindow is how long ValidateConn waits for the instance to report
+// that it closed a connection.
+const connProbeWindow = time.Millisecond
+
+// ErrConnInvalid is returned by ValidateConn for a connection that is no
+// longer usable.
+var ErrConnInvalid = errors.New("alloydbconn: connection is no longer usable")
+
+// ValidateConn checks that conn, as returned by Dial, is still usable before
+// it is reused, e.g., when a pool hands out an idle connection. In addition
+// to the checks of ConnValid, it probes the connection for a close by the
+// instance, e.g., when the instance restarted for maintenance, without
+// waiting for a query to fail. The probe sends nothing and takes about a
+// millisecond; data the instance sent in the meantime is kept for the next
+// Read.
+//
+// ValidateConn returns an error wrapping ErrConnInvalid if the connection is
+// not usable, in which case the caller should close it. It must not be
+// called concurrently with Read and clears the connection's read deadline.
+// For connections not returned by Dial, it only checks ctx.
+func ValidateConn(ctx context.Context, conn net.Conn) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c, ok := conn.(*instrumentedConn)
+	if !ok {
+		return nil
+	}
+	if c.valid != nil && !c.valid() {
+		return fmt.Errorf("%w: client certificate expired or cluster role changed", ErrConnInvalid)
+	}
+	return c.probe()
+}
+
+// probe reads from the connection until the probe window passes, buffering
+// any data for later reads. It reports an error if the read fails for any
+// reason other than the deadline, e.g., because the instance closed the
+// connection.
+func (i *instrumentedConn) probe() error {
+	if err := i.Conn.SetReadDeadline(time.Now().Add(connProbeWindow)); err != nil {
+		return fmt.Errorf("%w: %v", ErrConnInvalid, err)
+	}
+	defer i.Conn.SetReadDeadline(time.Time{})
+	buf := make([]byte, 512)
+	for {
+		n, err := i.Conn.Read(buf)
+		i.pending = append(i.pending, buf[:n]...)
+		if err == nil {
+			continue
+		}
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return nil
+		}
+		return fmt.Errorf("%w: %v", ErrConnInvalid, err)
+	}
+}
+
+// Read returns the data buffered by ValidateConn, if any, before reading from
+// the underlying connection.
+func (i *instrumentedConn) Read(b []byte) (int, error) {
+	if len(i.pending) > 0 {
+		n := copy(b, i.pending)
+		i.pending = i.pending[n:]
+		return n, nil
+	}
+	return i.Conn.Read(b)
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/alloydbadmin"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestValidateConn(t *testing.T) {
+	ctx := context.Background()
+	client, server := net.Pipe()
+	conn := newInstrumentedConn(client, func() {})
+	defer conn.Close()
+
+	if err := ValidateConn(ctx, conn); err != nil {
+		t.Fatalf("want idle connection to be valid, got = %v", err)
+	}
+
+	// Data sent by the instance while probing is kept for the next read.
+	go server.Write([]byte("notice"))
+	for i := 0; i < 100 && len(conn.pending) == 0; i++ {
+		if err := ValidateConn(ctx, conn); err != nil {
+			t.Fatalf("want connection with pending data to be valid, got = %v", err)
+		}
+	}
+	buf := make([]byte, len("notice"))
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "notice" {
+		t.Fatalf("want to read notice, got = %q, %v", buf, err)
+	}
+
+	server.Close()
+	if err := ValidateConn(ctx, conn); !errors.Is(err, ErrConnInvalid) {
+		t.Fatalf("want = %v, got = %v", ErrConnInvalid, err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := ValidateConn(canceled, conn); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want = %v, got = %v", context.Canceled, err)
+	}
+}
+
+func TestValidateConnClosedByInstance(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	// The fake instance writes its name and closes each connection.
+	var verr error
+	for i := 0; i < 100 && verr == nil; i++ {
+		verr = ValidateConn(ctx, conn)
+	}
+	if !errors.Is(verr, ErrConnInvalid) {
+		t.Fatalf("want = %v, got = %v", ErrConnInvalid, verr)
+	}
+}